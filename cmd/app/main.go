@@ -2,19 +2,29 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
+	// The runtime image has no zoneinfo, and SLA calendars need time zones.
+	_ "time/tzdata"
 
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/xddprog/avito-test-task/internal/config"
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/event"
 	"github.com/xddprog/avito-test-task/internal/handler"
 	"github.com/xddprog/avito-test-task/internal/logger"
+	"github.com/xddprog/avito-test-task/internal/metrics"
 	"github.com/xddprog/avito-test-task/internal/middleware"
-	"github.com/xddprog/avito-test-task/internal/repository"
+	"github.com/xddprog/avito-test-task/internal/notify"
 	"github.com/xddprog/avito-test-task/internal/service"
-	db "github.com/xddprog/avito-test-task/pkg/db/migration"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+	"github.com/xddprog/avito-test-task/internal/worker"
 )
 
 func main() {
@@ -26,50 +36,179 @@ func main() {
 
 	logger.InitLogger(cfg.Log.Level, cfg.Log.Format)
 
-	if err := db.RunMigrations("file://migrations", cfg.Postgres.DSN()); err != nil {
-		slog.Error("migration failed", "error", err)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			slog.Error("migrate failed", "error", err)
+			if errors.Is(err, errMigrateUsage) {
+				fmt.Fprintln(os.Stderr, migrateUsage)
+			}
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := run(cfg); err != nil {
+		slog.Error("service stopped with error", "error", err)
 		os.Exit(1)
 	}
+}
+
+func run(cfg *config.Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	config, err := pgxpool.ParseConfig(cfg.Postgres.DSN())
+	shutdownTracing, err := tracing.Init(ctx, tracing.Options{
+		Exporter:    cfg.Tracing.Exporter,
+		FilePath:    cfg.Tracing.FilePath,
+		Endpoint:    cfg.Tracing.OTLPEndpoint,
+		Insecure:    cfg.Tracing.OTLPInsecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+		ServiceName: cfg.Tracing.ServiceName,
+	})
 	if err != nil {
-		slog.Error("failed to parse db config", "error", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to init tracing: %w", err)
 	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("failed to flush traces", "error", err)
+		}
+	}()
 
-	db, err := pgxpool.NewWithConfig(context.Background(), config)
+	store, err := openStorage(ctx, cfg)
 	if err != nil {
-		slog.Error("failed to create db connection", "error", err)
-		os.Exit(1)
+		return err
 	}
-	defer db.Close()
+	defer store.close()
 
 	workDir, err := os.Getwd()
 	if err != nil {
-		slog.Error("failed to determine working directory", "error", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to determine working directory: %w", err)
 	}
 
-	userRepository := repository.NewUserRepository(db)
-	pullRequestRepository := repository.NewPullRequestRepository(db)
-	teamRepository := repository.NewTeamRepository(db)
-	statsRepository := repository.NewStatsRepository(db)
+	events := event.NewBus()
+	events.Subscribe(event.TypeFairnessImbalance, func(_ context.Context, e event.Event) {
+		if team, ok := e.Payload.(entity.TeamFairness); ok {
+			slog.Warn("review load imbalance detected",
+				"team", team.TeamName,
+				"gini", team.Gini,
+				"min_assignments", team.MinAssignments,
+				"max_assignments", team.MaxAssignments,
+			)
+		}
+	})
+
+	events.Subscribe(event.TypeReviewReminder, func(_ context.Context, e event.Event) {
+		if review, ok := e.Payload.(entity.StaleReview); ok {
+			slog.Info("review reminder",
+				"pull_request_id", review.PullRequestID,
+				"reviewer_id", review.ReviewerID,
+				"waiting_days", review.Waiting.Days,
+			)
+		}
+	})
+	events.Subscribe(event.TypeReviewEscalated, func(_ context.Context, e event.Event) {
+		if review, ok := e.Payload.(entity.StaleReview); ok {
+			slog.Warn("stale review escalated",
+				"pull_request_id", review.PullRequestID,
+				"reviewer_id", review.ReviewerID,
+				"action", review.Action,
+				"new_reviewer_id", review.NewReviewerID,
+				"lead_id", review.LeadID,
+			)
+		}
+	})
 
-	userService := service.NewUserService(userRepository)
-	pullRequestService := service.NewPullRequestService(pullRequestRepository, userRepository)
-	teamService := service.NewTeamService(teamRepository, pullRequestRepository, pullRequestService, userRepository)
-	statsService := service.NewStatsService(statsRepository)
+	appMetrics := metrics.New()
+	appMetrics.MustRegister(store.collectors...)
+	appMetrics.MustRegister(metrics.NewDomainCollector(store.stats))
+	appMetrics.Subscribe(events)
+
+	userService := service.NewUserService(store.unitOfWork, store.users)
+	slaOpts, err := slaOptions(cfg.SLA)
+	if err != nil {
+		return err
+	}
+	pullRequestService := service.NewPullRequestService(store.unitOfWork, store.users, events, slaOpts)
+	teamService := service.NewTeamService(store.unitOfWork, store.teams, pullRequestService, events)
+	statsService := service.NewStatsService(store.stats, store.audit, events, service.FairnessOptions{
+		GiniThreshold: cfg.Fairness.GiniThreshold,
+		Window:        cfg.Fairness.Window,
+		CheckInterval: cfg.Fairness.CheckInterval,
+	})
+	idempotencyService := service.NewIdempotencyService(store.idempotency, cfg.Idempotency.TTL, cfg.Idempotency.Lease)
+	backupService := service.NewBackupService(store.unitOfWork, store.backup)
+	auditService := service.NewAuditService(store.audit)
+
+	notificationOpts, err := notificationOptions(cfg.Notify)
+	if err != nil {
+		return err
+	}
+	notificationService := service.NewNotificationService(store.notifications, store.users, store.pullRequests, notificationOpts)
+
+	workers := worker.NewGroup()
+	workers.Go("idempotency-sweeper", idempotencyService.Sweep)
+	if cfg.Fairness.CheckInterval > 0 {
+		workers.Go("fairness-checker", statsService.RunFairnessChecks)
+	}
+	if cfg.StaleReview.Enabled {
+		opts, err := staleReviewOptions(cfg.StaleReview)
+		if err != nil {
+			return err
+		}
+		staleReviewService := service.NewStaleReviewService(store.pullRequests, store.audit, pullRequestService, events, opts)
+		workers.Go("stale-review-scheduler", staleReviewService.Run)
+	}
+	if cfg.Notify.Enabled {
+		notificationService.Subscribe(events)
+		workers.Go("notification-dispatcher", notificationService.Run)
+	}
 
 	userHandler := handler.NewUserHandler(userService)
+	notificationHandler := handler.NewNotificationHandler(notificationService)
 	pullRequestHandler := handler.NewPullRequestHandler(pullRequestService)
 	teamHandler := handler.NewTeamHandler(teamService)
 	statsHandler := handler.NewStatsHandler(statsService)
-	healthHandler := handler.NewHealthHandler()
+	healthHandler := handler.NewHealthHandler(store.checks)
+	adminHandler := handler.NewAdminHandler(backupService, auditService, notificationService, cfg.Admin.Token, cfg.Admin.MaxImportBytes)
+	if adminHandler == nil {
+		slog.Info("admin API is disabled, set ADMIN_TOKEN to enable it")
+	}
 
 	openAPISpecPath := filepath.Join(workDir, "api", "openapi.yml")
-	mux := handler.NewRouter(userHandler, teamHandler, pullRequestHandler, statsHandler, healthHandler, openAPISpecPath)
+	mux := handler.NewRouter(userHandler, notificationHandler, teamHandler, pullRequestHandler, statsHandler, healthHandler, adminHandler, appMetrics.Handler(), openAPISpecPath)
+
+	rateLimiter, err := middleware.NewRateLimiter(cfg.RateLimit, func(r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		return pattern
+	})
+	if err != nil {
+		return fmt.Errorf("invalid rate limit config: %w", err)
+	}
+	workers.Go("rate-limit-sweeper", rateLimiter.Sweep)
 
-	handlerWithLogging := middleware.LoggingMiddleware(mux)
+	requestValidator, err := middleware.NewOpenAPIValidator(openAPISpecPath)
+	if err != nil {
+		return err
+	}
+
+	handlerWithLogging := middleware.RequestIDMiddleware(
+		middleware.LoggingMiddleware(
+			middleware.TracingMiddleware(
+				middleware.MetricsMiddleware(appMetrics,
+					rateLimiter.Middleware(
+						middleware.BodyLimits{
+							Default: cfg.HTTP.MaxBodyBytes,
+							Routes:  map[string]int64{"/admin/import": cfg.Admin.MaxImportBytes},
+						}.Middleware(
+							requestValidator.Middleware(
+								middleware.IdempotencyMiddleware(idempotencyService, middleware.ClientIdentity(cfg.RateLimit), mux),
+							),
+						),
+					),
+				),
+			),
+		),
+	)
 
 	srv := &http.Server{
 		Addr:         cfg.HTTP.Address(),
@@ -78,9 +217,114 @@ func main() {
 		WriteTimeout: cfg.HTTP.WriteTimeout,
 	}
 
-	slog.Info("starting HTTP server", "address", cfg.HTTP.Address())
-	if err := srv.ListenAndServe(); err != nil {
-		slog.Error("server stopped", "error", err)
-		os.Exit(1)
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("starting HTTP server", "address", cfg.HTTP.Address())
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	select {
+	case err := <-serverErr:
+		_ = workers.Stop(context.Background())
+		return fmt.Errorf("server stopped: %w", err)
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutdown signal received, draining", "delay", cfg.HTTP.DrainDelay, "timeout", cfg.HTTP.ShutdownTimeout)
+	healthHandler.StartDraining()
+	time.Sleep(cfg.HTTP.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("failed to drain HTTP server", "error", err)
+	}
+	if err := workers.Stop(shutdownCtx); err != nil {
+		slog.Error("background workers did not stop in time", "error", err)
+	}
+
+	slog.Info("shutdown complete")
+	return nil
+}
+
+func staleReviewOptions(cfg config.StaleReviewConfig) (service.StaleReviewOptions, error) {
+	policy := func(p config.StaleReviewPolicy) service.StaleReviewPolicy {
+		return service.StaleReviewPolicy{
+			RemindAfter:   p.RemindAfter,
+			EscalateAfter: p.EscalateAfter,
+			Action:        entity.StaleAction(p.Action),
+			LeadID:        p.Lead,
+		}
+	}
+
+	defaults, err := cfg.Default()
+	if err != nil {
+		return service.StaleReviewOptions{}, err
+	}
+	teams, err := cfg.TeamPolicies()
+	if err != nil {
+		return service.StaleReviewOptions{}, err
+	}
+
+	opts := service.StaleReviewOptions{
+		Interval: cfg.Interval,
+		Default:  policy(defaults),
+		Teams:    make(map[string]service.StaleReviewPolicy, len(teams)),
+	}
+	for team, p := range teams {
+		opts.Teams[team] = policy(p)
+	}
+	return opts, nil
+}
+
+func slaOptions(cfg config.SLAConfig) (service.SLAOptions, error) {
+	defaults, err := cfg.Default()
+	if err != nil {
+		return service.SLAOptions{}, err
+	}
+	teams, err := cfg.TeamPolicies()
+	if err != nil {
+		return service.SLAOptions{}, err
+	}
+
+	opts := service.SLAOptions{
+		Default: service.SLAPolicy(defaults),
+		Teams:   make(map[string]service.SLAPolicy, len(teams)),
+	}
+	for team, p := range teams {
+		opts.Teams[team] = service.SLAPolicy(p)
+	}
+	return opts, nil
+}
+
+func notificationOptions(cfg config.NotifyConfig) (service.NotificationOptions, error) {
+	templates, err := notify.LoadTemplates(cfg.TemplatesDir)
+	if err != nil {
+		return service.NotificationOptions{}, err
+	}
+
+	opts := service.NotificationOptions{
+		Channels: map[entity.NotificationChannel]notify.Channel{
+			entity.ChannelLog: notify.NewLogChannel(slog.Default()),
+		},
+		Templates:    templates,
+		Interval:     cfg.Interval,
+		MaxAttempts:  cfg.MaxAttempts,
+		RetryBackoff: cfg.RetryBackoff,
+		Lease:        cfg.Lease,
+	}
+	if cfg.SMTP.Host != "" {
+		opts.Channels[entity.ChannelEmail] = notify.NewSMTPChannel(notify.SMTPConfig(cfg.SMTP))
+	}
+	if cfg.Chat.WebhookURL != "" {
+		opts.Channels[entity.ChannelChat] = notify.NewWebhookChannel(cfg.Chat.WebhookURL, cfg.Chat.Timeout)
+	}
+	for _, c := range cfg.Defaults() {
+		opts.DefaultChannels = append(opts.DefaultChannels, entity.NotificationChannel(c))
 	}
+	return opts, nil
 }
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/xddprog/avito-test-task/internal/config"
+	adapter "github.com/xddprog/avito-test-task/pkg/db/adapter"
+	db "github.com/xddprog/avito-test-task/pkg/db/migration"
+)
+
+const migrateUsage = `usage: reviewer-service migrate <command>
+
+commands:
+  up            apply all pending migrations
+  down N        roll back the last N migrations
+  status        print the applied and the latest available version
+  force V       mark version V as applied and clear the dirty flag
+  create NAME   add an empty up/down migration for every dialect`
+
+var errMigrateUsage = errors.New("invalid migrate usage")
+
+// runMigrate handles "migrate ..." subcommands against the database selected
+// by STORAGE, using the same migrations directory as the service.
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing command", errMigrateUsage)
+	}
+	command, args := args[0], args[1:]
+
+	if command == "create" {
+		if len(args) != 1 {
+			return fmt.Errorf("%w: create expects a migration name", errMigrateUsage)
+		}
+		files, err := db.Create(createDirs(cfg), args[0])
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			fmt.Println(f)
+		}
+		return nil
+	}
+
+	databaseURL, err := migrationsDatabaseURL(cfg)
+	if err != nil {
+		return err
+	}
+	m, err := db.NewMigrator("file://"+cfg.MigrationsDir(), databaseURL)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch command {
+	case "up":
+		if len(args) != 0 {
+			return fmt.Errorf("%w: up takes no arguments", errMigrateUsage)
+		}
+		applied, err := m.Up()
+		if err != nil {
+			return err
+		}
+		if !applied {
+			fmt.Println("no pending migrations")
+		}
+		return printStatus(cfg, m)
+	case "down":
+		n, err := intArg(args, "down expects the number of migrations to roll back")
+		if err != nil {
+			return err
+		}
+		if err := m.Down(n); err != nil {
+			return err
+		}
+		return printStatus(cfg, m)
+	case "force":
+		v, err := intArg(args, "force expects a version")
+		if err != nil {
+			return err
+		}
+		if err := m.Force(v); err != nil {
+			return err
+		}
+		return printStatus(cfg, m)
+	case "status":
+		return printStatus(cfg, m)
+	default:
+		return fmt.Errorf("%w: unknown command %q", errMigrateUsage, command)
+	}
+}
+
+func printStatus(cfg *config.Config, m *db.Migrator) error {
+	version, dirty, applied, err := m.Version()
+	if err != nil {
+		return err
+	}
+	latest, err := db.LatestVersion(cfg.MigrationsDir())
+	if err != nil {
+		return err
+	}
+
+	current := "none"
+	if applied {
+		current = strconv.FormatUint(uint64(version), 10)
+	}
+	fmt.Printf("storage: %s\nmigrations: %s\nversion: %s\nlatest: %d\ndirty: %t\n",
+		cfg.Storage, cfg.MigrationsDir(), current, latest, dirty)
+	return nil
+}
+
+func intArg(args []string, usage string) (int, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("%w: %s", errMigrateUsage, usage)
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s, got %q", errMigrateUsage, usage, args[0])
+	}
+	return n, nil
+}
+
+// migrationsDatabaseURL is the golang-migrate URL of the configured storage.
+func migrationsDatabaseURL(cfg *config.Config) (string, error) {
+	switch cfg.Storage {
+	case config.StoragePostgres:
+		return cfg.Postgres.DSN(), nil
+	case config.StorageSQLite:
+		return adapter.SQLiteURL(cfg.SQLite.Path), nil
+	default:
+		return "", fmt.Errorf("STORAGE=%s has no migrations", cfg.Storage)
+	}
+}
+
+// createDirs keeps the dialects on one version sequence: new migrations go
+// to every dialect unless MIGRATIONS_DIR points at a single directory.
+func createDirs(cfg *config.Config) []string {
+	if cfg.Migrations.Dir != "" {
+		return []string{cfg.Migrations.Dir}
+	}
+	return []string{
+		filepath.Join("migrations", config.StoragePostgres),
+		filepath.Join("migrations", config.StorageSQLite),
+	}
+}
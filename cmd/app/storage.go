@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xddprog/avito-test-task/internal/config"
+	"github.com/xddprog/avito-test-task/internal/handler"
+	"github.com/xddprog/avito-test-task/internal/metrics"
+	"github.com/xddprog/avito-test-task/internal/repository"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+	adapter "github.com/xddprog/avito-test-task/pkg/db/adapter"
+	db "github.com/xddprog/avito-test-task/pkg/db/migration"
+)
+
+// storage is the repository backend selected by STORAGE, together with the
+// readiness checks and metrics that only make sense for that backend.
+type storage struct {
+	unitOfWork    repository.UnitOfWork
+	pullRequests  repository.PullRequestRepository
+	users         repository.UserRepository
+	teams         repository.TeamRepository
+	stats         repository.StatsRepository
+	idempotency   repository.IdempotencyRepository
+	backup        repository.BackupRepository
+	audit         repository.AuditRepository
+	notifications repository.NotificationRepository
+
+	checks     map[string]handler.ReadinessCheck
+	collectors []prometheus.Collector
+	close      func()
+}
+
+func openStorage(ctx context.Context, cfg *config.Config) (*storage, error) {
+	if cfg.Storage == config.StorageMemory {
+		slog.Warn("using in-memory storage, data is lost on restart")
+		return openMemoryStorage(), nil
+	}
+
+	if cfg.Migrations.Auto {
+		databaseURL, err := migrationsDatabaseURL(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if err := db.RunMigrations("file://"+cfg.MigrationsDir(), databaseURL); err != nil {
+			return nil, fmt.Errorf("migration failed: %w", err)
+		}
+	} else {
+		slog.Info("automatic migrations are disabled")
+	}
+
+	switch cfg.Storage {
+	case config.StorageSQLite:
+		return openSQLiteStorage(cfg.SQLite, cfg.MigrationsDir())
+	default:
+		return openPostgresStorage(ctx, cfg.Postgres, cfg.MigrationsDir())
+	}
+}
+
+func openMemoryStorage() *storage {
+	store := repository.NewMemoryStore()
+	return &storage{
+		unitOfWork:    store.UnitOfWork(),
+		pullRequests:  store.PullRequests(),
+		users:         store.Users(),
+		teams:         store.Teams(),
+		stats:         store.Stats(),
+		idempotency:   store.Idempotency(),
+		backup:        store.Backup(),
+		audit:         store.Audit(),
+		notifications: store.Notifications(),
+		checks:        map[string]handler.ReadinessCheck{},
+		close:         func() {},
+	}
+}
+
+func openPostgresStorage(ctx context.Context, cfg config.PostgresConfig, migrationsDir string) (*storage, error) {
+	poolConfig, err := pgxpool.ParseConfig(cfg.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse db config: %w", err)
+	}
+	poolConfig.ConnConfig.Tracer = tracing.NewPgxTracer()
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create db connection: %w", err)
+	}
+
+	s, err := newSQLStorage(adapter.NewPostgres(pool), migrationsDir, "postgres", pool.Ping)
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+	s.collectors = []prometheus.Collector{metrics.NewPoolCollector(pool)}
+	s.close = pool.Close
+	return s, nil
+}
+
+func openSQLiteStorage(cfg config.SQLiteConfig, migrationsDir string) (*storage, error) {
+	conn, err := adapter.OpenSQLite(cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	s, err := newSQLStorage(conn, migrationsDir, "sqlite", conn.Ping)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	s.close = func() {
+		if err := conn.Close(); err != nil {
+			slog.Error("failed to close sqlite database", "error", err)
+		}
+	}
+	return s, nil
+}
+
+// newSQLStorage builds the repositories over a database adapter and checks
+// that its schema matches the migrations shipped with this build.
+func newSQLStorage(conn adapter.DB, migrationsDir, name string, ping handler.ReadinessCheck) (*storage, error) {
+	expectedVersion, err := db.LatestVersion(migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &storage{
+		unitOfWork:    repository.NewUnitOfWork(conn),
+		pullRequests:  repository.NewPullRequestRepository(conn),
+		users:         repository.NewUserRepository(conn),
+		teams:         repository.NewTeamRepository(conn),
+		stats:         repository.NewStatsRepository(conn),
+		idempotency:   repository.NewIdempotencyRepository(conn),
+		backup:        repository.NewBackupRepository(conn),
+		audit:         repository.NewAuditRepository(conn),
+		notifications: repository.NewNotificationRepository(conn),
+		checks: map[string]handler.ReadinessCheck{
+			name: ping,
+			"migrations": func(ctx context.Context) error {
+				version, dirty, err := db.CurrentVersion(ctx, conn)
+				if err != nil {
+					return err
+				}
+				if dirty || version != expectedVersion {
+					return fmt.Errorf("schema version %d (dirty=%t), expected %d", version, dirty, expectedVersion)
+				}
+				return nil
+			},
+		},
+		close: func() {},
+	}, nil
+}
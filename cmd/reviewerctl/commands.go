@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xddprog/avito-test-task/pkg/client"
+	"gopkg.in/yaml.v3"
+)
+
+func newCommands(common *commonFlags) map[string]command {
+	callOpts := func() []client.CallOption {
+		if common.ifMatch > 0 {
+			return []client.CallOption{client.IfMatch(common.ifMatch)}
+		}
+		return nil
+	}
+
+	commands := make(map[string]command)
+	add := func(name string, setup func(fs *flag.FlagSet) func(ctx context.Context, c *client.Client, out *printer) error) {
+		fs := flag.NewFlagSet(name, flag.ContinueOnError)
+		commands[name] = command{flags: fs, run: setup(fs)}
+	}
+
+	add("team add", func(fs *flag.FlagSet) func(context.Context, *client.Client, *printer) error {
+		file := fs.String("from-file", "", "")
+		return func(ctx context.Context, c *client.Client, out *printer) error {
+			if *file == "" {
+				return fmt.Errorf("%w: team add: --from-file is required", errUsage)
+			}
+			var req client.CreateTeamRequest
+			if err := readFile(*file, &req); err != nil {
+				return err
+			}
+			team, err := c.AddTeam(ctx, req, callOpts()...)
+			if err != nil {
+				return err
+			}
+			return out.print(team, teamTable(team))
+		}
+	})
+
+	add("team get", func(fs *flag.FlagSet) func(context.Context, *client.Client, *printer) error {
+		name := fs.String("name", "", "")
+		return func(ctx context.Context, c *client.Client, out *printer) error {
+			if *name == "" {
+				return fmt.Errorf("%w: team get: --name is required", errUsage)
+			}
+			team, err := c.GetTeam(ctx, *name, callOpts()...)
+			if err != nil {
+				return err
+			}
+			return out.print(team, teamTable(team))
+		}
+	})
+
+	add("user set-active", func(fs *flag.FlagSet) func(context.Context, *client.Client, *printer) error {
+		id := fs.String("id", "", "")
+		active := fs.Bool("active", true, "")
+		return func(ctx context.Context, c *client.Client, out *printer) error {
+			if *id == "" {
+				return fmt.Errorf("%w: user set-active: --id is required", errUsage)
+			}
+			user, err := c.SetUserActive(ctx, *id, *active, callOpts()...)
+			if err != nil {
+				return err
+			}
+			return out.print(user, usersTable([]client.User{*user}))
+		}
+	})
+
+	add("pr create", func(fs *flag.FlagSet) func(context.Context, *client.Client, *printer) error {
+		id := fs.String("id", "", "")
+		name := fs.String("name", "", "")
+		author := fs.String("author", "", "")
+		return func(ctx context.Context, c *client.Client, out *printer) error {
+			if *id == "" || *name == "" || *author == "" {
+				return fmt.Errorf("%w: pr create: --id, --name and --author are required", errUsage)
+			}
+			pr, err := c.CreatePullRequest(ctx, client.CreatePRRequest{ID: *id, Name: *name, AuthorID: *author}, callOpts()...)
+			if err != nil {
+				return err
+			}
+			return out.print(pr, prTable(pr))
+		}
+	})
+
+	add("pr reassign", func(fs *flag.FlagSet) func(context.Context, *client.Client, *printer) error {
+		id := fs.String("id", "", "")
+		oldUser := fs.String("old-user", "", "")
+		return func(ctx context.Context, c *client.Client, out *printer) error {
+			if *id == "" || *oldUser == "" {
+				return fmt.Errorf("%w: pr reassign: --id and --old-user are required", errUsage)
+			}
+			result, err := c.ReassignPullRequest(ctx, *id, *oldUser, callOpts()...)
+			if err != nil {
+				return err
+			}
+			table := prTable(result.PR)
+			table.rows = append(table.rows, []string{"REPLACED BY", result.ReplacedBy})
+			return out.print(result, table)
+		}
+	})
+
+	add("pr merge", func(fs *flag.FlagSet) func(context.Context, *client.Client, *printer) error {
+		id := fs.String("id", "", "")
+		return func(ctx context.Context, c *client.Client, out *printer) error {
+			if *id == "" {
+				return fmt.Errorf("%w: pr merge: --id is required", errUsage)
+			}
+			pr, err := c.MergePullRequest(ctx, *id, callOpts()...)
+			if err != nil {
+				return err
+			}
+			return out.print(pr, prTable(pr))
+		}
+	})
+
+	add("stats", func(fs *flag.FlagSet) func(context.Context, *client.Client, *printer) error {
+		team := fs.String("team", "", "")
+		author := fs.String("author", "", "")
+		from := fs.String("from", "", "")
+		to := fs.String("to", "", "")
+		staleDays := fs.Int("stale-after-days", 0, "")
+		return func(ctx context.Context, c *client.Client, out *printer) error {
+			filter := client.StatsFilter{
+				TeamName:   *team,
+				AuthorID:   *author,
+				StaleAfter: time.Duration(*staleDays) * 24 * time.Hour,
+			}
+			var err error
+			if filter.From, err = parseDate("from", *from); err != nil {
+				return err
+			}
+			if filter.To, err = parseDate("to", *to); err != nil {
+				return err
+			}
+			stats, err := c.StatsSummary(ctx, filter, callOpts()...)
+			if err != nil {
+				return err
+			}
+			return out.print(stats, statsTable(stats))
+		}
+	})
+
+	return commands
+}
+
+// readFile decodes a JSON or YAML file (by extension) into dst. YAML goes
+// through JSON so the same field names work in both formats.
+func readFile(path string, dst any) error {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var doc any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	return nil
+}
+
+func parseDate(name, raw string) (*time.Time, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: --%s must be a date (2006-01-02) or RFC 3339 time, got %q", errUsage, name, raw)
+}
+
+func teamTable(team *client.Team) table {
+	t := usersTable(team.Members)
+	t.title = fmt.Sprintf("team %s (version %d)", team.Name, team.Version)
+	return t
+}
+
+func usersTable(users []client.User) table {
+	t := table{header: []string{"ID", "USERNAME", "TEAM", "ACTIVE", "VERSION"}}
+	for _, u := range users {
+		t.rows = append(t.rows, []string{u.ID, u.Username, u.TeamName, strconv.FormatBool(u.IsActive), strconv.Itoa(u.Version)})
+	}
+	return t
+}
+
+func prTable(pr *client.PullRequest) table {
+	t := table{rows: [][]string{
+		{"ID", pr.ID},
+		{"NAME", pr.Name},
+		{"AUTHOR", pr.AuthorID},
+		{"STATUS", string(pr.Status)},
+		{"REVIEWERS", strings.Join(pr.Reviewers, ", ")},
+		{"VERSION", strconv.Itoa(pr.Version)},
+	}}
+	if pr.CreatedAt != nil {
+		t.rows = append(t.rows, []string{"CREATED", pr.CreatedAt.Format(time.RFC3339)})
+	}
+	if pr.MergedAt != nil {
+		t.rows = append(t.rows, []string{"MERGED", pr.MergedAt.Format(time.RFC3339)})
+	}
+	return t
+}
+
+func statsTable(stats *client.StatsSummary) table {
+	t := table{
+		title:  fmt.Sprintf("pull requests: %d total, %d open, %d merged, %.2f reviewers on average", stats.PRStatus.Total, stats.PRStatus.Open, stats.PRStatus.Merged, stats.PRStatus.AverageReviewers),
+		header: []string{"REVIEWER", "ASSIGNMENTS"},
+	}
+	for _, a := range stats.ReviewerAssignments {
+		t.rows = append(t.rows, []string{a.UserID, strconv.Itoa(a.Assignments)})
+	}
+	return t
+}
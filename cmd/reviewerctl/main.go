@@ -0,0 +1,118 @@
+// Command reviewerctl manages teams, users and pull requests of a running
+// reviewer service through pkg/client.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xddprog/avito-test-task/pkg/client"
+)
+
+const usage = `usage: reviewerctl <command> [flags]
+
+commands:
+  team add --from-file FILE     create a team from a JSON or YAML file ("-" reads JSON from stdin)
+  team get --name NAME          show a team and its members
+  user set-active --id ID --active=BOOL
+  pr create --id ID --name NAME --author USER_ID
+  pr reassign --id ID --old-user USER_ID
+  pr merge --id ID
+  stats [--team NAME] [--author USER_ID] [--from DATE] [--to DATE] [--stale-after-days N]
+
+common flags:
+  --server URL      service address (default $REVIEWERCTL_SERVER or http://localhost:8080)
+  -o, --output FMT  table, json or yaml (default table)
+  --timeout DUR     request timeout (default 10s)
+  --if-match N      send If-Match with the expected entity version`
+
+var errUsage = errors.New("invalid usage")
+
+// command runs one subcommand with its flags already parsed.
+type command struct {
+	flags *flag.FlagSet
+	run   func(ctx context.Context, c *client.Client, out *printer) error
+}
+
+type commonFlags struct {
+	server  string
+	output  string
+	timeout time.Duration
+	ifMatch int
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		if errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprintln(stdout, usage)
+		return nil
+	}
+
+	var common commonFlags
+	commands := newCommands(&common)
+
+	name := args[0]
+	rest := args[1:]
+	if _, ok := commands[name]; !ok && len(args) > 1 {
+		name, rest = args[0]+" "+args[1], args[2:]
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("%w: unknown command %q (known: %s)", errUsage, name, strings.Join(commandNames(commands), ", "))
+	}
+
+	cmd.flags.StringVar(&common.server, "server", defaultServer(), "")
+	cmd.flags.StringVar(&common.output, "output", "table", "")
+	cmd.flags.StringVar(&common.output, "o", "table", "")
+	cmd.flags.DurationVar(&common.timeout, "timeout", 10*time.Second, "")
+	cmd.flags.IntVar(&common.ifMatch, "if-match", 0, "")
+	cmd.flags.SetOutput(io.Discard)
+	if err := cmd.flags.Parse(rest); err != nil {
+		return fmt.Errorf("%w: %s: %v", errUsage, name, err)
+	}
+	if cmd.flags.NArg() > 0 {
+		return fmt.Errorf("%w: %s: unexpected arguments %v", errUsage, name, cmd.flags.Args())
+	}
+
+	out, err := newPrinter(stdout, common.output)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), common.timeout)
+	defer cancel()
+	return cmd.run(ctx, client.New(common.server), out)
+}
+
+func defaultServer() string {
+	if server := os.Getenv("REVIEWERCTL_SERVER"); server != "" {
+		return server
+	}
+	return "http://localhost:8080"
+}
+
+func commandNames(commands map[string]command) []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
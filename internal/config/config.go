@@ -2,15 +2,39 @@ package config
 
 import (
 	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/xddprog/avito-test-task/pkg/workcal"
+)
+
+const (
+	StoragePostgres = "postgres"
+	StorageSQLite   = "sqlite"
+	StorageMemory   = "memory"
 )
 
 type Config struct {
-	HTTP     HTTPConfig
-	Postgres PostgresConfig
-	Log      LogConfig
+	// Storage selects the repository backend: "postgres", "sqlite" for a
+	// single-binary setup, or "memory" for a throwaway demo.
+	Storage     string `env:"STORAGE" env-default:"postgres"`
+	HTTP        HTTPConfig
+	Postgres    PostgresConfig
+	SQLite      SQLiteConfig
+	Migrations  MigrationsConfig
+	Log         LogConfig
+	Fairness    FairnessConfig
+	Tracing     TracingConfig
+	RateLimit   RateLimitConfig
+	Idempotency IdempotencyConfig
+	Admin       AdminConfig
+	StaleReview StaleReviewConfig
+	SLA         SLAConfig
+	Notify      NotifyConfig
 }
 
 type LogConfig struct {
@@ -18,10 +42,414 @@ type LogConfig struct {
 	Format string `env:"LOG_FORMAT" env-default:"text"`
 }
 
+type TracingConfig struct {
+	Exporter     string  `env:"TRACING_EXPORTER" env-default:"none"`
+	FilePath     string  `env:"TRACING_FILE" env-default:"traces.json"`
+	OTLPEndpoint string  `env:"TRACING_OTLP_ENDPOINT"`
+	OTLPInsecure bool    `env:"TRACING_OTLP_INSECURE" env-default:"true"`
+	SampleRatio  float64 `env:"TRACING_SAMPLE_RATIO" env-default:"1"`
+	ServiceName  string  `env:"TRACING_SERVICE_NAME" env-default:"reviewer-service"`
+}
+
+// RateLimitConfig describes token buckets kept per client (API key or IP).
+// Routes overrides the default bucket for individual paths, in the form
+// "/pullRequest/create=5:10,/team/deactivate=1:2" (path=rps:burst).
+type RateLimitConfig struct {
+	Enabled        bool    `env:"RATE_LIMIT_ENABLED" env-default:"true"`
+	RPS            float64 `env:"RATE_LIMIT_RPS" env-default:"200"`
+	Burst          int     `env:"RATE_LIMIT_BURST" env-default:"500"`
+	Routes         string  `env:"RATE_LIMIT_ROUTES"`
+	APIKeyHeader   string  `env:"RATE_LIMIT_API_KEY_HEADER" env-default:"X-API-Key"`
+	TrustForwarded bool    `env:"RATE_LIMIT_TRUST_FORWARDED" env-default:"false"`
+	// APIKeys are the comma-separated keys clients may identify with. Any
+	// other key is ignored, so that made-up keys cannot mint new buckets.
+	APIKeys string `env:"RATE_LIMIT_API_KEYS"`
+}
+
+// Keys returns the keys of APIKeys.
+func (c RateLimitConfig) Keys() []string {
+	return splitList(c.APIKeys, ",")
+}
+
+type RouteLimit struct {
+	RPS   float64
+	Burst int
+}
+
+// Validate checks the default bucket and the per-route limits. A zero RPS
+// would make Retry-After infinite and a zero Burst would reject everything.
+func (c RateLimitConfig) Validate() error {
+	if c.RPS <= 0 {
+		return fmt.Errorf("RATE_LIMIT_RPS must be a positive number")
+	}
+	if c.Burst < 1 {
+		return fmt.Errorf("RATE_LIMIT_BURST must be a positive integer")
+	}
+	_, err := c.RouteLimits()
+	return err
+}
+
+// RouteLimits parses Routes into per-path limits.
+func (c RateLimitConfig) RouteLimits() (map[string]RouteLimit, error) {
+	limits := make(map[string]RouteLimit)
+	for _, item := range strings.Split(c.Routes, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		path, spec, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate limit %q: expected path=rps:burst", item)
+		}
+		rpsStr, burstStr, ok := strings.Cut(spec, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate limit %q: expected path=rps:burst", item)
+		}
+		rps, err := strconv.ParseFloat(rpsStr, 64)
+		if err != nil || rps <= 0 {
+			return nil, fmt.Errorf("invalid rate limit %q: rps must be a positive number", item)
+		}
+		burst, err := strconv.Atoi(burstStr)
+		if err != nil || burst < 1 {
+			return nil, fmt.Errorf("invalid rate limit %q: burst must be a positive integer", item)
+		}
+		limits[strings.TrimSpace(path)] = RouteLimit{RPS: rps, Burst: burst}
+	}
+	return limits, nil
+}
+
+// IdempotencyConfig keeps responses for TTL. Lease bounds how long an
+// unfinished request holds its key, so it must exceed the slowest request.
+type IdempotencyConfig struct {
+	TTL   time.Duration `env:"IDEMPOTENCY_TTL" env-default:"24h"`
+	Lease time.Duration `env:"IDEMPOTENCY_LEASE" env-default:"1m"`
+}
+
+// AdminConfig guards /admin/export and /admin/import. Without a token the
+// admin routes are not served at all.
+type AdminConfig struct {
+	Token          string `env:"ADMIN_TOKEN"`
+	MaxImportBytes int64  `env:"ADMIN_MAX_IMPORT_BYTES" env-default:"67108864"`
+}
+
+const (
+	StaleActionRemind   = "remind"
+	StaleActionReassign = "reassign"
+	StaleActionEscalate = "escalate"
+)
+
+// StaleReviewConfig drives the scheduler that chases reviewers who sit on
+// an open PR. A reviewer gets a reminder after RemindAfter; after
+// EscalateAfter the scheduler applies Action: "reassign" hands the review to
+// someone else, "escalate" notifies the team lead and "remind" does nothing
+// more. Teams overrides the defaults per team, in the form
+// "backend:remind=24h,escalate=72h,action=escalate,lead=u1;frontend:action=remind".
+type StaleReviewConfig struct {
+	Enabled       bool          `env:"STALE_REVIEW_ENABLED" env-default:"false"`
+	Interval      time.Duration `env:"STALE_REVIEW_INTERVAL" env-default:"15m"`
+	RemindAfter   time.Duration `env:"STALE_REVIEW_REMIND_AFTER" env-default:"48h"`
+	EscalateAfter time.Duration `env:"STALE_REVIEW_ESCALATE_AFTER" env-default:"96h"`
+	Action        string        `env:"STALE_REVIEW_ACTION" env-default:"reassign"`
+	Teams         string        `env:"STALE_REVIEW_TEAMS"`
+}
+
+type StaleReviewPolicy struct {
+	RemindAfter   time.Duration
+	EscalateAfter time.Duration
+	Action        string
+	Lead          string
+}
+
+// Default is the policy of teams without an override.
+func (c StaleReviewConfig) Default() (StaleReviewPolicy, error) {
+	p := StaleReviewPolicy{RemindAfter: c.RemindAfter, EscalateAfter: c.EscalateAfter, Action: c.Action}
+	if err := p.validate(); err != nil {
+		return p, fmt.Errorf("invalid stale review policy: %w", err)
+	}
+	return p, nil
+}
+
+// TeamPolicies parses Teams. Options a team does not set are taken from the
+// defaults.
+func (c StaleReviewConfig) TeamPolicies() (map[string]StaleReviewPolicy, error) {
+	policies := make(map[string]StaleReviewPolicy)
+	for _, item := range strings.Split(c.Teams, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		team, options, ok := strings.Cut(item, ":")
+		team = strings.TrimSpace(team)
+		if !ok || team == "" {
+			return nil, fmt.Errorf("invalid stale review policy %q: expected team:option=value,...", item)
+		}
+
+		p := StaleReviewPolicy{RemindAfter: c.RemindAfter, EscalateAfter: c.EscalateAfter, Action: c.Action}
+		for _, option := range strings.Split(options, ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(option), "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid stale review policy %q: expected option=value, got %q", item, option)
+			}
+			var err error
+			switch strings.TrimSpace(key) {
+			case "remind":
+				p.RemindAfter, err = time.ParseDuration(value)
+			case "escalate":
+				p.EscalateAfter, err = time.ParseDuration(value)
+			case "action":
+				p.Action = value
+			case "lead":
+				p.Lead = value
+			default:
+				err = fmt.Errorf("unknown option %q", key)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid stale review policy %q: %w", item, err)
+			}
+		}
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("invalid stale review policy for team %q: %w", team, err)
+		}
+		policies[team] = p
+	}
+	return policies, nil
+}
+
+func (p StaleReviewPolicy) validate() error {
+	if p.RemindAfter <= 0 {
+		return fmt.Errorf("remind threshold must be positive")
+	}
+	switch p.Action {
+	case StaleActionRemind:
+	case StaleActionReassign, StaleActionEscalate:
+		if p.EscalateAfter <= p.RemindAfter {
+			return fmt.Errorf("escalate threshold %s must be greater than remind threshold %s", p.EscalateAfter, p.RemindAfter)
+		}
+	default:
+		return fmt.Errorf("unknown action %q: expected %s, %s or %s", p.Action, StaleActionRemind, StaleActionReassign, StaleActionEscalate)
+	}
+	if p.Action == StaleActionEscalate && p.Lead == "" {
+		return fmt.Errorf("action %s needs a team lead", StaleActionEscalate)
+	}
+	return nil
+}
+
+// SLAConfig sets the review deadline of new PRs: a PR should be merged
+// within Target of its creation. With BusinessHours set only working time
+// counts: BusinessDays within BusinessHours in Timezone, except Holidays
+// (comma-separated dates). Teams overrides the defaults per team, in the form
+// "backend:target=16h,hours=10:00-19:00,days=mon-fri,tz=Europe/Moscow,holidays=2026-01-01|2026-01-02;ops:target=4h,hours=".
+// An empty hours option counts wall-clock time, a zero target turns the SLA
+// off.
+type SLAConfig struct {
+	Target        time.Duration `env:"SLA_TARGET" env-default:"24h"`
+	BusinessHours string        `env:"SLA_BUSINESS_HOURS"`
+	BusinessDays  string        `env:"SLA_BUSINESS_DAYS" env-default:"mon-fri"`
+	Timezone      string        `env:"SLA_TIMEZONE" env-default:"UTC"`
+	Holidays      string        `env:"SLA_HOLIDAYS"`
+	Teams         string        `env:"SLA_TEAMS"`
+}
+
+type SLAPolicy struct {
+	Target time.Duration
+	// Calendar is nil when the SLA counts wall-clock time.
+	Calendar *workcal.Calendar
+}
+
+// slaSpec is an SLA policy before its calendar is built.
+type slaSpec struct {
+	target          time.Duration
+	hours, days, tz string
+	holidays        []string
+}
+
+func (c SLAConfig) spec() slaSpec {
+	return slaSpec{
+		target:   c.Target,
+		hours:    c.BusinessHours,
+		days:     c.BusinessDays,
+		tz:       c.Timezone,
+		holidays: splitList(c.Holidays, ","),
+	}
+}
+
+// splitList splits s by sep, dropping empty items.
+func splitList(s, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(s, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (s slaSpec) policy() (SLAPolicy, error) {
+	if s.target < 0 {
+		return SLAPolicy{}, fmt.Errorf("target %s must not be negative", s.target)
+	}
+	p := SLAPolicy{Target: s.target}
+	if s.hours == "" {
+		return p, nil
+	}
+	var err error
+	p.Calendar, err = workcal.New(s.days, s.hours, s.tz, s.holidays)
+	return p, err
+}
+
+// Default is the policy of teams without an override.
+func (c SLAConfig) Default() (SLAPolicy, error) {
+	p, err := c.spec().policy()
+	if err != nil {
+		return p, fmt.Errorf("invalid SLA policy: %w", err)
+	}
+	return p, nil
+}
+
+// TeamPolicies parses Teams. Options a team does not set are taken from the
+// defaults.
+func (c SLAConfig) TeamPolicies() (map[string]SLAPolicy, error) {
+	policies := make(map[string]SLAPolicy)
+	for _, item := range strings.Split(c.Teams, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		team, options, ok := strings.Cut(item, ":")
+		team = strings.TrimSpace(team)
+		if !ok || team == "" {
+			return nil, fmt.Errorf("invalid SLA policy %q: expected team:option=value,...", item)
+		}
+
+		spec := c.spec()
+		for _, option := range strings.Split(options, ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(option), "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid SLA policy %q: expected option=value, got %q", item, option)
+			}
+			var err error
+			switch strings.TrimSpace(key) {
+			case "target":
+				spec.target, err = time.ParseDuration(value)
+			case "hours":
+				spec.hours = value
+			case "days":
+				spec.days = value
+			case "tz":
+				spec.tz = value
+			case "holidays":
+				spec.holidays = splitList(value, "|")
+			default:
+				err = fmt.Errorf("unknown option %q", key)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid SLA policy %q: %w", item, err)
+			}
+		}
+		p, err := spec.policy()
+		if err != nil {
+			return nil, fmt.Errorf("invalid SLA policy for team %q: %w", team, err)
+		}
+		policies[team] = p
+	}
+	return policies, nil
+}
+
+// NotifyConfig drives review notifications. The log channel is always
+// available, email once SMTP_HOST is set and chat once a webhook URL is set.
+// DefaultChannels apply to users who saved no settings of their own.
+type NotifyConfig struct {
+	Enabled         bool          `env:"NOTIFY_ENABLED" env-default:"false"`
+	DefaultChannels string        `env:"NOTIFY_DEFAULT_CHANNELS" env-default:"log"`
+	Interval        time.Duration `env:"NOTIFY_INTERVAL" env-default:"10s"`
+	MaxAttempts     int           `env:"NOTIFY_MAX_ATTEMPTS" env-default:"5"`
+	RetryBackoff    time.Duration `env:"NOTIFY_RETRY_BACKOFF" env-default:"1m"`
+	// Lease is how long a dispatcher may take to send a claimed batch
+	// before another replica sends it again.
+	Lease time.Duration `env:"NOTIFY_LEASE" env-default:"5m"`
+	// TemplatesDir may hold <kind>.tmpl files replacing the built-in
+	// templates.
+	TemplatesDir string `env:"NOTIFY_TEMPLATES_DIR"`
+	SMTP         SMTPConfig
+	Chat         ChatConfig
+}
+
+type SMTPConfig struct {
+	Host     string        `env:"SMTP_HOST"`
+	Port     int           `env:"SMTP_PORT" env-default:"587"`
+	Username string        `env:"SMTP_USERNAME"`
+	Password string        `env:"SMTP_PASSWORD"`
+	From     string        `env:"SMTP_FROM"`
+	Timeout  time.Duration `env:"SMTP_TIMEOUT" env-default:"10s"`
+}
+
+type ChatConfig struct {
+	WebhookURL string        `env:"NOTIFY_CHAT_WEBHOOK_URL"`
+	Timeout    time.Duration `env:"NOTIFY_CHAT_TIMEOUT" env-default:"5s"`
+}
+
+// Channels returns the names of the configured channels.
+func (c NotifyConfig) Channels() []string {
+	channels := []string{"log"}
+	if c.SMTP.Host != "" {
+		channels = append(channels, "email")
+	}
+	if c.Chat.WebhookURL != "" {
+		channels = append(channels, "chat")
+	}
+	return channels
+}
+
+// Defaults returns the channels of DefaultChannels.
+func (c NotifyConfig) Defaults() []string {
+	return splitList(c.DefaultChannels, ",")
+}
+
+func (c NotifyConfig) validate() error {
+	if c.Interval <= 0 {
+		return fmt.Errorf("NOTIFY_INTERVAL must be positive")
+	}
+	if c.MaxAttempts <= 0 {
+		return fmt.Errorf("NOTIFY_MAX_ATTEMPTS must be positive")
+	}
+	if c.RetryBackoff <= 0 {
+		return fmt.Errorf("NOTIFY_RETRY_BACKOFF must be positive")
+	}
+	if c.Lease <= 0 {
+		return fmt.Errorf("NOTIFY_LEASE must be positive")
+	}
+	if c.SMTP.Host != "" && c.SMTP.From == "" {
+		return fmt.Errorf("SMTP_FROM is required when SMTP_HOST is set")
+	}
+	if c.SMTP.Timeout <= 0 || c.Chat.Timeout <= 0 {
+		return fmt.Errorf("SMTP_TIMEOUT and NOTIFY_CHAT_TIMEOUT must be positive")
+	}
+	available := c.Channels()
+	for _, ch := range c.Defaults() {
+		if !slices.Contains(available, ch) {
+			return fmt.Errorf("NOTIFY_DEFAULT_CHANNELS: channel %q is not configured, available: %s", ch, strings.Join(available, ", "))
+		}
+	}
+	return nil
+}
+
+type FairnessConfig struct {
+	GiniThreshold float64       `env:"FAIRNESS_GINI_THRESHOLD" env-default:"0.4"`
+	Window        time.Duration `env:"FAIRNESS_WINDOW" env-default:"720h"`
+	// CheckInterval is how often imbalance events are evaluated over the
+	// last Window. Zero turns the check off.
+	CheckInterval time.Duration `env:"FAIRNESS_CHECK_INTERVAL" env-default:"1h"`
+}
+
 type HTTPConfig struct {
-	Port         string        `env:"HTTP_PORT" env-default:"8080"`
-	ReadTimeout  time.Duration `env:"HTTP_READ_TIMEOUT" env-default:"5s"`
-	WriteTimeout time.Duration `env:"HTTP_WRITE_TIMEOUT" env-default:"5s"`
+	Port            string        `env:"HTTP_PORT" env-default:"8080"`
+	ReadTimeout     time.Duration `env:"HTTP_READ_TIMEOUT" env-default:"5s"`
+	WriteTimeout    time.Duration `env:"HTTP_WRITE_TIMEOUT" env-default:"5s"`
+	ShutdownTimeout time.Duration `env:"HTTP_SHUTDOWN_TIMEOUT" env-default:"15s"`
+	MaxBodyBytes    int64         `env:"HTTP_MAX_BODY_BYTES" env-default:"1048576"`
+	// DrainDelay is how long readiness reports DRAINING while the server
+	// still accepts requests, so probes see it before the listener closes.
+	DrainDelay time.Duration `env:"HTTP_DRAIN_DELAY" env-default:"5s"`
 }
 
 func (h HTTPConfig) Address() string {
@@ -32,11 +460,31 @@ type PostgresConfig struct {
 	Host     string `env:"POSTGRES_HOST" env-default:"localhost"`
 	Port     string `env:"POSTGRES_PORT" env-default:"5432"`
 	User     string `env:"POSTGRES_USER" env-default:"postgres"`
-	Password string `env:"POSTGRES_PASSWORD" env-required:"true"`
+	Password string `env:"POSTGRES_PASSWORD"`
 	DBName   string `env:"POSTGRES_DB" env-default:"avito_service"`
 	SSLMode  string `env:"POSTGRES_SSLMODE" env-default:"disable"`
 }
 
+type SQLiteConfig struct {
+	Path string `env:"SQLITE_PATH" env-default:"reviewer.db"`
+}
+
+type MigrationsConfig struct {
+	// Dir defaults to migrations/<STORAGE>, each dialect has its own set.
+	Dir string `env:"MIGRATIONS_DIR"`
+	// Auto applies pending migrations on startup. Turn it off when several
+	// replicas roll out at once and run "migrate up" as a separate step.
+	Auto bool `env:"MIGRATIONS_AUTO" env-default:"true"`
+}
+
+// MigrationsDir is the migrations directory for the selected storage.
+func (c *Config) MigrationsDir() string {
+	if c.Migrations.Dir != "" {
+		return c.Migrations.Dir
+	}
+	return filepath.Join("migrations", c.Storage)
+}
+
 func (p PostgresConfig) DSN() string {
 	return fmt.Sprintf(
 		"postgres://%s:%s@%s:%s/%s?sslmode=%s",
@@ -58,5 +506,48 @@ func Load() (*Config, error) {
 		}
 	}
 
+	switch cfg.Storage {
+	case StoragePostgres:
+		if cfg.Postgres.Password == "" {
+			return nil, fmt.Errorf("POSTGRES_PASSWORD is required when STORAGE=%s", StoragePostgres)
+		}
+	case StorageSQLite, StorageMemory:
+	default:
+		return nil, fmt.Errorf("unknown STORAGE %q: expected %s, %s or %s", cfg.Storage, StoragePostgres, StorageSQLite, StorageMemory)
+	}
+
+	if err := cfg.RateLimit.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := cfg.SLA.Default(); err != nil {
+		return nil, err
+	}
+	if _, err := cfg.SLA.TeamPolicies(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Notify.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Idempotency.TTL <= 0 || cfg.Idempotency.Lease <= 0 {
+		return nil, fmt.Errorf("IDEMPOTENCY_TTL and IDEMPOTENCY_LEASE must be positive")
+	}
+	if cfg.HTTP.DrainDelay < 0 {
+		return nil, fmt.Errorf("HTTP_DRAIN_DELAY must not be negative")
+	}
+	if cfg.Fairness.CheckInterval < 0 {
+		return nil, fmt.Errorf("FAIRNESS_CHECK_INTERVAL must not be negative")
+	}
+	if cfg.StaleReview.Enabled {
+		if cfg.StaleReview.Interval <= 0 {
+			return nil, fmt.Errorf("STALE_REVIEW_INTERVAL must be positive")
+		}
+		if _, err := cfg.StaleReview.Default(); err != nil {
+			return nil, err
+		}
+		if _, err := cfg.StaleReview.TeamPolicies(); err != nil {
+			return nil, err
+		}
+	}
+
 	return cfg, nil
 }
@@ -0,0 +1,45 @@
+package entity
+
+import "time"
+
+type AuditAction string
+
+const (
+	AuditReviewReminder     AuditAction = "REVIEW_REMINDER"
+	AuditReviewReassigned   AuditAction = "REVIEW_REASSIGNED"
+	AuditReviewEscalated    AuditAction = "REVIEW_ESCALATED"
+	AuditReviewActionFailed AuditAction = "REVIEW_ACTION_FAILED"
+	// AuditFairnessImbalanced and AuditFairnessBalanced record a team
+	// crossing the fairness threshold one way or the other.
+	AuditFairnessImbalanced AuditAction = "FAIRNESS_IMBALANCED"
+	AuditFairnessBalanced   AuditAction = "FAIRNESS_BALANCED"
+)
+
+// AuditEntry records an action taken on a PR or a team by the system or an
+// operator.
+// Entries are never updated and outlive the PRs and users they mention.
+type AuditEntry struct {
+	ID            int64             `json:"id"`
+	OccurredAt    time.Time         `json:"occurred_at"`
+	Actor         string            `json:"actor"`
+	Action        AuditAction       `json:"action"`
+	PullRequestID string            `json:"pull_request_id,omitempty"`
+	UserID        string            `json:"user_id,omitempty"`
+	TeamName      string            `json:"team_name,omitempty"`
+	Details       map[string]string `json:"details,omitempty"`
+	// Key, when set, identifies the event the entry records, so that
+	// RecordOnce stores it only once however many times it is reported.
+	Key string `json:"-"`
+}
+
+// AuditFilter selects entries; empty fields match everything. Entries come
+// newest first, at most Limit of them.
+type AuditFilter struct {
+	Action        AuditAction
+	PullRequestID string
+	UserID        string
+	TeamName      string
+	From          *time.Time
+	To            *time.Time
+	Limit         int
+}
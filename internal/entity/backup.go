@@ -0,0 +1,71 @@
+package entity
+
+import "time"
+
+// BackupFormatVersion is bumped whenever the backup layout changes in a way
+// older readers cannot handle. Import accepts only this version.
+const BackupFormatVersion = 1
+
+// Backup is a full dump of teams, users, pull requests and their current
+// reviewers. Optimistic-lock versions and the reassignment history are not
+// included; imported rows start at version 1.
+type Backup struct {
+	FormatVersion int                 `json:"format_version"`
+	ExportedAt    time.Time           `json:"exported_at"`
+	Teams         []BackupTeam        `json:"teams"`
+	Users         []BackupUser        `json:"users"`
+	PullRequests  []BackupPullRequest `json:"pull_requests"`
+	Reviewers     []BackupReviewer    `json:"reviewers"`
+}
+
+type BackupTeam struct {
+	Name string `json:"team_name"`
+}
+
+type BackupUser struct {
+	ID       string `json:"user_id"`
+	Username string `json:"username"`
+	TeamName string `json:"team_name"`
+	IsActive bool   `json:"is_active"`
+}
+
+type BackupPullRequest struct {
+	ID        string     `json:"pull_request_id"`
+	Name      string     `json:"pull_request_name"`
+	AuthorID  string     `json:"author_id"`
+	Status    PRStatus   `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	MergedAt  *time.Time `json:"merged_at,omitempty"`
+	// SLADeadline is restored as is; whether a merged PR met it is derived
+	// from merged_at on import.
+	SLADeadline *time.Time `json:"sla_deadline,omitempty"`
+}
+
+type BackupReviewer struct {
+	PullRequestID string    `json:"pull_request_id"`
+	UserID        string    `json:"user_id"`
+	AssignedAt    time.Time `json:"assigned_at"`
+}
+
+type ExportOptions struct {
+	// Anonymize replaces usernames and PR titles and remaps user ids and
+	// team names consistently, so the relations survive.
+	Anonymize bool
+}
+
+type ImportOptions struct {
+	// DryRun runs the whole import and rolls it back.
+	DryRun bool
+	// Replace deletes the existing review data first. Otherwise rows that
+	// already exist fail the import with ErrImportConflict.
+	Replace bool
+}
+
+type ImportResult struct {
+	DryRun       bool `json:"dry_run"`
+	Replaced     bool `json:"replaced"`
+	Teams        int  `json:"teams"`
+	Users        int  `json:"users"`
+	PullRequests int  `json:"pull_requests"`
+	Reviewers    int  `json:"reviewers"`
+}
@@ -70,6 +70,54 @@ var (
 		Message:  "no active replacement candidate in team",
 	}
 
+	ErrConflictVersion = &AppError{
+		Code:     http.StatusConflict,
+		SafeCode: "CONFLICT_VERSION",
+		Message:  "resource was modified concurrently, reload and retry",
+	}
+
+	ErrPreconditionFailed = &AppError{
+		Code:     http.StatusPreconditionFailed,
+		SafeCode: "PRECONDITION_FAILED",
+		Message:  "resource does not match If-Match, reload and retry",
+	}
+
+	ErrRateLimited = &AppError{
+		Code:     http.StatusTooManyRequests,
+		SafeCode: "RATE_LIMITED",
+		Message:  "too many requests",
+	}
+
+	ErrPayloadTooLarge = &AppError{
+		Code:     http.StatusRequestEntityTooLarge,
+		SafeCode: "PAYLOAD_TOO_LARGE",
+		Message:  "request body is too large",
+	}
+
+	ErrIdempotencyKeyReused = &AppError{
+		Code:     http.StatusUnprocessableEntity,
+		SafeCode: "IDEMPOTENCY_KEY_REUSED",
+		Message:  "idempotency key was already used with a different request",
+	}
+
+	ErrIdempotencyInProgress = &AppError{
+		Code:     http.StatusConflict,
+		SafeCode: "IDEMPOTENCY_IN_PROGRESS",
+		Message:  "a request with this idempotency key is still being processed",
+	}
+
+	ErrUnauthorized = &AppError{
+		Code:     http.StatusUnauthorized,
+		SafeCode: "UNAUTHORIZED",
+		Message:  "missing or invalid credentials",
+	}
+
+	ErrImportConflict = &AppError{
+		Code:     http.StatusConflict,
+		SafeCode: "IMPORT_CONFLICT",
+		Message:  "imported data conflicts with existing records",
+	}
+
 	ErrNotFoundAuthor = &AppError{
 		
 	}
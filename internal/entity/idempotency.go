@@ -0,0 +1,34 @@
+package entity
+
+import "time"
+
+// IdempotencyScope names one idempotency key. The same Idempotency-Key sent
+// by two clients, or to two endpoints, belongs to two different requests.
+// Client is a fingerprint of the caller's API key or IP.
+type IdempotencyScope struct {
+	Client string
+	Key    string
+	Method string
+	Path   string
+}
+
+// IdempotencyRecord is the stored outcome of a POST sent with an
+// Idempotency-Key. StatusCode is zero while the first request is in flight;
+// once LockedUntil passes, a retry may take the key over from a request that
+// never finished. ETag is kept so a replay carries the version tag the
+// first response had.
+type IdempotencyRecord struct {
+	IdempotencyScope
+	RequestHash string
+	StatusCode  int
+	ContentType string
+	ETag        string
+	Body        []byte
+	CreatedAt   time.Time
+	LockedUntil time.Time
+	ExpiresAt   time.Time
+}
+
+func (r *IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
+}
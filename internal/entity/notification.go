@@ -0,0 +1,97 @@
+package entity
+
+import "time"
+
+type NotificationChannel string
+
+const (
+	ChannelEmail NotificationChannel = "email"
+	ChannelChat  NotificationChannel = "chat"
+	ChannelLog   NotificationChannel = "log"
+)
+
+// NotificationKind is what a notification is about. Every kind has its own
+// message template.
+type NotificationKind string
+
+const (
+	NotifyReviewAssigned   NotificationKind = "review_assigned"
+	NotifyReviewUnassigned NotificationKind = "review_unassigned"
+	NotifyReviewReminder   NotificationKind = "review_reminder"
+)
+
+type NotificationStatus string
+
+const (
+	NotificationPending NotificationStatus = "PENDING"
+	// NotificationSending is claimed by a dispatcher until LockedUntil.
+	NotificationSending NotificationStatus = "SENDING"
+	NotificationSent    NotificationStatus = "SENT"
+	NotificationFailed  NotificationStatus = "FAILED"
+)
+
+// QuietHours is a daily window in the user's time zone when notifications
+// are held back until the window ends. A Start later than End spans
+// midnight.
+type QuietHours struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// NotificationSettings are a user's delivery preferences. An empty Channels
+// list turns notifications off. Chat messages go to ChatHandle, or to the
+// username when it is empty.
+type NotificationSettings struct {
+	UserID     string                `json:"user_id"`
+	Channels   []NotificationChannel `json:"channels"`
+	Email      string                `json:"email,omitempty"`
+	ChatHandle string                `json:"chat_handle,omitempty"`
+	QuietHours *QuietHours           `json:"quiet_hours,omitempty"`
+}
+
+// Notification is one message to one user over one channel. It stays in the
+// outbox as PENDING until it is sent or runs out of attempts, and is SENDING
+// while a dispatcher holds it. A claim that lapses, say because the
+// dispatcher crashed, lets another one send it again.
+type Notification struct {
+	ID            int64               `json:"id"`
+	UserID        string              `json:"user_id"`
+	Channel       NotificationChannel `json:"channel"`
+	Kind          NotificationKind    `json:"kind"`
+	Recipient     string              `json:"recipient"`
+	PullRequestID string              `json:"pull_request_id,omitempty"`
+	Subject       string              `json:"subject"`
+	Body          string              `json:"body"`
+	Status        NotificationStatus  `json:"status"`
+	Attempts      int                 `json:"attempts"`
+	LastError     string              `json:"last_error,omitempty"`
+	CreatedAt     time.Time           `json:"created_at"`
+	DeliverAfter  time.Time           `json:"deliver_after"`
+	SentAt        *time.Time          `json:"sent_at,omitempty"`
+	LockedUntil   *time.Time          `json:"locked_until,omitempty"`
+}
+
+// NotificationFilter selects notifications; empty fields match everything.
+// Notifications come newest first, at most Limit of them.
+type NotificationFilter struct {
+	UserID string
+	Status NotificationStatus
+	Limit  int
+}
+
+// ReviewAssignment is the payload of assignment and reassignment events.
+// PreviousReviewerID and Reason are only set on reassignment.
+type ReviewAssignment struct {
+	PullRequestID      string         `json:"pull_request_id"`
+	ReviewerID         string         `json:"reviewer_id"`
+	PreviousReviewerID string         `json:"previous_reviewer_id,omitempty"`
+	Reason             ReassignReason `json:"reason,omitempty"`
+}
+
+// NotificationDispatch counts what one pass of the dispatcher did.
+type NotificationDispatch struct {
+	Sent    int `json:"sent"`
+	Retried int `json:"retried"`
+	Failed  int `json:"failed"`
+}
@@ -11,6 +11,14 @@ const (
 	StatusMerged PRStatus = "MERGED"
 )
 
+type ReassignReason string
+
+const (
+	ReasonManual       ReassignReason = "MANUAL"
+	ReasonDeactivation ReassignReason = "DEACTIVATION"
+	ReasonStale        ReassignReason = "STALE"
+)
+
 type BasePullRequest struct {
 	ID       string   `json:"pull_request_id"`
 	Name     string   `json:"pull_request_name"`
@@ -23,6 +31,11 @@ type PullRequest struct {
 	CreatedAt *time.Time `json:"createdAt,omitempty"`
 	MergedAt  *time.Time `json:"mergedAt,omitempty"`
 	Reviewers []string   `json:"assigned_reviewers"`
+	Version   int        `json:"version"`
+	// SLADeadline is when the PR is due to be merged under the review SLA of
+	// its team, nil if no SLA applied. SLAMet is set on merge.
+	SLADeadline *time.Time `json:"sla_deadline,omitempty"`
+	SLAMet      *bool      `json:"sla_met,omitempty"`
 }
 
 type CreatePRRequest struct {
@@ -39,3 +52,36 @@ type ReassignPRRequest struct {
 	PRID      string `json:"pull_request_id"`
 	OldUserID string `json:"old_user_id"`
 }
+
+type NoCandidateFailure struct {
+	PullRequestID string         `json:"pull_request_id"`
+	ReviewerID    string         `json:"reviewer_id"`
+	Reason        ReassignReason `json:"reason"`
+}
+
+// BulkCreatePRItem is one PR of a bulk import. Reviewers are kept as given
+// when present; when omitted they are picked like in CreatePRRequest.
+// CreatedAt backdates the PR, its reviewer assignments and SLA deadline.
+type BulkCreatePRItem struct {
+	ID        string     `json:"pull_request_id"`
+	Name      string     `json:"pull_request_name"`
+	AuthorID  string     `json:"author_id"`
+	Reviewers []string   `json:"assigned_reviewers,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+type BulkCreatePRRequest struct {
+	PullRequests []BulkCreatePRItem `json:"pull_requests"`
+}
+
+type BulkCreatePRResult struct {
+	PullRequestID string   `json:"pull_request_id"`
+	Reviewers     []string `json:"assigned_reviewers,omitempty"`
+	ErrorCode     string   `json:"error_code,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+type BulkCreatePRResponse struct {
+	Created []BulkCreatePRResult `json:"created"`
+	Failed  []BulkCreatePRResult `json:"failed"`
+}
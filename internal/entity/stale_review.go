@@ -0,0 +1,46 @@
+package entity
+
+import "time"
+
+type StaleAction string
+
+const (
+	StaleActionRemind   StaleAction = "remind"
+	StaleActionReassign StaleAction = "reassign"
+	StaleActionEscalate StaleAction = "escalate"
+)
+
+// OpenReviewAssignment is a reviewer currently assigned to an open PR,
+// with the team of the PR's author.
+type OpenReviewAssignment struct {
+	PullRequestID   string
+	PullRequestName string
+	AuthorID        string
+	TeamName        string
+	ReviewerID      string
+	AssignedAt      time.Time
+}
+
+// StaleReview is the payload of reminder and escalation events.
+// NewReviewerID is set when the review was reassigned, LeadID when it was
+// escalated to the team lead.
+type StaleReview struct {
+	PullRequestID   string            `json:"pull_request_id"`
+	PullRequestName string            `json:"pull_request_name"`
+	AuthorID        string            `json:"author_id"`
+	TeamName        string            `json:"team_name"`
+	ReviewerID      string            `json:"reviewer_id"`
+	AssignedAt      time.Time         `json:"assigned_at"`
+	Waiting         DurationBreakdown `json:"waiting"`
+	Action          StaleAction       `json:"action,omitempty"`
+	NewReviewerID   string            `json:"new_reviewer_id,omitempty"`
+	LeadID          string            `json:"lead_id,omitempty"`
+}
+
+// StaleReviewScan counts what one pass of the scheduler did.
+type StaleReviewScan struct {
+	Reminded   int `json:"reminded"`
+	Reassigned int `json:"reassigned"`
+	Escalated  int `json:"escalated"`
+	Failed     int `json:"failed"`
+}
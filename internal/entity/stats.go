@@ -1,5 +1,27 @@
 package entity
 
+import "time"
+
+type StatsInterval string
+
+const (
+	IntervalDay   StatsInterval = "day"
+	IntervalWeek  StatsInterval = "week"
+	IntervalMonth StatsInterval = "month"
+)
+
+// WeekStaleAfter is the fixed age behind PRLifetimeStat.OpenOlderThan7Days
+// and the default for StatsFilter.StaleAfter.
+const WeekStaleAfter = 7 * 24 * time.Hour
+
+type StatsFilter struct {
+	From       *time.Time
+	To         *time.Time
+	TeamName   string
+	AuthorID   string
+	StaleAfter time.Duration
+}
+
 type ReviewerAssignmentStat struct {
 	UserID      string `json:"user_id"`
 	Assignments int    `json:"assignments"`
@@ -24,9 +46,51 @@ type DurationBreakdown struct {
 	Minutes int `json:"minutes"`
 }
 
+// NewDurationBreakdown splits d, rounded to the minute, into days, hours
+// and minutes.
+func NewDurationBreakdown(d time.Duration) DurationBreakdown {
+	totalMinutes := int(d.Round(time.Minute) / time.Minute)
+	return DurationBreakdown{
+		Days:    totalMinutes / (24 * 60),
+		Hours:   (totalMinutes % (24 * 60)) / 60,
+		Minutes: totalMinutes % 60,
+	}
+}
+
+type DurationPercentiles struct {
+	P50 DurationBreakdown `json:"p50"`
+	P75 DurationBreakdown `json:"p75"`
+	P90 DurationBreakdown `json:"p90"`
+	P99 DurationBreakdown `json:"p99"`
+}
+
+type AgeHistogramBucket struct {
+	Label    string `json:"label"`
+	FromDays int    `json:"from_days"`
+	ToDays   *int   `json:"to_days"`
+	Count    int    `json:"count"`
+}
+
+type LifetimeBreakdown struct {
+	TeamName         string               `json:"team_name,omitempty"`
+	ReviewerCount    *int                 `json:"reviewer_count,omitempty"`
+	Merged           int                  `json:"merged"`
+	MergePercentiles DurationPercentiles  `json:"merge_percentiles"`
+	Open             int                  `json:"open"`
+	OpenAgeHistogram []AgeHistogramBucket `json:"open_age_histogram"`
+}
+
 type PRLifetimeStat struct {
-	AverageMerge       DurationBreakdown `json:"average_merge"`
-	OpenOlderThan7Days int               `json:"open_older_than_7_days"`
+	AverageMerge           DurationBreakdown    `json:"average_merge"`
+	MergePercentiles       DurationPercentiles  `json:"merge_percentiles"`
+	OpenAgeHistogram       []AgeHistogramBucket `json:"open_age_histogram"`
+	ByTeam                 []LifetimeBreakdown  `json:"by_team"`
+	ByReviewerCount        []LifetimeBreakdown  `json:"by_reviewer_count"`
+	StaleThreshold         DurationBreakdown    `json:"stale_threshold"`
+	OpenOlderThanThreshold int                  `json:"open_older_than_threshold"`
+	// OpenOlderThan7Days ignores StatsFilter.StaleAfter: it predates the
+	// configurable threshold and is kept for existing clients.
+	OpenOlderThan7Days int `json:"open_older_than_7_days"`
 }
 
 type StatsSummary struct {
@@ -35,3 +99,112 @@ type StatsSummary struct {
 	TeamMembers         []TeamMemberStat         `json:"team_members"`
 	PRLifetime          PRLifetimeStat           `json:"pr_lifetime"`
 }
+
+type TimeSeriesPoint struct {
+	Bucket      time.Time          `json:"bucket"`
+	TeamName    string             `json:"team_name,omitempty"`
+	Created     int                `json:"prs_created"`
+	Merged      int                `json:"prs_merged"`
+	Assignments int                `json:"assignments"`
+	MedianMerge *DurationBreakdown `json:"median_time_to_merge"`
+}
+
+type TimeSeries struct {
+	Interval StatsInterval     `json:"interval"`
+	From     time.Time         `json:"from"`
+	To       time.Time         `json:"to"`
+	ByTeam   bool              `json:"by_team"`
+	Points   []TimeSeriesPoint `json:"points"`
+}
+
+type ReassignReasonStat struct {
+	Reason ReassignReason `json:"reason"`
+	Count  int            `json:"count"`
+}
+
+type TeamReviewComparison struct {
+	TeamName          string  `json:"team_name"`
+	ActiveMembers     int     `json:"active_members"`
+	MedianAssignments float64 `json:"median_assignments"`
+	MedianOpenLoad    float64 `json:"median_open_load"`
+	OpenLoadVsMedian  float64 `json:"open_load_vs_median"`
+}
+
+type UserReviewStats struct {
+	UserID                   string               `json:"user_id"`
+	AssignmentsReceived      int                  `json:"assignments_received"`
+	ReassignedAway           int                  `json:"reassigned_away"`
+	ReassignReasons          []ReassignReasonStat `json:"reassign_reasons"`
+	MergedReviews            int                  `json:"merged_reviews"`
+	AverageAssignmentToMerge DurationBreakdown    `json:"average_assignment_to_merge"`
+	OpenLoad                 int                  `json:"open_load"`
+	Team                     TeamReviewComparison `json:"team_comparison"`
+}
+
+type MemberAssignmentCount struct {
+	TeamName    string `json:"team_name"`
+	UserID      string `json:"user_id"`
+	Assignments int    `json:"assignments"`
+}
+
+type TeamFairness struct {
+	TeamName         string   `json:"team_name"`
+	ActiveMembers    int      `json:"active_members"`
+	TotalAssignments int      `json:"total_assignments"`
+	MinAssignments   int      `json:"min_assignments"`
+	MaxAssignments   int      `json:"max_assignments"`
+	MaxMinRatio      *float64 `json:"max_min_ratio"`
+	Gini             float64  `json:"gini"`
+	Imbalanced       bool     `json:"imbalanced"`
+}
+
+type FairnessReport struct {
+	From          time.Time      `json:"from"`
+	To            time.Time      `json:"to"`
+	GiniThreshold float64        `json:"gini_threshold"`
+	Teams         []TeamFairness `json:"teams"`
+}
+
+type TeamOpenPRs struct {
+	TeamName string `json:"team_name"`
+	Open     int    `json:"open"`
+}
+
+type ReviewerLoad struct {
+	UserID   string `json:"user_id"`
+	TeamName string `json:"team_name"`
+	Open     int    `json:"open"`
+}
+
+// SLAOutcome counts PRs by review SLA state. Breached covers PRs merged
+// after their deadline and open PRs already past it; Pending are open PRs
+// that can still make it. BreachRate is Breached out of Met and Breached.
+type SLAOutcome struct {
+	Total      int     `json:"total"`
+	Met        int     `json:"met"`
+	Breached   int     `json:"breached"`
+	Pending    int     `json:"pending"`
+	BreachRate float64 `json:"breach_rate"`
+}
+
+// TeamSLA is the outcome of PRs whose author is in the team.
+type TeamSLA struct {
+	TeamName string `json:"team_name"`
+	SLAOutcome
+}
+
+// ReviewerSLA is the outcome of PRs the user is assigned to review, or was
+// when the PR was merged. A PR the user was reassigned away from after its
+// deadline counts as well, always as breached.
+type ReviewerSLA struct {
+	UserID   string `json:"user_id"`
+	TeamName string `json:"team_name"`
+	SLAOutcome
+}
+
+type SLAReport struct {
+	AsOf       time.Time     `json:"as_of"`
+	Overall    SLAOutcome    `json:"overall"`
+	ByTeam     []TeamSLA     `json:"by_team"`
+	ByReviewer []ReviewerSLA `json:"by_reviewer"`
+}
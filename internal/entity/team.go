@@ -3,6 +3,7 @@ package entity
 type Team struct {
 	Name    string `json:"name"`
 	Members []User `json:"members"`
+	Version int    `json:"version"`
 }
 
 type CreateTeamRequest struct {
@@ -32,6 +33,8 @@ type DeactivateTeamMembersResponse struct {
 	DeactivatedUsers    []string             `json:"deactivated_user_ids"`
 	SuccessfulReassigns []ReassignmentResult `json:"successful_reassignments"`
 	FailedReassigns     []ReassignmentResult `json:"failed_reassignments"`
+	// TeamVersion is the team's version after the deactivation.
+	TeamVersion int `json:"team_version"`
 }
 
 type ReviewerAssignment struct {
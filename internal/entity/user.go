@@ -5,6 +5,7 @@ type User struct {
 	Username string `json:"username"`
 	IsActive bool   `json:"is_active"`
 	TeamName string `json:"team_name"`
+	Version  int    `json:"version"`
 }
 
 type SetUserIsActiveRequest struct {
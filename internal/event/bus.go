@@ -0,0 +1,62 @@
+package event
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type Type string
+
+const (
+	TypeFairnessImbalance Type = "stats.fairness_imbalance"
+	TypeNoCandidate       Type = "review.no_candidate"
+	TypeReviewReminder    Type = "review.reminder"
+	TypeReviewEscalated   Type = "review.escalated"
+	TypeReviewAssigned    Type = "review.assigned"
+	TypeReviewReassigned  Type = "review.reassigned"
+)
+
+type Event struct {
+	Type       Type
+	OccurredAt time.Time
+	Payload    any
+}
+
+type Handler func(ctx context.Context, e Event)
+
+// Bus is an in-process publish/subscribe hub. Handlers run synchronously in
+// the publisher's goroutine, so anything slow must hand off work itself.
+type Bus interface {
+	Publish(ctx context.Context, e Event)
+	Subscribe(t Type, h Handler)
+}
+
+type bus struct {
+	mu       sync.RWMutex
+	handlers map[Type][]Handler
+}
+
+func NewBus() Bus {
+	return &bus{handlers: make(map[Type][]Handler)}
+}
+
+func (b *bus) Publish(ctx context.Context, e Event) {
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = time.Now()
+	}
+
+	b.mu.RLock()
+	handlers := append([]Handler(nil), b.handlers[e.Type]...)
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(ctx, e)
+	}
+}
+
+func (b *bus) Subscribe(t Type, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[t] = append(b.handlers[t], h)
+}
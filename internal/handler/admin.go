@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/service"
+	"github.com/xddprog/avito-test-task/internal/utils"
+)
+
+const zipContentType = "application/zip"
+
+// AdminHandler serves backup, restore, the audit log and the notification
+// outbox. Every request must
+// carry the configured token as "Authorization: Bearer <token>".
+type AdminHandler struct {
+	backupService       service.BackupService
+	auditService        service.AuditService
+	notificationService service.NotificationService
+	token               string
+	maxImportBytes      int64
+}
+
+// NewAdminHandler returns nil when token is empty, which leaves the admin
+// routes unregistered.
+func NewAdminHandler(
+	backupService service.BackupService,
+	auditService service.AuditService,
+	notificationService service.NotificationService,
+	token string,
+	maxImportBytes int64,
+) *AdminHandler {
+	if token == "" {
+		return nil
+	}
+	return &AdminHandler{
+		backupService:       backupService,
+		auditService:        auditService,
+		notificationService: notificationService,
+		token:               token,
+		maxImportBytes:      maxImportBytes,
+	}
+}
+
+func (h *AdminHandler) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+			utils.WriteError(w, r, entity.ErrUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Export writes the backup as JSON, or as a zip of CSV files with
+// format=csv.
+func (h *AdminHandler) Export(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		utils.WriteError(w, r, entity.ErrBadRequest)
+		return
+	}
+	anonymize, err := parseBoolParam(q.Get("anonymize"))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	backup, err := h.backupService.Export(r.Context(), entity.ExportOptions{Anonymize: anonymize})
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	filename := "reviewer-backup-" + backup.ExportedAt.Format("20060102T150405Z")
+	if format == "json" {
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+		utils.WriteOK(w, http.StatusOK, backup)
+		return
+	}
+
+	w.Header().Set("Content-Type", zipContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.zip"`)
+	w.WriteHeader(http.StatusOK)
+	if err := writeBackupZip(w, backup); err != nil {
+		slog.ErrorContext(r.Context(), "failed to write backup archive", "error", err)
+	}
+}
+
+// Import takes the JSON written by Export or its zip of CSV files, picked by
+// Content-Type.
+func (h *AdminHandler) Import(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	dryRun, err := parseBoolParam(q.Get("dry_run"))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	replace, err := parseBoolParam(q.Get("replace"))
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	backup, err := h.decodeBackup(r)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	result, err := h.backupService.Import(r.Context(), backup, entity.ImportOptions{DryRun: dryRun, Replace: replace})
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.WriteOK(w, http.StatusOK, map[string]any{
+		"result": result,
+	})
+}
+
+// Audit lists audit log entries, newest first.
+func (h *AdminHandler) Audit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := entity.AuditFilter{
+		Action:        entity.AuditAction(q.Get("action")),
+		PullRequestID: q.Get("pull_request_id"),
+		UserID:        q.Get("user_id"),
+		TeamName:      q.Get("team_name"),
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(q.Get("from")); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if filter.To, err = parseTimeParam(q.Get("to")); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+	if raw := q.Get("limit"); raw != "" {
+		if filter.Limit, err = strconv.Atoi(raw); err != nil || filter.Limit <= 0 {
+			utils.WriteError(w, r, entity.ErrBadRequest)
+			return
+		}
+	}
+
+	entries, err := h.auditService.List(r.Context(), filter)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.WriteOK(w, http.StatusOK, map[string]any{
+		"entries": entries,
+	})
+}
+
+// Notifications lists the notification outbox, newest first.
+func (h *AdminHandler) Notifications(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := entity.NotificationFilter{
+		UserID: q.Get("user_id"),
+		Status: entity.NotificationStatus(q.Get("status")),
+	}
+	if raw := q.Get("limit"); raw != "" {
+		var err error
+		if filter.Limit, err = strconv.Atoi(raw); err != nil || filter.Limit <= 0 {
+			utils.WriteError(w, r, entity.ErrBadRequest)
+			return
+		}
+	}
+
+	notifications, err := h.notificationService.List(r.Context(), filter)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.WriteOK(w, http.StatusOK, map[string]any{
+		"notifications": notifications,
+	})
+}
+
+func (h *AdminHandler) decodeBackup(r *http.Request) (*entity.Backup, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != zipContentType {
+		var backup entity.Backup
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&backup); err != nil {
+			return nil, decodeError(err)
+		}
+		return &backup, nil
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, decodeError(err)
+	}
+	return readBackupZip(data, h.maxImportBytes)
+}
+
+func parseBoolParam(raw string) (bool, error) {
+	if raw == "" {
+		return false, nil
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, entity.ErrBadRequest
+	}
+	return v, nil
+}
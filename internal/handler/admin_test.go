@@ -0,0 +1,183 @@
+package handler_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/middleware"
+)
+
+// TestBackupRoundTrip moves data between two services through the CSV
+// archive and checks that the second one exports the same data.
+func TestBackupRoundTrip(t *testing.T) {
+	validator, err := middleware.NewOpenAPIValidator(specPath)
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+	source := newContractHandler(t, validator)
+	target := newContractHandler(t, validator)
+
+	send(t, source, http.MethodPost, "/team/add", "application/json",
+		`{"team_name":"backend","members":[{"user_id":"u1","username":"Alice","is_active":true},{"user_id":"u2","username":"Bob, Jr.","is_active":true},{"user_id":"u3","username":"Carol","is_active":false}]}`,
+		http.StatusCreated)
+	send(t, source, http.MethodPost, "/pullRequest/create", "application/json",
+		`{"pull_request_id":"pr-1","pull_request_name":"feature \"quoted\"","author_id":"u1"}`, http.StatusCreated)
+	send(t, source, http.MethodPost, "/pullRequest/create", "application/json",
+		`{"pull_request_id":"pr-2","pull_request_name":"fix","author_id":"u2"}`, http.StatusCreated)
+	send(t, source, http.MethodPost, "/pullRequest/merge", "application/json",
+		`{"pull_request_id":"pr-2"}`, http.StatusOK)
+
+	archive := send(t, source, http.MethodGet, "/admin/export?format=csv", "", "", http.StatusOK)
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("export is not a zip: %v", err)
+	}
+	if len(zr.File) != 5 {
+		t.Fatalf("archive has %d files, want 5", len(zr.File))
+	}
+
+	send(t, target, http.MethodPost, "/admin/import?dry_run=true", "application/zip", string(archive), http.StatusOK)
+	if got := exportJSON(t, target); len(got.Teams) != 0 {
+		t.Fatalf("dry run left data behind: %+v", got)
+	}
+
+	body := send(t, target, http.MethodPost, "/admin/import", "application/zip", string(archive), http.StatusOK)
+	var imported struct {
+		Result entity.ImportResult `json:"result"`
+	}
+	if err := json.Unmarshal(body, &imported); err != nil {
+		t.Fatalf("decode import result: %v", err)
+	}
+	want := entity.ImportResult{Teams: 1, Users: 3, PullRequests: 2, Reviewers: 2}
+	if imported.Result != want {
+		t.Fatalf("import result = %+v, want %+v", imported.Result, want)
+	}
+
+	got, expected := exportJSON(t, target), exportJSON(t, source)
+	got.ExportedAt = expected.ExportedAt
+	if !reflect.DeepEqual(normalizeBackup(got), normalizeBackup(expected)) {
+		t.Fatalf("round trip changed the data:\n got %+v\nwant %+v", got, expected)
+	}
+
+	send(t, target, http.MethodPost, "/admin/import", "application/zip", string(archive), http.StatusConflict)
+	send(t, target, http.MethodPost, "/admin/import?replace=true", "application/zip", string(archive), http.StatusOK)
+	send(t, target, http.MethodPost, "/admin/import", "application/zip", "not a zip", http.StatusBadRequest)
+
+	// Archives exported before sla_deadline was added still import.
+	legacy := rewriteCSV(t, zr, "pull_requests.csv", func(record []string) []string { return record[:len(record)-1] })
+	send(t, target, http.MethodPost, "/admin/import?replace=true", "application/zip", string(legacy), http.StatusOK)
+	for _, pr := range exportJSON(t, target).PullRequests {
+		if pr.SLADeadline != nil {
+			t.Fatalf("legacy import invented an SLA deadline: %+v", pr)
+		}
+	}
+
+	anonymized := exportJSON(t, source, "anonymize=true")
+	for _, u := range anonymized.Users {
+		if !strings.HasPrefix(u.ID, "user-") || u.Username != u.ID || !strings.HasPrefix(u.TeamName, "team-") {
+			t.Fatalf("user %+v was not anonymized", u)
+		}
+	}
+	for _, rv := range anonymized.Reviewers {
+		if !strings.HasPrefix(rv.UserID, "user-") {
+			t.Fatalf("reviewer %+v was not anonymized", rv)
+		}
+	}
+	// Every id maps to the same alias everywhere, so the references resolve.
+	payload, err := json.Marshal(anonymized)
+	if err != nil {
+		t.Fatalf("encode anonymized backup: %v", err)
+	}
+	send(t, target, http.MethodPost, "/admin/import?replace=true", "application/json", string(payload), http.StatusOK)
+	if got := exportJSON(t, target); len(got.Users) != 3 || len(got.Reviewers) != 2 {
+		t.Fatalf("anonymized import lost data: %+v", got)
+	}
+}
+
+// rewriteCSV copies the archive, passing every record of the named file
+// through edit.
+func rewriteCSV(t *testing.T, zr *zip.Reader, name string, edit func([]string) []string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		records, err := csv.NewReader(rc).ReadAll()
+		rc.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", f.Name, err)
+		}
+		w, _ := zw.Create(f.Name)
+		cw := csv.NewWriter(w)
+		for _, record := range records {
+			if f.Name == name {
+				record = edit(record)
+			}
+			_ = cw.Write(record)
+		}
+		cw.Flush()
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func send(t *testing.T, h http.Handler, method, path, contentType, body string, status int) []byte {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != status {
+		t.Fatalf("%s %s: status = %d, want %d: %s", method, path, rec.Code, status, rec.Body)
+	}
+	return rec.Body.Bytes()
+}
+
+func exportJSON(t *testing.T, h http.Handler, query ...string) *entity.Backup {
+	t.Helper()
+	path := "/admin/export"
+	if len(query) > 0 {
+		path += "?" + strings.Join(query, "&")
+	}
+	var backup entity.Backup
+	if err := json.Unmarshal(send(t, h, http.MethodGet, path, "", "", http.StatusOK), &backup); err != nil {
+		t.Fatalf("decode export: %v", err)
+	}
+	return &backup
+}
+
+// normalizeBackup converts times to UTC so backups compare by instant.
+func normalizeBackup(b *entity.Backup) *entity.Backup {
+	for i := range b.PullRequests {
+		pr := &b.PullRequests[i]
+		pr.CreatedAt = pr.CreatedAt.UTC()
+		if pr.MergedAt != nil {
+			mergedAt := pr.MergedAt.UTC()
+			pr.MergedAt = &mergedAt
+		}
+		if pr.SLADeadline != nil {
+			deadline := pr.SLADeadline.UTC()
+			pr.SLADeadline = &deadline
+		}
+	}
+	for i := range b.Reviewers {
+		b.Reviewers[i].AssignedAt = b.Reviewers[i].AssignedAt.UTC()
+	}
+	return b
+}
@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+)
+
+// The zip holds one CSV file per table, each with a header row. Times are
+// RFC 3339 in UTC, an empty merged_at means the PR is not merged and an
+// empty sla_deadline that no review SLA applied.
+const (
+	manifestCSV     = "manifest.csv"
+	teamsCSV        = "teams.csv"
+	usersCSV        = "users.csv"
+	pullRequestsCSV = "pull_requests.csv"
+	reviewersCSV    = "reviewers.csv"
+)
+
+var backupCSVHeaders = map[string][]string{
+	manifestCSV:     {"format_version", "exported_at"},
+	teamsCSV:        {"team_name"},
+	usersCSV:        {"user_id", "username", "team_name", "is_active"},
+	pullRequestsCSV: {"pull_request_id", "pull_request_name", "author_id", "status", "created_at", "merged_at", "sla_deadline"},
+	reviewersCSV:    {"pull_request_id", "user_id", "assigned_at"},
+}
+
+// backupCSVAddedColumns counts the columns appended to a file since the
+// format was introduced. Archives without them still import, as if the
+// cells were empty.
+var backupCSVAddedColumns = map[string]int{
+	pullRequestsCSV: 1,
+}
+
+func writeBackupZip(w io.Writer, backup *entity.Backup) error {
+	zw := zip.NewWriter(w)
+
+	files := []struct {
+		name string
+		rows [][]string
+	}{
+		{manifestCSV, [][]string{{strconv.Itoa(backup.FormatVersion), formatCSVTime(backup.ExportedAt)}}},
+		{teamsCSV, mapRows(backup.Teams, func(t entity.BackupTeam) []string {
+			return []string{t.Name}
+		})},
+		{usersCSV, mapRows(backup.Users, func(u entity.BackupUser) []string {
+			return []string{u.ID, u.Username, u.TeamName, strconv.FormatBool(u.IsActive)}
+		})},
+		{pullRequestsCSV, mapRows(backup.PullRequests, func(pr entity.BackupPullRequest) []string {
+			return []string{pr.ID, pr.Name, pr.AuthorID, string(pr.Status), formatCSVTime(pr.CreatedAt),
+				formatOptionalCSVTime(pr.MergedAt), formatOptionalCSVTime(pr.SLADeadline)}
+		})},
+		{reviewersCSV, mapRows(backup.Reviewers, func(rv entity.BackupReviewer) []string {
+			return []string{rv.PullRequestID, rv.UserID, formatCSVTime(rv.AssignedAt)}
+		})},
+	}
+
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: backup.ExportedAt})
+		if err != nil {
+			return err
+		}
+		cw := csv.NewWriter(fw)
+		if err := cw.Write(backupCSVHeaders[f.name]); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(f.rows); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// readBackupZip parses a zip written by writeBackupZip. Each file is read
+// through a limit of maxBytes, so a zip bomb fails with PAYLOAD_TOO_LARGE.
+func readBackupZip(data []byte, maxBytes int64) (*entity.Backup, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, invalidBackupFile("not a zip archive")
+	}
+
+	tables := make(map[string][][]string, len(backupCSVHeaders))
+	for _, f := range zr.File {
+		header, ok := backupCSVHeaders[f.Name]
+		if !ok {
+			continue
+		}
+		rows, err := readCSVFile(f, maxBytes)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 || !knownHeader(rows[0], header, backupCSVAddedColumns[f.Name]) {
+			return nil, invalidBackupFile("%s: expected header %v", f.Name, header)
+		}
+		for i, row := range rows[1:] {
+			rows[i+1] = append(row, make([]string, len(header)-len(row))...)
+		}
+		tables[f.Name] = rows[1:]
+	}
+	for name := range backupCSVHeaders {
+		if _, ok := tables[name]; !ok {
+			return nil, invalidBackupFile("%s is missing", name)
+		}
+	}
+
+	manifest := tables[manifestCSV]
+	if len(manifest) != 1 {
+		return nil, invalidBackupFile("%s: expected one row", manifestCSV)
+	}
+	backup := &entity.Backup{
+		Teams:        []entity.BackupTeam{},
+		Users:        []entity.BackupUser{},
+		PullRequests: []entity.BackupPullRequest{},
+		Reviewers:    []entity.BackupReviewer{},
+	}
+	var p csvParser
+	p.start(manifestCSV)
+	p.next()
+	backup.FormatVersion = p.int(manifest[0][0])
+	backup.ExportedAt = p.time(manifest[0][1])
+
+	for _, row := range tables[teamsCSV] {
+		backup.Teams = append(backup.Teams, entity.BackupTeam{Name: row[0]})
+	}
+	p.start(usersCSV)
+	for _, row := range tables[usersCSV] {
+		p.next()
+		backup.Users = append(backup.Users, entity.BackupUser{ID: row[0], Username: row[1], TeamName: row[2], IsActive: p.bool(row[3])})
+	}
+	p.start(pullRequestsCSV)
+	for _, row := range tables[pullRequestsCSV] {
+		p.next()
+		pr := entity.BackupPullRequest{ID: row[0], Name: row[1], AuthorID: row[2], Status: entity.PRStatus(row[3]), CreatedAt: p.time(row[4])}
+		pr.MergedAt = p.optionalTime(row[5])
+		pr.SLADeadline = p.optionalTime(row[6])
+		backup.PullRequests = append(backup.PullRequests, pr)
+	}
+	p.start(reviewersCSV)
+	for _, row := range tables[reviewersCSV] {
+		p.next()
+		backup.Reviewers = append(backup.Reviewers, entity.BackupReviewer{PullRequestID: row[0], UserID: row[1], AssignedAt: p.time(row[2])})
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return backup, nil
+}
+
+func readCSVFile(f *zip.File, maxBytes int64) ([][]string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, invalidBackupFile("%s: %v", f.Name, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxBytes+1))
+	if err != nil {
+		return nil, invalidBackupFile("%s: %v", f.Name, err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, entity.ErrPayloadTooLarge
+	}
+
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, invalidBackupFile("%s: line %d: %v", f.Name, parseErr.Line, parseErr.Err)
+		}
+		return nil, invalidBackupFile("%s: %v", f.Name, err)
+	}
+	return rows, nil
+}
+
+// csvParser converts cells and keeps the first error with its position.
+// Line 1 of every file is the header.
+type csvParser struct {
+	file string
+	line int
+	err  error
+}
+
+func (p *csvParser) start(file string) {
+	p.file, p.line = file, 1
+}
+
+func (p *csvParser) next() {
+	p.line++
+}
+
+func (p *csvParser) fail(value, kind string) {
+	if p.err == nil {
+		p.err = invalidBackupFile("%s: line %d: %q is not a valid %s", p.file, p.line, value, kind)
+	}
+}
+
+func (p *csvParser) int(value string) int {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		p.fail(value, "integer")
+	}
+	return n
+}
+
+func (p *csvParser) bool(value string) bool {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		p.fail(value, "boolean")
+	}
+	return b
+}
+
+func (p *csvParser) time(value string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		p.fail(value, "RFC 3339 time")
+	}
+	return t
+}
+
+func (p *csvParser) optionalTime(value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	t := p.time(value)
+	return &t
+}
+
+// knownHeader reports whether got is header, possibly without up to added
+// trailing columns.
+func knownHeader(got, header []string, added int) bool {
+	return len(got) >= len(header)-added && slices.Equal(got, header[:len(got)])
+}
+
+func formatCSVTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func formatOptionalCSVTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatCSVTime(*t)
+}
+
+func mapRows[T any](items []T, row func(T) []string) [][]string {
+	rows := make([][]string, 0, len(items))
+	for _, item := range items {
+		rows = append(rows, row(item))
+	}
+	return rows
+}
+
+func invalidBackupFile(format string, args ...any) error {
+	return entity.New(http.StatusBadRequest, entity.ErrBadRequest.SafeCode, "invalid backup file: "+fmt.Sprintf(format, args...))
+}
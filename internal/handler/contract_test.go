@@ -0,0 +1,311 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/xddprog/avito-test-task/internal/config"
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/event"
+	"github.com/xddprog/avito-test-task/internal/handler"
+	"github.com/xddprog/avito-test-task/internal/metrics"
+	"github.com/xddprog/avito-test-task/internal/middleware"
+	"github.com/xddprog/avito-test-task/internal/notify"
+	"github.com/xddprog/avito-test-task/internal/repository"
+	"github.com/xddprog/avito-test-task/internal/service"
+)
+
+const (
+	specPath   = "../../api/openapi.yml"
+	adminToken = "admin-secret"
+)
+
+// contractCase is one request in a scenario that runs in order against the
+// same in-memory service.
+type contractCase struct {
+	name    string
+	method  string
+	path    string
+	body    string
+	headers map[string]string
+	status  int
+	code    string
+}
+
+// TestContract checks every response of the router, behind the same request
+// validation as in production, against the schemas in api/openapi.yml, and
+// that every operation in the spec is exercised.
+func TestContract(t *testing.T) {
+	validator, err := middleware.NewOpenAPIValidator(specPath)
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+	h := newContractHandler(t, validator)
+	admin := map[string]string{"Authorization": "Bearer " + adminToken}
+
+	cases := []contractCase{
+		{name: "add team", method: http.MethodPost, path: "/team/add", status: http.StatusCreated,
+			body: `{"team_name":"backend","members":[{"user_id":"u1","username":"Alice","is_active":true},{"user_id":"u2","username":"Bob","is_active":true},{"user_id":"u3","username":"Carol","is_active":true},{"user_id":"u4","username":"Dave","is_active":false}]}`},
+		{name: "add duplicate team", method: http.MethodPost, path: "/team/add", status: http.StatusBadRequest, code: "TEAM_EXISTS",
+			body: `{"team_name":"backend","members":[]}`},
+		{name: "add team without members", method: http.MethodPost, path: "/team/add", status: http.StatusBadRequest, code: "BAD_REQUEST",
+			body: `{"team_name":"frontend"}`},
+		{name: "add team with wrong types", method: http.MethodPost, path: "/team/add", status: http.StatusBadRequest, code: "BAD_REQUEST",
+			body: `{"team_name":"frontend","members":[{"user_id":1,"username":"x","is_active":true}]}`},
+		{name: "add team with oversized body", method: http.MethodPost, path: "/team/add", status: http.StatusRequestEntityTooLarge, code: "PAYLOAD_TOO_LARGE",
+			body: `{"team_name":"` + strings.Repeat("x", 4096) + `","members":[]}`},
+
+		{name: "get team", method: http.MethodGet, path: "/team/get?team_name=backend", status: http.StatusOK},
+		{name: "get unchanged team", method: http.MethodGet, path: "/team/get?team_name=backend", status: http.StatusNotModified,
+			headers: map[string]string{"If-None-Match": `"1"`}},
+		{name: "get team without name", method: http.MethodGet, path: "/team/get", status: http.StatusBadRequest, code: "BAD_REQUEST"},
+		{name: "get unknown team", method: http.MethodGet, path: "/team/get?team_name=nope", status: http.StatusNotFound, code: "NOT_FOUND"},
+
+		{name: "create pr", method: http.MethodPost, path: "/pullRequest/create", status: http.StatusCreated,
+			body: `{"pull_request_id":"pr-1","pull_request_name":"feature","author_id":"u1"}`},
+		{name: "create duplicate pr", method: http.MethodPost, path: "/pullRequest/create", status: http.StatusConflict, code: "PR_EXISTS",
+			body: `{"pull_request_id":"pr-1","pull_request_name":"feature","author_id":"u1"}`},
+		{name: "create pr for unknown author", method: http.MethodPost, path: "/pullRequest/create", status: http.StatusNotFound, code: "NOT_FOUND",
+			body: `{"pull_request_id":"pr-2","pull_request_name":"feature","author_id":"nobody"}`},
+		{name: "create pr without name", method: http.MethodPost, path: "/pullRequest/create", status: http.StatusBadRequest, code: "BAD_REQUEST",
+			body: `{"pull_request_id":"pr-2","author_id":"u1"}`},
+		{name: "create pr with malformed json", method: http.MethodPost, path: "/pullRequest/create", status: http.StatusBadRequest, code: "BAD_REQUEST",
+			body: `{"pull_request_id":`},
+
+		{name: "activate user", method: http.MethodPost, path: "/users/setIsActive", status: http.StatusOK,
+			body: `{"user_id":"u4","is_active":true}`},
+		{name: "set active with stale version", method: http.MethodPost, path: "/users/setIsActive", status: http.StatusPreconditionFailed, code: "PRECONDITION_FAILED",
+			body: `{"user_id":"u4","is_active":false}`, headers: map[string]string{"If-Match": `"1"`}},
+		{name: "set active for unknown user", method: http.MethodPost, path: "/users/setIsActive", status: http.StatusNotFound, code: "NOT_FOUND",
+			body: `{"user_id":"nobody","is_active":true}`},
+		{name: "set active without flag", method: http.MethodPost, path: "/users/setIsActive", status: http.StatusBadRequest, code: "BAD_REQUEST",
+			body: `{"user_id":"u4"}`},
+
+		{name: "get reviews", method: http.MethodGet, path: "/users/getReview?user_id=u2", status: http.StatusOK},
+		{name: "get reviews without user", method: http.MethodGet, path: "/users/getReview", status: http.StatusBadRequest, code: "BAD_REQUEST"},
+
+		{name: "get default notification settings", method: http.MethodGet, path: "/users/getNotificationSettings?user_id=u2", status: http.StatusOK},
+		{name: "get notification settings of unknown user", method: http.MethodGet, path: "/users/getNotificationSettings?user_id=nobody", status: http.StatusNotFound, code: "NOT_FOUND"},
+		{name: "set notification settings", method: http.MethodPost, path: "/users/setNotificationSettings", status: http.StatusOK,
+			body: `{"user_id":"u2","channels":["log","email","log"],"email":"bob@example.com","quiet_hours":{"start":"22:00","end":"08:00","timezone":"Europe/Moscow"}}`},
+		{name: "set unconfigured channel", method: http.MethodPost, path: "/users/setNotificationSettings", status: http.StatusBadRequest, code: "BAD_REQUEST",
+			body: `{"user_id":"u2","channels":["chat"]}`},
+		{name: "set email channel without address", method: http.MethodPost, path: "/users/setNotificationSettings", status: http.StatusBadRequest, code: "BAD_REQUEST",
+			body: `{"user_id":"u2","channels":["email"]}`},
+		{name: "set malformed quiet hours", method: http.MethodPost, path: "/users/setNotificationSettings", status: http.StatusBadRequest, code: "BAD_REQUEST",
+			body: `{"user_id":"u2","channels":[],"quiet_hours":{"start":"25:00","end":"08:00"}}`},
+
+		{name: "reassign", method: http.MethodPost, path: "/pullRequest/reassign", status: http.StatusOK,
+			body: `{"pull_request_id":"pr-1","old_user_id":"u2"}`},
+		{name: "reassign author", method: http.MethodPost, path: "/pullRequest/reassign", status: http.StatusConflict, code: "NOT_ASSIGNED",
+			body: `{"pull_request_id":"pr-1","old_user_id":"u1"}`},
+		{name: "reassign unknown pr", method: http.MethodPost, path: "/pullRequest/reassign", status: http.StatusNotFound, code: "NOT_FOUND",
+			body: `{"pull_request_id":"nope","old_user_id":"u1"}`},
+		{name: "reassign without user", method: http.MethodPost, path: "/pullRequest/reassign", status: http.StatusBadRequest, code: "BAD_REQUEST",
+			body: `{"pull_request_id":"pr-1"}`},
+
+		{name: "idempotent create", method: http.MethodPost, path: "/pullRequest/create", status: http.StatusCreated,
+			body: `{"pull_request_id":"pr-3","pull_request_name":"idempotent","author_id":"u2"}`, headers: map[string]string{"Idempotency-Key": "k1"}},
+		{name: "idempotency key reused", method: http.MethodPost, path: "/pullRequest/create", status: http.StatusUnprocessableEntity, code: "IDEMPOTENCY_KEY_REUSED",
+			body: `{"pull_request_id":"pr-4","pull_request_name":"other","author_id":"u2"}`, headers: map[string]string{"Idempotency-Key": "k1"}},
+		{name: "idempotency key of another client", method: http.MethodPost, path: "/pullRequest/create", status: http.StatusCreated,
+			body: `{"pull_request_id":"pr-4","pull_request_name":"other","author_id":"u2"}`, headers: map[string]string{"Idempotency-Key": "k1", "X-API-Key": "other"}},
+		{name: "idempotency key with an unknown api key", method: http.MethodPost, path: "/pullRequest/create", status: http.StatusUnprocessableEntity, code: "IDEMPOTENCY_KEY_REUSED",
+			body: `{"pull_request_id":"pr-5","pull_request_name":"made up","author_id":"u2"}`, headers: map[string]string{"Idempotency-Key": "k1", "X-API-Key": "made-up"}},
+
+		{name: "deactivate", method: http.MethodPost, path: "/team/deactivate", status: http.StatusOK,
+			body: `{"team_name":"backend","user_ids":["u3"]}`},
+		{name: "deactivate nobody", method: http.MethodPost, path: "/team/deactivate", status: http.StatusBadRequest, code: "BAD_REQUEST",
+			body: `{"team_name":"backend","user_ids":[]}`},
+		{name: "deactivate with stale version", method: http.MethodPost, path: "/team/deactivate", status: http.StatusPreconditionFailed, code: "PRECONDITION_FAILED",
+			body: `{"team_name":"backend","user_ids":["u4"]}`, headers: map[string]string{"If-Match": `"1"`}},
+
+		{name: "merge", method: http.MethodPost, path: "/pullRequest/merge", status: http.StatusOK,
+			body: `{"pull_request_id":"pr-1"}`},
+		{name: "merge again", method: http.MethodPost, path: "/pullRequest/merge", status: http.StatusOK,
+			body: `{"pull_request_id":"pr-1"}`},
+		{name: "merge with stale version", method: http.MethodPost, path: "/pullRequest/merge", status: http.StatusPreconditionFailed, code: "PRECONDITION_FAILED",
+			body: `{"pull_request_id":"pr-3"}`, headers: map[string]string{"If-Match": `"7"`}},
+		{name: "merge unknown pr", method: http.MethodPost, path: "/pullRequest/merge", status: http.StatusNotFound, code: "NOT_FOUND",
+			body: `{"pull_request_id":"nope"}`},
+		{name: "reassign merged pr", method: http.MethodPost, path: "/pullRequest/reassign", status: http.StatusConflict, code: "PR_MERGED",
+			body: `{"pull_request_id":"pr-1","old_user_id":"u4"}`},
+
+		{name: "bulk create", method: http.MethodPost, path: "/pullRequest/bulkCreate", status: http.StatusOK,
+			body: `{"pull_requests":[{"pull_request_id":"pr-10","pull_request_name":"kept","author_id":"u1","assigned_reviewers":["u2"],"createdAt":"2025-10-01T09:30:00Z"},{"pull_request_id":"pr-11","pull_request_name":"auto","author_id":"u1"},{"pull_request_id":"pr-1","pull_request_name":"dup","author_id":"u1"}]}`},
+		{name: "bulk create ndjson", method: http.MethodPost, path: "/pullRequest/bulkCreate", status: http.StatusOK,
+			body:    "{\"pull_request_id\":\"pr-12\",\"pull_request_name\":\"a\",\"author_id\":\"u2\"}\n{\"pull_request_id\":\"pr-13\",\"pull_request_name\":\"b\",\"author_id\":\"nobody\"}\n",
+			headers: map[string]string{"Content-Type": "application/x-ndjson"}},
+		{name: "bulk create nothing", method: http.MethodPost, path: "/pullRequest/bulkCreate", status: http.StatusBadRequest, code: "BAD_REQUEST",
+			body: `{"pull_requests":[]}`},
+		{name: "bulk create without author", method: http.MethodPost, path: "/pullRequest/bulkCreate", status: http.StatusBadRequest, code: "BAD_REQUEST",
+			body: "{\"pull_request_id\":\"pr-14\",\"pull_request_name\":\"c\"}\n", headers: map[string]string{"Content-Type": "application/x-ndjson"}},
+
+		{name: "stats summary", method: http.MethodGet, path: "/stats/summary?team_name=backend&stale_after_days=3", status: http.StatusOK},
+		{name: "stats summary with bad threshold", method: http.MethodGet, path: "/stats/summary?stale_after_days=0", status: http.StatusBadRequest, code: "BAD_REQUEST"},
+		{name: "stats summary with reversed window", method: http.MethodGet, path: "/stats/summary?from=2025-02-01&to=2025-01-01", status: http.StatusBadRequest, code: "BAD_REQUEST"},
+		{name: "stats timeseries", method: http.MethodGet, path: "/stats/timeseries?interval=day&by_team=true", status: http.StatusOK},
+		{name: "stats timeseries with bad interval", method: http.MethodGet, path: "/stats/timeseries?interval=hour", status: http.StatusBadRequest, code: "BAD_REQUEST"},
+		{name: "stats user", method: http.MethodGet, path: "/stats/user?user_id=u2", status: http.StatusOK},
+		{name: "stats unknown user", method: http.MethodGet, path: "/stats/user?user_id=nobody", status: http.StatusNotFound, code: "NOT_FOUND"},
+		{name: "stats user without id", method: http.MethodGet, path: "/stats/user", status: http.StatusBadRequest, code: "BAD_REQUEST"},
+		{name: "stats fairness", method: http.MethodGet, path: "/stats/fairness", status: http.StatusOK},
+		{name: "stats summary as csv", method: http.MethodGet, path: "/stats/summary?team_name=backend", status: http.StatusOK,
+			headers: map[string]string{"Accept": "text/csv"}},
+		{name: "stats timeseries as csv", method: http.MethodGet, path: "/stats/timeseries?interval=week", status: http.StatusOK,
+			headers: map[string]string{"Accept": "text/csv, application/json;q=0.5"}},
+		{name: "stats user as csv", method: http.MethodGet, path: "/stats/user?user_id=u2", status: http.StatusOK,
+			headers: map[string]string{"Accept": "text/*"}},
+		{name: "stats fairness as csv", method: http.MethodGet, path: "/stats/fairness", status: http.StatusOK,
+			headers: map[string]string{"Accept": "text/csv"}},
+		{name: "stats csv error stays json", method: http.MethodGet, path: "/stats/user?user_id=nobody", status: http.StatusNotFound, code: "NOT_FOUND",
+			headers: map[string]string{"Accept": "text/csv"}},
+		{name: "stats sla", method: http.MethodGet, path: "/stats/sla?team_name=backend", status: http.StatusOK},
+		{name: "stats sla as csv", method: http.MethodGet, path: "/stats/sla", status: http.StatusOK,
+			headers: map[string]string{"Accept": "text/csv"}},
+		{name: "stats sla with reversed window", method: http.MethodGet, path: "/stats/sla?from=2025-02-01&to=2025-01-01", status: http.StatusBadRequest, code: "BAD_REQUEST"},
+		{name: "stats report", method: http.MethodGet, path: "/stats/report.xlsx?team_name=backend&stale_after_days=3", status: http.StatusOK},
+		{name: "stats report with bad threshold", method: http.MethodGet, path: "/stats/report.xlsx?stale_after_days=0", status: http.StatusBadRequest, code: "BAD_REQUEST"},
+
+		{name: "export without token", method: http.MethodGet, path: "/admin/export", status: http.StatusUnauthorized, code: "UNAUTHORIZED"},
+		{name: "export", method: http.MethodGet, path: "/admin/export?anonymize=true", status: http.StatusOK, headers: admin},
+		{name: "export csv", method: http.MethodGet, path: "/admin/export?format=csv", status: http.StatusOK, headers: admin},
+		{name: "export in unknown format", method: http.MethodGet, path: "/admin/export?format=xml", status: http.StatusBadRequest, code: "BAD_REQUEST", headers: admin},
+		{name: "import dry run", method: http.MethodPost, path: "/admin/import?dry_run=true&replace=true", status: http.StatusOK, headers: admin,
+			body: `{"format_version":1,"exported_at":"2025-01-01T00:00:00Z","teams":[{"team_name":"backend"}],"users":[{"user_id":"u1","username":"Alice","team_name":"backend","is_active":true}],"pull_requests":[],"reviewers":[]}`},
+		{name: "import conflicting team", method: http.MethodPost, path: "/admin/import", status: http.StatusConflict, code: "IMPORT_CONFLICT", headers: admin,
+			body: `{"format_version":1,"exported_at":"2025-01-01T00:00:00Z","teams":[{"team_name":"backend"}],"users":[],"pull_requests":[],"reviewers":[]}`},
+		{name: "import unknown format version", method: http.MethodPost, path: "/admin/import", status: http.StatusBadRequest, code: "BAD_REQUEST", headers: admin,
+			body: `{"format_version":2,"exported_at":"2025-01-01T00:00:00Z","teams":[],"users":[],"pull_requests":[],"reviewers":[]}`},
+		{name: "import", method: http.MethodPost, path: "/admin/import", status: http.StatusOK, headers: admin,
+			body: `{"format_version":1,"exported_at":"2025-01-01T00:00:00Z","teams":[{"team_name":"ops"}],"users":[{"user_id":"o1","username":"Olga","team_name":"ops","is_active":true},{"user_id":"o2","username":"Oleg","team_name":"ops","is_active":true}],"pull_requests":[{"pull_request_id":"ops-1","pull_request_name":"infra","author_id":"o1","status":"MERGED","created_at":"2025-01-01T00:00:00Z","merged_at":"2025-01-02T00:00:00Z"}],"reviewers":[{"pull_request_id":"ops-1","user_id":"o2","assigned_at":"2025-01-01T00:00:00Z"}]}`},
+		{name: "audit without token", method: http.MethodGet, path: "/admin/audit", status: http.StatusUnauthorized, code: "UNAUTHORIZED"},
+		{name: "audit", method: http.MethodGet, path: "/admin/audit?action=REVIEW_REMINDER&from=2025-01-01&limit=10", status: http.StatusOK, headers: admin},
+		{name: "audit with inverted window", method: http.MethodGet, path: "/admin/audit?from=2025-02-01&to=2025-01-01", status: http.StatusBadRequest, code: "BAD_REQUEST", headers: admin},
+		{name: "audit with unknown action", method: http.MethodGet, path: "/admin/audit?action=DELETED", status: http.StatusBadRequest, code: "BAD_REQUEST", headers: admin},
+		{name: "notifications without token", method: http.MethodGet, path: "/admin/notifications", status: http.StatusUnauthorized, code: "UNAUTHORIZED"},
+		{name: "notifications", method: http.MethodGet, path: "/admin/notifications?user_id=u2&status=PENDING&limit=10", status: http.StatusOK, headers: admin},
+		{name: "notifications with unknown status", method: http.MethodGet, path: "/admin/notifications?status=LOST", status: http.StatusBadRequest, code: "BAD_REQUEST", headers: admin},
+
+		{name: "health", method: http.MethodGet, path: "/health", status: http.StatusOK},
+		{name: "liveness", method: http.MethodGet, path: "/health/live", status: http.StatusOK},
+		{name: "readiness", method: http.MethodGet, path: "/health/ready", status: http.StatusOK},
+		{name: "metrics", method: http.MethodGet, path: "/metrics", status: http.StatusOK},
+	}
+
+	covered := make(map[string]bool)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if tc.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			route := validator.Route(req)
+			if route == nil {
+				t.Fatalf("%s %s is not described in %s", tc.method, req.URL.Path, specPath)
+			}
+
+			// The router reads the body, keep a copy for response validation.
+			input := &openapi3filter.RequestValidationInput{
+				Request:    req.Clone(context.Background()),
+				PathParams: map[string]string{},
+				Route:      route,
+				Options:    &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc},
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			resp := rec.Result()
+			body, _ := io.ReadAll(resp.Body)
+
+			if resp.StatusCode != tc.status {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tc.status, body)
+			}
+			if tc.code != "" {
+				var envelope struct {
+					Error struct {
+						Code string `json:"code"`
+					} `json:"error"`
+				}
+				if err := json.Unmarshal(body, &envelope); err != nil || envelope.Error.Code != tc.code {
+					t.Fatalf("error code = %q, want %q: %s", envelope.Error.Code, tc.code, body)
+				}
+			}
+
+			err := openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: input,
+				Status:                 resp.StatusCode,
+				Header:                 resp.Header,
+				Body:                   io.NopCloser(bytes.NewReader(body)),
+				Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+			})
+			if err != nil {
+				t.Fatalf("response does not match the spec: %v\nbody: %s", err, body)
+			}
+			if resp.StatusCode < http.StatusBadRequest {
+				covered[tc.method+" "+route.Path] = true
+			}
+		})
+	}
+
+	for _, op := range validator.Operations() {
+		if !covered[op] {
+			t.Errorf("%s has no successful contract case", op)
+		}
+	}
+}
+
+// newContractHandler assembles the production middleware that shapes
+// requests and responses, over in-memory storage.
+func newContractHandler(t *testing.T, validator *middleware.OpenAPIValidator) http.Handler {
+	t.Helper()
+	store := repository.NewMemoryStore()
+	events := event.NewBus()
+	uow := store.UnitOfWork()
+
+	templates, err := notify.LoadTemplates("")
+	if err != nil {
+		t.Fatalf("load templates: %v", err)
+	}
+	notifications := service.NewNotificationService(store.Notifications(), store.Users(), store.PullRequests(), service.NotificationOptions{
+		Channels: map[entity.NotificationChannel]notify.Channel{
+			entity.ChannelLog:   notify.NewLogChannel(slog.New(slog.DiscardHandler)),
+			entity.ChannelEmail: notify.NewSMTPChannel(notify.SMTPConfig{Host: "127.0.0.1", Port: 25, From: "reviews@example.com"}),
+		},
+		DefaultChannels: []entity.NotificationChannel{entity.ChannelLog},
+		Templates:       templates,
+	})
+	notifications.Subscribe(events)
+
+	prService := service.NewPullRequestService(uow, store.Users(), events, service.SLAOptions{Default: service.SLAPolicy{Target: 24 * time.Hour}})
+	router := handler.NewRouter(
+		handler.NewUserHandler(service.NewUserService(uow, store.Users())),
+		handler.NewNotificationHandler(notifications),
+		handler.NewTeamHandler(service.NewTeamService(uow, store.Teams(), prService, events)),
+		handler.NewPullRequestHandler(prService),
+		handler.NewStatsHandler(service.NewStatsService(store.Stats(), store.Audit(), events, service.FairnessOptions{GiniThreshold: 0.4, Window: 24 * time.Hour})),
+		handler.NewHealthHandler(map[string]handler.ReadinessCheck{}),
+		handler.NewAdminHandler(service.NewBackupService(uow, store.Backup()), service.NewAuditService(store.Audit()), notifications, adminToken, 64<<10),
+		metrics.New().Handler(),
+		specPath,
+	)
+	idempotency := service.NewIdempotencyService(store.Idempotency(), time.Hour, time.Minute)
+
+	return middleware.BodyLimits{Default: 1024, Routes: map[string]int64{"/admin/import": 64 << 10}}.Middleware(
+		validator.Middleware(
+			middleware.IdempotencyMiddleware(idempotency, middleware.ClientIdentity(config.RateLimitConfig{APIKeyHeader: "X-API-Key", APIKeys: "other"}), router),
+		),
+	)
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+)
+
+// decodeBody reads a JSON request body, reporting bodies cut off by
+// BodyLimitMiddleware as PAYLOAD_TOO_LARGE rather than BAD_REQUEST.
+func decodeBody(r *http.Request, dst any) error {
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	return nil
+}
+
+// ndjsonContentType is the media type for newline-delimited JSON.
+const ndjsonContentType = "application/x-ndjson"
+
+func isNDJSON(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == ndjsonContentType
+}
+
+// decodeNDJSON reads one JSON value per line until the body ends.
+func decodeNDJSON[T any](r *http.Request) ([]T, error) {
+	dec := json.NewDecoder(r.Body)
+	items := []T{}
+	for {
+		var item T
+		err := dec.Decode(&item)
+		if errors.Is(err, io.EOF) {
+			return items, nil
+		}
+		if err != nil {
+			return nil, decodeError(err)
+		}
+		items = append(items, item)
+	}
+}
+
+func decodeError(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return entity.ErrPayloadTooLarge
+	}
+	return entity.ErrBadRequest
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+)
+
+// ETags carry the entity version: `"3"` is version 3 of whatever the
+// endpoint returns.
+
+func setETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", formatETag(version))
+}
+
+func formatETag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// ifMatchVersion returns the version required by If-Match, or 0 when the
+// header is absent or "*". Weak tags never match, per RFC 9110.
+func ifMatchVersion(r *http.Request) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	if strings.HasPrefix(header, "W/") {
+		return 0, entity.ErrPreconditionFailed
+	}
+	unquoted, err := strconv.Unquote(header)
+	if err != nil {
+		return 0, entity.ErrBadRequest
+	}
+	version, err := strconv.Atoi(unquoted)
+	if err != nil || version < 1 {
+		return 0, entity.ErrBadRequest
+	}
+	return version, nil
+}
+
+// versionError reports a version conflict as 412 when the request carried
+// If-Match: the client's precondition failed, whether the entity changed
+// before the request or during it. Without If-Match a conflict stays 409.
+func versionError(err error, expectedVersion int) error {
+	if expectedVersion != 0 && errors.Is(err, entity.ErrConflictVersion) {
+		return entity.ErrPreconditionFailed
+	}
+	return err
+}
@@ -1,19 +1,68 @@
 package handler
 
 import (
+	"context"
 	"net/http"
+	"sync/atomic"
+	"time"
 
 	"github.com/xddprog/avito-test-task/internal/utils"
 )
 
-type HealthHandler struct{}
+const readinessTimeout = 2 * time.Second
 
-func NewHealthHandler() *HealthHandler {
-	return &HealthHandler{}
+// ReadinessCheck reports whether a dependency is usable; a nil error means ready.
+type ReadinessCheck func(ctx context.Context) error
+
+type HealthHandler struct {
+	checks   map[string]ReadinessCheck
+	draining atomic.Bool
+}
+
+func NewHealthHandler(checks map[string]ReadinessCheck) *HealthHandler {
+	return &HealthHandler{checks: checks}
 }
 
-func (h *HealthHandler) Check(w http.ResponseWriter, _ *http.Request) {
-	utils.WriteOK(w, 200, map[string]string{
+// StartDraining makes readiness fail so load balancers stop sending traffic
+// while in-flight requests finish.
+func (h *HealthHandler) StartDraining() {
+	h.draining.Store(true)
+}
+
+func (h *HealthHandler) Live(w http.ResponseWriter, _ *http.Request) {
+	utils.WriteOK(w, http.StatusOK, map[string]string{
 		"status": "OK",
 	})
 }
+
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	if h.draining.Load() {
+		utils.WriteOK(w, http.StatusServiceUnavailable, map[string]any{
+			"status": "DRAINING",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	status := http.StatusOK
+	results := make(map[string]string, len(h.checks))
+	for name, check := range h.checks {
+		if err := check(ctx); err != nil {
+			status = http.StatusServiceUnavailable
+			results[name] = err.Error()
+			continue
+		}
+		results[name] = "OK"
+	}
+
+	overall := "OK"
+	if status != http.StatusOK {
+		overall = "UNAVAILABLE"
+	}
+	utils.WriteOK(w, status, map[string]any{
+		"status": overall,
+		"checks": results,
+	})
+}
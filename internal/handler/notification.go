@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/service"
+	"github.com/xddprog/avito-test-task/internal/utils"
+)
+
+type NotificationHandler struct {
+	notificationService service.NotificationService
+}
+
+func NewNotificationHandler(notificationService service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{notificationService: notificationService}
+}
+
+func (h *NotificationHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		utils.WriteError(w, r, entity.ErrBadRequest)
+		return
+	}
+
+	settings, err := h.notificationService.GetSettings(r.Context(), userID)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.WriteOK(w, http.StatusOK, map[string]any{
+		"settings": settings,
+	})
+}
+
+func (h *NotificationHandler) SetSettings(w http.ResponseWriter, r *http.Request) {
+	var req entity.NotificationSettings
+
+	if err := decodeBody(r, &req); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	settings, err := h.notificationService.SaveSettings(r.Context(), &req)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.WriteOK(w, http.StatusOK, map[string]any{
+		"settings": settings,
+	})
+}
@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"encoding/json"
 	"net/http"
 
 	"github.com/xddprog/avito-test-task/internal/entity"
@@ -19,45 +18,79 @@ func NewPullRequestHandler(prService service.PullRequestService) *PullRequestHan
 
 func (h *PullRequestHandler) CreatePullRequest(w http.ResponseWriter, r *http.Request) {
 	var req entity.CreatePRRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.WriteError(w, entity.ErrBadRequest)
+	if err := decodeBody(r, &req); err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	if req.ID == "" || req.Name == "" || req.AuthorID == "" {
-		utils.WriteError(w, entity.ErrBadRequest)
+		utils.WriteError(w, r, entity.ErrBadRequest)
 		return
 	}
 
 	pr, err := h.prService.Create(r.Context(), &req)
 	if err != nil {
-		utils.WriteError(w, err)
+		utils.WriteError(w, r, err)
 		return
 	}
 
+	setETag(w, pr.Version)
 	utils.WriteOK(w, http.StatusCreated, map[string]any{
 		"pr": pr,
 	})
 }
 
+// BulkCreatePullRequests takes {"pull_requests": [...]} as JSON or one PR
+// per line as application/x-ndjson.
+func (h *PullRequestHandler) BulkCreatePullRequests(w http.ResponseWriter, r *http.Request) {
+	var req entity.BulkCreatePRRequest
+	var err error
+	if isNDJSON(r) {
+		req.PullRequests, err = decodeNDJSON[entity.BulkCreatePRItem](r)
+	} else {
+		err = decodeBody(r, &req)
+	}
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	result, err := h.prService.BulkCreate(r.Context(), req.PullRequests)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	utils.WriteOK(w, http.StatusOK, map[string]any{
+		"result": result,
+	})
+}
+
 func (h *PullRequestHandler) MergePullRequest(w http.ResponseWriter, r *http.Request) {
 	var req entity.MergePRRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.WriteError(w, entity.ErrBadRequest)
+	if err := decodeBody(r, &req); err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	if req.ID == "" {
-		utils.WriteError(w, entity.ErrBadRequest)
+		utils.WriteError(w, r, entity.ErrBadRequest)
+		return
+	}
+
+	expectedVersion, err := ifMatchVersion(r)
+	if err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
-	pr, err := h.prService.Merge(r.Context(), req.ID)
+	pr, err := h.prService.Merge(r.Context(), req.ID, expectedVersion)
 	if err != nil {
-		utils.WriteError(w, err)
+		utils.WriteError(w, r, versionError(err, expectedVersion))
 		return
 	}
 
+	setETag(w, pr.Version)
 	utils.WriteOK(w, http.StatusOK, map[string]any{
 		"pr": pr,
 	})
@@ -65,22 +98,29 @@ func (h *PullRequestHandler) MergePullRequest(w http.ResponseWriter, r *http.Req
 
 func (h *PullRequestHandler) ReassignPullRequest(w http.ResponseWriter, r *http.Request) {
 	var req entity.ReassignPRRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.WriteError(w, entity.ErrBadRequest)
+	if err := decodeBody(r, &req); err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	if req.PRID == "" || req.OldUserID == "" {
-		utils.WriteError(w, entity.ErrBadRequest)
+		utils.WriteError(w, r, entity.ErrBadRequest)
+		return
+	}
+
+	expectedVersion, err := ifMatchVersion(r)
+	if err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
-	pr, newUserID, err := h.prService.Reassign(r.Context(), req.PRID, req.OldUserID)
+	pr, newUserID, err := h.prService.Reassign(r.Context(), req.PRID, req.OldUserID, expectedVersion)
 	if err != nil {
-		utils.WriteError(w, err)
+		utils.WriteError(w, r, versionError(err, expectedVersion))
 		return
 	}
 
+	setETag(w, pr.Version)
 	utils.WriteOK(w, http.StatusOK, map[string]any{
 		"pr":          pr,
 		"replaced_by": newUserID,
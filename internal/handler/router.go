@@ -7,26 +7,47 @@ import (
 
 func NewRouter(
 	user *UserHandler,
+	notification *NotificationHandler,
 	team *TeamHandler,
 	pr *PullRequestHandler,
 	stats *StatsHandler,
 	health *HealthHandler,
+	admin *AdminHandler,
+	metrics http.Handler,
 	openAPISpecPath string,
 ) *http.ServeMux {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("POST /users/setIsActive", user.SetIsActive)
 	mux.HandleFunc("GET  /users/getReview", user.GetReview)
+	mux.HandleFunc("GET  /users/getNotificationSettings", notification.GetSettings)
+	mux.HandleFunc("POST /users/setNotificationSettings", notification.SetSettings)
 
 	mux.HandleFunc("GET  /team/get", team.GetTeam)
 	mux.HandleFunc("POST /team/add", team.AddTeam)
 	mux.HandleFunc("POST /team/deactivate", team.DeactivateMembers)
 
 	mux.HandleFunc("POST /pullRequest/create", pr.CreatePullRequest)
+	mux.HandleFunc("POST /pullRequest/bulkCreate", pr.BulkCreatePullRequests)
 	mux.HandleFunc("POST /pullRequest/merge", pr.MergePullRequest)
 	mux.HandleFunc("POST /pullRequest/reassign", pr.ReassignPullRequest)
 	mux.HandleFunc("GET /stats/summary", stats.Summary)
-	mux.HandleFunc("GET /health", health.Check)
+	mux.HandleFunc("GET /stats/timeseries", stats.TimeSeries)
+	mux.HandleFunc("GET /stats/user", stats.User)
+	mux.HandleFunc("GET /stats/fairness", stats.Fairness)
+	mux.HandleFunc("GET /stats/sla", stats.SLA)
+	mux.HandleFunc("GET /stats/report.xlsx", stats.Report)
+	mux.HandleFunc("GET /health", health.Live)
+	mux.HandleFunc("GET /health/live", health.Live)
+	mux.HandleFunc("GET /health/ready", health.Ready)
+	mux.Handle("GET /metrics", metrics)
+
+	if admin != nil {
+		mux.HandleFunc("GET /admin/export", admin.authorize(admin.Export))
+		mux.HandleFunc("POST /admin/import", admin.authorize(admin.Import))
+		mux.HandleFunc("GET /admin/audit", admin.authorize(admin.Audit))
+		mux.HandleFunc("GET /admin/notifications", admin.authorize(admin.Notifications))
+	}
 
 	mux.HandleFunc("/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
@@ -2,11 +2,17 @@ package handler
 
 import (
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
+	"github.com/xddprog/avito-test-task/internal/entity"
 	"github.com/xddprog/avito-test-task/internal/service"
 	"github.com/xddprog/avito-test-task/internal/utils"
 )
 
+const dateLayout = "2006-01-02"
+
 type StatsHandler struct {
 	statsService service.StatsService
 }
@@ -16,13 +22,207 @@ func NewStatsHandler(statsService service.StatsService) *StatsHandler {
 }
 
 func (h *StatsHandler) Summary(w http.ResponseWriter, r *http.Request) {
-	stats, err := h.statsService.GetSummary(r.Context())
+	filter, err := parseStatsFilter(r.URL.Query())
 	if err != nil {
-		utils.WriteError(w, err)
+		utils.WriteError(w, r, err)
 		return
 	}
 
+	stats, err := h.statsService.GetSummary(r.Context(), filter)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept")
+	if prefersCSV(r) {
+		if err := writeCSV(w, "stats-summary", summaryTables(stats)...); err != nil {
+			utils.WriteError(w, r, err)
+		}
+		return
+	}
 	utils.WriteOK(w, http.StatusOK, map[string]any{
 		"stats": stats,
 	})
 }
+
+func (h *StatsHandler) TimeSeries(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter, err := parseStatsFilter(q)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	byTeam := false
+	if raw := q.Get("by_team"); raw != "" {
+		byTeam, err = strconv.ParseBool(raw)
+		if err != nil {
+			utils.WriteError(w, r, entity.ErrBadRequest)
+			return
+		}
+	}
+
+	series, err := h.statsService.GetTimeSeries(r.Context(), filter, entity.StatsInterval(q.Get("interval")), byTeam)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept")
+	if prefersCSV(r) {
+		if err := writeCSV(w, "stats-timeseries", timeSeriesTable(series)); err != nil {
+			utils.WriteError(w, r, err)
+		}
+		return
+	}
+	utils.WriteOK(w, http.StatusOK, map[string]any{
+		"timeseries": series,
+	})
+}
+
+func (h *StatsHandler) User(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	userID := q.Get("user_id")
+	if userID == "" {
+		utils.WriteError(w, r, entity.ErrBadRequest)
+		return
+	}
+
+	filter, err := parseStatsFilter(q)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	stats, err := h.statsService.GetUserStats(r.Context(), userID, filter)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept")
+	if prefersCSV(r) {
+		if err := writeCSV(w, "stats-user", userStatsTables(stats)...); err != nil {
+			utils.WriteError(w, r, err)
+		}
+		return
+	}
+	utils.WriteOK(w, http.StatusOK, map[string]any{
+		"stats": stats,
+	})
+}
+
+func (h *StatsHandler) Fairness(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseStatsFilter(r.URL.Query())
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	report, err := h.statsService.GetFairness(r.Context(), filter)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept")
+	if prefersCSV(r) {
+		if err := writeCSV(w, "stats-fairness", fairnessTable(report)); err != nil {
+			utils.WriteError(w, r, err)
+		}
+		return
+	}
+	utils.WriteOK(w, http.StatusOK, map[string]any{
+		"fairness": report,
+	})
+}
+
+func (h *StatsHandler) SLA(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseStatsFilter(r.URL.Query())
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	report, err := h.statsService.GetSLA(r.Context(), filter)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept")
+	if prefersCSV(r) {
+		if err := writeCSV(w, "stats-sla", slaTable(report)); err != nil {
+			utils.WriteError(w, r, err)
+		}
+		return
+	}
+	utils.WriteOK(w, http.StatusOK, map[string]any{
+		"sla": report,
+	})
+}
+
+// Report is the summary as a workbook with one sheet per section.
+func (h *StatsHandler) Report(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseStatsFilter(r.URL.Query())
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	stats, err := h.statsService.GetSummary(r.Context(), filter)
+	if err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	if err := writeXLSX(w, "stats-report", summaryTables(stats)...); err != nil {
+		utils.WriteError(w, r, err)
+	}
+}
+
+func parseStatsFilter(q url.Values) (entity.StatsFilter, error) {
+	filter := entity.StatsFilter{
+		TeamName: q.Get("team_name"),
+		AuthorID: q.Get("author_id"),
+	}
+
+	from, err := parseTimeParam(q.Get("from"))
+	if err != nil {
+		return filter, err
+	}
+	filter.From = from
+
+	to, err := parseTimeParam(q.Get("to"))
+	if err != nil {
+		return filter, err
+	}
+	filter.To = to
+
+	if raw := q.Get("stale_after_days"); raw != "" {
+		days, err := strconv.Atoi(raw)
+		if err != nil || days <= 0 {
+			return filter, entity.ErrBadRequest
+		}
+		filter.StaleAfter = time.Duration(days) * 24 * time.Hour
+	}
+
+	return filter, nil
+}
+
+// parseTimeParam accepts either a full RFC 3339 timestamp or a plain date,
+// which is treated as midnight UTC.
+func parseTimeParam(raw string) (*time.Time, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(dateLayout, raw)
+	if err != nil {
+		return nil, entity.ErrBadRequest
+	}
+	return &t, nil
+}
@@ -0,0 +1,309 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/pkg/xlsx"
+)
+
+const csvContentType = "text/csv"
+
+// statsTable is a flat view of one stats section for CSV and XLSX. Cells
+// hold strings, numbers, bools, times or nil; durations are in hours.
+type statsTable struct {
+	name   string
+	header []string
+	rows   [][]any
+}
+
+// prefersCSV reports whether the Accept header ranks text/csv above JSON.
+// Anything else, including no acceptable type at all, gets JSON.
+func prefersCSV(r *http.Request) bool {
+	header := r.Header.Get("Accept")
+	if header == "" {
+		return false
+	}
+	return acceptQuality(header, csvContentType) > acceptQuality(header, "application/json")
+}
+
+// acceptQuality returns the q-value of the most specific media range in
+// header that matches mediaType.
+func acceptQuality(header, mediaType string) float64 {
+	typ, _, _ := strings.Cut(mediaType, "/")
+	quality, specificity := 0.0, -1
+	for _, part := range strings.Split(header, ",") {
+		accepted, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		var s int
+		switch accepted {
+		case mediaType:
+			s = 2
+		case typ + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s <= specificity {
+			continue
+		}
+		q := 1.0
+		if raw, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(raw, 64); err != nil {
+				q = 0
+			}
+		}
+		quality, specificity = q, s
+	}
+	return quality
+}
+
+// writeCSV writes a single table as is. Several tables are written one
+// after another, each under a row with its name and separated by an empty
+// line, which spreadsheets open as blocks on one sheet. Rows are padded to
+// the widest table so every record has the same number of fields.
+func writeCSV(w http.ResponseWriter, filename string, tables ...statsTable) error {
+	width := 0
+	for _, t := range tables {
+		width = max(width, len(t.header))
+	}
+	write := func(cw *csv.Writer, cells []string) {
+		record := make([]string, width)
+		copy(record, cells)
+		_ = cw.Write(record)
+	}
+
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	for i, t := range tables {
+		if len(tables) > 1 {
+			if i > 0 {
+				cw.Flush()
+				buf.WriteString("\n")
+			}
+			write(cw, []string{t.name})
+		}
+		write(cw, t.header)
+		for _, row := range t.rows {
+			record := make([]string, len(row))
+			for j, v := range row {
+				record[j] = csvCell(v)
+			}
+			write(cw, record)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", csvContentType+"; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.csv"`)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+	return nil
+}
+
+func writeXLSX(w http.ResponseWriter, filename string, tables ...statsTable) error {
+	sheets := make([]xlsx.Sheet, 0, len(tables))
+	for _, t := range tables {
+		sheets = append(sheets, xlsx.Sheet{Name: t.name, Header: t.header, Rows: t.rows})
+	}
+	var buf bytes.Buffer
+	if err := xlsx.Write(&buf, sheets); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", xlsx.ContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.xlsx"`)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+	return nil
+}
+
+// csvCell formats a cell. Text starting with a formula character is
+// prefixed with a quote so spreadsheets do not evaluate it.
+func csvCell(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			return "'" + v
+		}
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func hours(d entity.DurationBreakdown) float64 {
+	total := float64(d.Days*24+d.Hours) + float64(d.Minutes)/60
+	return math.Round(total*100) / 100
+}
+
+func summaryTables(s *entity.StatsSummary) []statsTable {
+	assignments := statsTable{name: "Reviewer assignments", header: []string{"user_id", "assignments"}}
+	for _, a := range s.ReviewerAssignments {
+		assignments.rows = append(assignments.rows, []any{a.UserID, a.Assignments})
+	}
+
+	status := statsTable{
+		name:   "PR status",
+		header: []string{"total", "open", "merged", "average_reviewers"},
+		rows:   [][]any{{s.PRStatus.Total, s.PRStatus.Open, s.PRStatus.Merged, s.PRStatus.AverageReviewers}},
+	}
+
+	members := statsTable{name: "Team members", header: []string{"team_name", "active_members", "inactive_members"}}
+	for _, m := range s.TeamMembers {
+		members.rows = append(members.rows, []any{m.TeamName, m.Active, m.Inactive})
+	}
+
+	return []statsTable{assignments, status, members, lifetimeTable(s.PRLifetime)}
+}
+
+// lifetimeTable puts the overall figures and every breakdown on one sheet:
+// the scope column tells them apart, and the columns that only exist for
+// the overall row are empty elsewhere.
+func lifetimeTable(l entity.PRLifetimeStat) statsTable {
+	t := statsTable{
+		name: "Lifetime",
+		header: []string{
+			"scope", "team_name", "reviewer_count", "merged", "open",
+			"average_merge_hours", "merge_p50_hours", "merge_p75_hours", "merge_p90_hours", "merge_p99_hours",
+			"stale_threshold_hours", "open_older_than_threshold",
+		},
+	}
+	for _, b := range l.OpenAgeHistogram {
+		t.header = append(t.header, "open_"+b.Label)
+	}
+
+	row := func(scope string, team, reviewers, merged, open, average any, p entity.DurationPercentiles,
+		threshold, stale any, histogram []entity.AgeHistogramBucket) []any {
+		cells := []any{scope, team, reviewers, merged, open, average,
+			hours(p.P50), hours(p.P75), hours(p.P90), hours(p.P99), threshold, stale}
+		for _, b := range histogram {
+			cells = append(cells, b.Count)
+		}
+		return cells
+	}
+
+	t.rows = append(t.rows, row("all", nil, nil, nil, nil, hours(l.AverageMerge), l.MergePercentiles,
+		hours(l.StaleThreshold), l.OpenOlderThanThreshold, l.OpenAgeHistogram))
+	for _, b := range l.ByTeam {
+		t.rows = append(t.rows, row("team", b.TeamName, nil, b.Merged, b.Open, nil, b.MergePercentiles, nil, nil, b.OpenAgeHistogram))
+	}
+	for _, b := range l.ByReviewerCount {
+		var reviewers any
+		if b.ReviewerCount != nil {
+			reviewers = *b.ReviewerCount
+		}
+		t.rows = append(t.rows, row("reviewer_count", nil, reviewers, b.Merged, b.Open, nil, b.MergePercentiles, nil, nil, b.OpenAgeHistogram))
+	}
+	return t
+}
+
+func timeSeriesTable(s *entity.TimeSeries) statsTable {
+	t := statsTable{name: "Time series"}
+	if s.ByTeam {
+		t.header = []string{"bucket", "team_name", "prs_created", "prs_merged", "assignments", "median_time_to_merge_hours"}
+	} else {
+		t.header = []string{"bucket", "prs_created", "prs_merged", "assignments", "median_time_to_merge_hours"}
+	}
+	for _, p := range s.Points {
+		var median any
+		if p.MedianMerge != nil {
+			median = hours(*p.MedianMerge)
+		}
+		if s.ByTeam {
+			t.rows = append(t.rows, []any{p.Bucket, p.TeamName, p.Created, p.Merged, p.Assignments, median})
+		} else {
+			t.rows = append(t.rows, []any{p.Bucket, p.Created, p.Merged, p.Assignments, median})
+		}
+	}
+	return t
+}
+
+func userStatsTables(s *entity.UserReviewStats) []statsTable {
+	user := statsTable{
+		name: "User",
+		header: []string{
+			"user_id", "assignments_received", "reassigned_away", "merged_reviews",
+			"average_assignment_to_merge_hours", "open_load", "team_name", "team_active_members",
+			"team_median_assignments", "team_median_open_load", "open_load_vs_median",
+		},
+		rows: [][]any{{
+			s.UserID, s.AssignmentsReceived, s.ReassignedAway, s.MergedReviews,
+			hours(s.AverageAssignmentToMerge), s.OpenLoad, s.Team.TeamName, s.Team.ActiveMembers,
+			s.Team.MedianAssignments, s.Team.MedianOpenLoad, s.Team.OpenLoadVsMedian,
+		}},
+	}
+
+	reasons := statsTable{name: "Reassign reasons", header: []string{"reason", "count"}}
+	for _, r := range s.ReassignReasons {
+		reasons.rows = append(reasons.rows, []any{string(r.Reason), r.Count})
+	}
+	return []statsTable{user, reasons}
+}
+
+func fairnessTable(f *entity.FairnessReport) statsTable {
+	t := statsTable{
+		name: "Fairness",
+		header: []string{
+			"team_name", "active_members", "total_assignments", "min_assignments",
+			"max_assignments", "max_min_ratio", "gini", "imbalanced",
+		},
+	}
+	for _, team := range f.Teams {
+		var ratio any
+		if team.MaxMinRatio != nil {
+			ratio = *team.MaxMinRatio
+		}
+		t.rows = append(t.rows, []any{
+			team.TeamName, team.ActiveMembers, team.TotalAssignments, team.MinAssignments,
+			team.MaxAssignments, ratio, team.Gini, team.Imbalanced,
+		})
+	}
+	return t
+}
+
+// slaTable puts the overall outcome, the teams and the reviewers on one
+// sheet, told apart by the scope column like lifetimeTable.
+func slaTable(s *entity.SLAReport) statsTable {
+	t := statsTable{
+		name:   "SLA",
+		header: []string{"scope", "team_name", "user_id", "total", "met", "breached", "pending", "breach_rate"},
+	}
+	row := func(scope string, team, user any, o entity.SLAOutcome) []any {
+		return []any{scope, team, user, o.Total, o.Met, o.Breached, o.Pending, o.BreachRate}
+	}
+
+	t.rows = append(t.rows, row("all", nil, nil, s.Overall))
+	for _, team := range s.ByTeam {
+		t.rows = append(t.rows, row("team", team.TeamName, nil, team.SLAOutcome))
+	}
+	for _, reviewer := range s.ByReviewer {
+		t.rows = append(t.rows, row("reviewer", reviewer.TeamName, reviewer.UserID, reviewer.SLAOutcome))
+	}
+	return t
+}
@@ -0,0 +1,117 @@
+package handler_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/xddprog/avito-test-task/internal/middleware"
+	"github.com/xddprog/avito-test-task/pkg/xlsx"
+)
+
+// TestStatsExport checks content negotiation on the stats endpoints and the
+// XLSX report, including that both honour the filters.
+func TestStatsExport(t *testing.T) {
+	validator, err := middleware.NewOpenAPIValidator(specPath)
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+	h := newContractHandler(t, validator)
+
+	send(t, h, http.MethodPost, "/team/add", "application/json",
+		`{"team_name":"backend","members":[{"user_id":"u1","username":"Alice","is_active":true},{"user_id":"u2","username":"Bob","is_active":true}]}`,
+		http.StatusCreated)
+	send(t, h, http.MethodPost, "/team/add", "application/json",
+		`{"team_name":"=ops","members":[{"user_id":"o1","username":"Olga","is_active":true},{"user_id":"o2","username":"Oleg","is_active":false}]}`,
+		http.StatusCreated)
+	send(t, h, http.MethodPost, "/pullRequest/create", "application/json",
+		`{"pull_request_id":"pr-1","pull_request_name":"feature","author_id":"u1"}`, http.StatusCreated)
+
+	get := func(path, accept string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d: %s", path, rec.Code, rec.Body)
+		}
+		return rec.Result()
+	}
+
+	for accept, wantCSV := range map[string]bool{
+		"":                                 false,
+		"application/json":                 false,
+		"*/*":                              false,
+		"text/csv":                         true,
+		"text/csv;q=0.5, application/json": false,
+		"application/json;q=0.1, text/*":   true,
+		"text/csv;q=0":                     false,
+	} {
+		resp := get("/stats/fairness", accept)
+		gotCSV := strings.HasPrefix(resp.Header.Get("Content-Type"), "text/csv")
+		if gotCSV != wantCSV {
+			t.Errorf("Accept %q: Content-Type = %q, want csv=%t", accept, resp.Header.Get("Content-Type"), wantCSV)
+		}
+		if resp.Header.Get("Vary") != "Accept" {
+			t.Errorf("Accept %q: Vary = %q", accept, resp.Header.Get("Vary"))
+		}
+	}
+
+	body, _ := io.ReadAll(get("/stats/summary?team_name=backend", "text/csv").Body)
+	if _, err := csv.NewReader(bytes.NewReader(body)).ReadAll(); err != nil {
+		t.Fatalf("summary is not valid CSV: %v\n%s", err, body)
+	}
+	var sections []string
+	for _, block := range strings.Split(string(body), "\n\n") {
+		name, _, _ := strings.Cut(block, ",")
+		sections = append(sections, name)
+	}
+	if want := []string{"Reviewer assignments", "PR status", "Team members", "Lifetime"}; !slices.Equal(sections, want) {
+		t.Errorf("sections = %v, want %v", sections, want)
+	}
+	if strings.Contains(string(body), "ops") {
+		t.Errorf("team filter was ignored:\n%s", body)
+	}
+
+	body, _ = io.ReadAll(get("/stats/fairness", "text/csv").Body)
+	if !strings.Contains(string(body), "\n'=ops,") {
+		t.Errorf("formula-like team name was not escaped:\n%s", body)
+	}
+
+	resp := get("/stats/report.xlsx?team_name=backend", "")
+	if ct := resp.Header.Get("Content-Type"); ct != xlsx.ContentType {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("report is not a zip: %v", err)
+	}
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(data)
+	}
+	for i, name := range []string{"Reviewer assignments", "PR status", "Team members", "Lifetime"} {
+		if !strings.Contains(files["xl/workbook.xml"], `name="`+name+`"`) {
+			t.Errorf("workbook has no sheet %q", name)
+		}
+		if _, ok := files["xl/worksheets/sheet"+string(rune('1'+i))+".xml"]; !ok {
+			t.Errorf("sheet %d is missing", i+1)
+		}
+	}
+	if members := files["xl/worksheets/sheet3.xml"]; !strings.Contains(members, ">backend<") || strings.Contains(members, "ops") {
+		t.Errorf("team members sheet ignores the filter: %s", members)
+	}
+}
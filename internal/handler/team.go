@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"encoding/json"
 	"net/http"
 
 	"github.com/xddprog/avito-test-task/internal/entity"
@@ -20,8 +19,8 @@ func NewTeamHandler(teamService service.TeamService) *TeamHandler {
 func (h *TeamHandler) AddTeam(w http.ResponseWriter, r *http.Request) {
 	var req entity.CreateTeamRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.WriteError(w, entity.ErrBadRequest)
+	if err := decodeBody(r, &req); err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -41,10 +40,11 @@ func (h *TeamHandler) AddTeam(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.teamService.Create(r.Context(), newTeam); err != nil {
-		utils.WriteError(w, err)
+		utils.WriteError(w, r, err)
 		return
 	}
 
+	setETag(w, newTeam.Version)
 	utils.WriteOK(w, http.StatusCreated, map[string]any{
 		"team": newTeam,
 	})
@@ -55,7 +55,13 @@ func (h *TeamHandler) GetTeam(w http.ResponseWriter, r *http.Request) {
 
 	team, err := h.teamService.GetByName(r.Context(), teamName)
 	if err != nil {
-		utils.WriteError(w, err)
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	setETag(w, team.Version)
+	if r.Header.Get("If-None-Match") == formatETag(team.Version) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
@@ -64,17 +70,24 @@ func (h *TeamHandler) GetTeam(w http.ResponseWriter, r *http.Request) {
 
 func (h *TeamHandler) DeactivateMembers(w http.ResponseWriter, r *http.Request) {
 	var req entity.DeactivateTeamMembersRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.WriteError(w, entity.ErrBadRequest)
+	if err := decodeBody(r, &req); err != nil {
+		utils.WriteError(w, r, err)
+		return
+	}
+
+	expectedVersion, err := ifMatchVersion(r)
+	if err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
-	result, err := h.teamService.DeactivateMembers(r.Context(), &req)
+	result, err := h.teamService.DeactivateMembers(r.Context(), &req, expectedVersion)
 	if err != nil {
-		utils.WriteError(w, err)
+		utils.WriteError(w, r, versionError(err, expectedVersion))
 		return
 	}
 
+	setETag(w, result.TeamVersion)
 	utils.WriteOK(w, http.StatusOK, map[string]any{
 		"result": result,
 	})
@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"encoding/json"
 	"net/http"
 
 	"github.com/xddprog/avito-test-task/internal/entity"
@@ -25,22 +24,29 @@ type setIsActiveRequest struct {
 func (h *UserHandler) SetIsActive(w http.ResponseWriter, r *http.Request) {
 	var req setIsActiveRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.WriteError(w, entity.ErrBadRequest)
+	if err := decodeBody(r, &req); err != nil {
+		utils.WriteError(w, r, err)
 		return
 	}
 
 	if err := utils.ValidateForm(req); err != nil {
-		utils.WriteError(w, err)
+		utils.WriteError(w, r, err)
 		return
 	}
 
-	updatedUser, err := h.userService.SetIsActive(r.Context(), req.UserID, req.IsActive)
+	expectedVersion, err := ifMatchVersion(r)
 	if err != nil {
-		utils.WriteError(w, err)
+		utils.WriteError(w, r, err)
 		return
 	}
 
+	updatedUser, err := h.userService.SetIsActive(r.Context(), req.UserID, req.IsActive, expectedVersion)
+	if err != nil {
+		utils.WriteError(w, r, versionError(err, expectedVersion))
+		return
+	}
+
+	setETag(w, updatedUser.Version)
 	utils.WriteOK(w, http.StatusOK, map[string]any{
 		"user": updatedUser,
 	})
@@ -49,13 +55,13 @@ func (h *UserHandler) SetIsActive(w http.ResponseWriter, r *http.Request) {
 func (h *UserHandler) GetReview(w http.ResponseWriter, r *http.Request) {
 	userID := r.URL.Query().Get("user_id")
 	if userID == "" {
-		utils.WriteError(w, entity.ErrBadRequest)
+		utils.WriteError(w, r, entity.ErrBadRequest)
 		return
 	}
 
 	prs, err := h.userService.GetReviews(r.Context(), userID)
 	if err != nil {
-		utils.WriteError(w, err)
+		utils.WriteError(w, r, err)
 		return
 	}
 
@@ -38,7 +38,8 @@ func InitLogger(level, format string) {
 		handler = slog.NewTextHandler(os.Stdout, opts)
 	}
 
-	defaultLogger = slog.New(handler)
+	defaultLogger = slog.New(NewContextHandler(handler))
+	slog.SetDefault(defaultLogger)
 }
 
 func Logger() *slog.Logger {
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xddprog/avito-test-task/internal/repository"
+)
+
+const domainScrapeTimeout = 3 * time.Second
+
+type domainCollector struct {
+	stats        repository.StatsRepository
+	openPRs      *prometheus.Desc
+	reviewerLoad *prometheus.Desc
+	scrapeErrors prometheus.Counter
+}
+
+// NewDomainCollector queries open PRs per team and reviewer load from one
+// stats snapshot on every scrape.
+func NewDomainCollector(stats repository.StatsRepository) prometheus.Collector {
+	return &domainCollector{
+		stats: stats,
+		openPRs: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "open_pull_requests"),
+			"Open pull requests by author team.",
+			[]string{"team"}, nil,
+		),
+		reviewerLoad: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "reviewer_open_assignments"),
+			"Open pull requests currently assigned to each active reviewer.",
+			[]string{"team", "user_id"}, nil,
+		),
+		scrapeErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "domain_scrape_errors_total",
+			Help:      "Failed database queries while collecting domain metrics.",
+		}),
+	}
+}
+
+func (c *domainCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.openPRs
+	ch <- c.reviewerLoad
+	c.scrapeErrors.Describe(ch)
+}
+
+func (c *domainCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), domainScrapeTimeout)
+	defer cancel()
+
+	source, release, err := c.stats.Snapshot(ctx)
+	if err != nil {
+		slog.Error("failed to read domain metrics", "error", err)
+		c.scrapeErrors.Inc()
+		c.scrapeErrors.Collect(ch)
+		return
+	}
+	defer release()
+
+	teams, err := source.GetOpenPRsByTeam(ctx)
+	if err != nil {
+		slog.Error("failed to collect open PR metrics", "error", err)
+		c.scrapeErrors.Inc()
+	}
+	for _, t := range teams {
+		ch <- prometheus.MustNewConstMetric(c.openPRs, prometheus.GaugeValue, float64(t.Open), t.TeamName)
+	}
+
+	loads, err := source.GetReviewerLoad(ctx)
+	if err != nil {
+		slog.Error("failed to collect reviewer load metrics", "error", err)
+		c.scrapeErrors.Inc()
+	}
+	for _, l := range loads {
+		ch <- prometheus.MustNewConstMetric(c.reviewerLoad, prometheus.GaugeValue, float64(l.Open), l.TeamName, l.UserID)
+	}
+
+	c.scrapeErrors.Collect(ch)
+}
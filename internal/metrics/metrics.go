@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/event"
+)
+
+const namespace = "reviewer_service"
+
+type Metrics struct {
+	registry     *prometheus.Registry
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	noCandidate  *prometheus.CounterVec
+	staleReview  *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route pattern and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, route pattern and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		noCandidate: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "no_candidate_failures_total",
+			Help:      "Reassignments that failed because the team had no active replacement.",
+		}, []string{"reason"}),
+		staleReview: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "stale_review_actions_total",
+			Help:      "Reminders, reassignments and escalations of stale reviews.",
+		}, []string{"action"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.noCandidate,
+		m.staleReview,
+	)
+
+	return m
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) ObserveHTTP(method, route string, status int, duration time.Duration) {
+	code := strconv.Itoa(status)
+	m.httpRequests.WithLabelValues(method, route, code).Inc()
+	m.httpDuration.WithLabelValues(method, route, code).Observe(duration.Seconds())
+}
+
+// Subscribe counts NO_CANDIDATE failures and stale review actions published
+// by the services.
+func (m *Metrics) Subscribe(bus event.Bus) {
+	bus.Subscribe(event.TypeNoCandidate, func(_ context.Context, e event.Event) {
+		reason := "unknown"
+		if failure, ok := e.Payload.(entity.NoCandidateFailure); ok {
+			reason = string(failure.Reason)
+		}
+		m.noCandidate.WithLabelValues(reason).Inc()
+	})
+	bus.Subscribe(event.TypeReviewReminder, func(context.Context, event.Event) {
+		m.staleReview.WithLabelValues(string(entity.StaleActionRemind)).Inc()
+	})
+	bus.Subscribe(event.TypeReviewEscalated, func(_ context.Context, e event.Event) {
+		action := "unknown"
+		if review, ok := e.Payload.(entity.StaleReview); ok {
+			action = string(review.Action)
+		}
+		m.staleReview.WithLabelValues(action).Inc()
+	})
+}
+
+func (m *Metrics) MustRegister(cs ...prometheus.Collector) {
+	m.registry.MustRegister(cs...)
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import "net/http"
+
+// BodyLimitMiddleware caps request bodies at maxBytes. Handlers see the
+// overflow as a *http.MaxBytesError from Read.
+func BodyLimitMiddleware(maxBytes int64, next http.Handler) http.Handler {
+	return BodyLimits{Default: maxBytes}.Middleware(next)
+}
+
+// BodyLimits caps request bodies at Default, or at Routes[path] for the
+// paths that need more room, such as backup imports. Zero means no limit.
+type BodyLimits struct {
+	Default int64
+	Routes  map[string]int64
+}
+
+func (l BodyLimits) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		maxBytes := l.Default
+		if limit, ok := l.Routes[r.URL.Path]; ok {
+			maxBytes = limit
+		}
+		if r.Body != nil && maxBytes > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/service"
+	"github.com/xddprog/avito-test-task/internal/utils"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// IdempotencyMiddleware stores the first response to a POST carrying an
+// Idempotency-Key and replays it for retries from the same client with the
+// same key and payload. Server errors are not stored, so a retry after a 5xx
+// runs the handler again. It must run inside BodyLimitMiddleware and pass r
+// through unchanged.
+func IdempotencyMiddleware(svc service.IdempotencyService, client ClientResolver, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			utils.WriteError(w, r, entity.ErrBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				utils.WriteError(w, r, entity.ErrPayloadTooLarge)
+				return
+			}
+			utils.WriteError(w, r, entity.ErrBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		rec := &entity.IdempotencyRecord{
+			IdempotencyScope: entity.IdempotencyScope{
+				// Hashed so that API keys are not stored in the clear.
+				Client: sha256Hex([]byte(client(r))),
+				Key:    key,
+				Method: r.Method,
+				Path:   r.URL.Path,
+			},
+			RequestHash: requestHash(body),
+		}
+
+		stored, err := svc.Begin(r.Context(), rec)
+		if err != nil {
+			utils.WriteError(w, r, err)
+			return
+		}
+		if stored != nil {
+			replay(w, stored)
+			return
+		}
+
+		// The response is already sent by the time the key is settled, so the
+		// bookkeeping must not depend on the client still being connected.
+		ctx := context.WithoutCancel(r.Context())
+
+		handled := false
+		defer func() {
+			if !handled {
+				_ = svc.Release(ctx, rec)
+			}
+		}()
+
+		cw := &capturingResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(cw, r)
+		handled = true
+
+		if cw.statusCode >= http.StatusInternalServerError {
+			if err := svc.Release(ctx, rec); err != nil {
+				slog.ErrorContext(ctx, "failed to release idempotency key", "error", err)
+			}
+			return
+		}
+
+		rec.StatusCode = cw.statusCode
+		rec.ContentType = cw.Header().Get("Content-Type")
+		rec.ETag = cw.Header().Get("ETag")
+		rec.Body = cw.body.Bytes()
+		if err := svc.Complete(ctx, rec); err != nil {
+			slog.ErrorContext(ctx, "failed to store idempotent response", "error", err)
+		}
+	})
+}
+
+func replay(w http.ResponseWriter, rec *entity.IdempotencyRecord) {
+	if rec.ContentType != "" {
+		w.Header().Set("Content-Type", rec.ContentType)
+	}
+	if rec.ETag != "" {
+		w.Header().Set("ETag", rec.ETag)
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(rec.StatusCode)
+	_, _ = w.Write(rec.Body)
+}
+
+// requestHash fingerprints the payload. JSON bodies are re-encoded first so
+// that key order and whitespace don't count as a different request.
+func requestHash(body []byte) string {
+	var decoded any
+	if err := json.Unmarshal(body, &decoded); err == nil {
+		if canonical, err := json.Marshal(decoded); err == nil {
+			body = canonical
+		}
+	}
+	return sha256Hex(body)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+type capturingResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (cw *capturingResponseWriter) WriteHeader(code int) {
+	cw.statusCode = code
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *capturingResponseWriter) Write(b []byte) (int, error) {
+	cw.body.Write(b)
+	return cw.ResponseWriter.Write(b)
+}
@@ -3,12 +3,13 @@ package middleware
 import (
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 )
 
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/health" {
+		if strings.HasPrefix(r.URL.Path, "/health") || r.URL.Path == "/metrics" {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -25,7 +26,7 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		duration := time.Since(start)
 
 		if lw.statusCode >= 400 {
-			slog.Warn("HTTP request",
+			slog.WarnContext(r.Context(), "HTTP request",
 				"method", r.Method,
 				"path", r.URL.Path,
 				"status", lw.statusCode,
@@ -33,7 +34,7 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 				"remote_addr", r.RemoteAddr,
 			)
 		} else {
-			slog.Info("HTTP request",
+			slog.InfoContext(r.Context(), "HTTP request",
 				"method", r.Method,
 				"path", r.URL.Path,
 				"status", lw.statusCode,
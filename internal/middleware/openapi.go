@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/utils"
+	"github.com/xddprog/avito-test-task/pkg/xlsx"
+)
+
+// OpenAPIValidator checks requests against api/openapi.yml before they reach
+// the handlers. Paths and methods the spec does not describe pass through,
+// so the router still answers them with 404 or 405.
+type OpenAPIValidator struct {
+	spec    *openapi3.T
+	options *openapi3filter.Options
+}
+
+func init() {
+	openapi3filter.RegisterBodyDecoder("application/x-ndjson", ndjsonBodyDecoder)
+	// Archives are checked only for being present; the handler parses them.
+	// Workbooks appear only in responses.
+	openapi3filter.RegisterBodyDecoder("application/zip", openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder(xlsx.ContentType, openapi3filter.FileBodyDecoder)
+}
+
+// ndjsonBodyDecoder reads newline-delimited JSON as an array, so the spec
+// describes an NDJSON body with an array schema.
+func ndjsonBodyDecoder(body io.Reader, _ http.Header, _ *openapi3.SchemaRef, _ openapi3filter.EncodingFn) (any, error) {
+	dec := json.NewDecoder(body)
+	dec.UseNumber()
+	values := []any{}
+	for {
+		var value any
+		err := dec.Decode(&value)
+		if errors.Is(err, io.EOF) {
+			return values, nil
+		}
+		if err != nil {
+			return nil, &openapi3filter.ParseError{Kind: openapi3filter.KindInvalidFormat, Cause: err}
+		}
+		values = append(values, value)
+	}
+}
+
+func NewOpenAPIValidator(specPath string) (*OpenAPIValidator, error) {
+	loader := openapi3.NewLoader()
+	spec, err := loader.LoadFromFile(specPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load OpenAPI spec: %w", err)
+	}
+	if err := spec.Validate(loader.Context); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI spec: %w", err)
+	}
+	return &OpenAPIValidator{
+		spec: spec,
+		options: &openapi3filter.Options{
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		},
+	}, nil
+}
+
+// Route finds the spec operation for r, or nil if the spec has none.
+func (v *OpenAPIValidator) Route(r *http.Request) *routers.Route {
+	pathItem := v.spec.Paths.Value(r.URL.Path)
+	if pathItem == nil {
+		return nil
+	}
+	op := pathItem.GetOperation(r.Method)
+	if op == nil {
+		return nil
+	}
+	return &routers.Route{
+		Spec:      v.spec,
+		Path:      r.URL.Path,
+		PathItem:  pathItem,
+		Method:    r.Method,
+		Operation: op,
+	}
+}
+
+// Middleware answers BAD_REQUEST with the first mismatch. It must run inside
+// BodyLimitMiddleware and passes r through unchanged apart from the body.
+func (v *OpenAPIValidator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := v.Route(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if route.Operation.RequestBody != nil {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					utils.WriteError(w, r, entity.ErrPayloadTooLarge)
+					return
+				}
+				utils.WriteError(w, r, entity.ErrBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			// The handlers always decoded JSON, so clients that omit the
+			// header keep working.
+			if r.Header.Get("Content-Type") == "" {
+				r.Header.Set("Content-Type", "application/json")
+			}
+		}
+
+		err := openapi3filter.ValidateRequest(r.Context(), &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: map[string]string{},
+			Route:      route,
+			Options:    v.options,
+		})
+		if err != nil {
+			utils.WriteError(w, r, entity.New(http.StatusBadRequest, entity.ErrBadRequest.SafeCode, validationMessage(err)))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validationMessage reduces a kin-openapi error to the offending field and
+// the reason, without echoing the schema back.
+func validationMessage(err error) string {
+	var reqErr *openapi3filter.RequestError
+	if !errors.As(err, &reqErr) {
+		return entity.ErrBadRequest.Message
+	}
+
+	var schemaErr *openapi3.SchemaError
+	reason := reqErr.Reason
+	if errors.As(err, &schemaErr) {
+		reason = schemaErr.Reason
+		if pointer := schemaErr.JSONPointer(); len(pointer) > 0 {
+			reason = fmt.Sprintf("field '%s': %s", strings.Join(pointer, "."), reason)
+		}
+	} else if reason == "" && reqErr.Err != nil {
+		reason = reqErr.Err.Error()
+	}
+
+	switch {
+	case reqErr.Parameter != nil:
+		return fmt.Sprintf("%s parameter '%s': %s", reqErr.Parameter.In, reqErr.Parameter.Name, reason)
+	case reqErr.RequestBody != nil:
+		return "request body: " + reason
+	default:
+		return reason
+	}
+}
+
+// Operations lists every operation in the spec as "METHOD /path".
+func (v *OpenAPIValidator) Operations() []string {
+	var ops []string
+	for _, path := range v.spec.Paths.InMatchingOrder() {
+		for method := range v.spec.Paths.Value(path).Operations() {
+			ops = append(ops, method+" "+path)
+		}
+	}
+	sort.Strings(ops)
+	return ops
+}
@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/config"
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/utils"
+)
+
+const (
+	rateLimitSweepInterval = time.Minute
+	rateLimitIdleTTL       = 10 * time.Minute
+	defaultRouteKey        = "*"
+)
+
+// RouteResolver returns the mux pattern that will serve r, or "" if none.
+type RouteResolver func(r *http.Request) string
+
+// ClientResolver names the caller of r for per-client state.
+type ClientResolver func(r *http.Request) string
+
+type tokenBucket struct {
+	tokens   float64
+	last     time.Time
+	lastSeen time.Time
+}
+
+// RateLimiter keeps a token bucket per client identity and route. Routes
+// without an explicit limit share one default bucket per client.
+type RateLimiter struct {
+	cfg     config.RateLimitConfig
+	routes  map[string]config.RouteLimit
+	resolve RouteResolver
+	client  ClientResolver
+	now     func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func NewRateLimiter(cfg config.RateLimitConfig, resolve RouteResolver) (*RateLimiter, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	routes, err := cfg.RouteLimits()
+	if err != nil {
+		return nil, err
+	}
+	return &RateLimiter{
+		cfg:     cfg,
+		routes:  routes,
+		resolve: resolve,
+		client:  ClientIdentity(cfg),
+		now:     time.Now,
+		buckets: make(map[string]*tokenBucket),
+	}, nil
+}
+
+// Middleware answers 429 with Retry-After once the caller's bucket is empty.
+// It must sit between MetricsMiddleware and the mux and pass r through
+// unchanged, so rejected requests still get a route label.
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	if !l.cfg.Enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/health") || r.URL.Path == "/metrics" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		pattern := l.resolve(r)
+		route, limit := l.limitFor(pattern)
+
+		allowed, retryAfter := l.take(l.client(r)+" "+route, limit)
+		if !allowed {
+			r.Pattern = pattern
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			utils.WriteError(w, r, entity.ErrRateLimited)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Sweep drops buckets that have been idle long enough to be full again. It
+// blocks until ctx is cancelled.
+func (l *RateLimiter) Sweep(ctx context.Context) {
+	ticker := time.NewTicker(rateLimitSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cutoff := l.now().Add(-rateLimitIdleTTL)
+			l.mu.Lock()
+			for key, b := range l.buckets {
+				if b.lastSeen.Before(cutoff) {
+					delete(l.buckets, key)
+				}
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+func (l *RateLimiter) limitFor(pattern string) (string, config.RouteLimit) {
+	if pattern != "" {
+		fields := strings.Fields(pattern)
+		path := fields[len(fields)-1]
+		if limit, ok := l.routes[path]; ok {
+			return path, limit
+		}
+	}
+	return defaultRouteKey, config.RouteLimit{RPS: l.cfg.RPS, Burst: l.cfg.Burst}
+}
+
+func (l *RateLimiter) take(key string, limit config.RouteLimit) (bool, time.Duration) {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*limit.RPS)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / limit.RPS * float64(time.Second))
+	return false, wait
+}
+
+// ClientIdentity identifies callers the way the rate limiter does. It prefers
+// the API key so clients behind one NAT are told apart, but only a key from
+// cfg.APIKeys: an unchecked key would let a caller pick a fresh identity on
+// every request.
+func ClientIdentity(cfg config.RateLimitConfig) ClientResolver {
+	keys := make(map[string]bool)
+	for _, key := range cfg.Keys() {
+		keys[key] = true
+	}
+	return func(r *http.Request) string {
+		if key := r.Header.Get(cfg.APIKeyHeader); keys[key] {
+			return "key:" + key
+		}
+		if cfg.TrustForwarded {
+			if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+				first, _, _ := strings.Cut(fwd, ",")
+				return "ip:" + strings.TrimSpace(first)
+			}
+		}
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return "ip:" + r.RemoteAddr
+		}
+		return "ip:" + host
+	}
+}
@@ -0,0 +1,89 @@
+// Package notify renders review notifications and delivers them over
+// email, a chat webhook or the application log.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Message is a rendered notification addressed to one recipient: an email
+// address, a chat handle or a user ID, depending on the channel.
+type Message struct {
+	Recipient string
+	Subject   string
+	Body      string
+}
+
+// Channel delivers messages. Send returns an error when the message may not
+// have been delivered, and the caller retries it later.
+type Channel interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type logChannel struct {
+	logger *slog.Logger
+}
+
+// NewLogChannel writes messages to logger, which is handy in development
+// and as a record alongside the real channels.
+func NewLogChannel(logger *slog.Logger) Channel {
+	return &logChannel{logger: logger}
+}
+
+func (c *logChannel) Send(ctx context.Context, msg Message) error {
+	c.logger.InfoContext(ctx, "notification",
+		"recipient", msg.Recipient,
+		"subject", msg.Subject,
+		"body", msg.Body,
+	)
+	return nil
+}
+
+type webhookChannel struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookChannel posts messages as JSON to a chat webhook:
+// {"channel": recipient, "subject": ..., "text": ...}. The text repeats the
+// subject on its first line for chats that only show text. Any 2xx answer
+// counts as delivered.
+func NewWebhookChannel(url string, timeout time.Duration) Channel {
+	return &webhookChannel{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (c *webhookChannel) Send(ctx context.Context, msg Message) error {
+	payload, err := json.Marshal(map[string]string{
+		"channel": msg.Recipient,
+		"subject": msg.Subject,
+		"text":    msg.Subject + "\n\n" + msg.Body,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("chat webhook answered %s", resp.Status)
+	}
+	return nil
+}
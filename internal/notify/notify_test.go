@@ -0,0 +1,196 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/notify"
+)
+
+// smtpStub accepts one SMTP session on a local port and records the
+// envelope and the message.
+type smtpStub struct {
+	addr     string
+	from, to string
+	data     string
+	done     chan struct{}
+}
+
+func newSMTPStub(t *testing.T) *smtpStub {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	stub := &smtpStub{addr: ln.Addr().String(), done: make(chan struct{})}
+	go func() {
+		defer close(stub.done)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		_ = tp.PrintfLine("220 stub ready")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			verb, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(verb) {
+			case "EHLO", "HELO":
+				_ = tp.PrintfLine("250 stub")
+			case "MAIL":
+				stub.from = arg
+				_ = tp.PrintfLine("250 OK")
+			case "RCPT":
+				stub.to = arg
+				_ = tp.PrintfLine("250 OK")
+			case "DATA":
+				_ = tp.PrintfLine("354 go ahead")
+				data, _ := io.ReadAll(tp.DotReader())
+				stub.data = string(data)
+				_ = tp.PrintfLine("250 OK")
+			case "QUIT":
+				_ = tp.PrintfLine("221 bye")
+				return
+			default:
+				_ = tp.PrintfLine("502 not implemented")
+			}
+		}
+	}()
+	return stub
+}
+
+func TestSMTPChannel(t *testing.T) {
+	stub := newSMTPStub(t)
+	host, port, _ := net.SplitHostPort(stub.addr)
+	portNum, _ := net.LookupPort("tcp", port)
+
+	ch := notify.NewSMTPChannel(notify.SMTPConfig{Host: host, Port: portNum, From: "reviews@example.com", Timeout: 5 * time.Second})
+	err := ch.Send(context.Background(), notify.Message{
+		Recipient: "alice@example.com",
+		Subject:   "Ревью: feature",
+		Body:      "line one\nline two",
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	<-stub.done
+
+	if stub.from != "FROM:<reviews@example.com>" || stub.to != "TO:<alice@example.com>" {
+		t.Fatalf("envelope = %q, %q", stub.from, stub.to)
+	}
+	header, body, _ := strings.Cut(stub.data, "\n\n")
+	var subject string
+	for _, line := range strings.Split(header, "\n") {
+		if v, ok := strings.CutPrefix(line, "Subject: "); ok {
+			subject, _ = new(mime.WordDecoder).DecodeHeader(v)
+		}
+	}
+	if subject != "Ревью: feature" {
+		t.Errorf("subject = %q", subject)
+	}
+	if !strings.Contains(header, "To: alice@example.com") || body != "line one\nline two\n" {
+		t.Errorf("unexpected message:\n%s", stub.data)
+	}
+}
+
+func TestWebhookChannel(t *testing.T) {
+	var got map[string]string
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type = %q", r.Header.Get("Content-Type"))
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	ch := notify.NewWebhookChannel(srv.URL, time.Second)
+	msg := notify.Message{Recipient: "@alice", Subject: "Review requested", Body: "details"}
+	if err := ch.Send(context.Background(), msg); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	want := map[string]string{"channel": "@alice", "subject": "Review requested", "text": "Review requested\n\ndetails"}
+	if len(got) != len(want) || got["channel"] != want["channel"] || got["text"] != want["text"] {
+		t.Fatalf("payload = %v, want %v", got, want)
+	}
+
+	status = http.StatusBadGateway
+	if err := ch.Send(context.Background(), msg); err == nil {
+		t.Fatal("expected an error for a 502 answer")
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	deadline := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	data := notify.Data{
+		Kind:      entity.NotifyReviewAssigned,
+		Recipient: entity.User{ID: "u2", Username: "Bob"},
+		PullRequest: entity.PullRequest{
+			BasePullRequest: entity.BasePullRequest{ID: "pr-1", Name: "feature", AuthorID: "u1"},
+			SLADeadline:     &deadline,
+		},
+		Reason:             entity.ReasonDeactivation,
+		PreviousReviewerID: "u3",
+	}
+
+	templates, err := notify.LoadTemplates("")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	subject, body, err := templates.Render(data)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if subject != "Review requested: feature" {
+		t.Errorf("subject = %q", subject)
+	}
+	for _, want := range []string{"Hi Bob,", `"feature" (pr-1) by u1`, "from u3: the previous reviewer was deactivated", "by 2026-03-02 15:00 UTC"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body does not contain %q:\n%s", want, body)
+		}
+	}
+
+	dir := t.TempDir()
+	custom := `{{define "subject"}}[review] {{.PullRequest.ID}}{{end}}{{define "body"}}{{.Recipient.Username}}{{end}}`
+	if err := os.WriteFile(filepath.Join(dir, "review_assigned.tmpl"), []byte(custom), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	templates, err = notify.LoadTemplates(dir)
+	if err != nil {
+		t.Fatalf("load custom: %v", err)
+	}
+	if subject, body, _ := templates.Render(data); subject != "[review] pr-1" || body != "Bob" {
+		t.Errorf("custom template rendered %q / %q", subject, body)
+	}
+	data.Kind = entity.NotifyReviewReminder
+	data.Waiting = entity.DurationBreakdown{Days: 2, Hours: 3}
+	if _, body, _ := templates.Render(data); !strings.Contains(body, "for 2d 3h") {
+		t.Errorf("built-in reminder was not kept:\n%s", body)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "review_reminder.tmpl"), []byte(`{{define "subject"}}x{{end}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := notify.LoadTemplates(dir); err == nil {
+		t.Fatal("expected an error for a template without a body")
+	}
+}
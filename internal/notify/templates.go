@@ -0,0 +1,124 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+)
+
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+var kinds = []entity.NotificationKind{
+	entity.NotifyReviewAssigned,
+	entity.NotifyReviewUnassigned,
+	entity.NotifyReviewReminder,
+}
+
+// Data is what message templates are executed with.
+type Data struct {
+	Kind        entity.NotificationKind
+	Recipient   entity.User
+	PullRequest entity.PullRequest
+	// Reason is why the review changed hands, set together with
+	// PreviousReviewerID on assignments and NewReviewerID on unassignments
+	// caused by a reassignment.
+	Reason             entity.ReassignReason
+	PreviousReviewerID string
+	NewReviewerID      string
+	// Waiting is how long the review has been waiting, set on reminders.
+	Waiting entity.DurationBreakdown
+}
+
+// Templates holds one template per notification kind, each defining a
+// "subject" and a "body".
+type Templates struct {
+	byKind map[entity.NotificationKind]*template.Template
+}
+
+var funcs = template.FuncMap{
+	"datetime": func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04 MST") },
+	"waiting": func(d entity.DurationBreakdown) string {
+		switch {
+		case d.Days > 0:
+			return fmt.Sprintf("%dd %dh", d.Days, d.Hours)
+		case d.Hours > 0:
+			return fmt.Sprintf("%dh %dm", d.Hours, d.Minutes)
+		default:
+			return fmt.Sprintf("%dm", d.Minutes)
+		}
+	},
+	"reason": func(r entity.ReassignReason) string {
+		switch r {
+		case entity.ReasonDeactivation:
+			return "the previous reviewer was deactivated"
+		case entity.ReasonStale:
+			return "the review was waiting for too long"
+		default:
+			return "the review was reassigned"
+		}
+	},
+}
+
+// LoadTemplates parses the built-in templates. A <kind>.tmpl file in dir,
+// e.g. review_assigned.tmpl, replaces the built-in one; an empty dir keeps
+// them all.
+func LoadTemplates(dir string) (*Templates, error) {
+	t := &Templates{byKind: make(map[entity.NotificationKind]*template.Template, len(kinds))}
+	for _, kind := range kinds {
+		name := string(kind) + ".tmpl"
+		src, err := fs.ReadFile(defaultTemplates, "templates/"+name)
+		if err != nil {
+			return nil, err
+		}
+		if dir != "" {
+			custom, err := os.ReadFile(filepath.Join(dir, name))
+			switch {
+			case err == nil:
+				src = custom
+			case !errors.Is(err, fs.ErrNotExist):
+				return nil, fmt.Errorf("notify: read template: %w", err)
+			}
+		}
+
+		tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(string(src))
+		if err != nil {
+			return nil, fmt.Errorf("notify: parse %s: %w", name, err)
+		}
+		for _, part := range []string{"subject", "body"} {
+			if tmpl.Lookup(part) == nil {
+				return nil, fmt.Errorf("notify: %s does not define %q", name, part)
+			}
+		}
+		t.byKind[kind] = tmpl
+	}
+	return t, nil
+}
+
+// Render returns the subject and body for data.Kind, trimmed of surrounding
+// whitespace.
+func (t *Templates) Render(data Data) (subject, body string, err error) {
+	tmpl, ok := t.byKind[data.Kind]
+	if !ok {
+		return "", "", fmt.Errorf("notify: no template for %q", data.Kind)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return "", "", err
+	}
+	subject = strings.TrimSpace(buf.String())
+	buf.Reset()
+	if err := tmpl.ExecuteTemplate(&buf, "body", data); err != nil {
+		return "", "", err
+	}
+	return subject, strings.TrimSpace(buf.String()), nil
+}
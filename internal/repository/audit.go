@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+	adapter "github.com/xddprog/avito-test-task/pkg/db/adapter"
+)
+
+type AuditRepository interface {
+	// Record appends entry, filling in its ID and, when zero, OccurredAt.
+	Record(ctx context.Context, entry *entity.AuditEntry) error
+	// RecordOnce is Record for an entry with a Key. It stores nothing and
+	// returns false when an entry with the same Key is already recorded.
+	RecordOnce(ctx context.Context, entry *entity.AuditEntry) (bool, error)
+	List(ctx context.Context, filter entity.AuditFilter) ([]entity.AuditEntry, error)
+}
+
+type auditRepo struct {
+	db adapter.DB
+}
+
+func NewAuditRepository(db adapter.DB) AuditRepository {
+	return &auditRepo{db: db}
+}
+
+func (r *auditRepo) Record(ctx context.Context, entry *entity.AuditEntry) error {
+	ctx, span := tracing.Start(ctx, "AuditRepository.Record")
+	defer span.End()
+
+	return r.insert(ctx, entry, "")
+}
+
+func (r *auditRepo) RecordOnce(ctx context.Context, entry *entity.AuditEntry) (bool, error) {
+	ctx, span := tracing.Start(ctx, "AuditRepository.RecordOnce")
+	defer span.End()
+
+	err := r.insert(ctx, entry, "ON CONFLICT (dedup_key) DO NOTHING")
+	if errors.Is(err, adapter.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *auditRepo) insert(ctx context.Context, entry *entity.AuditEntry, onConflict string) error {
+	if entry.OccurredAt.IsZero() {
+		entry.OccurredAt = time.Now()
+	}
+	var details *string
+	if len(entry.Details) > 0 {
+		data, err := json.Marshal(entry.Details)
+		if err != nil {
+			return err
+		}
+		encoded := string(data)
+		details = &encoded
+	}
+
+	return r.db.QueryRow(ctx, `
+		INSERT INTO audit_log (occurred_at, actor, action, pr_id, user_id, team_name, details, dedup_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`+onConflict+`
+		RETURNING id
+	`, entry.OccurredAt, entry.Actor, entry.Action,
+		nullString(entry.PullRequestID), nullString(entry.UserID), nullString(entry.TeamName), details, nullString(entry.Key),
+	).Scan(&entry.ID)
+}
+
+func (r *auditRepo) List(ctx context.Context, filter entity.AuditFilter) ([]entity.AuditEntry, error) {
+	ctx, span := tracing.Start(ctx, "AuditRepository.List")
+	defer span.End()
+
+	var conds []string
+	var args []any
+	where := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.Action != "" {
+		where("action = $%d", filter.Action)
+	}
+	if filter.PullRequestID != "" {
+		where("pr_id = $%d", filter.PullRequestID)
+	}
+	if filter.UserID != "" {
+		where("user_id = $%d", filter.UserID)
+	}
+	if filter.TeamName != "" {
+		where("team_name = $%d", filter.TeamName)
+	}
+	if filter.From != nil {
+		where("occurred_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		where("occurred_at < $%d", *filter.To)
+	}
+
+	query := `SELECT id, occurred_at, actor, action, pr_id, user_id, team_name, details FROM audit_log`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY occurred_at DESC, id DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []entity.AuditEntry{}
+	for rows.Next() {
+		var (
+			e                           entity.AuditEntry
+			prID, userID, team, details *string
+		)
+		if err := rows.Scan(&e.ID, &e.OccurredAt, &e.Actor, &e.Action, &prID, &userID, &team, &details); err != nil {
+			return nil, err
+		}
+		e.PullRequestID, e.UserID, e.TeamName = deref(prID), deref(userID), deref(team)
+		if details != nil {
+			if err := json.Unmarshal([]byte(*details), &e.Details); err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
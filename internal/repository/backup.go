@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+	adapter "github.com/xddprog/avito-test-task/pkg/db/adapter"
+)
+
+type BackupRepository interface {
+	// Export reads every team, user, pull request and reviewer in one
+	// transaction, ordered by id.
+	Export(ctx context.Context) (*entity.Backup, error)
+	// Clear deletes all review data, including the reassignment history,
+	// the audit log and the users' notification settings and outbox, which
+	// would otherwise point at users of the replaced data.
+	// Only meaningful inside a UnitOfWork.
+	Clear(ctx context.Context) error
+	// Import inserts the rows of backup. Rows that already exist fail with
+	// ErrImportConflict. Only meaningful inside a UnitOfWork.
+	Import(ctx context.Context, backup *entity.Backup) error
+}
+
+type backupRepo struct {
+	db adapter.DB
+}
+
+func NewBackupRepository(db adapter.DB) BackupRepository {
+	return &backupRepo{db: db}
+}
+
+func (r *backupRepo) Export(ctx context.Context) (*entity.Backup, error) {
+	ctx, span := tracing.Start(ctx, "BackupRepository.Export")
+	defer span.End()
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	backup := &entity.Backup{
+		Teams:        []entity.BackupTeam{},
+		Users:        []entity.BackupUser{},
+		PullRequests: []entity.BackupPullRequest{},
+		Reviewers:    []entity.BackupReviewer{},
+	}
+
+	err = scanEach(ctx, tx, `SELECT name FROM teams ORDER BY name`, func(rows adapter.Rows) error {
+		var t entity.BackupTeam
+		if err := rows.Scan(&t.Name); err != nil {
+			return err
+		}
+		backup.Teams = append(backup.Teams, t)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = scanEach(ctx, tx, `SELECT id, username, team_name, is_active FROM users ORDER BY id`, func(rows adapter.Rows) error {
+		var u entity.BackupUser
+		if err := rows.Scan(&u.ID, &u.Username, &u.TeamName, &u.IsActive); err != nil {
+			return err
+		}
+		backup.Users = append(backup.Users, u)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = scanEach(ctx, tx, `
+		SELECT id, name, author_id, status, created_at, merged_at, sla_deadline FROM pull_requests ORDER BY id
+	`, func(rows adapter.Rows) error {
+		var pr entity.BackupPullRequest
+		var createdAt *time.Time
+		if err := rows.Scan(&pr.ID, &pr.Name, &pr.AuthorID, &pr.Status, &createdAt, &pr.MergedAt, &pr.SLADeadline); err != nil {
+			return err
+		}
+		if createdAt != nil {
+			pr.CreatedAt = *createdAt
+		}
+		backup.PullRequests = append(backup.PullRequests, pr)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = scanEach(ctx, tx, `
+		SELECT pr_id, user_id, assigned_at FROM pr_reviewers ORDER BY pr_id, assigned_at, user_id
+	`, func(rows adapter.Rows) error {
+		var rv entity.BackupReviewer
+		if err := rows.Scan(&rv.PullRequestID, &rv.UserID, &rv.AssignedAt); err != nil {
+			return err
+		}
+		backup.Reviewers = append(backup.Reviewers, rv)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return backup, nil
+}
+
+func (r *backupRepo) Clear(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "BackupRepository.Clear")
+	defer span.End()
+
+	for _, table := range []string{
+		"notifications", "notification_settings", "audit_log",
+		"reviewer_reassignments", "pr_reviewers", "pull_requests", "users", "teams",
+	} {
+		if _, err := r.db.Exec(ctx, `DELETE FROM `+table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *backupRepo) Import(ctx context.Context, backup *entity.Backup) error {
+	ctx, span := tracing.Start(ctx, "BackupRepository.Import")
+	defer span.End()
+
+	for _, t := range backup.Teams {
+		if err := r.insert(ctx, `INSERT INTO teams (name) VALUES ($1)`, t.Name); err != nil {
+			return err
+		}
+	}
+	for _, u := range backup.Users {
+		err := r.insert(ctx, `
+			INSERT INTO users (id, username, is_active, team_name) VALUES ($1, $2, $3, $4)
+		`, u.ID, u.Username, u.IsActive, u.TeamName)
+		if err != nil {
+			return err
+		}
+	}
+	for _, pr := range backup.PullRequests {
+		var met *bool
+		if pr.MergedAt != nil {
+			met = slaMet(pr.SLADeadline, *pr.MergedAt)
+		}
+		err := r.insert(ctx, `
+			INSERT INTO pull_requests (id, name, author_id, status, created_at, merged_at, sla_deadline, sla_met)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, pr.ID, pr.Name, pr.AuthorID, pr.Status, pr.CreatedAt, pr.MergedAt, pr.SLADeadline, met)
+		if err != nil {
+			return err
+		}
+	}
+	for _, rv := range backup.Reviewers {
+		err := r.insert(ctx, `
+			INSERT INTO pr_reviewers (pr_id, user_id, assigned_at) VALUES ($1, $2, $3)
+		`, rv.PullRequestID, rv.UserID, rv.AssignedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *backupRepo) insert(ctx context.Context, query string, args ...any) error {
+	_, err := r.db.Exec(ctx, query, args...)
+	if err == nil {
+		return nil
+	}
+
+	dialect := r.db.Dialect()
+	if dialect.IsUniqueViolation(err) {
+		return entity.ErrImportConflict
+	}
+	if dialect.IsForeignKeyViolation(err) {
+		return entity.ErrNotFound
+	}
+	return err
+}
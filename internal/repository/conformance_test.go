@@ -0,0 +1,1112 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/xddprog/avito-test-task/internal/entity"
+	adapter "github.com/xddprog/avito-test-task/pkg/db/adapter"
+	db "github.com/xddprog/avito-test-task/pkg/db/migration"
+)
+
+// conformanceBackend is one storage implementation under test. Every call to
+// the factory must return empty storage.
+type conformanceBackend struct {
+	uow           UnitOfWork
+	prs           PullRequestRepository
+	users         UserRepository
+	teams         TeamRepository
+	stats         StatsRepository
+	backup        BackupRepository
+	audit         AuditRepository
+	notifications NotificationRepository
+	idempotency   IdempotencyRepository
+}
+
+func TestMemoryConformance(t *testing.T) {
+	runConformance(t, func(t *testing.T) conformanceBackend {
+		store := NewMemoryStore()
+		return conformanceBackend{
+			uow:           store.UnitOfWork(),
+			prs:           store.PullRequests(),
+			users:         store.Users(),
+			teams:         store.Teams(),
+			stats:         store.Stats(),
+			backup:        store.Backup(),
+			audit:         store.Audit(),
+			notifications: store.Notifications(),
+			idempotency:   store.Idempotency(),
+		}
+	})
+}
+
+// TestPostgresConformance runs against the database in TEST_POSTGRES_DSN.
+// The database is migrated and truncated, so never point it at real data.
+func TestPostgresConformance(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	if err := db.RunMigrations("file://../../migrations/postgres", dsn); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	pool, err := pgxpool.New(context.Background(), dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	runConformance(t, func(t *testing.T) conformanceBackend {
+		_, err := pool.Exec(context.Background(), `
+			TRUNCATE teams, users, pull_requests, pr_reviewers, reviewer_reassignments, audit_log,
+				notification_settings, notifications, idempotency_keys CASCADE
+		`)
+		if err != nil {
+			t.Fatalf("truncate: %v", err)
+		}
+		return sqlBackend(adapter.NewPostgres(pool))
+	})
+}
+
+// TestSQLiteConformance gives every case its own database file.
+func TestSQLiteConformance(t *testing.T) {
+	runConformance(t, func(t *testing.T) conformanceBackend {
+		path := filepath.Join(t.TempDir(), "reviewer.db")
+		if err := db.RunMigrations("file://../../migrations/sqlite", adapter.SQLiteURL(path)); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+		conn, err := adapter.OpenSQLite(path)
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		return sqlBackend(conn)
+	})
+}
+
+// TestSQLiteStatsReadDuringWrite checks that reading stats does not wait for
+// the SQLite write lock and sees only committed data.
+func TestSQLiteStatsReadDuringWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reviewer.db")
+	if err := db.RunMigrations("file://../../migrations/sqlite", adapter.SQLiteURL(path)); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	conn, err := adapter.OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	b := sqlBackend(conn)
+	ctx := context.Background()
+	seedTeam(t, b, "backend", member("u1", true), member("u2", true))
+	seedPR(t, b, "pr-1", "u1", "u2")
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	pr := &entity.PullRequest{BasePullRequest: entity.BasePullRequest{ID: "pr-2", Name: "pr-2", AuthorID: "u1"}}
+	if err := NewPullRequestRepository(tx).Create(ctx, pr); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	started := time.Now()
+	status, err := b.stats.GetPRStatus(ctx, entity.StatsFilter{})
+	if err != nil {
+		t.Fatalf("pr status during a write: %v", err)
+	}
+	if waited := time.Since(started); waited > time.Second {
+		t.Fatalf("stats waited %s for the writer", waited)
+	}
+	expectEqual(t, status.Total, 1)
+}
+
+func sqlBackend(conn adapter.DB) conformanceBackend {
+	return conformanceBackend{
+		uow:           NewUnitOfWork(conn),
+		prs:           NewPullRequestRepository(conn),
+		users:         NewUserRepository(conn),
+		teams:         NewTeamRepository(conn),
+		stats:         NewStatsRepository(conn),
+		backup:        NewBackupRepository(conn),
+		audit:         NewAuditRepository(conn),
+		notifications: NewNotificationRepository(conn),
+		idempotency:   NewIdempotencyRepository(conn),
+	}
+}
+
+func runConformance(t *testing.T, newBackend func(t *testing.T) conformanceBackend) {
+	cases := map[string]func(t *testing.T, b conformanceBackend){
+		"Teams":         testTeams,
+		"Users":         testUsers,
+		"PullRequests":  testPullRequests,
+		"CreateBatch":   testCreateBatch,
+		"Deactivation":  testDeactivation,
+		"UnitOfWork":    testUnitOfWork,
+		"Backup":        testBackup,
+		"Stats":         testStats,
+		"OpenReviews":   testOpenReviewAssignments,
+		"Audit":         testAudit,
+		"SLA":           testSLA,
+		"Notifications": testNotifications,
+		"Idempotency":   testIdempotency,
+	}
+	names := make([]string, 0, len(cases))
+	for name := range cases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			cases[name](t, newBackend(t))
+		})
+	}
+}
+
+func seedTeam(t *testing.T, b conformanceBackend, name string, members ...entity.User) *entity.Team {
+	t.Helper()
+	team := &entity.Team{Name: name, Members: members}
+	if err := b.teams.Create(context.Background(), team); err != nil {
+		t.Fatalf("create team %s: %v", name, err)
+	}
+	return team
+}
+
+func seedPR(t *testing.T, b conformanceBackend, id, authorID string, reviewers ...string) *entity.PullRequest {
+	t.Helper()
+	pr := &entity.PullRequest{
+		BasePullRequest: entity.BasePullRequest{ID: id, Name: id, AuthorID: authorID},
+		Reviewers:       reviewers,
+	}
+	if err := b.prs.Create(context.Background(), pr); err != nil {
+		t.Fatalf("create pr %s: %v", id, err)
+	}
+	return pr
+}
+
+func member(id string, active bool) entity.User {
+	return entity.User{ID: id, Username: id, IsActive: active}
+}
+
+func expectErr(t *testing.T, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("expected %v, got %v", want, err)
+	}
+}
+
+func expectEqual(t *testing.T, got, want any) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+func testTeams(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+
+	team := seedTeam(t, b, "backend", member("u1", true), member("u2", false))
+	expectEqual(t, team.Version, 1)
+	expectEqual(t, team.Members[0].Version, 1)
+
+	expectErr(t, b.teams.Create(ctx, &entity.Team{Name: "backend"}), entity.ErrTeamExists)
+
+	got, err := b.teams.GetByName(ctx, "backend")
+	if err != nil {
+		t.Fatalf("get team: %v", err)
+	}
+	sort.Slice(got.Members, func(i, j int) bool { return got.Members[i].ID < got.Members[j].ID })
+	expectEqual(t, got, &entity.Team{
+		Name: "backend",
+		Members: []entity.User{
+			{ID: "u1", Username: "u1", IsActive: true, TeamName: "backend", Version: 1},
+			{ID: "u2", Username: "u2", IsActive: false, TeamName: "backend", Version: 1},
+		},
+		Version: 1,
+	})
+
+	_, err = b.teams.GetByName(ctx, "missing")
+	expectErr(t, err, entity.ErrNotFound)
+	_, err = b.teams.LockByName(ctx, "missing")
+	expectErr(t, err, entity.ErrNotFound)
+
+	// Adding an existing user to another team moves them and bumps the version.
+	moved := seedTeam(t, b, "frontend", member("u2", true))
+	expectEqual(t, moved.Members[0].Version, 2)
+
+	user, err := b.users.GetByID(ctx, "u2")
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	expectEqual(t, user, &entity.User{ID: "u2", Username: "u2", IsActive: true, TeamName: "frontend", Version: 2})
+}
+
+func testUsers(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	seedTeam(t, b, "backend", member("u1", true), member("u2", true), member("u3", false))
+
+	_, err := b.users.GetByID(ctx, "missing")
+	expectErr(t, err, entity.ErrNotFound)
+
+	active, err := b.users.GetActiveByTeamID(ctx, "backend")
+	if err != nil {
+		t.Fatalf("get active: %v", err)
+	}
+	expectEqual(t, len(active), 2)
+
+	updated, err := b.users.UpdateActivity(ctx, "u1", false, 1)
+	if err != nil {
+		t.Fatalf("update activity: %v", err)
+	}
+	expectEqual(t, updated.IsActive, false)
+	expectEqual(t, updated.Version, 2)
+
+	version, err := b.teams.LockByName(ctx, "backend")
+	if err != nil {
+		t.Fatalf("lock team: %v", err)
+	}
+	expectEqual(t, version, 2)
+
+	_, err = b.users.UpdateActivity(ctx, "u1", true, 1)
+	expectErr(t, err, entity.ErrConflictVersion)
+	_, err = b.users.UpdateActivity(ctx, "missing", true, 1)
+	expectErr(t, err, entity.ErrNotFound)
+	_, err = b.users.UpdateActivity(ctx, "missing", true, 0)
+	expectErr(t, err, entity.ErrNotFound)
+
+	updated, err = b.users.UpdateActivity(ctx, "u1", true, 0)
+	if err != nil {
+		t.Fatalf("unconditional update: %v", err)
+	}
+	expectEqual(t, updated.Version, 3)
+}
+
+func testPullRequests(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	seedTeam(t, b, "backend", member("u1", true), member("u2", true), member("u3", true), member("u4", true))
+
+	pr := seedPR(t, b, "pr-1", "u1", "u3", "u2")
+	expectEqual(t, pr.Version, 1)
+
+	expectErr(t, b.prs.Create(ctx, &entity.PullRequest{
+		BasePullRequest: entity.BasePullRequest{ID: "pr-1", Name: "again", AuthorID: "u1"},
+	}), entity.ErrPRExists)
+	expectErr(t, b.prs.Create(ctx, &entity.PullRequest{
+		BasePullRequest: entity.BasePullRequest{ID: "pr-2", Name: "orphan", AuthorID: "missing"},
+	}), entity.ErrNotFound)
+
+	got, err := b.prs.GetByID(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("get pr: %v", err)
+	}
+	expectEqual(t, got.Status, entity.StatusOpen)
+	expectEqual(t, got.Reviewers, []string{"u2", "u3"})
+	if got.CreatedAt == nil || got.MergedAt != nil {
+		t.Fatalf("unexpected timestamps: created %v, merged %v", got.CreatedAt, got.MergedAt)
+	}
+
+	_, err = b.prs.GetByID(ctx, "missing")
+	expectErr(t, err, entity.ErrNotFound)
+
+	expectErr(t, b.prs.Reassign(ctx, "pr-1", "u2", "u4", entity.ReasonManual, 7), entity.ErrConflictVersion)
+	expectErr(t, b.prs.Reassign(ctx, "pr-1", "u4", "u2", entity.ReasonManual, 1), entity.ErrNotAssigned)
+	if err := b.prs.Reassign(ctx, "pr-1", "u2", "u4", entity.ReasonManual, 1); err != nil {
+		t.Fatalf("reassign: %v", err)
+	}
+
+	got, err = b.prs.GetByIDForUpdate(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("get pr for update: %v", err)
+	}
+	expectEqual(t, got.Version, 2)
+	expectEqual(t, got.Reviewers, []string{"u3", "u4"})
+
+	assigned, err := b.users.GetAssignedPRs(ctx, "u4")
+	if err != nil {
+		t.Fatalf("assigned prs: %v", err)
+	}
+	expectEqual(t, assigned, []entity.BasePullRequest{{ID: "pr-1", Name: "pr-1", AuthorID: "u1", Status: entity.StatusOpen}})
+
+	_, err = b.prs.Merge(ctx, "pr-1", 1)
+	expectErr(t, err, entity.ErrConflictVersion)
+	_, err = b.prs.Merge(ctx, "missing", 1)
+	expectErr(t, err, entity.ErrNotFound)
+
+	merged, err := b.prs.Merge(ctx, "pr-1", 2)
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	expectEqual(t, merged.Status, entity.StatusMerged)
+	expectEqual(t, merged.Version, 3)
+	if merged.MergedAt == nil {
+		t.Fatal("merged PR has no merge time")
+	}
+}
+
+func testOpenReviewAssignments(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	seedTeam(t, b, "backend", member("u1", true), member("u2", true), member("u3", true), member("u4", true))
+	seedTeam(t, b, "frontend", member("f1", true), member("f2", true))
+	seedPR(t, b, "pr-2", "u1", "u3", "u2")
+	seedPR(t, b, "pr-1", "f1", "f2")
+	seedPR(t, b, "pr-3", "u4", "u1")
+	if _, err := b.prs.Merge(ctx, "pr-3", 1); err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+
+	list := func(before time.Time) []string {
+		t.Helper()
+		assignments, err := b.prs.GetOpenReviewAssignments(ctx, before)
+		if err != nil {
+			t.Fatalf("open review assignments: %v", err)
+		}
+		ids := []string{}
+		for _, a := range assignments {
+			ids = append(ids, a.PullRequestID+"/"+a.TeamName+"/"+a.ReviewerID)
+		}
+		return ids
+	}
+
+	expectEqual(t, list(time.Now().Add(-time.Hour)), []string{})
+	expectEqual(t, list(time.Now().Add(time.Minute)), []string{"pr-1/frontend/f2", "pr-2/backend/u2", "pr-2/backend/u3"})
+
+	time.Sleep(10 * time.Millisecond)
+	cutoff := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	if err := b.prs.Reassign(ctx, "pr-2", "u2", "u4", entity.ReasonManual, 1); err != nil {
+		t.Fatalf("reassign: %v", err)
+	}
+	expectEqual(t, list(time.Now().Add(time.Minute)), []string{"pr-1/frontend/f2", "pr-2/backend/u3", "pr-2/backend/u4"})
+	expectEqual(t, list(cutoff), []string{"pr-1/frontend/f2", "pr-2/backend/u3"})
+}
+
+func testSLA(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	seedTeam(t, b, "backend", member("u1", true), member("u2", true), member("u3", true))
+	seedTeam(t, b, "frontend", member("f1", true), member("f2", true), member("f3", true))
+
+	// Whole seconds survive every backend's timestamp precision.
+	now := time.Now().Truncate(time.Second)
+	create := func(id, authorID string, created, deadline time.Duration, reviewers ...string) {
+		t.Helper()
+		createdAt := now.Add(created)
+		pr := &entity.PullRequest{
+			BasePullRequest: entity.BasePullRequest{ID: id, Name: id, AuthorID: authorID},
+			CreatedAt:       &createdAt,
+			Reviewers:       reviewers,
+		}
+		if deadline != 0 {
+			slaDeadline := now.Add(deadline)
+			pr.SLADeadline = &slaDeadline
+		}
+		if err := b.prs.Create(ctx, pr); err != nil {
+			t.Fatalf("create pr %s: %v", id, err)
+		}
+	}
+	create("pr-met", "u1", -time.Hour, time.Hour, "u2")
+	create("pr-late", "u1", -3*time.Hour, -time.Hour, "u2", "u3")
+	create("pr-none", "u1", -time.Hour, 0, "u3")
+	create("pr-overdue", "f1", -2*time.Hour, -time.Minute, "f2")
+	create("pr-pending", "f1", -time.Hour, time.Hour, "f2")
+
+	// f2 hands over pr-overdue after its deadline, so the breach stays with
+	// f2 too, and pr-pending before it.
+	for _, id := range []string{"pr-overdue", "pr-pending"} {
+		if err := b.prs.Reassign(ctx, id, "f2", "f3", entity.ReasonManual, 1); err != nil {
+			t.Fatalf("reassign %s: %v", id, err)
+		}
+	}
+
+	got, err := b.prs.GetByID(ctx, "pr-late")
+	if err != nil {
+		t.Fatalf("get pr: %v", err)
+	}
+	if !got.CreatedAt.Equal(now.Add(-3*time.Hour)) || got.SLADeadline == nil || !got.SLADeadline.Equal(now.Add(-time.Hour)) {
+		t.Fatalf("unexpected times: created %v, deadline %v", got.CreatedAt, got.SLADeadline)
+	}
+	if got.SLAMet != nil {
+		t.Fatalf("open PR has sla_met %v", *got.SLAMet)
+	}
+
+	for id, want := range map[string]*bool{"pr-met": ptr(true), "pr-late": ptr(false), "pr-none": nil} {
+		merged, err := b.prs.Merge(ctx, id, 1)
+		if err != nil {
+			t.Fatalf("merge %s: %v", id, err)
+		}
+		expectEqual(t, merged.SLAMet, want)
+	}
+
+	report, err := b.stats.GetSLA(ctx, entity.StatsFilter{}, now)
+	if err != nil {
+		t.Fatalf("sla: %v", err)
+	}
+	expectEqual(t, report.ByTeam, []entity.TeamSLA{
+		{TeamName: "backend", SLAOutcome: entity.SLAOutcome{Total: 2, Met: 1, Breached: 1}},
+		{TeamName: "frontend", SLAOutcome: entity.SLAOutcome{Total: 2, Breached: 1, Pending: 1}},
+	})
+	expectEqual(t, report.ByReviewer, []entity.ReviewerSLA{
+		{UserID: "u2", TeamName: "backend", SLAOutcome: entity.SLAOutcome{Total: 2, Met: 1, Breached: 1}},
+		{UserID: "u3", TeamName: "backend", SLAOutcome: entity.SLAOutcome{Total: 1, Breached: 1}},
+		{UserID: "f2", TeamName: "frontend", SLAOutcome: entity.SLAOutcome{Total: 1, Breached: 1}},
+		{UserID: "f3", TeamName: "frontend", SLAOutcome: entity.SLAOutcome{Total: 2, Breached: 1, Pending: 1}},
+	})
+
+	// Two hours later the pending PR is overdue too.
+	report, err = b.stats.GetSLA(ctx, entity.StatsFilter{TeamName: "frontend"}, now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("sla: %v", err)
+	}
+	expectEqual(t, report.ByTeam, []entity.TeamSLA{
+		{TeamName: "frontend", SLAOutcome: entity.SLAOutcome{Total: 2, Breached: 2}},
+	})
+	expectEqual(t, report.ByReviewer, []entity.ReviewerSLA{
+		{UserID: "f2", TeamName: "frontend", SLAOutcome: entity.SLAOutcome{Total: 1, Breached: 1}},
+		{UserID: "f3", TeamName: "frontend", SLAOutcome: entity.SLAOutcome{Total: 2, Breached: 2}},
+	})
+}
+
+func testNotifications(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+
+	_, err := b.notifications.GetSettings(ctx, "u1")
+	expectErr(t, err, entity.ErrNotFound)
+
+	settings := &entity.NotificationSettings{
+		UserID:     "u1",
+		Channels:   []entity.NotificationChannel{entity.ChannelEmail, entity.ChannelChat},
+		Email:      "u1@example.com",
+		QuietHours: &entity.QuietHours{Start: "22:00", End: "08:00", Timezone: "Europe/Moscow"},
+	}
+	if err := b.notifications.SaveSettings(ctx, settings); err != nil {
+		t.Fatalf("save settings: %v", err)
+	}
+	got, err := b.notifications.GetSettings(ctx, "u1")
+	if err != nil {
+		t.Fatalf("get settings: %v", err)
+	}
+	expectEqual(t, got, settings)
+
+	settings = &entity.NotificationSettings{UserID: "u1", Channels: []entity.NotificationChannel{}}
+	if err := b.notifications.SaveSettings(ctx, settings); err != nil {
+		t.Fatalf("overwrite settings: %v", err)
+	}
+	got, err = b.notifications.GetSettings(ctx, "u1")
+	if err != nil {
+		t.Fatalf("get settings: %v", err)
+	}
+	expectEqual(t, got, settings)
+
+	now := time.Now().Truncate(time.Second)
+	enqueue := func(userID string, deliverAfter time.Duration) int64 {
+		t.Helper()
+		n := &entity.Notification{
+			UserID:        userID,
+			Channel:       entity.ChannelLog,
+			Kind:          entity.NotifyReviewAssigned,
+			Recipient:     userID,
+			PullRequestID: "pr-1",
+			Subject:       "subject",
+			Body:          "body",
+			CreatedAt:     now,
+			DeliverAfter:  now.Add(deliverAfter),
+		}
+		if err := b.notifications.Enqueue(ctx, n); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		expectEqual(t, n.Status, entity.NotificationPending)
+		return n.ID
+	}
+	quiet := enqueue("u1", time.Hour)
+	first := enqueue("u2", -time.Minute)
+	second := enqueue("u1", -2*time.Minute)
+
+	claim := func(at time.Time, lease time.Duration) []int64 {
+		t.Helper()
+		lockedUntil := at.Add(lease)
+		claimed, err := b.notifications.ClaimDue(ctx, at, lockedUntil, 10)
+		if err != nil {
+			t.Fatalf("claim due: %v", err)
+		}
+		ids := []int64{}
+		for _, n := range claimed {
+			expectEqual(t, n.Status, entity.NotificationSending)
+			if n.LockedUntil == nil || !n.LockedUntil.Equal(lockedUntil) {
+				t.Fatalf("notification %d locked until %v, want %v", n.ID, n.LockedUntil, lockedUntil)
+			}
+			ids = append(ids, n.ID)
+		}
+		return ids
+	}
+	expectEqual(t, claim(now, time.Minute), []int64{second, first})
+	expectEqual(t, claim(now, time.Minute), []int64{})
+	// The claims lapse after a minute and go to the next caller.
+	expectEqual(t, claim(now.Add(2*time.Hour), time.Hour), []int64{second, first, quiet})
+
+	if err := b.notifications.MarkSent(ctx, second, now); err != nil {
+		t.Fatalf("mark sent: %v", err)
+	}
+	retryAt := now.Add(time.Minute)
+	if err := b.notifications.MarkFailed(ctx, first, "connection refused", &retryAt); err != nil {
+		t.Fatalf("mark failed: %v", err)
+	}
+	expectEqual(t, claim(now, time.Minute), []int64{})
+	expectEqual(t, claim(retryAt, time.Minute), []int64{first})
+	if err := b.notifications.MarkFailed(ctx, first, "connection refused", nil); err != nil {
+		t.Fatalf("mark failed: %v", err)
+	}
+	expectErr(t, b.notifications.MarkSent(ctx, 999, now), entity.ErrNotFound)
+
+	list, err := b.notifications.List(ctx, entity.NotificationFilter{UserID: "u2"})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	expectEqual(t, len(list), 1)
+	expectEqual(t, [3]any{list[0].Status, list[0].Attempts, list[0].LastError}, [3]any{entity.NotificationFailed, 2, "connection refused"})
+
+	list, err = b.notifications.List(ctx, entity.NotificationFilter{Status: entity.NotificationSent})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != second || list[0].SentAt == nil || !list[0].SentAt.Equal(now) || list[0].LockedUntil != nil {
+		t.Fatalf("unexpected sent notifications: %+v", list)
+	}
+
+	list, err = b.notifications.List(ctx, entity.NotificationFilter{Limit: 2})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	expectEqual(t, []int64{list[0].ID, list[1].ID}, []int64{second, first})
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func testAudit(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	entries := []*entity.AuditEntry{
+		{OccurredAt: base, Actor: "scheduler", Action: entity.AuditReviewReminder, PullRequestID: "pr-1", UserID: "u2", TeamName: "backend"},
+		{OccurredAt: base.Add(time.Hour), Actor: "scheduler", Action: entity.AuditReviewReassigned, PullRequestID: "pr-1", UserID: "u2", TeamName: "backend",
+			Details: map[string]string{"new_user_id": "u3"}},
+		{OccurredAt: base.Add(time.Hour), Actor: "scheduler", Action: entity.AuditReviewReminder, PullRequestID: "pr-2", UserID: "f2", TeamName: "frontend"},
+	}
+	for _, e := range entries {
+		if err := b.audit.Record(ctx, e); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	if entries[0].ID == 0 || entries[1].ID <= entries[0].ID || entries[2].ID <= entries[1].ID {
+		t.Fatalf("ids are not increasing: %d, %d, %d", entries[0].ID, entries[1].ID, entries[2].ID)
+	}
+
+	list := func(filter entity.AuditFilter) []int64 {
+		t.Helper()
+		got, err := b.audit.List(ctx, filter)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		ids := []int64{}
+		for _, e := range got {
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+	id := func(i int) int64 { return entries[i].ID }
+
+	expectEqual(t, list(entity.AuditFilter{}), []int64{id(2), id(1), id(0)})
+	expectEqual(t, list(entity.AuditFilter{Limit: 2}), []int64{id(2), id(1)})
+	expectEqual(t, list(entity.AuditFilter{Action: entity.AuditReviewReminder}), []int64{id(2), id(0)})
+	expectEqual(t, list(entity.AuditFilter{PullRequestID: "pr-1", UserID: "u2"}), []int64{id(1), id(0)})
+	expectEqual(t, list(entity.AuditFilter{TeamName: "frontend"}), []int64{id(2)})
+	from, to := base.Add(time.Minute), base.Add(time.Hour)
+	expectEqual(t, list(entity.AuditFilter{From: &from}), []int64{id(2), id(1)})
+	expectEqual(t, list(entity.AuditFilter{To: &to}), []int64{id(0)})
+
+	got, err := b.audit.List(ctx, entity.AuditFilter{Action: entity.AuditReviewReassigned})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(got) != 1 || !got[0].OccurredAt.Equal(base.Add(time.Hour)) {
+		t.Fatalf("unexpected entries: %+v", got)
+	}
+	got[0].OccurredAt = time.Time{}
+	expectEqual(t, got[0], entity.AuditEntry{
+		ID: id(1), Actor: "scheduler", Action: entity.AuditReviewReassigned, PullRequestID: "pr-1", UserID: "u2", TeamName: "backend",
+		Details: map[string]string{"new_user_id": "u3"},
+	})
+
+	for i, want := range []bool{true, false} {
+		entry := &entity.AuditEntry{OccurredAt: base, Actor: "scheduler", Action: entity.AuditReviewEscalated,
+			PullRequestID: "pr-1", UserID: "u2", Key: "escalated:pr-1:u2"}
+		recorded, err := b.audit.RecordOnce(ctx, entry)
+		if err != nil {
+			t.Fatalf("record once #%d: %v", i+1, err)
+		}
+		if recorded != want {
+			t.Fatalf("record once #%d = %t, want %t", i+1, recorded, want)
+		}
+	}
+	expectEqual(t, len(list(entity.AuditFilter{Action: entity.AuditReviewEscalated})), 1)
+}
+
+func testIdempotency(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	now := time.Now()
+	scope := entity.IdempotencyScope{Client: "c1", Key: "k1", Method: "POST", Path: "/pullRequest/create"}
+	reserve := func(scope entity.IdempotencyScope, lockedUntil time.Time) bool {
+		t.Helper()
+		ok, err := b.idempotency.Reserve(ctx, &entity.IdempotencyRecord{
+			IdempotencyScope: scope, RequestHash: "h1", LockedUntil: lockedUntil, ExpiresAt: now.Add(time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("reserve: %v", err)
+		}
+		return ok
+	}
+
+	expectEqual(t, reserve(scope, now.Add(time.Minute)), true)
+	expectEqual(t, reserve(scope, now.Add(time.Minute)), false)
+	other := scope
+	other.Client = "c2"
+	expectEqual(t, reserve(other, now.Add(time.Minute)), true)
+
+	// A request whose lock has lapsed gives the key up to the next one.
+	stale := scope
+	stale.Key = "k2"
+	expectEqual(t, reserve(stale, now.Add(-time.Second)), true)
+	expectEqual(t, reserve(stale, now.Add(time.Minute)), true)
+	expectEqual(t, reserve(stale, now.Add(time.Minute)), false)
+
+	// A completed response is kept for the whole TTL, whatever the lock says.
+	done := &entity.IdempotencyRecord{IdempotencyScope: scope, StatusCode: 201, ContentType: "application/json", ETag: `"3"`, Body: []byte(`{}`)}
+	if err := b.idempotency.Complete(ctx, done); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	got, err := b.idempotency.Get(ctx, scope)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	expectEqual(t, got.StatusCode, 201)
+	expectEqual(t, got.RequestHash, "h1")
+	expectEqual(t, got.ETag, `"3"`)
+	expectEqual(t, reserve(scope, now.Add(time.Minute)), false)
+
+	if err := b.idempotency.Release(ctx, other); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	_, err = b.idempotency.Get(ctx, other)
+	expectErr(t, err, entity.ErrNotFound)
+	if _, err := b.idempotency.Get(ctx, scope); err != nil {
+		t.Fatalf("get after releasing another client's key: %v", err)
+	}
+}
+
+func testCreateBatch(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	seedTeam(t, b, "backend", member("u1", true), member("u2", true), member("u3", false))
+	seedPR(t, b, "pr-1", "u1")
+
+	newPR := func(id, authorID string, reviewers ...string) *entity.PullRequest {
+		return &entity.PullRequest{
+			BasePullRequest: entity.BasePullRequest{ID: id, Name: id, AuthorID: authorID},
+			Reviewers:       reviewers,
+		}
+	}
+
+	expectErr(t, b.prs.CreateBatch(ctx, []*entity.PullRequest{newPR("pr-2", "u1"), newPR("pr-1", "u2")}), entity.ErrPRExists)
+	expectErr(t, b.prs.CreateBatch(ctx, []*entity.PullRequest{newPR("pr-2", "u1"), newPR("pr-3", "missing")}), entity.ErrNotFound)
+	_, err := b.prs.GetByID(ctx, "pr-2")
+	expectErr(t, err, entity.ErrNotFound)
+
+	batch := []*entity.PullRequest{newPR("pr-2", "u1", "u3", "u2"), newPR("pr-3", "u2")}
+	if err := b.prs.CreateBatch(ctx, batch); err != nil {
+		t.Fatalf("create batch: %v", err)
+	}
+	for _, pr := range batch {
+		expectEqual(t, pr.Version, 1)
+	}
+
+	got, err := b.prs.GetByID(ctx, "pr-2")
+	if err != nil {
+		t.Fatalf("get pr: %v", err)
+	}
+	expectEqual(t, got.Status, entity.StatusOpen)
+	expectEqual(t, got.Reviewers, []string{"u2", "u3"})
+
+	got, err = b.prs.GetByID(ctx, "pr-3")
+	if err != nil {
+		t.Fatalf("get pr: %v", err)
+	}
+	expectEqual(t, got.Reviewers, []string{})
+
+	if err := b.prs.CreateBatch(ctx, nil); err != nil {
+		t.Fatalf("create empty batch: %v", err)
+	}
+}
+
+func testDeactivation(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	seedTeam(t, b, "backend", member("u1", true), member("u2", true), member("u3", true), member("u4", true))
+	seedPR(t, b, "pr-1", "u1", "u2", "u3")
+	seedPR(t, b, "pr-2", "u1", "u2")
+	seedPR(t, b, "pr-3", "u1", "u2")
+	if _, err := b.prs.Merge(ctx, "pr-3", 1); err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+
+	empty, err := b.prs.GetOpenAssignmentsForUsers(ctx, nil)
+	if err != nil {
+		t.Fatalf("open assignments: %v", err)
+	}
+	expectEqual(t, empty, []entity.ReviewerAssignment{})
+
+	assignments, err := b.prs.GetOpenAssignmentsForUsers(ctx, []string{"u3", "u2"})
+	if err != nil {
+		t.Fatalf("open assignments: %v", err)
+	}
+	var keys []string
+	for _, a := range assignments {
+		keys = append(keys, a.PullRequestID+"/"+a.OldReviewerID)
+		expectEqual(t, a.AuthorID, "u1")
+	}
+	expectEqual(t, keys, []string{"pr-1/u2", "pr-1/u3", "pr-2/u2"})
+
+	err = b.prs.ApplyReviewerReplacements(ctx, []entity.ReassignmentResult{
+		{PullRequestID: "pr-1", OldReviewerID: "u2", NewReviewerID: "u4"},
+		{PullRequestID: "pr-2", OldReviewerID: "u2", NewReviewerID: "u4"},
+	}, entity.ReasonDeactivation)
+	if err != nil {
+		t.Fatalf("apply replacements: %v", err)
+	}
+
+	pr, err := b.prs.GetByID(ctx, "pr-2")
+	if err != nil {
+		t.Fatalf("get pr: %v", err)
+	}
+	expectEqual(t, pr.Reviewers, []string{"u4"})
+	expectEqual(t, pr.Version, 2)
+
+	_, err = b.teams.DeactivateMembers(ctx, "backend", nil, 0)
+	expectErr(t, err, entity.ErrBadRequest)
+	_, err = b.teams.DeactivateMembers(ctx, "missing", []string{"u2"}, 0)
+	expectErr(t, err, entity.ErrNotFound)
+	_, err = b.teams.DeactivateMembers(ctx, "backend", []string{"u2"}, 5)
+	expectErr(t, err, entity.ErrConflictVersion)
+
+	deactivated, err := b.teams.DeactivateMembers(ctx, "backend", []string{"u2", "unknown"}, 1)
+	if err != nil {
+		t.Fatalf("deactivate: %v", err)
+	}
+	expectEqual(t, deactivated, []string{"u2"})
+
+	version, err := b.teams.LockByName(ctx, "backend")
+	if err != nil {
+		t.Fatalf("lock team: %v", err)
+	}
+	expectEqual(t, version, 2)
+
+	deactivated, err = b.teams.DeactivateMembers(ctx, "backend", []string{"u2"}, 0)
+	if err != nil {
+		t.Fatalf("repeat deactivate: %v", err)
+	}
+	expectEqual(t, deactivated, []string{})
+
+	user, err := b.users.GetByID(ctx, "u2")
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	expectEqual(t, user.IsActive, false)
+	expectEqual(t, user.Version, 2)
+}
+
+func testUnitOfWork(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	err := b.uow.Do(ctx, func(ctx context.Context, repos Repositories) error {
+		if err := repos.Teams.Create(ctx, &entity.Team{Name: "rolled-back", Members: []entity.User{member("u1", true)}}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	expectErr(t, err, errAbort)
+
+	_, err = b.teams.GetByName(ctx, "rolled-back")
+	expectErr(t, err, entity.ErrNotFound)
+	_, err = b.users.GetByID(ctx, "u1")
+	expectErr(t, err, entity.ErrNotFound)
+
+	err = b.uow.Do(ctx, func(ctx context.Context, repos Repositories) error {
+		return repos.Teams.Create(ctx, &entity.Team{Name: "committed"})
+	})
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if _, err := b.teams.GetByName(ctx, "committed"); err != nil {
+		t.Fatalf("committed team missing: %v", err)
+	}
+}
+
+func testBackup(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	seedTeam(t, b, "backend", member("u1", true), member("u2", true), member("u3", false))
+	seedTeam(t, b, "frontend", member("f1", true))
+	deadline := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := b.prs.Create(ctx, &entity.PullRequest{
+		BasePullRequest: entity.BasePullRequest{ID: "pr-2", Name: "pr-2", AuthorID: "u1"},
+		Reviewers:       []string{"u2"},
+		SLADeadline:     &deadline,
+	}); err != nil {
+		t.Fatalf("create pr: %v", err)
+	}
+	seedPR(t, b, "pr-1", "u2", "u1", "u3")
+	if _, err := b.prs.Merge(ctx, "pr-2", 1); err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if err := b.prs.Reassign(ctx, "pr-1", "u3", "f1", entity.ReasonManual, 1); err != nil {
+		t.Fatalf("reassign: %v", err)
+	}
+
+	exported, err := b.backup.Export(ctx)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	expectEqual(t, exported.Teams, []entity.BackupTeam{{Name: "backend"}, {Name: "frontend"}})
+	userIDs := []string{}
+	for _, u := range exported.Users {
+		userIDs = append(userIDs, u.ID)
+	}
+	expectEqual(t, userIDs, []string{"f1", "u1", "u2", "u3"})
+	expectEqual(t, len(exported.PullRequests), 2)
+	expectEqual(t, exported.PullRequests[0].ID, "pr-1")
+	expectEqual(t, exported.PullRequests[1].Status, entity.StatusMerged)
+	if exported.PullRequests[1].MergedAt == nil {
+		t.Fatal("merged PR exported without merged_at")
+	}
+	if exported.PullRequests[0].SLADeadline != nil || !exported.PullRequests[1].SLADeadline.Equal(deadline) {
+		t.Fatalf("unexpected SLA deadlines: %v, %v", exported.PullRequests[0].SLADeadline, exported.PullRequests[1].SLADeadline)
+	}
+	reviewers := []string{}
+	for _, rv := range exported.Reviewers {
+		reviewers = append(reviewers, rv.PullRequestID+"/"+rv.UserID)
+	}
+	expectEqual(t, reviewers, []string{"pr-1/u1", "pr-1/f1", "pr-2/u2"})
+
+	importBackup := func(replace bool, backup *entity.Backup) error {
+		return b.uow.Do(ctx, func(ctx context.Context, repos Repositories) error {
+			if replace {
+				if err := repos.Backup.Clear(ctx); err != nil {
+					return err
+				}
+			}
+			return repos.Backup.Import(ctx, backup)
+		})
+	}
+
+	expectErr(t, importBackup(false, exported), entity.ErrImportConflict)
+	expectErr(t, importBackup(false, &entity.Backup{
+		Reviewers: []entity.BackupReviewer{{PullRequestID: "pr-1", UserID: "missing", AssignedAt: exported.Reviewers[0].AssignedAt}},
+	}), entity.ErrNotFound)
+
+	if err := b.audit.Record(ctx, &entity.AuditEntry{OccurredAt: time.Now(), Actor: "scheduler", Action: entity.AuditReviewReminder, UserID: "u2"}); err != nil {
+		t.Fatalf("record audit: %v", err)
+	}
+	if err := b.notifications.SaveSettings(ctx, &entity.NotificationSettings{UserID: "u2", Channels: []entity.NotificationChannel{}}); err != nil {
+		t.Fatalf("save settings: %v", err)
+	}
+	if err := b.notifications.Enqueue(ctx, &entity.Notification{
+		UserID: "u2", Channel: entity.ChannelLog, Kind: entity.NotifyReviewAssigned, Recipient: "u2",
+		Subject: "subject", Body: "body", DeliverAfter: time.Now(),
+	}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	if err := importBackup(true, exported); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	entries, err := b.audit.List(ctx, entity.AuditFilter{})
+	if err != nil {
+		t.Fatalf("list audit: %v", err)
+	}
+	expectEqual(t, len(entries), 0)
+	_, err = b.notifications.GetSettings(ctx, "u2")
+	expectErr(t, err, entity.ErrNotFound)
+	outbox, err := b.notifications.List(ctx, entity.NotificationFilter{})
+	if err != nil {
+		t.Fatalf("list notifications: %v", err)
+	}
+	expectEqual(t, len(outbox), 0)
+	restored, err := b.backup.Export(ctx)
+	if err != nil {
+		t.Fatalf("export after replace: %v", err)
+	}
+	expectEqual(t, restored, exported)
+
+	pr, err := b.prs.GetByID(ctx, "pr-2")
+	if err != nil {
+		t.Fatalf("get pr: %v", err)
+	}
+	expectEqual(t, pr.SLAMet, ptr(true))
+
+	user, err := b.users.GetByID(ctx, "u2")
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	expectEqual(t, user.Version, 1)
+	assignments, err := b.prs.GetOpenAssignmentsForUsers(ctx, []string{"u1"})
+	if err != nil {
+		t.Fatalf("assignments: %v", err)
+	}
+	expectEqual(t, len(assignments), 1)
+}
+
+func testStats(t *testing.T, b conformanceBackend) {
+	ctx := context.Background()
+	seedTeam(t, b, "backend", member("u1", true), member("u2", true), member("u3", true), member("u4", true))
+	seedTeam(t, b, "frontend", member("f1", true), member("f2", false))
+	seedPR(t, b, "pr-1", "u1", "u2", "u3")
+	seedPR(t, b, "pr-2", "u1", "u2")
+	if _, err := b.prs.Merge(ctx, "pr-2", 1); err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	if err := b.prs.Reassign(ctx, "pr-1", "u3", "u4", entity.ReasonManual, 1); err != nil {
+		t.Fatalf("reassign: %v", err)
+	}
+
+	all := entity.StatsFilter{}
+
+	status, err := b.stats.GetPRStatus(ctx, all)
+	if err != nil {
+		t.Fatalf("pr status: %v", err)
+	}
+	expectEqual(t, status, entity.PRStatusStat{Total: 2, Open: 1, Merged: 1, AverageReviewers: 1.5})
+
+	status, err = b.stats.GetPRStatus(ctx, entity.StatsFilter{TeamName: "frontend"})
+	if err != nil {
+		t.Fatalf("pr status: %v", err)
+	}
+	expectEqual(t, status, entity.PRStatusStat{})
+
+	assignments, err := b.stats.GetReviewerAssignments(ctx, all)
+	if err != nil {
+		t.Fatalf("reviewer assignments: %v", err)
+	}
+	expectEqual(t, assignments[0], entity.ReviewerAssignmentStat{UserID: "u2", Assignments: 2})
+	expectEqual(t, len(assignments), 2)
+
+	members, err := b.stats.GetTeamMembers(ctx, all)
+	if err != nil {
+		t.Fatalf("team members: %v", err)
+	}
+	expectEqual(t, members, []entity.TeamMemberStat{
+		{TeamName: "backend", Active: 4},
+		{TeamName: "frontend", Active: 1, Inactive: 1},
+	})
+
+	members, err = b.stats.GetTeamMembers(ctx, entity.StatsFilter{AuthorID: "f2"})
+	if err != nil {
+		t.Fatalf("team members: %v", err)
+	}
+	expectEqual(t, members, []entity.TeamMemberStat{{TeamName: "frontend", Active: 1, Inactive: 1}})
+
+	lifetime, err := b.stats.GetPRLifetime(ctx, all)
+	if err != nil {
+		t.Fatalf("lifetime: %v", err)
+	}
+	expectEqual(t, lifetime.OpenOlderThanThreshold, 1)
+	expectEqual(t, lifetime.OpenOlderThan7Days, 0)
+	expectEqual(t, lifetime.OpenAgeHistogram[0].Count, 1)
+	expectEqual(t, len(lifetime.ByTeam), 1)
+	expectEqual(t, lifetime.ByTeam[0].TeamName, "backend")
+	expectEqual(t, lifetime.ByTeam[0].Merged, 1)
+	expectEqual(t, lifetime.ByTeam[0].Open, 1)
+	expectEqual(t, len(lifetime.ByReviewerCount), 2)
+
+	points, err := b.stats.GetTimeSeries(ctx, all, entity.IntervalDay, true)
+	if err != nil {
+		t.Fatalf("time series: %v", err)
+	}
+	expectEqual(t, len(points), 1)
+	expectEqual(t, points[0].TeamName, "backend")
+	expectEqual(t, [3]int{points[0].Created, points[0].Merged, points[0].Assignments}, [3]int{2, 1, 3})
+	if points[0].MedianMerge == nil {
+		t.Fatal("time series point has no median merge time")
+	}
+
+	userStats, err := b.stats.GetUserStats(ctx, "u3", all)
+	if err != nil {
+		t.Fatalf("user stats: %v", err)
+	}
+	expectEqual(t, userStats.AssignmentsReceived, 1)
+	expectEqual(t, userStats.ReassignedAway, 1)
+	expectEqual(t, userStats.ReassignReasons, []entity.ReassignReasonStat{{Reason: entity.ReasonManual, Count: 1}})
+	expectEqual(t, userStats.OpenLoad, 0)
+	expectEqual(t, userStats.Team.ActiveMembers, 4)
+	expectEqual(t, userStats.Team.MedianAssignments, 1.0)
+	expectEqual(t, userStats.Team.MedianOpenLoad, 0.5)
+
+	userStats, err = b.stats.GetUserStats(ctx, "u2", all)
+	if err != nil {
+		t.Fatalf("user stats: %v", err)
+	}
+	expectEqual(t, userStats.MergedReviews, 1)
+	expectEqual(t, userStats.OpenLoad, 1)
+
+	_, err = b.stats.GetUserStats(ctx, "missing", all)
+	expectErr(t, err, entity.ErrNotFound)
+
+	counts, err := b.stats.GetMemberAssignmentCounts(ctx, entity.StatsFilter{TeamName: "backend"})
+	if err != nil {
+		t.Fatalf("member assignment counts: %v", err)
+	}
+	expectEqual(t, counts, []entity.MemberAssignmentCount{
+		{TeamName: "backend", UserID: "u1", Assignments: 0},
+		{TeamName: "backend", UserID: "u2", Assignments: 2},
+		{TeamName: "backend", UserID: "u3", Assignments: 1},
+		{TeamName: "backend", UserID: "u4", Assignments: 1},
+	})
+
+	open, err := b.stats.GetOpenPRsByTeam(ctx)
+	if err != nil {
+		t.Fatalf("open prs by team: %v", err)
+	}
+	expectEqual(t, open, []entity.TeamOpenPRs{{TeamName: "backend", Open: 1}, {TeamName: "frontend", Open: 0}})
+
+	load, err := b.stats.GetReviewerLoad(ctx)
+	if err != nil {
+		t.Fatalf("reviewer load: %v", err)
+	}
+	expectEqual(t, load, []entity.ReviewerLoad{
+		{UserID: "u1", TeamName: "backend", Open: 0},
+		{UserID: "u2", TeamName: "backend", Open: 1},
+		{UserID: "u3", TeamName: "backend", Open: 0},
+		{UserID: "u4", TeamName: "backend", Open: 1},
+		{UserID: "f1", TeamName: "frontend", Open: 0},
+	})
+
+	snapshot, release, err := b.stats.Snapshot(ctx)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	status, err = snapshot.GetPRStatus(ctx, all)
+	if err != nil {
+		t.Fatalf("snapshot pr status: %v", err)
+	}
+	open, err = snapshot.GetOpenPRsByTeam(ctx)
+	if err != nil {
+		t.Fatalf("snapshot open prs by team: %v", err)
+	}
+	release()
+	expectEqual(t, status, entity.PRStatusStat{Total: 2, Open: 1, Merged: 1, AverageReviewers: 1.5})
+	expectEqual(t, open, []entity.TeamOpenPRs{{TeamName: "backend", Open: 1}, {TeamName: "frontend", Open: 0}})
+}
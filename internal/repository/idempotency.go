@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+	adapter "github.com/xddprog/avito-test-task/pkg/db/adapter"
+)
+
+type IdempotencyRepository interface {
+	// Reserve claims the key for rec, taking over an expired entry or an
+	// unfinished one whose lock has lapsed. It returns false when a live entry
+	// already holds the key.
+	Reserve(ctx context.Context, rec *entity.IdempotencyRecord) (bool, error)
+	Get(ctx context.Context, scope entity.IdempotencyScope) (*entity.IdempotencyRecord, error)
+	Complete(ctx context.Context, rec *entity.IdempotencyRecord) error
+	Release(ctx context.Context, scope entity.IdempotencyScope) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type idempotencyRepo struct {
+	db adapter.DB
+}
+
+func NewIdempotencyRepository(db adapter.DB) IdempotencyRepository {
+	return &idempotencyRepo{db: db}
+}
+
+func (r *idempotencyRepo) Reserve(ctx context.Context, rec *entity.IdempotencyRecord) (bool, error) {
+	ctx, span := tracing.Start(ctx, "IdempotencyRepository.Reserve")
+	defer span.End()
+
+	var key string
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO idempotency_keys (client, key, method, path, request_hash, created_at, locked_until, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (client, key, method, path) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash,
+			status_code = NULL,
+			content_type = NULL,
+			etag = NULL,
+			response_body = NULL,
+			created_at = EXCLUDED.created_at,
+			locked_until = EXCLUDED.locked_until,
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < EXCLUDED.created_at
+			OR (idempotency_keys.status_code IS NULL AND idempotency_keys.locked_until < EXCLUDED.created_at)
+		RETURNING key
+	`, rec.Client, rec.Key, rec.Method, rec.Path, rec.RequestHash, time.Now(), rec.LockedUntil, rec.ExpiresAt).Scan(&key)
+
+	if err != nil {
+		if errors.Is(err, adapter.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *idempotencyRepo) Get(ctx context.Context, scope entity.IdempotencyScope) (*entity.IdempotencyRecord, error) {
+	ctx, span := tracing.Start(ctx, "IdempotencyRepository.Get")
+	defer span.End()
+
+	rec := entity.IdempotencyRecord{IdempotencyScope: scope}
+	var statusCode *int
+	var contentType, etag *string
+	var lockedUntil *time.Time
+	err := r.db.QueryRow(ctx, `
+		SELECT request_hash, status_code, content_type, etag, response_body, created_at, locked_until, expires_at
+		FROM idempotency_keys
+		WHERE client = $1 AND key = $2 AND method = $3 AND path = $4
+	`, scope.Client, scope.Key, scope.Method, scope.Path).Scan(&rec.RequestHash, &statusCode, &contentType, &etag, &rec.Body, &rec.CreatedAt, &lockedUntil, &rec.ExpiresAt)
+
+	if err != nil {
+		if errors.Is(err, adapter.ErrNoRows) {
+			return nil, entity.ErrNotFound
+		}
+		return nil, err
+	}
+	if statusCode != nil {
+		rec.StatusCode = *statusCode
+	}
+	rec.ContentType, rec.ETag = deref(contentType), deref(etag)
+	if lockedUntil != nil {
+		rec.LockedUntil = *lockedUntil
+	}
+	return &rec, nil
+}
+
+func (r *idempotencyRepo) Complete(ctx context.Context, rec *entity.IdempotencyRecord) error {
+	ctx, span := tracing.Start(ctx, "IdempotencyRepository.Complete")
+	defer span.End()
+
+	_, err := r.db.Exec(ctx, `
+		UPDATE idempotency_keys
+		SET status_code = $5, content_type = $6, etag = $7, response_body = $8
+		WHERE client = $1 AND key = $2 AND method = $3 AND path = $4
+	`, rec.Client, rec.Key, rec.Method, rec.Path, rec.StatusCode, rec.ContentType, nullString(rec.ETag), rec.Body)
+	return err
+}
+
+func (r *idempotencyRepo) Release(ctx context.Context, scope entity.IdempotencyScope) error {
+	ctx, span := tracing.Start(ctx, "IdempotencyRepository.Release")
+	defer span.End()
+
+	_, err := r.db.Exec(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE client = $1 AND key = $2 AND method = $3 AND path = $4 AND status_code IS NULL
+	`, scope.Client, scope.Key, scope.Method, scope.Path)
+	return err
+}
+
+func (r *idempotencyRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	ctx, span := tracing.Start(ctx, "IdempotencyRepository.DeleteExpired")
+	defer span.End()
+
+	tag, err := r.db.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at < $1`, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+)
+
+type memAuditRepo struct {
+	memoryBinding
+}
+
+func (r *memAuditRepo) Record(ctx context.Context, entry *entity.AuditEntry) error {
+	_, span := tracing.Start(ctx, "AuditRepository.Record")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	data.appendAudit(entry)
+	return nil
+}
+
+func (r *memAuditRepo) RecordOnce(ctx context.Context, entry *entity.AuditEntry) (bool, error) {
+	_, span := tracing.Start(ctx, "AuditRepository.RecordOnce")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	for _, e := range data.audit {
+		if e.Key != "" && e.Key == entry.Key {
+			return false, nil
+		}
+	}
+	data.appendAudit(entry)
+	return true, nil
+}
+
+func (d *memoryData) appendAudit(entry *entity.AuditEntry) {
+	if entry.OccurredAt.IsZero() {
+		entry.OccurredAt = time.Now()
+	}
+	d.auditSeq++
+	entry.ID = d.auditSeq
+
+	stored := *entry
+	stored.Details = maps.Clone(entry.Details)
+	d.audit = append(d.audit, stored)
+}
+
+func (r *memAuditRepo) List(ctx context.Context, filter entity.AuditFilter) ([]entity.AuditEntry, error) {
+	_, span := tracing.Start(ctx, "AuditRepository.List")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	entries := []entity.AuditEntry{}
+	for _, e := range data.audit {
+		if auditMatches(e, filter) {
+			e.Details = maps.Clone(e.Details)
+			e.Key = ""
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].OccurredAt.Equal(entries[j].OccurredAt) {
+			return entries[i].OccurredAt.After(entries[j].OccurredAt)
+		}
+		return entries[i].ID > entries[j].ID
+	})
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+	return entries, nil
+}
+
+func auditMatches(e entity.AuditEntry, f entity.AuditFilter) bool {
+	switch {
+	case f.Action != "" && e.Action != f.Action,
+		f.PullRequestID != "" && e.PullRequestID != f.PullRequestID,
+		f.UserID != "" && e.UserID != f.UserID,
+		f.TeamName != "" && e.TeamName != f.TeamName,
+		f.From != nil && e.OccurredAt.Before(*f.From),
+		f.To != nil && !e.OccurredAt.Before(*f.To):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"sort"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+)
+
+type memBackupRepo struct {
+	memoryBinding
+}
+
+func (r *memBackupRepo) Export(ctx context.Context) (*entity.Backup, error) {
+	_, span := tracing.Start(ctx, "BackupRepository.Export")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	backup := &entity.Backup{
+		Teams:        make([]entity.BackupTeam, 0, len(data.teams)),
+		Users:        make([]entity.BackupUser, 0, len(data.users)),
+		PullRequests: make([]entity.BackupPullRequest, 0, len(data.pullRequests)),
+		Reviewers:    []entity.BackupReviewer{},
+	}
+
+	for name := range data.teams {
+		backup.Teams = append(backup.Teams, entity.BackupTeam{Name: name})
+	}
+	sort.Slice(backup.Teams, func(i, j int) bool { return backup.Teams[i].Name < backup.Teams[j].Name })
+
+	for _, u := range data.users {
+		backup.Users = append(backup.Users, entity.BackupUser{ID: u.ID, Username: u.Username, TeamName: u.TeamName, IsActive: u.IsActive})
+	}
+	sort.Slice(backup.Users, func(i, j int) bool { return backup.Users[i].ID < backup.Users[j].ID })
+
+	for _, row := range data.sortedPullRequests() {
+		pr := entity.BackupPullRequest{
+			ID:          row.id,
+			Name:        row.name,
+			AuthorID:    row.authorID,
+			Status:      row.status,
+			CreatedAt:   row.createdAt,
+			SLADeadline: copyTime(row.slaDeadline),
+		}
+		if row.mergedAt != nil {
+			mergedAt := *row.mergedAt
+			pr.MergedAt = &mergedAt
+		}
+		backup.PullRequests = append(backup.PullRequests, pr)
+
+		for _, reviewer := range row.sortedReviewers() {
+			backup.Reviewers = append(backup.Reviewers, entity.BackupReviewer{
+				PullRequestID: row.id,
+				UserID:        reviewer.userID,
+				AssignedAt:    reviewer.assignedAt,
+			})
+		}
+	}
+	return backup, nil
+}
+
+func (r *memBackupRepo) Clear(ctx context.Context) error {
+	_, span := tracing.Start(ctx, "BackupRepository.Clear")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	data.teams = make(map[string]*memTeam)
+	data.users = make(map[string]*entity.User)
+	data.pullRequests = make(map[string]*memPullRequest)
+	data.reassignments = nil
+	data.notificationSettings = make(map[string]*entity.NotificationSettings)
+	data.notifications = nil
+	data.audit = nil
+	return nil
+}
+
+// Import checks every row before inserting any, so a failed import changes
+// nothing even outside a UnitOfWork.
+func (r *memBackupRepo) Import(ctx context.Context, backup *entity.Backup) error {
+	_, span := tracing.Start(ctx, "BackupRepository.Import")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	teams := make(map[string]bool, len(backup.Teams))
+	for _, t := range backup.Teams {
+		if _, ok := data.teams[t.Name]; ok || teams[t.Name] {
+			return entity.ErrImportConflict
+		}
+		teams[t.Name] = true
+	}
+	users := make(map[string]bool, len(backup.Users))
+	for _, u := range backup.Users {
+		if _, ok := data.users[u.ID]; ok || users[u.ID] {
+			return entity.ErrImportConflict
+		}
+		if _, ok := data.teams[u.TeamName]; !ok && !teams[u.TeamName] {
+			return entity.ErrNotFound
+		}
+		users[u.ID] = true
+	}
+	prs := make(map[string]bool, len(backup.PullRequests))
+	for _, pr := range backup.PullRequests {
+		if _, ok := data.pullRequests[pr.ID]; ok || prs[pr.ID] {
+			return entity.ErrImportConflict
+		}
+		if _, ok := data.users[pr.AuthorID]; !ok && !users[pr.AuthorID] {
+			return entity.ErrNotFound
+		}
+		prs[pr.ID] = true
+	}
+	reviewers := make(map[[2]string]bool, len(backup.Reviewers))
+	for _, rv := range backup.Reviewers {
+		key := [2]string{rv.PullRequestID, rv.UserID}
+		if row, ok := data.pullRequests[rv.PullRequestID]; (ok && row.hasReviewer(rv.UserID)) || reviewers[key] {
+			return entity.ErrImportConflict
+		}
+		if _, ok := data.pullRequests[rv.PullRequestID]; !ok && !prs[rv.PullRequestID] {
+			return entity.ErrNotFound
+		}
+		if _, ok := data.users[rv.UserID]; !ok && !users[rv.UserID] {
+			return entity.ErrNotFound
+		}
+		reviewers[key] = true
+	}
+
+	for _, t := range backup.Teams {
+		data.teams[t.Name] = &memTeam{name: t.Name, version: 1}
+	}
+	for _, u := range backup.Users {
+		data.users[u.ID] = &entity.User{ID: u.ID, Username: u.Username, IsActive: u.IsActive, TeamName: u.TeamName, Version: 1}
+	}
+	for _, pr := range backup.PullRequests {
+		row := &memPullRequest{
+			id:          pr.ID,
+			name:        pr.Name,
+			authorID:    pr.AuthorID,
+			status:      pr.Status,
+			createdAt:   pr.CreatedAt,
+			version:     1,
+			slaDeadline: copyTime(pr.SLADeadline),
+		}
+		if pr.MergedAt != nil {
+			mergedAt := *pr.MergedAt
+			row.mergedAt = &mergedAt
+			row.slaMet = slaMet(row.slaDeadline, mergedAt)
+		}
+		data.pullRequests[pr.ID] = row
+	}
+	for _, rv := range backup.Reviewers {
+		row := data.pullRequests[rv.PullRequestID]
+		row.reviewers = append(row.reviewers, memReviewer{userID: rv.UserID, assignedAt: rv.AssignedAt})
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+)
+
+type memIdempotencyRepo struct {
+	memoryBinding
+}
+
+func (r *memIdempotencyRepo) Reserve(ctx context.Context, rec *entity.IdempotencyRecord) (bool, error) {
+	_, span := tracing.Start(ctx, "IdempotencyRepository.Reserve")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	now := time.Now()
+	if existing, ok := data.idempotency[rec.IdempotencyScope]; ok && !existing.ExpiresAt.Before(now) &&
+		(existing.Completed() || !existing.LockedUntil.Before(now)) {
+		return false, nil
+	}
+
+	data.idempotency[rec.IdempotencyScope] = &entity.IdempotencyRecord{
+		IdempotencyScope: rec.IdempotencyScope,
+		RequestHash:      rec.RequestHash,
+		CreatedAt:        now,
+		LockedUntil:      rec.LockedUntil,
+		ExpiresAt:        rec.ExpiresAt,
+	}
+	return true, nil
+}
+
+func (r *memIdempotencyRepo) Get(ctx context.Context, scope entity.IdempotencyScope) (*entity.IdempotencyRecord, error) {
+	_, span := tracing.Start(ctx, "IdempotencyRepository.Get")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	rec, ok := data.idempotency[scope]
+	if !ok {
+		return nil, entity.ErrNotFound
+	}
+	copied := *rec
+	copied.Body = append([]byte(nil), rec.Body...)
+	return &copied, nil
+}
+
+func (r *memIdempotencyRepo) Complete(ctx context.Context, rec *entity.IdempotencyRecord) error {
+	_, span := tracing.Start(ctx, "IdempotencyRepository.Complete")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	stored, ok := data.idempotency[rec.IdempotencyScope]
+	if !ok {
+		return nil
+	}
+	stored.StatusCode = rec.StatusCode
+	stored.ContentType = rec.ContentType
+	stored.ETag = rec.ETag
+	stored.Body = append([]byte(nil), rec.Body...)
+	return nil
+}
+
+func (r *memIdempotencyRepo) Release(ctx context.Context, scope entity.IdempotencyScope) error {
+	_, span := tracing.Start(ctx, "IdempotencyRepository.Release")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	if rec, ok := data.idempotency[scope]; ok && !rec.Completed() {
+		delete(data.idempotency, scope)
+	}
+	return nil
+}
+
+func (r *memIdempotencyRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	_, span := tracing.Start(ctx, "IdempotencyRepository.DeleteExpired")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	now := time.Now()
+	var deleted int64
+	for k, rec := range data.idempotency {
+		if rec.ExpiresAt.Before(now) {
+			delete(data.idempotency, k)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
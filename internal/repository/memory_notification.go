@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+)
+
+type memNotificationRepo struct {
+	memoryBinding
+}
+
+func (r *memNotificationRepo) GetSettings(ctx context.Context, userID string) (*entity.NotificationSettings, error) {
+	_, span := tracing.Start(ctx, "NotificationRepository.GetSettings")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	s, ok := data.notificationSettings[userID]
+	if !ok {
+		return nil, entity.ErrNotFound
+	}
+	return copySettings(s), nil
+}
+
+func (r *memNotificationRepo) SaveSettings(ctx context.Context, s *entity.NotificationSettings) error {
+	_, span := tracing.Start(ctx, "NotificationRepository.SaveSettings")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	data.notificationSettings[s.UserID] = copySettings(s)
+	return nil
+}
+
+func (r *memNotificationRepo) Enqueue(ctx context.Context, n *entity.Notification) error {
+	_, span := tracing.Start(ctx, "NotificationRepository.Enqueue")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	if n.CreatedAt.IsZero() {
+		n.CreatedAt = time.Now()
+	}
+	n.Status, n.Attempts, n.LastError, n.SentAt = entity.NotificationPending, 0, "", nil
+	data.notificationSeq++
+	n.ID = data.notificationSeq
+	data.notifications = append(data.notifications, *n)
+	return nil
+}
+
+func (r *memNotificationRepo) ClaimDue(ctx context.Context, now, lockedUntil time.Time, limit int) ([]entity.Notification, error) {
+	_, span := tracing.Start(ctx, "NotificationRepository.ClaimDue")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	due := []*entity.Notification{}
+	for i := range data.notifications {
+		n := &data.notifications[i]
+		switch {
+		case n.Status == entity.NotificationPending && !n.DeliverAfter.After(now),
+			n.Status == entity.NotificationSending && !n.LockedUntil.After(now):
+			due = append(due, n)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].DeliverAfter.Before(due[j].DeliverAfter) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]entity.Notification, 0, len(due))
+	for _, n := range due {
+		n.Status, n.LockedUntil = entity.NotificationSending, &lockedUntil
+		claimed = append(claimed, *n)
+	}
+	return claimed, nil
+}
+
+func (r *memNotificationRepo) List(ctx context.Context, filter entity.NotificationFilter) ([]entity.Notification, error) {
+	_, span := tracing.Start(ctx, "NotificationRepository.List")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	notifications := []entity.Notification{}
+	for _, n := range data.notifications {
+		if (filter.UserID == "" || n.UserID == filter.UserID) && (filter.Status == "" || n.Status == filter.Status) {
+			notifications = append(notifications, n)
+		}
+	}
+	sort.SliceStable(notifications, func(i, j int) bool {
+		if !notifications[i].CreatedAt.Equal(notifications[j].CreatedAt) {
+			return notifications[i].CreatedAt.After(notifications[j].CreatedAt)
+		}
+		return notifications[i].ID > notifications[j].ID
+	})
+	if filter.Limit > 0 && len(notifications) > filter.Limit {
+		notifications = notifications[:filter.Limit]
+	}
+	return notifications, nil
+}
+
+func (r *memNotificationRepo) MarkSent(ctx context.Context, id int64, sentAt time.Time) error {
+	_, span := tracing.Start(ctx, "NotificationRepository.MarkSent")
+	defer span.End()
+
+	return r.update(id, func(n *entity.Notification) {
+		n.Status = entity.NotificationSent
+		n.SentAt = &sentAt
+		n.LockedUntil = nil
+	})
+}
+
+func (r *memNotificationRepo) MarkFailed(ctx context.Context, id int64, lastError string, retryAt *time.Time) error {
+	_, span := tracing.Start(ctx, "NotificationRepository.MarkFailed")
+	defer span.End()
+
+	return r.update(id, func(n *entity.Notification) {
+		n.LastError = lastError
+		n.LockedUntil = nil
+		if retryAt == nil {
+			n.Status = entity.NotificationFailed
+		} else {
+			n.Status = entity.NotificationPending
+			n.DeliverAfter = *retryAt
+		}
+	})
+}
+
+// update applies change to the notification and counts the attempt. SentAt
+// and LockedUntil are only ever replaced, so clones may share them.
+func (r *memNotificationRepo) update(id int64, change func(n *entity.Notification)) error {
+	data, unlock := r.lock()
+	defer unlock()
+
+	for i := range data.notifications {
+		if data.notifications[i].ID == id {
+			data.notifications[i].Attempts++
+			change(&data.notifications[i])
+			return nil
+		}
+	}
+	return entity.ErrNotFound
+}
+
+func copySettings(s *entity.NotificationSettings) *entity.NotificationSettings {
+	c := *s
+	c.Channels = slices.Clone(s.Channels)
+	if c.Channels == nil {
+		c.Channels = []entity.NotificationChannel{}
+	}
+	if s.QuietHours != nil {
+		quiet := *s.QuietHours
+		c.QuietHours = &quiet
+	}
+	return &c
+}
@@ -0,0 +1,391 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+)
+
+type memPRRepo struct {
+	memoryBinding
+}
+
+func (r *memPRRepo) Create(ctx context.Context, pr *entity.PullRequest) error {
+	_, span := tracing.Start(ctx, "PullRequestRepository.Create")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	if err := data.checkNewPullRequest(pr); err != nil {
+		return err
+	}
+	data.insertPullRequest(pr, time.Now())
+	return nil
+}
+
+func (r *memPRRepo) CreateBatch(ctx context.Context, prs []*entity.PullRequest) error {
+	_, span := tracing.Start(ctx, "PullRequestRepository.CreateBatch")
+	defer span.End()
+
+	if len(prs) == 0 {
+		return nil
+	}
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	// Validate everything up front so a bad PR leaves no partial changes
+	// behind, like the single transaction on the SQL side.
+	seen := make(map[string]bool, len(prs))
+	for _, pr := range prs {
+		if seen[pr.ID] {
+			return entity.ErrPRExists
+		}
+		seen[pr.ID] = true
+		if err := data.checkNewPullRequest(pr); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	for _, pr := range prs {
+		data.insertPullRequest(pr, now)
+	}
+	return nil
+}
+
+// checkNewPullRequest reports the error the SQL constraints would raise for
+// inserting pr.
+func (d *memoryData) checkNewPullRequest(pr *entity.PullRequest) error {
+	if _, ok := d.pullRequests[pr.ID]; ok {
+		return entity.ErrPRExists
+	}
+	if _, ok := d.users[pr.AuthorID]; !ok {
+		return entity.ErrNotFound
+	}
+	seen := make(map[string]bool, len(pr.Reviewers))
+	for _, reviewerID := range pr.Reviewers {
+		if _, ok := d.users[reviewerID]; !ok {
+			return fmt.Errorf("failed to add reviewer %s: %w", reviewerID, entity.ErrNotFound)
+		}
+		if seen[reviewerID] {
+			return fmt.Errorf("failed to add reviewer %s: already assigned", reviewerID)
+		}
+		seen[reviewerID] = true
+	}
+	return nil
+}
+
+func (d *memoryData) insertPullRequest(pr *entity.PullRequest, now time.Time) {
+	row := &memPullRequest{
+		id:          pr.ID,
+		name:        pr.Name,
+		authorID:    pr.AuthorID,
+		status:      entity.StatusOpen,
+		createdAt:   now,
+		version:     1,
+		slaDeadline: copyTime(pr.SLADeadline),
+	}
+	if pr.CreatedAt != nil {
+		row.createdAt = *pr.CreatedAt
+	}
+	for _, reviewerID := range pr.Reviewers {
+		row.reviewers = append(row.reviewers, memReviewer{userID: reviewerID, assignedAt: row.createdAt})
+	}
+
+	d.pullRequests[pr.ID] = row
+	pr.Version = row.version
+}
+
+func (r *memPRRepo) GetByID(ctx context.Context, id string) (*entity.PullRequest, error) {
+	_, span := tracing.Start(ctx, "PullRequestRepository.GetByID")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	return data.pullRequest(id)
+}
+
+// GetByIDForUpdate needs no extra locking: a UnitOfWork already holds the
+// whole store.
+func (r *memPRRepo) GetByIDForUpdate(ctx context.Context, id string) (*entity.PullRequest, error) {
+	_, span := tracing.Start(ctx, "PullRequestRepository.GetByIDForUpdate")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	return data.pullRequest(id)
+}
+
+func (r *memPRRepo) Merge(ctx context.Context, id string, version int) (*entity.PullRequest, error) {
+	_, span := tracing.Start(ctx, "PullRequestRepository.Merge")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	row, ok := data.pullRequests[id]
+	if !ok {
+		return nil, entity.ErrNotFound
+	}
+	if row.version != version {
+		return nil, entity.ErrConflictVersion
+	}
+
+	now := time.Now()
+	row.status = entity.StatusMerged
+	row.mergedAt = &now
+	row.slaMet = slaMet(row.slaDeadline, now)
+	row.version++
+	return data.pullRequest(id)
+}
+
+func (r *memPRRepo) Reassign(ctx context.Context, prID, oldUserID, newUserID string, reason entity.ReassignReason, version int) error {
+	_, span := tracing.Start(ctx, "PullRequestRepository.Reassign")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	row, ok := data.pullRequests[prID]
+	if !ok || row.version != version {
+		return entity.ErrConflictVersion
+	}
+	if !row.hasReviewer(oldUserID) {
+		return entity.ErrNotAssigned
+	}
+	if _, ok := data.users[newUserID]; !ok {
+		return fmt.Errorf("failed to add reviewer %s: %w", newUserID, entity.ErrNotFound)
+	}
+	if oldUserID != newUserID && row.hasReviewer(newUserID) {
+		return fmt.Errorf("failed to add reviewer %s: already assigned", newUserID)
+	}
+
+	now := time.Now()
+	row.version++
+	data.removeReviewer(row, oldUserID, newUserID, reason, now)
+	row.reviewers = append(row.reviewers, memReviewer{userID: newUserID, assignedAt: now})
+	return nil
+}
+
+func (r *memPRRepo) GetOpenAssignmentsForUsers(ctx context.Context, userIDs []string) ([]entity.ReviewerAssignment, error) {
+	_, span := tracing.Start(ctx, "PullRequestRepository.GetOpenAssignmentsForUsers")
+	defer span.End()
+
+	assignments := []entity.ReviewerAssignment{}
+	if len(userIDs) == 0 {
+		return assignments, nil
+	}
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	wanted := make(map[string]bool, len(userIDs))
+	for _, id := range userIDs {
+		wanted[id] = true
+	}
+
+	for _, row := range data.sortedPullRequests() {
+		if row.status != entity.StatusOpen {
+			continue
+		}
+		reviewers := row.reviewerIDs()
+		matched := make([]string, 0, len(reviewers))
+		for _, id := range reviewers {
+			if wanted[id] {
+				matched = append(matched, id)
+			}
+		}
+		sort.Strings(matched)
+		for _, id := range matched {
+			assignments = append(assignments, entity.ReviewerAssignment{
+				PullRequestID: row.id,
+				AuthorID:      row.authorID,
+				OldReviewerID: id,
+				Reviewers:     append([]string(nil), reviewers...),
+			})
+		}
+	}
+	return assignments, nil
+}
+
+func (r *memPRRepo) GetOpenReviewAssignments(ctx context.Context, assignedBefore time.Time) ([]entity.OpenReviewAssignment, error) {
+	_, span := tracing.Start(ctx, "PullRequestRepository.GetOpenReviewAssignments")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	assignments := []entity.OpenReviewAssignment{}
+	for _, row := range data.sortedPullRequests() {
+		if row.status != entity.StatusOpen {
+			continue
+		}
+		var team string
+		if author, ok := data.users[row.authorID]; ok {
+			team = author.TeamName
+		}
+		for _, reviewer := range row.sortedReviewers() {
+			if reviewer.assignedAt.After(assignedBefore) {
+				continue
+			}
+			assignments = append(assignments, entity.OpenReviewAssignment{
+				PullRequestID:   row.id,
+				PullRequestName: row.name,
+				AuthorID:        row.authorID,
+				TeamName:        team,
+				ReviewerID:      reviewer.userID,
+				AssignedAt:      reviewer.assignedAt,
+			})
+		}
+	}
+	return assignments, nil
+}
+
+func (r *memPRRepo) ApplyReviewerReplacements(ctx context.Context, replacements []entity.ReassignmentResult, reason entity.ReassignReason) error {
+	_, span := tracing.Start(ctx, "PullRequestRepository.ApplyReviewerReplacements")
+	defer span.End()
+
+	if len(replacements) == 0 {
+		return nil
+	}
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	// Validate everything up front so a bad replacement leaves no partial
+	// changes behind, like the single transaction on the Postgres side.
+	for _, repl := range replacements {
+		if _, ok := data.users[repl.NewReviewerID]; !ok {
+			return fmt.Errorf("failed to add reviewer %s: %w", repl.NewReviewerID, entity.ErrNotFound)
+		}
+	}
+
+	now := time.Now()
+	for _, repl := range replacements {
+		row, ok := data.pullRequests[repl.PullRequestID]
+		if !ok {
+			continue
+		}
+		data.removeReviewer(row, repl.OldReviewerID, repl.NewReviewerID, reason, now)
+		if !row.hasReviewer(repl.NewReviewerID) {
+			row.reviewers = append(row.reviewers, memReviewer{userID: repl.NewReviewerID, assignedAt: now})
+		}
+		row.version++
+	}
+	return nil
+}
+
+func (d *memoryData) pullRequest(id string) (*entity.PullRequest, error) {
+	row, ok := d.pullRequests[id]
+	if !ok {
+		return nil, entity.ErrNotFound
+	}
+
+	createdAt := row.createdAt
+	pr := &entity.PullRequest{
+		BasePullRequest: row.base(),
+		CreatedAt:       &createdAt,
+		Reviewers:       row.reviewerIDs(),
+		Version:         row.version,
+	}
+	if row.mergedAt != nil {
+		mergedAt := *row.mergedAt
+		pr.MergedAt = &mergedAt
+	}
+	pr.SLADeadline = copyTime(row.slaDeadline)
+	if row.slaMet != nil {
+		met := *row.slaMet
+		pr.SLAMet = &met
+	}
+	return pr, nil
+}
+
+// slaMet reports whether a PR merged at mergedAt met its deadline, nil when
+// it had none. It matches the sla_met expression in prRepo.Merge.
+func slaMet(deadline *time.Time, mergedAt time.Time) *bool {
+	if deadline == nil {
+		return nil
+	}
+	met := !mergedAt.After(*deadline)
+	return &met
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+// removeReviewer drops an assignment and records it in the reassignment
+// history, matching removeReviewer.
+func (d *memoryData) removeReviewer(row *memPullRequest, oldUserID, newUserID string, reason entity.ReassignReason, now time.Time) {
+	for i, reviewer := range row.reviewers {
+		if reviewer.userID != oldUserID {
+			continue
+		}
+		row.reviewers = append(row.reviewers[:i:i], row.reviewers[i+1:]...)
+		d.reassignments = append(d.reassignments, memReassignment{
+			prID:         row.id,
+			oldUserID:    oldUserID,
+			newUserID:    newUserID,
+			reason:       reason,
+			assignedAt:   reviewer.assignedAt,
+			reassignedAt: now,
+		})
+		return
+	}
+}
+
+func (d *memoryData) sortedPullRequests() []*memPullRequest {
+	rows := make([]*memPullRequest, 0, len(d.pullRequests))
+	for _, row := range d.pullRequests {
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].id < rows[j].id })
+	return rows
+}
+
+func (p *memPullRequest) base() entity.BasePullRequest {
+	return entity.BasePullRequest{ID: p.id, Name: p.name, AuthorID: p.authorID, Status: p.status}
+}
+
+// sortedReviewers returns reviewers ordered by assignment time, then id.
+func (p *memPullRequest) sortedReviewers() []memReviewer {
+	reviewers := append([]memReviewer(nil), p.reviewers...)
+	sort.SliceStable(reviewers, func(i, j int) bool {
+		if !reviewers[i].assignedAt.Equal(reviewers[j].assignedAt) {
+			return reviewers[i].assignedAt.Before(reviewers[j].assignedAt)
+		}
+		return reviewers[i].userID < reviewers[j].userID
+	})
+	return reviewers
+}
+
+// reviewerIDs returns reviewers ordered by assignment time, then id.
+func (p *memPullRequest) reviewerIDs() []string {
+	reviewers := p.sortedReviewers()
+	ids := make([]string, 0, len(reviewers))
+	for _, reviewer := range reviewers {
+		ids = append(ids, reviewer.userID)
+	}
+	return ids
+}
+
+func (p *memPullRequest) hasReviewer(userID string) bool {
+	for _, reviewer := range p.reviewers {
+		if reviewer.userID == userID {
+			return true
+		}
+	}
+	return false
+}
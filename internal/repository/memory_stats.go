@@ -0,0 +1,659 @@
+package repository
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+)
+
+// memStatsRepo computes the same aggregates as statsRepo by scanning the
+// tables in process. Percentiles interpolate linearly like percentile_cont.
+// It serves the memory store and databases without the analytic SQL the
+// Postgres queries rely on.
+type memStatsRepo struct {
+	// snapshot returns the data to aggregate and a func to call when done.
+	snapshot func(ctx context.Context) (*memoryData, func(), error)
+}
+
+func (r *memStatsRepo) Snapshot(ctx context.Context) (StatsRepository, func(), error) {
+	data, release, err := r.snapshot(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &memStatsRepo{snapshot: func(context.Context) (*memoryData, func(), error) {
+		return data, func() {}, nil
+	}}, release, nil
+}
+
+func (r *memStatsRepo) GetReviewerAssignments(ctx context.Context, filter entity.StatsFilter) ([]entity.ReviewerAssignmentStat, error) {
+	ctx, span := tracing.Start(ctx, "StatsRepository.GetReviewerAssignments")
+	defer span.End()
+
+	data, release, err := r.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	counts := make(map[string]int)
+	for _, row := range data.pullRequests {
+		if !data.matchesPR(filter, row) {
+			continue
+		}
+		for _, reviewer := range row.reviewers {
+			counts[reviewer.userID]++
+		}
+	}
+
+	stats := make([]entity.ReviewerAssignmentStat, 0, len(counts))
+	for userID, count := range counts {
+		stats = append(stats, entity.ReviewerAssignmentStat{UserID: userID, Assignments: count})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Assignments != stats[j].Assignments {
+			return stats[i].Assignments > stats[j].Assignments
+		}
+		return stats[i].UserID < stats[j].UserID
+	})
+	return stats, nil
+}
+
+func (r *memStatsRepo) GetPRStatus(ctx context.Context, filter entity.StatsFilter) (entity.PRStatusStat, error) {
+	ctx, span := tracing.Start(ctx, "StatsRepository.GetPRStatus")
+	defer span.End()
+
+	data, release, err := r.snapshot(ctx)
+	if err != nil {
+		return entity.PRStatusStat{}, err
+	}
+	defer release()
+
+	var stat entity.PRStatusStat
+	var reviewed, reviewers int
+	for _, row := range data.pullRequests {
+		if !data.matchesPR(filter, row) {
+			continue
+		}
+		stat.Total++
+		switch row.status {
+		case entity.StatusOpen:
+			stat.Open++
+		case entity.StatusMerged:
+			stat.Merged++
+		}
+		if len(row.reviewers) > 0 {
+			reviewed++
+			reviewers += len(row.reviewers)
+		}
+	}
+	if reviewed > 0 {
+		stat.AverageReviewers = math.Round(float64(reviewers)/float64(reviewed)*10) / 10
+	}
+	return stat, nil
+}
+
+func (r *memStatsRepo) GetTeamMembers(ctx context.Context, filter entity.StatsFilter) ([]entity.TeamMemberStat, error) {
+	ctx, span := tracing.Start(ctx, "StatsRepository.GetTeamMembers")
+	defer span.End()
+
+	data, release, err := r.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	authorTeam := ""
+	if filter.AuthorID != "" {
+		author, ok := data.users[filter.AuthorID]
+		if !ok {
+			return []entity.TeamMemberStat{}, nil
+		}
+		authorTeam = author.TeamName
+	}
+
+	byTeam := make(map[string]*entity.TeamMemberStat)
+	for _, user := range data.users {
+		if filter.TeamName != "" && user.TeamName != filter.TeamName {
+			continue
+		}
+		if filter.AuthorID != "" && user.TeamName != authorTeam {
+			continue
+		}
+		s, ok := byTeam[user.TeamName]
+		if !ok {
+			s = &entity.TeamMemberStat{TeamName: user.TeamName}
+			byTeam[user.TeamName] = s
+		}
+		if user.IsActive {
+			s.Active++
+		} else {
+			s.Inactive++
+		}
+	}
+
+	stats := make([]entity.TeamMemberStat, 0, len(byTeam))
+	for _, s := range byTeam {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].TeamName < stats[j].TeamName })
+	return stats, nil
+}
+
+func (r *memStatsRepo) GetPRLifetime(ctx context.Context, filter entity.StatsFilter) (entity.PRLifetimeStat, error) {
+	ctx, span := tracing.Start(ctx, "StatsRepository.GetPRLifetime")
+	defer span.End()
+
+	data, release, err := r.snapshot(ctx)
+	if err != nil {
+		return entity.PRLifetimeStat{}, err
+	}
+	defer release()
+
+	now := time.Now()
+	staleBefore := now.Add(-filter.StaleAfter)
+	weekBefore := now.Add(-entity.WeekStaleAfter)
+
+	var total float64
+	var merged []memLifetimeSample
+	var open []memLifetimeSample
+	lifetime := entity.PRLifetimeStat{StaleThreshold: secondsToDuration(filter.StaleAfter.Seconds())}
+
+	for _, row := range data.sortedPullRequests() {
+		if !data.matchesPR(filter, row) {
+			continue
+		}
+		sample := memLifetimeSample{reviewers: len(row.reviewers)}
+		if author, ok := data.users[row.authorID]; ok {
+			sample.team = author.TeamName
+		}
+
+		switch {
+		case row.status == entity.StatusMerged && row.mergedAt != nil:
+			sample.value = row.mergedAt.Sub(row.createdAt).Seconds()
+			total += sample.value
+			merged = append(merged, sample)
+		case row.status == entity.StatusOpen:
+			if !row.createdAt.After(staleBefore) {
+				lifetime.OpenOlderThanThreshold++
+			}
+			if !row.createdAt.After(weekBefore) {
+				lifetime.OpenOlderThan7Days++
+			}
+			sample.value = now.Sub(row.createdAt).Seconds() / 86400
+			open = append(open, sample)
+		}
+	}
+	if len(merged) > 0 {
+		lifetime.AverageMerge = secondsToDuration(total / float64(len(merged)))
+	}
+
+	groups := newLifetimeGroups()
+	fillMemMergeDistribution(groups, merged)
+	for _, sample := range open {
+		bucket := ageBucket(sample.value)
+		team, reviewers := sample.team, sample.reviewers
+		for _, breakdown := range []*entity.LifetimeBreakdown{
+			groups.get(false, false, nil, nil),
+			groups.get(true, false, &team, nil),
+			groups.get(false, true, nil, &reviewers),
+		} {
+			breakdown.Open++
+			breakdown.OpenAgeHistogram[bucket].Count++
+		}
+	}
+
+	lifetime.MergePercentiles = groups.overall.MergePercentiles
+	lifetime.OpenAgeHistogram = groups.overall.OpenAgeHistogram
+	lifetime.ByTeam = groups.teams()
+	lifetime.ByReviewerCount = groups.reviewerCounts()
+	return lifetime, nil
+}
+
+type memLifetimeSample struct {
+	team      string
+	reviewers int
+	value     float64
+}
+
+func fillMemMergeDistribution(groups *lifetimeGroups, merged []memLifetimeSample) {
+	overall := make([]float64, 0, len(merged))
+	byTeam := make(map[string][]float64)
+	byReviewers := make(map[int][]float64)
+	for _, sample := range merged {
+		overall = append(overall, sample.value)
+		byTeam[sample.team] = append(byTeam[sample.team], sample.value)
+		byReviewers[sample.reviewers] = append(byReviewers[sample.reviewers], sample.value)
+	}
+
+	setMergeStats(groups.get(false, false, nil, nil), overall)
+	for team, values := range byTeam {
+		team := team
+		setMergeStats(groups.get(true, false, &team, nil), values)
+	}
+	for count, values := range byReviewers {
+		count := count
+		setMergeStats(groups.get(false, true, nil, &count), values)
+	}
+}
+
+func setMergeStats(breakdown *entity.LifetimeBreakdown, seconds []float64) {
+	breakdown.Merged = len(seconds)
+	if len(seconds) == 0 {
+		return
+	}
+	sort.Float64s(seconds)
+	breakdown.MergePercentiles = entity.DurationPercentiles{
+		P50: secondsToDuration(percentileCont(seconds, mergePercentiles[0])),
+		P75: secondsToDuration(percentileCont(seconds, mergePercentiles[1])),
+		P90: secondsToDuration(percentileCont(seconds, mergePercentiles[2])),
+		P99: secondsToDuration(percentileCont(seconds, mergePercentiles[3])),
+	}
+}
+
+// ageBucket mirrors width_bucket over openAgeBucketDays: the index is the
+// number of bounds not greater than the age.
+func ageBucket(days float64) int {
+	bucket := 0
+	for _, bound := range openAgeBucketDays {
+		if days >= bound {
+			bucket++
+		}
+	}
+	return bucket
+}
+
+func (r *memStatsRepo) GetTimeSeries(
+	ctx context.Context,
+	filter entity.StatsFilter,
+	interval entity.StatsInterval,
+	byTeam bool,
+) ([]entity.TimeSeriesPoint, error) {
+	ctx, span := tracing.Start(ctx, "StatsRepository.GetTimeSeries")
+	defer span.End()
+
+	data, release, err := r.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	type key struct {
+		bucket time.Time
+		team   string
+	}
+	points := make(map[key]*entity.TimeSeriesPoint)
+	mergeSeconds := make(map[key][]float64)
+
+	add := func(row *memPullRequest, at time.Time) (key, *entity.TimeSeriesPoint, bool) {
+		author, ok := data.users[row.authorID]
+		if !ok || !data.matchesEvent(filter, row.authorID, at) {
+			return key{}, nil, false
+		}
+		k := key{bucket: truncateUTC(at, interval)}
+		if byTeam {
+			k.team = author.TeamName
+		}
+		p, ok := points[k]
+		if !ok {
+			p = &entity.TimeSeriesPoint{Bucket: k.bucket, TeamName: k.team}
+			points[k] = p
+		}
+		return k, p, true
+	}
+
+	for _, row := range data.pullRequests {
+		if _, p, ok := add(row, row.createdAt); ok {
+			p.Created++
+		}
+		if row.mergedAt != nil {
+			if k, p, ok := add(row, *row.mergedAt); ok {
+				p.Merged++
+				mergeSeconds[k] = append(mergeSeconds[k], row.mergedAt.Sub(row.createdAt).Seconds())
+			}
+		}
+		for _, reviewer := range row.reviewers {
+			if _, p, ok := add(row, reviewer.assignedAt); ok {
+				p.Assignments++
+			}
+		}
+	}
+
+	result := make([]entity.TimeSeriesPoint, 0, len(points))
+	for k, p := range points {
+		if values := mergeSeconds[k]; len(values) > 0 {
+			sort.Float64s(values)
+			d := secondsToDuration(percentileCont(values, 0.5))
+			p.MedianMerge = &d
+		}
+		result = append(result, *p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Bucket.Equal(result[j].Bucket) {
+			return result[i].Bucket.Before(result[j].Bucket)
+		}
+		return result[i].TeamName < result[j].TeamName
+	})
+	return result, nil
+}
+
+// truncateUTC matches date_trunc on a UTC timestamp; weeks start on Monday.
+func truncateUTC(t time.Time, interval entity.StatsInterval) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch interval {
+	case entity.IntervalWeek:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case entity.IntervalMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+func (r *memStatsRepo) GetUserStats(ctx context.Context, userID string, filter entity.StatsFilter) (*entity.UserReviewStats, error) {
+	ctx, span := tracing.Start(ctx, "StatsRepository.GetUserStats")
+	defer span.End()
+
+	data, release, err := r.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	user, ok := data.users[userID]
+	if !ok {
+		return nil, entity.ErrNotFound
+	}
+
+	stats := &entity.UserReviewStats{
+		UserID:              userID,
+		AssignmentsReceived: data.assignmentsReceived(userID, filter),
+		ReassignReasons:     []entity.ReassignReasonStat{},
+		OpenLoad:            data.openLoad(userID),
+	}
+	stats.Team.TeamName = user.TeamName
+
+	reasons := make(map[entity.ReassignReason]int)
+	for _, rr := range data.reassignments {
+		if rr.oldUserID == userID && inWindow(filter, rr.reassignedAt) {
+			reasons[rr.reason]++
+		}
+	}
+	for reason, count := range reasons {
+		stats.ReassignedAway += count
+		stats.ReassignReasons = append(stats.ReassignReasons, entity.ReassignReasonStat{Reason: reason, Count: count})
+	}
+	sort.Slice(stats.ReassignReasons, func(i, j int) bool {
+		a, b := stats.ReassignReasons[i], stats.ReassignReasons[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Reason < b.Reason
+	})
+
+	var totalSeconds float64
+	for _, row := range data.pullRequests {
+		if row.status != entity.StatusMerged || row.mergedAt == nil {
+			continue
+		}
+		for _, reviewer := range row.reviewers {
+			if reviewer.userID == userID && inWindow(filter, reviewer.assignedAt) {
+				stats.MergedReviews++
+				totalSeconds += row.mergedAt.Sub(reviewer.assignedAt).Seconds()
+			}
+		}
+	}
+	if stats.MergedReviews > 0 {
+		stats.AverageAssignmentToMerge = secondsToDuration(totalSeconds / float64(stats.MergedReviews))
+	}
+
+	var received, openLoad []float64
+	for _, member := range data.teamMembers(user.TeamName) {
+		if !member.IsActive {
+			continue
+		}
+		received = append(received, float64(data.assignmentsReceived(member.ID, filter)))
+		openLoad = append(openLoad, float64(data.openLoad(member.ID)))
+	}
+	stats.Team.ActiveMembers = len(received)
+	if len(received) > 0 {
+		sort.Float64s(received)
+		sort.Float64s(openLoad)
+		stats.Team.MedianAssignments = math.Round(percentileCont(received, 0.5)*10) / 10
+		stats.Team.MedianOpenLoad = math.Round(percentileCont(openLoad, 0.5)*10) / 10
+	}
+
+	return stats, nil
+}
+
+func (r *memStatsRepo) GetMemberAssignmentCounts(ctx context.Context, filter entity.StatsFilter) ([]entity.MemberAssignmentCount, error) {
+	ctx, span := tracing.Start(ctx, "StatsRepository.GetMemberAssignmentCounts")
+	defer span.End()
+
+	data, release, err := r.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	counts := []entity.MemberAssignmentCount{}
+	for _, user := range data.sortedUsers() {
+		if !user.IsActive || (filter.TeamName != "" && user.TeamName != filter.TeamName) {
+			continue
+		}
+		counts = append(counts, entity.MemberAssignmentCount{
+			TeamName:    user.TeamName,
+			UserID:      user.ID,
+			Assignments: data.assignmentsReceived(user.ID, filter),
+		})
+	}
+	return counts, nil
+}
+
+func (r *memStatsRepo) GetOpenPRsByTeam(ctx context.Context) ([]entity.TeamOpenPRs, error) {
+	ctx, span := tracing.Start(ctx, "StatsRepository.GetOpenPRsByTeam")
+	defer span.End()
+
+	data, release, err := r.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	open := make(map[string]int)
+	for _, row := range data.pullRequests {
+		if row.status != entity.StatusOpen {
+			continue
+		}
+		if author, ok := data.users[row.authorID]; ok {
+			open[author.TeamName]++
+		}
+	}
+
+	stats := make([]entity.TeamOpenPRs, 0, len(data.teams))
+	for name := range data.teams {
+		stats = append(stats, entity.TeamOpenPRs{TeamName: name, Open: open[name]})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].TeamName < stats[j].TeamName })
+	return stats, nil
+}
+
+func (r *memStatsRepo) GetReviewerLoad(ctx context.Context) ([]entity.ReviewerLoad, error) {
+	ctx, span := tracing.Start(ctx, "StatsRepository.GetReviewerLoad")
+	defer span.End()
+
+	data, release, err := r.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	stats := []entity.ReviewerLoad{}
+	for _, user := range data.sortedUsers() {
+		if !user.IsActive {
+			continue
+		}
+		stats = append(stats, entity.ReviewerLoad{
+			UserID:   user.ID,
+			TeamName: user.TeamName,
+			Open:     data.openLoad(user.ID),
+		})
+	}
+	return stats, nil
+}
+
+func (r *memStatsRepo) GetSLA(ctx context.Context, filter entity.StatsFilter, now time.Time) (*entity.SLAReport, error) {
+	ctx, span := tracing.Start(ctx, "StatsRepository.GetSLA")
+	defer span.End()
+
+	data, release, err := r.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	teams := make(map[string]*entity.SLAOutcome)
+	reviewers := make(map[string]*entity.SLAOutcome)
+	add := func(outcomes map[string]*entity.SLAOutcome, key string, row *memPullRequest) {
+		o, ok := outcomes[key]
+		if !ok {
+			o = &entity.SLAOutcome{}
+			outcomes[key] = o
+		}
+		o.Total++
+		switch {
+		case row.slaMet != nil && *row.slaMet:
+			o.Met++
+		case row.slaMet != nil || row.slaDeadline.Before(now):
+			o.Breached++
+		default:
+			o.Pending++
+		}
+	}
+	for _, row := range data.pullRequests {
+		if row.slaDeadline == nil || !data.matchesPR(filter, row) {
+			continue
+		}
+		author, ok := data.users[row.authorID]
+		if !ok {
+			continue
+		}
+		add(teams, author.TeamName, row)
+		held := make(map[string]bool)
+		for _, reviewer := range row.reviewers {
+			held[reviewer.userID] = true
+		}
+		if breached := row.slaMet != nil && !*row.slaMet || row.slaMet == nil && row.slaDeadline.Before(now); breached {
+			for _, rr := range data.reassignments {
+				if rr.prID == row.id && rr.reassignedAt.After(*row.slaDeadline) {
+					held[rr.oldUserID] = true
+				}
+			}
+		}
+		for userID := range held {
+			if _, ok := data.users[userID]; ok {
+				add(reviewers, userID, row)
+			}
+		}
+	}
+
+	report := &entity.SLAReport{AsOf: now, ByTeam: []entity.TeamSLA{}, ByReviewer: []entity.ReviewerSLA{}}
+	for team, o := range teams {
+		report.ByTeam = append(report.ByTeam, entity.TeamSLA{TeamName: team, SLAOutcome: *o})
+	}
+	sort.Slice(report.ByTeam, func(i, j int) bool { return report.ByTeam[i].TeamName < report.ByTeam[j].TeamName })
+	for _, user := range data.sortedUsers() {
+		if o, ok := reviewers[user.ID]; ok {
+			report.ByReviewer = append(report.ByReviewer, entity.ReviewerSLA{UserID: user.ID, TeamName: user.TeamName, SLAOutcome: *o})
+		}
+	}
+	return report, nil
+}
+
+// assignmentsReceived counts the user's current assignments and the ones
+// later reassigned away, by assignment time.
+func (d *memoryData) assignmentsReceived(userID string, filter entity.StatsFilter) int {
+	count := 0
+	for _, row := range d.pullRequests {
+		for _, reviewer := range row.reviewers {
+			if reviewer.userID == userID && inWindow(filter, reviewer.assignedAt) {
+				count++
+			}
+		}
+	}
+	for _, rr := range d.reassignments {
+		if rr.oldUserID == userID && inWindow(filter, rr.assignedAt) {
+			count++
+		}
+	}
+	return count
+}
+
+func (d *memoryData) openLoad(userID string) int {
+	count := 0
+	for _, row := range d.pullRequests {
+		if row.status == entity.StatusOpen && row.hasReviewer(userID) {
+			count++
+		}
+	}
+	return count
+}
+
+// sortedUsers returns users ordered by team, then id.
+func (d *memoryData) sortedUsers() []*entity.User {
+	users := make([]*entity.User, 0, len(d.users))
+	for _, user := range d.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].TeamName != users[j].TeamName {
+			return users[i].TeamName < users[j].TeamName
+		}
+		return users[i].ID < users[j].ID
+	})
+	return users
+}
+
+// matchesPR is the in-memory counterpart of prFilter.
+func (d *memoryData) matchesPR(filter entity.StatsFilter, row *memPullRequest) bool {
+	return d.matchesEvent(filter, row.authorID, row.createdAt)
+}
+
+func (d *memoryData) matchesEvent(filter entity.StatsFilter, authorID string, at time.Time) bool {
+	if !inWindow(filter, at) {
+		return false
+	}
+	if filter.TeamName != "" {
+		author, ok := d.users[authorID]
+		if !ok || author.TeamName != filter.TeamName {
+			return false
+		}
+	}
+	return filter.AuthorID == "" || authorID == filter.AuthorID
+}
+
+func inWindow(filter entity.StatsFilter, at time.Time) bool {
+	if filter.From != nil && at.Before(*filter.From) {
+		return false
+	}
+	if filter.To != nil && !at.Before(*filter.To) {
+		return false
+	}
+	return true
+}
+
+// percentileCont interpolates the p-th percentile of sorted values the way
+// Postgres' percentile_cont does.
+func percentileCont(sorted []float64, p float64) float64 {
+	pos := p * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	if lower+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lower] + (pos-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
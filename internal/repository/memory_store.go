@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+)
+
+// MemoryStore keeps every table in process memory. It backs the in-memory
+// repositories used by STORAGE=memory and by the conformance tests, and
+// mirrors the Postgres schema closely enough that both behave the same.
+//
+// A single mutex serializes all access: standalone repository calls hold it
+// for one call, a UnitOfWork holds it for the whole callback.
+type MemoryStore struct {
+	mu   sync.Mutex
+	data *memoryData
+}
+
+type memoryData struct {
+	teams                map[string]*memTeam
+	users                map[string]*entity.User
+	pullRequests         map[string]*memPullRequest
+	reassignments        []memReassignment
+	idempotency          map[entity.IdempotencyScope]*entity.IdempotencyRecord
+	audit                []entity.AuditEntry
+	auditSeq             int64
+	notificationSettings map[string]*entity.NotificationSettings
+	notifications        []entity.Notification
+	notificationSeq      int64
+}
+
+type memTeam struct {
+	name    string
+	version int
+}
+
+type memPullRequest struct {
+	id        string
+	name      string
+	authorID  string
+	status    entity.PRStatus
+	createdAt time.Time
+	mergedAt  *time.Time
+	version   int
+	reviewers []memReviewer
+	// slaDeadline and slaMet are never modified in place, so clones may
+	// share them.
+	slaDeadline *time.Time
+	slaMet      *bool
+}
+
+type memReviewer struct {
+	userID     string
+	assignedAt time.Time
+}
+
+type memReassignment struct {
+	prID         string
+	oldUserID    string
+	newUserID    string
+	reason       entity.ReassignReason
+	assignedAt   time.Time
+	reassignedAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: newMemoryData()}
+}
+
+func newMemoryData() *memoryData {
+	return &memoryData{
+		teams:                make(map[string]*memTeam),
+		users:                make(map[string]*entity.User),
+		pullRequests:         make(map[string]*memPullRequest),
+		idempotency:          make(map[entity.IdempotencyScope]*entity.IdempotencyRecord),
+		notificationSettings: make(map[string]*entity.NotificationSettings),
+	}
+}
+
+// clone deep-copies the data so a failed UnitOfWork can be rolled back.
+func (d *memoryData) clone() *memoryData {
+	c := newMemoryData()
+	for name, t := range d.teams {
+		team := *t
+		c.teams[name] = &team
+	}
+	for id, u := range d.users {
+		user := *u
+		c.users[id] = &user
+	}
+	for id, pr := range d.pullRequests {
+		copied := *pr
+		copied.reviewers = append([]memReviewer(nil), pr.reviewers...)
+		c.pullRequests[id] = &copied
+	}
+	c.reassignments = append([]memReassignment(nil), d.reassignments...)
+	c.audit = append([]entity.AuditEntry(nil), d.audit...)
+	c.auditSeq = d.auditSeq
+	for id, s := range d.notificationSettings {
+		c.notificationSettings[id] = copySettings(s)
+	}
+	c.notifications = append([]entity.Notification(nil), d.notifications...)
+	c.notificationSeq = d.notificationSeq
+	for k, rec := range d.idempotency {
+		copied := *rec
+		c.idempotency[k] = &copied
+	}
+	return c
+}
+
+func (s *MemoryStore) PullRequests() PullRequestRepository {
+	return &memPRRepo{memoryBinding{store: s}}
+}
+
+func (s *MemoryStore) Users() UserRepository {
+	return &memUserRepo{memoryBinding{store: s}}
+}
+
+func (s *MemoryStore) Teams() TeamRepository {
+	return &memTeamRepo{memoryBinding{store: s}}
+}
+
+func (s *MemoryStore) Backup() BackupRepository {
+	return &memBackupRepo{memoryBinding{store: s}}
+}
+
+func (s *MemoryStore) Stats() StatsRepository {
+	return &memStatsRepo{snapshot: func(context.Context) (*memoryData, func(), error) {
+		data, unlock := memoryBinding{store: s}.lock()
+		return data, unlock, nil
+	}}
+}
+
+func (s *MemoryStore) Idempotency() IdempotencyRepository {
+	return &memIdempotencyRepo{memoryBinding{store: s}}
+}
+
+func (s *MemoryStore) Audit() AuditRepository {
+	return &memAuditRepo{memoryBinding{store: s}}
+}
+
+func (s *MemoryStore) Notifications() NotificationRepository {
+	return &memNotificationRepo{memoryBinding{store: s}}
+}
+
+func (s *MemoryStore) UnitOfWork() UnitOfWork {
+	return &memUnitOfWork{store: s}
+}
+
+// memoryBinding gives a repository access to the store. Repositories handed
+// out by a UnitOfWork run while the store is already locked.
+type memoryBinding struct {
+	store *MemoryStore
+	inTx  bool
+}
+
+func (b memoryBinding) lock() (*memoryData, func()) {
+	if b.inTx {
+		return b.store.data, func() {}
+	}
+	b.store.mu.Lock()
+	return b.store.data, b.store.mu.Unlock
+}
+
+type memUnitOfWork struct {
+	store *MemoryStore
+}
+
+func (u *memUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context, repos Repositories) error) error {
+	ctx, span := tracing.Start(ctx, "UnitOfWork.Do")
+	defer span.End()
+
+	u.store.mu.Lock()
+	defer u.store.mu.Unlock()
+
+	snapshot := u.store.data.clone()
+	binding := memoryBinding{store: u.store, inTx: true}
+	err := fn(ctx, Repositories{
+		PullRequests: &memPRRepo{binding},
+		Users:        &memUserRepo{binding},
+		Teams:        &memTeamRepo{binding},
+		Backup:       &memBackupRepo{binding},
+		Audit:        &memAuditRepo{binding},
+	})
+	if err != nil {
+		u.store.data = snapshot
+	}
+	return err
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"sort"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+)
+
+type memTeamRepo struct {
+	memoryBinding
+}
+
+func (r *memTeamRepo) Create(ctx context.Context, team *entity.Team) error {
+	_, span := tracing.Start(ctx, "TeamRepository.Create")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	if _, ok := data.teams[team.Name]; ok {
+		return entity.ErrTeamExists
+	}
+
+	data.teams[team.Name] = &memTeam{name: team.Name, version: 1}
+	team.Version = 1
+
+	for i, member := range team.Members {
+		user, ok := data.users[member.ID]
+		if !ok {
+			user = &entity.User{ID: member.ID}
+			data.users[member.ID] = user
+		}
+		user.Username = member.Username
+		user.IsActive = member.IsActive
+		user.TeamName = team.Name
+		user.Version++
+		team.Members[i].Version = user.Version
+	}
+	return nil
+}
+
+func (r *memTeamRepo) GetByName(ctx context.Context, name string) (*entity.Team, error) {
+	_, span := tracing.Start(ctx, "TeamRepository.GetByName")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	team, ok := data.teams[name]
+	if !ok {
+		return nil, entity.ErrNotFound
+	}
+	return &entity.Team{
+		Name:    team.name,
+		Members: data.teamMembers(name),
+		Version: team.version,
+	}, nil
+}
+
+func (r *memTeamRepo) LockByName(ctx context.Context, name string) (int, error) {
+	_, span := tracing.Start(ctx, "TeamRepository.LockByName")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	team, ok := data.teams[name]
+	if !ok {
+		return 0, entity.ErrNotFound
+	}
+	return team.version, nil
+}
+
+func (r *memTeamRepo) DeactivateMembers(ctx context.Context, teamName string, userIDs []string, version int) ([]string, error) {
+	_, span := tracing.Start(ctx, "TeamRepository.DeactivateMembers")
+	defer span.End()
+
+	if teamName == "" || len(userIDs) == 0 {
+		return nil, entity.ErrBadRequest
+	}
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	team, ok := data.teams[teamName]
+	if !ok {
+		return nil, entity.ErrNotFound
+	}
+	if version != 0 && team.version != version {
+		return nil, entity.ErrConflictVersion
+	}
+
+	affected := []string{}
+	for _, id := range userIDs {
+		user, ok := data.users[id]
+		if !ok || user.TeamName != teamName || !user.IsActive {
+			continue
+		}
+		user.IsActive = false
+		user.Version++
+		affected = append(affected, id)
+	}
+	if len(affected) > 0 {
+		team.version++
+	}
+	sort.Strings(affected)
+	return affected, nil
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"sort"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+)
+
+type memUserRepo struct {
+	memoryBinding
+}
+
+func (r *memUserRepo) GetByID(ctx context.Context, userID string) (*entity.User, error) {
+	_, span := tracing.Start(ctx, "UserRepository.GetByID")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	user, ok := data.users[userID]
+	if !ok {
+		return nil, entity.ErrNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *memUserRepo) GetActiveByTeamID(ctx context.Context, teamName string) ([]entity.User, error) {
+	_, span := tracing.Start(ctx, "UserRepository.GetActiveByTeamID")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	users := []entity.User{}
+	for _, user := range data.teamMembers(teamName) {
+		if user.IsActive {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (r *memUserRepo) UpdateActivity(ctx context.Context, userID string, isActive bool, version int) (*entity.User, error) {
+	_, span := tracing.Start(ctx, "UserRepository.UpdateActivity")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	user, ok := data.users[userID]
+	if !ok {
+		return nil, entity.ErrNotFound
+	}
+	if version != 0 && user.Version != version {
+		return nil, entity.ErrConflictVersion
+	}
+
+	user.IsActive = isActive
+	user.Version++
+	if team, ok := data.teams[user.TeamName]; ok {
+		team.version++
+	}
+
+	copied := *user
+	return &copied, nil
+}
+
+func (r *memUserRepo) GetAssignedPRs(ctx context.Context, userID string) ([]entity.BasePullRequest, error) {
+	_, span := tracing.Start(ctx, "UserRepository.GetAssignedPRs")
+	defer span.End()
+
+	data, unlock := r.lock()
+	defer unlock()
+
+	prs := []entity.BasePullRequest{}
+	for _, row := range data.sortedPullRequests() {
+		if row.hasReviewer(userID) {
+			prs = append(prs, row.base())
+		}
+	}
+	return prs, nil
+}
+
+// teamMembers returns copies of the team's users ordered by id.
+func (d *memoryData) teamMembers(teamName string) []entity.User {
+	var members []entity.User
+	for _, user := range d.users {
+		if user.TeamName == teamName {
+			members = append(members, *user)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].ID < members[j].ID })
+	return members
+}
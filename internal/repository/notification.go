@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+	adapter "github.com/xddprog/avito-test-task/pkg/db/adapter"
+)
+
+// NotificationRepository keeps users' notification settings and the outbox
+// of messages waiting to be delivered.
+type NotificationRepository interface {
+	// GetSettings fails with ErrNotFound when the user saved none.
+	GetSettings(ctx context.Context, userID string) (*entity.NotificationSettings, error)
+	SaveSettings(ctx context.Context, settings *entity.NotificationSettings) error
+	// Enqueue adds n as PENDING, filling in its ID and, when zero, CreatedAt.
+	Enqueue(ctx context.Context, n *entity.Notification) error
+	// ClaimDue marks up to limit notifications due at now as SENDING until
+	// lockedUntil and returns them in the order they became due. It takes
+	// PENDING ones and SENDING ones whose claim lapsed, and never hands the
+	// same notification to two callers while a claim holds.
+	ClaimDue(ctx context.Context, now, lockedUntil time.Time, limit int) ([]entity.Notification, error)
+	List(ctx context.Context, filter entity.NotificationFilter) ([]entity.Notification, error)
+	MarkSent(ctx context.Context, id int64, sentAt time.Time) error
+	// MarkFailed records a failed attempt. The notification is PENDING again
+	// until retryAt, or given up on as FAILED when retryAt is nil.
+	MarkFailed(ctx context.Context, id int64, lastError string, retryAt *time.Time) error
+}
+
+type notificationRepo struct {
+	db adapter.DB
+}
+
+func NewNotificationRepository(db adapter.DB) NotificationRepository {
+	return &notificationRepo{db: db}
+}
+
+func (r *notificationRepo) GetSettings(ctx context.Context, userID string) (*entity.NotificationSettings, error) {
+	ctx, span := tracing.Start(ctx, "NotificationRepository.GetSettings")
+	defer span.End()
+
+	var (
+		s                    = entity.NotificationSettings{UserID: userID}
+		channels             string
+		email, chat          *string
+		quietStart, quietEnd *string
+		quietTimezone        *string
+	)
+	err := r.db.QueryRow(ctx, `
+		SELECT channels, email, chat_handle, quiet_start, quiet_end, quiet_timezone
+		FROM notification_settings WHERE user_id = $1
+	`, userID).Scan(&channels, &email, &chat, &quietStart, &quietEnd, &quietTimezone)
+	if err != nil {
+		if errors.Is(err, adapter.ErrNoRows) {
+			return nil, entity.ErrNotFound
+		}
+		return nil, err
+	}
+
+	s.Channels = []entity.NotificationChannel{}
+	for _, c := range strings.Split(channels, ",") {
+		if c != "" {
+			s.Channels = append(s.Channels, entity.NotificationChannel(c))
+		}
+	}
+	s.Email, s.ChatHandle = deref(email), deref(chat)
+	if quietStart != nil {
+		s.QuietHours = &entity.QuietHours{Start: *quietStart, End: deref(quietEnd), Timezone: deref(quietTimezone)}
+	}
+	return &s, nil
+}
+
+func (r *notificationRepo) SaveSettings(ctx context.Context, s *entity.NotificationSettings) error {
+	ctx, span := tracing.Start(ctx, "NotificationRepository.SaveSettings")
+	defer span.End()
+
+	channels := make([]string, 0, len(s.Channels))
+	for _, c := range s.Channels {
+		channels = append(channels, string(c))
+	}
+	var quiet entity.QuietHours
+	if s.QuietHours != nil {
+		quiet = *s.QuietHours
+	}
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO notification_settings (user_id, channels, email, chat_handle, quiet_start, quiet_end, quiet_timezone)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id) DO UPDATE SET
+			channels = EXCLUDED.channels,
+			email = EXCLUDED.email,
+			chat_handle = EXCLUDED.chat_handle,
+			quiet_start = EXCLUDED.quiet_start,
+			quiet_end = EXCLUDED.quiet_end,
+			quiet_timezone = EXCLUDED.quiet_timezone
+	`, s.UserID, strings.Join(channels, ","), nullString(s.Email), nullString(s.ChatHandle),
+		nullString(quiet.Start), nullString(quiet.End), nullString(quiet.Timezone))
+	return err
+}
+
+func (r *notificationRepo) Enqueue(ctx context.Context, n *entity.Notification) error {
+	ctx, span := tracing.Start(ctx, "NotificationRepository.Enqueue")
+	defer span.End()
+
+	if n.CreatedAt.IsZero() {
+		n.CreatedAt = time.Now()
+	}
+	n.Status, n.Attempts, n.LastError, n.SentAt = entity.NotificationPending, 0, "", nil
+
+	return r.db.QueryRow(ctx, `
+		INSERT INTO notifications (user_id, channel, kind, recipient, pr_id, subject, body, status, created_at, deliver_after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id
+	`, n.UserID, n.Channel, n.Kind, n.Recipient, nullString(n.PullRequestID), n.Subject, n.Body,
+		n.Status, n.CreatedAt, n.DeliverAfter,
+	).Scan(&n.ID)
+}
+
+const notificationColumns = `id, user_id, channel, kind, recipient, pr_id, subject, body,
+	status, attempts, last_error, created_at, deliver_after, sent_at, locked_until`
+
+func (r *notificationRepo) ClaimDue(ctx context.Context, now, lockedUntil time.Time, limit int) ([]entity.Notification, error) {
+	ctx, span := tracing.Start(ctx, "NotificationRepository.ClaimDue")
+	defer span.End()
+
+	// Dispatchers on other replicas skip the rows this one is claiming
+	// rather than queue up behind it and claim them too.
+	due, err := r.query(ctx, `
+		UPDATE notifications
+		SET status = $1, locked_until = $2
+		WHERE id IN (
+			SELECT id FROM notifications
+			WHERE (status = $3 AND deliver_after <= $4) OR (status = $1 AND locked_until <= $4)
+			ORDER BY deliver_after, id
+			LIMIT $5
+			`+r.db.Dialect().ForUpdateSkipLocked()+`
+		)
+		RETURNING `+notificationColumns,
+		entity.NotificationSending, lockedUntil, entity.NotificationPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	// RETURNING comes in no particular order.
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].DeliverAfter.Equal(due[j].DeliverAfter) {
+			return due[i].DeliverAfter.Before(due[j].DeliverAfter)
+		}
+		return due[i].ID < due[j].ID
+	})
+	return due, nil
+}
+
+func (r *notificationRepo) List(ctx context.Context, filter entity.NotificationFilter) ([]entity.Notification, error) {
+	ctx, span := tracing.Start(ctx, "NotificationRepository.List")
+	defer span.End()
+
+	var conds []string
+	var args []any
+	if filter.UserID != "" {
+		args = append(args, filter.UserID)
+		conds = append(conds, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conds = append(conds, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	query := `SELECT ` + notificationColumns + ` FROM notifications`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	return r.query(ctx, query, args...)
+}
+
+func (r *notificationRepo) query(ctx context.Context, query string, args ...any) ([]entity.Notification, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []entity.Notification{}
+	for rows.Next() {
+		var (
+			n               entity.Notification
+			prID, lastError *string
+		)
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Channel, &n.Kind, &n.Recipient, &prID, &n.Subject, &n.Body,
+			&n.Status, &n.Attempts, &lastError, &n.CreatedAt, &n.DeliverAfter, &n.SentAt, &n.LockedUntil); err != nil {
+			return nil, err
+		}
+		n.PullRequestID, n.LastError = deref(prID), deref(lastError)
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+func (r *notificationRepo) MarkSent(ctx context.Context, id int64, sentAt time.Time) error {
+	ctx, span := tracing.Start(ctx, "NotificationRepository.MarkSent")
+	defer span.End()
+
+	return r.update(ctx, `
+		UPDATE notifications
+		SET status = $1, attempts = attempts + 1, sent_at = $2, locked_until = NULL
+		WHERE id = $3
+	`, entity.NotificationSent, sentAt, id)
+}
+
+func (r *notificationRepo) MarkFailed(ctx context.Context, id int64, lastError string, retryAt *time.Time) error {
+	ctx, span := tracing.Start(ctx, "NotificationRepository.MarkFailed")
+	defer span.End()
+
+	if retryAt == nil {
+		return r.update(ctx, `
+			UPDATE notifications
+			SET status = $1, attempts = attempts + 1, last_error = $2, locked_until = NULL
+			WHERE id = $3
+		`, entity.NotificationFailed, lastError, id)
+	}
+	return r.update(ctx, `
+		UPDATE notifications
+		SET status = $1, attempts = attempts + 1, last_error = $2, deliver_after = $3, locked_until = NULL
+		WHERE id = $4
+	`, entity.NotificationPending, lastError, *retryAt, id)
+}
+
+func (r *notificationRepo) update(ctx context.Context, query string, args ...any) error {
+	tag, err := r.db.Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return entity.ErrNotFound
+	}
+	return nil
+}
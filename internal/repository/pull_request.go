@@ -6,82 +6,155 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/jackc/pgerrcode"
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+	adapter "github.com/xddprog/avito-test-task/pkg/db/adapter"
 )
 
 type PullRequestRepository interface {
 	Create(ctx context.Context, pr *entity.PullRequest) error
+	// CreateBatch inserts all prs in one transaction, so either every PR is
+	// created or none is.
+	CreateBatch(ctx context.Context, prs []*entity.PullRequest) error
 	GetByID(ctx context.Context, id string) (*entity.PullRequest, error)
-	Merge(ctx context.Context, id string) (*entity.PullRequest, error)
-	Reassign(ctx context.Context, prID, oldUserID, newUserID string) error
+	// GetByIDForUpdate is GetByID that also locks the PR row until the
+	// surrounding transaction ends. Only meaningful inside a UnitOfWork.
+	GetByIDForUpdate(ctx context.Context, id string) (*entity.PullRequest, error)
+	// Merge marks the PR merged if it is still at version. It returns
+	// ErrConflictVersion when the PR changed since it was read.
+	Merge(ctx context.Context, id string, version int) (*entity.PullRequest, error)
+	// Reassign swaps the reviewer if the PR is still at version.
+	Reassign(ctx context.Context, prID, oldUserID, newUserID string, reason entity.ReassignReason, version int) error
+	// GetOpenAssignmentsForUsers locks the returned PRs, in id order, when
+	// run inside a UnitOfWork.
 	GetOpenAssignmentsForUsers(ctx context.Context, userIDs []string) ([]entity.ReviewerAssignment, error)
-	ApplyReviewerReplacements(ctx context.Context, replacements []entity.ReassignmentResult) error
+	ApplyReviewerReplacements(ctx context.Context, replacements []entity.ReassignmentResult, reason entity.ReassignReason) error
+	// GetOpenReviewAssignments lists reviewers assigned to open PRs no later
+	// than assignedBefore, ordered by PR id, then assignment time and user id.
+	GetOpenReviewAssignments(ctx context.Context, assignedBefore time.Time) ([]entity.OpenReviewAssignment, error)
 }
 
 type prRepo struct {
-	db *pgxpool.Pool
+	db adapter.DB
 }
 
-func NewPullRequestRepository(db *pgxpool.Pool) PullRequestRepository {
+func NewPullRequestRepository(db adapter.DB) PullRequestRepository {
 	return &prRepo{db: db}
 }
 
 func (r *prRepo) Create(ctx context.Context, pr *entity.PullRequest) error {
+	ctx, span := tracing.Start(ctx, "PullRequestRepository.Create")
+	defer span.End()
+
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = tx.Rollback(ctx) }()
 
-	_, err = tx.Exec(ctx, `
-		INSERT INTO pull_requests (id, name, author_id, status, created_at)
-		VALUES ($1, $2, $3, $4, $5)
-	`, pr.ID, pr.Name, pr.AuthorID, entity.StatusOpen, time.Now())
+	if err := r.insert(ctx, tx, []*entity.PullRequest{pr}, time.Now()); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *prRepo) CreateBatch(ctx context.Context, prs []*entity.PullRequest) error {
+	ctx, span := tracing.Start(ctx, "PullRequestRepository.CreateBatch")
+	defer span.End()
 
+	if len(prs) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin(ctx)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
-			return entity.ErrPRExists
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := r.insert(ctx, tx, prs, time.Now()); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// insert writes the PR rows and their reviewers in one batch. It must run
+// inside a transaction. Each PR is created, and its reviewers assigned, at
+// pr.CreatedAt when the caller set it, otherwise at now.
+func (r *prRepo) insert(ctx context.Context, db adapter.Querier, prs []*entity.PullRequest, now time.Time) error {
+	batch := &adapter.Batch{}
+	for _, pr := range prs {
+		createdAt := now
+		if pr.CreatedAt != nil {
+			createdAt = *pr.CreatedAt
 		}
-		if errors.As(err, &pgErr) && (pgErr.Code == pgerrcode.ForeignKeyViolation || pgErr.ConstraintName == "fk_author") {
-			return entity.ErrNotFound
+		batch.Queue(`
+			INSERT INTO pull_requests (id, name, author_id, status, created_at, sla_deadline)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING version
+		`, pr.ID, pr.Name, pr.AuthorID, entity.StatusOpen, createdAt, pr.SLADeadline)
+		for _, reviewerID := range pr.Reviewers {
+			batch.Queue(`
+				INSERT INTO pr_reviewers (pr_id, user_id, assigned_at) VALUES ($1, $2, $3)
+			`, pr.ID, reviewerID, createdAt)
 		}
-		return err
 	}
 
-	if len(pr.Reviewers) > 0 {
+	br := db.SendBatch(ctx, batch)
+	for _, pr := range prs {
+		if err := br.QueryRow().Scan(&pr.Version); err != nil {
+			_ = br.Close()
+			dialect := r.db.Dialect()
+			if dialect.IsUniqueViolation(err) {
+				return entity.ErrPRExists
+			}
+			if dialect.IsForeignKeyViolation(err) {
+				return entity.ErrNotFound
+			}
+			return err
+		}
+
 		for _, reviewerID := range pr.Reviewers {
-			_, err := tx.Exec(ctx, `
-				INSERT INTO pr_reviewers (pr_id, user_id) VALUES ($1, $2)
-			`, pr.ID, reviewerID)
-			if err != nil {
+			if _, err := br.Exec(); err != nil {
+				_ = br.Close()
 				return fmt.Errorf("failed to add reviewer %s: %w", reviewerID, err)
 			}
 		}
 	}
-
-	return tx.Commit(ctx)
+	return br.Close()
 }
 
 func (r *prRepo) GetByID(ctx context.Context, id string) (*entity.PullRequest, error) {
+	ctx, span := tracing.Start(ctx, "PullRequestRepository.GetByID")
+	defer span.End()
+
+	return r.getByID(ctx, id, "")
+}
+
+func (r *prRepo) GetByIDForUpdate(ctx context.Context, id string) (*entity.PullRequest, error) {
+	ctx, span := tracing.Start(ctx, "PullRequestRepository.GetByIDForUpdate")
+	defer span.End()
+
+	return r.getByID(ctx, id, r.db.Dialect().ForUpdate())
+}
+
+func (r *prRepo) getByID(ctx context.Context, id string, lock string) (*entity.PullRequest, error) {
 	var pr entity.PullRequest
 	err := r.db.QueryRow(ctx, `
-		SELECT id, name, author_id, status, created_at, merged_at 
+		SELECT id, name, author_id, status, created_at, merged_at, version, sla_deadline, sla_met
 		FROM pull_requests WHERE id = $1
-	`, id).Scan(&pr.ID, &pr.Name, &pr.AuthorID, &pr.Status, &pr.CreatedAt, &pr.MergedAt)
+	`+lock, id).Scan(&pr.ID, &pr.Name, &pr.AuthorID, &pr.Status, &pr.CreatedAt, &pr.MergedAt, &pr.Version, &pr.SLADeadline, &pr.SLAMet)
 
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
+		if errors.Is(err, adapter.ErrNoRows) {
 			return nil, entity.ErrNotFound
 		}
 		return nil, err
 	}
 
-	rows, err := r.db.Query(ctx, `SELECT user_id FROM pr_reviewers WHERE pr_id = $1`, id)
+	rows, err := r.db.Query(ctx, `SELECT user_id FROM pr_reviewers WHERE pr_id = $1 ORDER BY assigned_at, user_id`, id)
 	if err != nil {
 		return nil, err
 	}
@@ -101,60 +174,88 @@ func (r *prRepo) GetByID(ctx context.Context, id string) (*entity.PullRequest, e
 	return &pr, nil
 }
 
-func (r *prRepo) Merge(ctx context.Context, id string) (*entity.PullRequest, error) {
-	tx, err := r.db.Begin(ctx)
+func (r *prRepo) Merge(ctx context.Context, id string, version int) (*entity.PullRequest, error) {
+	ctx, span := tracing.Start(ctx, "PullRequestRepository.Merge")
+	defer span.End()
+
+	// sla_met stays NULL for PRs without a deadline.
+	tag, err := r.db.Exec(ctx, `
+		UPDATE pull_requests 
+		SET status = $1, merged_at = $2, sla_met = (sla_deadline >= $2), version = version + 1
+		WHERE id = $3 AND version = $4
+	`, entity.StatusMerged, time.Now(), id, version)
 	if err != nil {
 		return nil, err
 	}
-	defer func() { _ = tx.Rollback(ctx) }()
 
 	pr, err := r.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-
-	if pr.Status == entity.StatusMerged {
-		return pr, nil
+	if tag.RowsAffected() == 0 {
+		return nil, entity.ErrConflictVersion
 	}
+	return pr, nil
+}
 
-	now := time.Now()
-	var mergedAt *time.Time
-	err = tx.QueryRow(ctx, `
-		UPDATE pull_requests 
-		SET status = $1, merged_at = $2
-		WHERE id = $3
-		RETURNING merged_at
-	`, entity.StatusMerged, now, id).Scan(&mergedAt)
-
+// removeReviewer drops an assignment and records it in the reassignment
+// history, so the history never drifts from pr_reviewers. It must run inside
+// a transaction and reports whether the reviewer was assigned.
+func removeReviewer(ctx context.Context, db adapter.Querier, prID, oldUserID, newUserID string, reason entity.ReassignReason, now time.Time) (bool, error) {
+	_, err := db.Exec(ctx, recordReassignmentQuery, prID, oldUserID, newUserID, reason, now)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-		return nil, err
+	res, err := db.Exec(ctx, deleteReviewerQuery, prID, oldUserID)
+	if err != nil {
+		return false, err
 	}
-
-	pr.Status = entity.StatusMerged
-	pr.MergedAt = mergedAt
-	return pr, nil
+	return res.RowsAffected() > 0, nil
 }
 
-func (r *prRepo) Reassign(ctx context.Context, prID, oldUserID, newUserID string) error {
+// recordReassignmentQuery copies an assignment into the reassignment history
+// before deleteReviewerQuery drops it.
+const recordReassignmentQuery = `
+	INSERT INTO reviewer_reassignments (pr_id, old_user_id, new_user_id, reason, assigned_at, reassigned_at)
+	SELECT pr_id, user_id, $3, $4, assigned_at, $5
+	FROM pr_reviewers
+	WHERE pr_id = $1 AND user_id = $2
+`
+
+const deleteReviewerQuery = `DELETE FROM pr_reviewers WHERE pr_id = $1 AND user_id = $2`
+
+// bumpVersionQuery marks a PR as changed for optimistic concurrency checks.
+const bumpVersionQuery = `UPDATE pull_requests SET version = version + 1 WHERE id = $1`
+
+func (r *prRepo) Reassign(ctx context.Context, prID, oldUserID, newUserID string, reason entity.ReassignReason, version int) error {
+	ctx, span := tracing.Start(ctx, "PullRequestRepository.Reassign")
+	defer span.End()
+
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = tx.Rollback(ctx) }()
 
-	tag, err := tx.Exec(ctx, `DELETE FROM pr_reviewers WHERE pr_id = $1 AND user_id = $2`, prID, oldUserID)
+	tag, err := tx.Exec(ctx, bumpVersionQuery+` AND version = $2`, prID, version)
 	if err != nil {
 		return err
 	}
 	if tag.RowsAffected() == 0 {
+		return entity.ErrConflictVersion
+	}
+
+	now := time.Now()
+	removed, err := removeReviewer(ctx, tx, prID, oldUserID, newUserID, reason, now)
+	if err != nil {
+		return err
+	}
+	if !removed {
 		return entity.ErrNotAssigned
 	}
 
-	_, err = tx.Exec(ctx, `INSERT INTO pr_reviewers (pr_id, user_id) VALUES ($1, $2)`, prID, newUserID)
+	_, err = tx.Exec(ctx, `INSERT INTO pr_reviewers (pr_id, user_id, assigned_at) VALUES ($1, $2, $3)`, prID, newUserID, now)
 	if err != nil {
 		return err
 	}
@@ -163,53 +264,84 @@ func (r *prRepo) Reassign(ctx context.Context, prID, oldUserID, newUserID string
 }
 
 func (r *prRepo) GetOpenAssignmentsForUsers(ctx context.Context, userIDs []string) ([]entity.ReviewerAssignment, error) {
+	ctx, span := tracing.Start(ctx, "PullRequestRepository.GetOpenAssignmentsForUsers")
+	defer span.End()
+
 	if len(userIDs) == 0 {
 		return []entity.ReviewerAssignment{}, nil
 	}
 
+	args := []any{entity.StatusOpen}
+	for _, id := range userIDs {
+		args = append(args, id)
+	}
 	rows, err := r.db.Query(ctx, `
-		SELECT
-			pr.id,
-			pr.author_id,
-			prr.user_id AS old_reviewer_id,
-			(
-				SELECT ARRAY_AGG(prr2.user_id)
-				FROM pr_reviewers prr2
-				WHERE prr2.pr_id = pr.id
-			) AS reviewers
+		SELECT pr.id, pr.author_id, prr.user_id
 		FROM pull_requests pr
 		JOIN pr_reviewers prr ON pr.id = prr.pr_id
 		WHERE pr.status = $1
-		  AND prr.user_id = ANY($2)
-	`, entity.StatusOpen, userIDs)
+		  AND prr.user_id IN (`+placeholders(2, len(userIDs))+`)
+		ORDER BY pr.id, prr.user_id
+		`+r.db.Dialect().ForUpdate("pr"), args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var assignments []entity.ReviewerAssignment
+	assignments := []entity.ReviewerAssignment{}
+	reviewers := make(map[string][]string)
 	for rows.Next() {
 		var assignment entity.ReviewerAssignment
-		if err := rows.Scan(
-			&assignment.PullRequestID,
-			&assignment.AuthorID,
-			&assignment.OldReviewerID,
-			&assignment.Reviewers,
-		); err != nil {
+		if err := rows.Scan(&assignment.PullRequestID, &assignment.AuthorID, &assignment.OldReviewerID); err != nil {
 			return nil, err
 		}
 		assignments = append(assignments, assignment)
+		reviewers[assignment.PullRequestID] = nil
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(assignments) == 0 {
+		return assignments, nil
+	}
+
+	prIDs := make([]any, 0, len(reviewers))
+	for id := range reviewers {
+		prIDs = append(prIDs, id)
+	}
+	rows, err = r.db.Query(ctx, `
+		SELECT pr_id, user_id FROM pr_reviewers
+		WHERE pr_id IN (`+placeholders(1, len(prIDs))+`)
+		ORDER BY assigned_at, user_id
+	`, prIDs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var prID, userID string
+		if err := rows.Scan(&prID, &userID); err != nil {
+			return nil, err
+		}
+		reviewers[prID] = append(reviewers[prID], userID)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
-	if assignments == nil {
-		assignments = []entity.ReviewerAssignment{}
+
+	for i := range assignments {
+		assignments[i].Reviewers = reviewers[assignments[i].PullRequestID]
 	}
 	return assignments, nil
 }
 
-func (r *prRepo) ApplyReviewerReplacements(ctx context.Context, replacements []entity.ReassignmentResult) error {
+func (r *prRepo) ApplyReviewerReplacements(ctx context.Context, replacements []entity.ReassignmentResult, reason entity.ReassignReason) error {
+	ctx, span := tracing.Start(ctx, "PullRequestRepository.ApplyReviewerReplacements")
+	defer span.End()
+
 	if len(replacements) == 0 {
 		return nil
 	}
@@ -220,24 +352,23 @@ func (r *prRepo) ApplyReviewerReplacements(ctx context.Context, replacements []e
 	}
 	defer func() { _ = tx.Rollback(ctx) }()
 
-	batch := &pgx.Batch{}
+	now := time.Now()
+	batch := &adapter.Batch{}
 	for _, repl := range replacements {
+		batch.Queue(recordReassignmentQuery, repl.PullRequestID, repl.OldReviewerID, repl.NewReviewerID, reason, now)
+		batch.Queue(deleteReviewerQuery, repl.PullRequestID, repl.OldReviewerID)
 		batch.Queue(`
-			DELETE FROM pr_reviewers
-			WHERE pr_id = $1 AND user_id = $2
-		`, repl.PullRequestID, repl.OldReviewerID)
-
-		batch.Queue(`
-			INSERT INTO pr_reviewers (pr_id, user_id)
-			VALUES ($1, $2)
+			INSERT INTO pr_reviewers (pr_id, user_id, assigned_at)
+			VALUES ($1, $2, $3)
 			ON CONFLICT DO NOTHING
-		`, repl.PullRequestID, repl.NewReviewerID)
+		`, repl.PullRequestID, repl.NewReviewerID, now)
+		batch.Queue(bumpVersionQuery, repl.PullRequestID)
 	}
 
 	br := tx.SendBatch(ctx, batch)
-	for range replacements {
+	for range batch.Len() {
 		if _, err := br.Exec(); err != nil {
-			br.Close()
+			_ = br.Close()
 			return err
 		}
 	}
@@ -247,3 +378,31 @@ func (r *prRepo) ApplyReviewerReplacements(ctx context.Context, replacements []e
 
 	return tx.Commit(ctx)
 }
+
+func (r *prRepo) GetOpenReviewAssignments(ctx context.Context, assignedBefore time.Time) ([]entity.OpenReviewAssignment, error) {
+	ctx, span := tracing.Start(ctx, "PullRequestRepository.GetOpenReviewAssignments")
+	defer span.End()
+
+	rows, err := r.db.Query(ctx, `
+		SELECT pr.id, pr.name, pr.author_id, u.team_name, prr.user_id, prr.assigned_at
+		FROM pull_requests pr
+		JOIN pr_reviewers prr ON pr.id = prr.pr_id
+		JOIN users u ON u.id = pr.author_id
+		WHERE pr.status = $1 AND prr.assigned_at <= $2
+		ORDER BY pr.id, prr.assigned_at, prr.user_id
+	`, entity.StatusOpen, assignedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assignments := []entity.OpenReviewAssignment{}
+	for rows.Next() {
+		var a entity.OpenReviewAssignment
+		if err := rows.Scan(&a.PullRequestID, &a.PullRequestName, &a.AuthorID, &a.TeamName, &a.ReviewerID, &a.AssignedAt); err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments, rows.Err()
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	adapter "github.com/xddprog/avito-test-task/pkg/db/adapter"
+)
+
+// loadSnapshot reads the review tables into memoryData inside one read-only
+// transaction, so the aggregates see a consistent state without holding up
+// writers.
+func loadSnapshot(ctx context.Context, db adapter.DB) (*memoryData, error) {
+	tx, err := db.BeginReadOnly(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	data := newMemoryData()
+
+	err = scanEach(ctx, tx, `SELECT name, version FROM teams`, func(rows adapter.Rows) error {
+		var t memTeam
+		if err := rows.Scan(&t.name, &t.version); err != nil {
+			return err
+		}
+		data.teams[t.name] = &t
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = scanEach(ctx, tx, `SELECT id, username, is_active, team_name, version FROM users`, func(rows adapter.Rows) error {
+		var u entity.User
+		if err := rows.Scan(&u.ID, &u.Username, &u.IsActive, &u.TeamName, &u.Version); err != nil {
+			return err
+		}
+		data.users[u.ID] = &u
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = scanEach(ctx, tx, `
+		SELECT id, name, author_id, status, created_at, merged_at, version, sla_deadline, sla_met FROM pull_requests
+	`, func(rows adapter.Rows) error {
+		var pr memPullRequest
+		var createdAt *time.Time
+		if err := rows.Scan(&pr.id, &pr.name, &pr.authorID, &pr.status, &createdAt, &pr.mergedAt, &pr.version, &pr.slaDeadline, &pr.slaMet); err != nil {
+			return err
+		}
+		if createdAt != nil {
+			pr.createdAt = *createdAt
+		}
+		data.pullRequests[pr.id] = &pr
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = scanEach(ctx, tx, `SELECT pr_id, user_id, assigned_at FROM pr_reviewers`, func(rows adapter.Rows) error {
+		var prID string
+		var reviewer memReviewer
+		if err := rows.Scan(&prID, &reviewer.userID, &reviewer.assignedAt); err != nil {
+			return err
+		}
+		if pr, ok := data.pullRequests[prID]; ok {
+			pr.reviewers = append(pr.reviewers, reviewer)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = scanEach(ctx, tx, `
+		SELECT pr_id, old_user_id, new_user_id, reason, assigned_at, reassigned_at
+		FROM reviewer_reassignments
+		ORDER BY id
+	`, func(rows adapter.Rows) error {
+		var rr memReassignment
+		if err := rows.Scan(&rr.prID, &rr.oldUserID, &rr.newUserID, &rr.reason, &rr.assignedAt, &rr.reassignedAt); err != nil {
+			return err
+		}
+		data.reassignments = append(data.reassignments, rr)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func scanEach(ctx context.Context, db adapter.Querier, query string, scan func(rows adapter.Rows) error) error {
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -4,34 +4,75 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"math"
+	"sort"
+	"strings"
+	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+	adapter "github.com/xddprog/avito-test-task/pkg/db/adapter"
 )
 
 type StatsRepository interface {
-	GetReviewerAssignments(ctx context.Context) ([]entity.ReviewerAssignmentStat, error)
-	GetPRStatus(ctx context.Context) (entity.PRStatusStat, error)
-	GetTeamMembers(ctx context.Context) ([]entity.TeamMemberStat, error)
-	GetPRLifetime(ctx context.Context) (entity.PRLifetimeStat, error)
+	GetReviewerAssignments(ctx context.Context, filter entity.StatsFilter) ([]entity.ReviewerAssignmentStat, error)
+	GetPRStatus(ctx context.Context, filter entity.StatsFilter) (entity.PRStatusStat, error)
+	GetTeamMembers(ctx context.Context, filter entity.StatsFilter) ([]entity.TeamMemberStat, error)
+	GetPRLifetime(ctx context.Context, filter entity.StatsFilter) (entity.PRLifetimeStat, error)
+	GetTimeSeries(ctx context.Context, filter entity.StatsFilter, interval entity.StatsInterval, byTeam bool) ([]entity.TimeSeriesPoint, error)
+	GetUserStats(ctx context.Context, userID string, filter entity.StatsFilter) (*entity.UserReviewStats, error)
+	GetMemberAssignmentCounts(ctx context.Context, filter entity.StatsFilter) ([]entity.MemberAssignmentCount, error)
+	GetOpenPRsByTeam(ctx context.Context) ([]entity.TeamOpenPRs, error)
+	GetReviewerLoad(ctx context.Context) ([]entity.ReviewerLoad, error)
+	// GetSLA counts PRs with a review SLA deadline by state as of now, per
+	// author team and per current reviewer. Overall and breach rates are
+	// left to the caller.
+	GetSLA(ctx context.Context, filter entity.StatsFilter, now time.Time) (*entity.SLAReport, error)
+	// Snapshot returns a StatsRepository whose reads all see the same state
+	// of the data, and a func to call when done with it. Callers combining
+	// several aggregates use it to read the database once.
+	Snapshot(ctx context.Context) (StatsRepository, func(), error)
 }
 
 type statsRepo struct {
-	db *pgxpool.Pool
+	db adapter.DB
 }
 
-func NewStatsRepository(db *pgxpool.Pool) StatsRepository {
-	return &statsRepo{db: db}
+// NewStatsRepository aggregates in SQL on Postgres. SQLite has no
+// percentile_cont, GROUPING SETS or date_trunc, so there the tables are read
+// into memory and aggregated in process.
+func NewStatsRepository(db adapter.DB) StatsRepository {
+	if db.Dialect().Name() == adapter.DialectPostgres {
+		return &statsRepo{db: db}
+	}
+	return &memStatsRepo{snapshot: func(ctx context.Context) (*memoryData, func(), error) {
+		data, err := loadSnapshot(ctx, db)
+		return data, func() {}, err
+	}}
+}
+
+func (r *statsRepo) Snapshot(ctx context.Context) (StatsRepository, func(), error) {
+	tx, err := r.db.BeginReadOnly(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &statsRepo{db: tx}, func() { _ = tx.Rollback(ctx) }, nil
 }
 
-func (r *statsRepo) GetReviewerAssignments(ctx context.Context) ([]entity.ReviewerAssignmentStat, error) {
+func (r *statsRepo) GetReviewerAssignments(ctx context.Context, filter entity.StatsFilter) ([]entity.ReviewerAssignmentStat, error) {
+	ctx, span := tracing.Start(ctx, "StatsRepository.GetReviewerAssignments")
+	defer span.End()
+
+	cond, args := prFilter(filter, "pr", nil)
 	rows, err := r.db.Query(ctx, `
-		SELECT user_id, COUNT(*) AS assignments
-		FROM pr_reviewers
-		GROUP BY user_id
+		SELECT prr.user_id, COUNT(*) AS assignments
+		FROM pr_reviewers prr
+		JOIN pull_requests pr ON pr.id = prr.pr_id
+		WHERE TRUE`+cond+`
+		GROUP BY prr.user_id
 		ORDER BY assignments DESC
-	`)
+	`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -54,12 +95,17 @@ func (r *statsRepo) GetReviewerAssignments(ctx context.Context) ([]entity.Review
 	return stats, nil
 }
 
-func (r *statsRepo) GetPRStatus(ctx context.Context) (entity.PRStatusStat, error) {
+func (r *statsRepo) GetPRStatus(ctx context.Context, filter entity.StatsFilter) (entity.PRStatusStat, error) {
+	ctx, span := tracing.Start(ctx, "StatsRepository.GetPRStatus")
+	defer span.End()
+
+	cond, args := prFilter(filter, "pr", nil)
 	rows, err := r.db.Query(ctx, `
-		SELECT status, COUNT(*) AS cnt
-		FROM pull_requests
-		GROUP BY status
-	`)
+		SELECT pr.status, COUNT(*) AS cnt
+		FROM pull_requests pr
+		WHERE TRUE`+cond+`
+		GROUP BY pr.status
+	`, args...)
 	if err != nil {
 		return entity.PRStatusStat{}, err
 	}
@@ -89,10 +135,12 @@ func (r *statsRepo) GetPRStatus(ctx context.Context) (entity.PRStatusStat, error
 		SELECT AVG(reviewer_count)
 		FROM (
 			SELECT COUNT(*) AS reviewer_count
-			FROM pr_reviewers
-			GROUP BY pr_id
+			FROM pr_reviewers prr
+			JOIN pull_requests pr ON pr.id = prr.pr_id
+			WHERE TRUE`+cond+`
+			GROUP BY prr.pr_id
 		) AS counts
-	`).Scan(&avg)
+	`, args...).Scan(&avg)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return entity.PRStatusStat{}, err
 	}
@@ -103,15 +151,20 @@ func (r *statsRepo) GetPRStatus(ctx context.Context) (entity.PRStatusStat, error
 	return stat, nil
 }
 
-func (r *statsRepo) GetTeamMembers(ctx context.Context) ([]entity.TeamMemberStat, error) {
+func (r *statsRepo) GetTeamMembers(ctx context.Context, filter entity.StatsFilter) ([]entity.TeamMemberStat, error) {
+	ctx, span := tracing.Start(ctx, "StatsRepository.GetTeamMembers")
+	defer span.End()
+
 	rows, err := r.db.Query(ctx, `
 		SELECT team_name,
 		       COUNT(*) FILTER (WHERE is_active)  AS active_members,
 		       COUNT(*) FILTER (WHERE NOT is_active) AS inactive_members
 		FROM users
+		WHERE ($1 = '' OR team_name = $1)
+		  AND ($2 = '' OR team_name = (SELECT team_name FROM users WHERE id = $2))
 		GROUP BY team_name
 		ORDER BY team_name
-	`)
+	`, filter.TeamName, filter.AuthorID)
 	if err != nil {
 		return nil, err
 	}
@@ -134,41 +187,623 @@ func (r *statsRepo) GetTeamMembers(ctx context.Context) ([]entity.TeamMemberStat
 	return stats, nil
 }
 
-func (r *statsRepo) GetPRLifetime(ctx context.Context) (entity.PRLifetimeStat, error) {
+func (r *statsRepo) GetPRLifetime(ctx context.Context, filter entity.StatsFilter) (entity.PRLifetimeStat, error) {
+	ctx, span := tracing.Start(ctx, "StatsRepository.GetPRLifetime")
+	defer span.End()
+
 	var averageSeconds float64
 
+	cond, args := prFilter(filter, "pr", []any{entity.StatusMerged})
 	err := r.db.QueryRow(ctx, `
-		SELECT COALESCE(AVG(EXTRACT(EPOCH FROM (merged_at - created_at))), 0)
-		FROM pull_requests
-		WHERE status = $1 AND merged_at IS NOT NULL
-	`, entity.StatusMerged).Scan(&averageSeconds)
+		SELECT COALESCE(AVG(EXTRACT(EPOCH FROM (pr.merged_at - pr.created_at))), 0)
+		FROM pull_requests pr
+		WHERE pr.status = $1 AND pr.merged_at IS NOT NULL`+cond,
+		args...).Scan(&averageSeconds)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return entity.PRLifetimeStat{}, err
 	}
 	lifetime := entity.PRLifetimeStat{
-		AverageMerge: secondsToDuration(averageSeconds),
+		AverageMerge:   secondsToDuration(averageSeconds),
+		StaleThreshold: secondsToDuration(filter.StaleAfter.Seconds()),
 	}
 
+	now := time.Now()
+	cond, args = prFilter(filter, "pr", []any{entity.StatusOpen, now.Add(-filter.StaleAfter), now.Add(-entity.WeekStaleAfter)})
 	err = r.db.QueryRow(ctx, `
-		SELECT COUNT(*)
-		FROM pull_requests
-		WHERE status = $1 AND created_at <= NOW() - INTERVAL '7 days'
-	`, entity.StatusOpen).Scan(&lifetime.OpenOlderThan7Days)
+		SELECT COUNT(*) FILTER (WHERE pr.created_at <= $2),
+		       COUNT(*) FILTER (WHERE pr.created_at <= $3)
+		FROM pull_requests pr
+		WHERE pr.status = $1`+cond,
+		args...).Scan(&lifetime.OpenOlderThanThreshold, &lifetime.OpenOlderThan7Days)
 	if err != nil {
 		return entity.PRLifetimeStat{}, err
 	}
 
+	if err := r.fillLifetimeDistribution(ctx, filter, &lifetime); err != nil {
+		return entity.PRLifetimeStat{}, err
+	}
+
 	return lifetime, nil
 }
 
-func secondsToDuration(seconds float64) entity.DurationBreakdown {
-	totalMinutes := int(math.Round(seconds / 60))
-	days := totalMinutes / (24 * 60)
-	hours := (totalMinutes % (24 * 60)) / 60
-	minutes := totalMinutes % 60
-	return entity.DurationBreakdown{
-		Days:    days,
-		Hours:   hours,
-		Minutes: minutes,
+// GetMemberAssignmentCounts returns how many assignments every active user
+// received inside the window, including ones later reassigned away. Members
+// without any assignment are reported with zero.
+func (r *statsRepo) GetMemberAssignmentCounts(ctx context.Context, filter entity.StatsFilter) ([]entity.MemberAssignmentCount, error) {
+	ctx, span := tracing.Start(ctx, "StatsRepository.GetMemberAssignmentCounts")
+	defer span.End()
+
+	window := entity.StatsFilter{From: filter.From, To: filter.To}
+	assignedCond, args := filterConds(window, "prr.assigned_at", "", []any{filter.TeamName})
+	historyCond, args := filterConds(window, "rr.assigned_at", "", args)
+
+	rows, err := r.db.Query(ctx, `
+		SELECT u.team_name, u.id,
+		       (SELECT COUNT(*) FROM pr_reviewers prr WHERE prr.user_id = u.id`+assignedCond+`)
+		       + (SELECT COUNT(*) FROM reviewer_reassignments rr WHERE rr.old_user_id = u.id`+historyCond+`) AS assignments
+		FROM users u
+		WHERE u.is_active AND ($1 = '' OR u.team_name = $1)
+		ORDER BY u.team_name, u.id
+	`, args...)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
+
+	var counts []entity.MemberAssignmentCount
+	for rows.Next() {
+		var c entity.MemberAssignmentCount
+		if err := rows.Scan(&c.TeamName, &c.UserID, &c.Assignments); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if counts == nil {
+		counts = []entity.MemberAssignmentCount{}
+	}
+	return counts, nil
+}
+
+func (r *statsRepo) GetOpenPRsByTeam(ctx context.Context) ([]entity.TeamOpenPRs, error) {
+	ctx, span := tracing.Start(ctx, "StatsRepository.GetOpenPRsByTeam")
+	defer span.End()
+
+	rows, err := r.db.Query(ctx, `
+		SELECT t.name, COUNT(pr.id)
+		FROM teams t
+		LEFT JOIN users a ON a.team_name = t.name
+		LEFT JOIN pull_requests pr ON pr.author_id = a.id AND pr.status = $1
+		GROUP BY t.name
+		ORDER BY t.name
+	`, entity.StatusOpen)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []entity.TeamOpenPRs
+	for rows.Next() {
+		var s entity.TeamOpenPRs
+		if err := rows.Scan(&s.TeamName, &s.Open); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if stats == nil {
+		stats = []entity.TeamOpenPRs{}
+	}
+	return stats, nil
+}
+
+func (r *statsRepo) GetReviewerLoad(ctx context.Context) ([]entity.ReviewerLoad, error) {
+	ctx, span := tracing.Start(ctx, "StatsRepository.GetReviewerLoad")
+	defer span.End()
+
+	rows, err := r.db.Query(ctx, `
+		SELECT u.id, u.team_name, COUNT(pr.id)
+		FROM users u
+		LEFT JOIN pr_reviewers prr ON prr.user_id = u.id
+		LEFT JOIN pull_requests pr ON pr.id = prr.pr_id AND pr.status = $1
+		WHERE u.is_active
+		GROUP BY u.id, u.team_name
+		ORDER BY u.team_name, u.id
+	`, entity.StatusOpen)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []entity.ReviewerLoad
+	for rows.Next() {
+		var s entity.ReviewerLoad
+		if err := rows.Scan(&s.UserID, &s.TeamName, &s.Open); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if stats == nil {
+		stats = []entity.ReviewerLoad{}
+	}
+	return stats, nil
+}
+
+func (r *statsRepo) GetSLA(ctx context.Context, filter entity.StatsFilter, now time.Time) (*entity.SLAReport, error) {
+	ctx, span := tracing.Start(ctx, "StatsRepository.GetSLA")
+	defer span.End()
+
+	cond, args := prFilter(filter, "pr", []any{now})
+	sla := `
+		WITH sla AS (
+			SELECT pr.id, pr.sla_deadline, a.team_name,
+			       CASE WHEN pr.sla_met THEN 'met'
+			            WHEN NOT pr.sla_met OR pr.sla_deadline < $1 THEN 'breached'
+			            ELSE 'pending' END AS state
+			FROM pull_requests pr
+			JOIN users a ON a.id = pr.author_id
+			WHERE pr.sla_deadline IS NOT NULL` + cond + `
+		)`
+	counts := `COUNT(*),
+		       COUNT(*) FILTER (WHERE sla.state = 'met'),
+		       COUNT(*) FILTER (WHERE sla.state = 'breached'),
+		       COUNT(*) FILTER (WHERE sla.state = 'pending')`
+
+	report := &entity.SLAReport{AsOf: now, ByTeam: []entity.TeamSLA{}, ByReviewer: []entity.ReviewerSLA{}}
+
+	rows, err := r.db.Query(ctx, sla+`
+		SELECT sla.team_name, `+counts+`
+		FROM sla
+		GROUP BY sla.team_name
+		ORDER BY sla.team_name
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t entity.TeamSLA
+		if err := rows.Scan(&t.TeamName, &t.Total, &t.Met, &t.Breached, &t.Pending); err != nil {
+			return nil, err
+		}
+		report.ByTeam = append(report.ByTeam, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// A reviewer reassigned away after the deadline held the PR when it
+	// was breached, so the breach counts for them as well.
+	rows, err = r.db.Query(ctx, sla+`,
+		held AS (
+			SELECT prr.pr_id, prr.user_id
+			FROM pr_reviewers prr
+			UNION
+			SELECT rr.pr_id, rr.old_user_id
+			FROM reviewer_reassignments rr
+			JOIN sla ON sla.id = rr.pr_id
+			WHERE sla.state = 'breached' AND rr.reassigned_at > sla.sla_deadline
+		)
+		SELECT held.user_id, u.team_name, `+counts+`
+		FROM sla
+		JOIN held ON held.pr_id = sla.id
+		JOIN users u ON u.id = held.user_id
+		GROUP BY held.user_id, u.team_name
+		ORDER BY u.team_name, held.user_id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rv entity.ReviewerSLA
+		if err := rows.Scan(&rv.UserID, &rv.TeamName, &rv.Total, &rv.Met, &rv.Breached, &rv.Pending); err != nil {
+			return nil, err
+		}
+		report.ByReviewer = append(report.ByReviewer, rv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// openAgeBucketDays are the upper bounds of the open PR age histogram buckets;
+// anything older than the last bound falls into a final open-ended bucket.
+var openAgeBucketDays = []float64{1, 3, 7, 14, 30}
+
+var mergePercentiles = []float64{0.5, 0.75, 0.9, 0.99}
+
+// fillLifetimeDistribution computes merge time percentiles and the open PR
+// age histogram overall, per author team and per reviewer count. Both queries
+// use GROUPING SETS so every breakdown comes from a single pass.
+func (r *statsRepo) fillLifetimeDistribution(ctx context.Context, filter entity.StatsFilter, lifetime *entity.PRLifetimeStat) error {
+	groups := newLifetimeGroups()
+
+	cond, args := prFilter(filter, "pr", []any{entity.StatusMerged, mergePercentiles})
+	rows, err := r.db.Query(ctx, `
+		SELECT GROUPING(m.team_name), GROUPING(m.reviewer_count), m.team_name, m.reviewer_count,
+		       COUNT(*),
+		       percentile_cont($2::float8[]) WITHIN GROUP (ORDER BY m.merge_seconds)
+		FROM (
+			SELECT a.team_name,
+			       (SELECT COUNT(*) FROM pr_reviewers prr WHERE prr.pr_id = pr.id) AS reviewer_count,
+			       EXTRACT(EPOCH FROM (pr.merged_at - pr.created_at))::float8 AS merge_seconds
+			FROM pull_requests pr
+			JOIN users a ON a.id = pr.author_id
+			WHERE pr.status = $1 AND pr.merged_at IS NOT NULL`+cond+`
+		) AS m
+		GROUP BY GROUPING SETS ((), (m.team_name), (m.reviewer_count))
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var groupedTeam, groupedReviewers, count int
+		var teamName *string
+		var reviewerCount *int
+		var percentiles []float64
+		if err := rows.Scan(&groupedTeam, &groupedReviewers, &teamName, &reviewerCount, &count, &percentiles); err != nil {
+			return err
+		}
+		breakdown := groups.get(groupedTeam == 0, groupedReviewers == 0, teamName, reviewerCount)
+		breakdown.Merged = count
+		if len(percentiles) == len(mergePercentiles) {
+			breakdown.MergePercentiles = entity.DurationPercentiles{
+				P50: secondsToDuration(percentiles[0]),
+				P75: secondsToDuration(percentiles[1]),
+				P90: secondsToDuration(percentiles[2]),
+				P99: secondsToDuration(percentiles[3]),
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	cond, args = prFilter(filter, "pr", []any{entity.StatusOpen, openAgeBucketDays})
+	rows, err = r.db.Query(ctx, `
+		SELECT GROUPING(o.team_name), GROUPING(o.reviewer_count), o.team_name, o.reviewer_count,
+		       o.bucket, COUNT(*)
+		FROM (
+			SELECT a.team_name,
+			       (SELECT COUNT(*) FROM pr_reviewers prr WHERE prr.pr_id = pr.id) AS reviewer_count,
+			       width_bucket(
+			           (EXTRACT(EPOCH FROM (NOW() - pr.created_at)) / 86400)::float8,
+			           $2::float8[]
+			       ) AS bucket
+			FROM pull_requests pr
+			JOIN users a ON a.id = pr.author_id
+			WHERE pr.status = $1`+cond+`
+		) AS o
+		GROUP BY GROUPING SETS ((o.bucket), (o.team_name, o.bucket), (o.reviewer_count, o.bucket))
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var groupedTeam, groupedReviewers, bucket, count int
+		var teamName *string
+		var reviewerCount *int
+		if err := rows.Scan(&groupedTeam, &groupedReviewers, &teamName, &reviewerCount, &bucket, &count); err != nil {
+			return err
+		}
+		breakdown := groups.get(groupedTeam == 0, groupedReviewers == 0, teamName, reviewerCount)
+		breakdown.Open += count
+		if bucket >= 0 && bucket < len(breakdown.OpenAgeHistogram) {
+			breakdown.OpenAgeHistogram[bucket].Count += count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	lifetime.MergePercentiles = groups.overall.MergePercentiles
+	lifetime.OpenAgeHistogram = groups.overall.OpenAgeHistogram
+	lifetime.ByTeam = groups.teams()
+	lifetime.ByReviewerCount = groups.reviewerCounts()
+	return nil
+}
+
+type lifetimeGroups struct {
+	overall     *entity.LifetimeBreakdown
+	byTeam      map[string]*entity.LifetimeBreakdown
+	byReviewers map[int]*entity.LifetimeBreakdown
+}
+
+func newLifetimeGroups() *lifetimeGroups {
+	return &lifetimeGroups{
+		overall:     newLifetimeBreakdown(),
+		byTeam:      make(map[string]*entity.LifetimeBreakdown),
+		byReviewers: make(map[int]*entity.LifetimeBreakdown),
+	}
+}
+
+func (g *lifetimeGroups) get(byTeam, byReviewers bool, teamName *string, reviewerCount *int) *entity.LifetimeBreakdown {
+	switch {
+	case byTeam && teamName != nil:
+		b, ok := g.byTeam[*teamName]
+		if !ok {
+			b = newLifetimeBreakdown()
+			b.TeamName = *teamName
+			g.byTeam[*teamName] = b
+		}
+		return b
+	case byReviewers && reviewerCount != nil:
+		b, ok := g.byReviewers[*reviewerCount]
+		if !ok {
+			b = newLifetimeBreakdown()
+			count := *reviewerCount
+			b.ReviewerCount = &count
+			g.byReviewers[count] = b
+		}
+		return b
+	default:
+		return g.overall
+	}
+}
+
+func (g *lifetimeGroups) teams() []entity.LifetimeBreakdown {
+	names := make([]string, 0, len(g.byTeam))
+	for name := range g.byTeam {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]entity.LifetimeBreakdown, 0, len(names))
+	for _, name := range names {
+		result = append(result, *g.byTeam[name])
+	}
+	return result
+}
+
+func (g *lifetimeGroups) reviewerCounts() []entity.LifetimeBreakdown {
+	counts := make([]int, 0, len(g.byReviewers))
+	for count := range g.byReviewers {
+		counts = append(counts, count)
+	}
+	sort.Ints(counts)
+
+	result := make([]entity.LifetimeBreakdown, 0, len(counts))
+	for _, count := range counts {
+		result = append(result, *g.byReviewers[count])
+	}
+	return result
+}
+
+func newLifetimeBreakdown() *entity.LifetimeBreakdown {
+	return &entity.LifetimeBreakdown{OpenAgeHistogram: newAgeHistogram()}
+}
+
+func newAgeHistogram() []entity.AgeHistogramBucket {
+	buckets := make([]entity.AgeHistogramBucket, 0, len(openAgeBucketDays)+1)
+	from := 0
+	for _, bound := range openAgeBucketDays {
+		to := int(bound)
+		buckets = append(buckets, entity.AgeHistogramBucket{
+			Label:    fmt.Sprintf("%d-%dd", from, to),
+			FromDays: from,
+			ToDays:   &to,
+		})
+		from = to
+	}
+	return append(buckets, entity.AgeHistogramBucket{
+		Label:    fmt.Sprintf("%dd+", from),
+		FromDays: from,
+	})
+}
+
+func (r *statsRepo) GetTimeSeries(
+	ctx context.Context,
+	filter entity.StatsFilter,
+	interval entity.StatsInterval,
+	byTeam bool,
+) ([]entity.TimeSeriesPoint, error) {
+	ctx, span := tracing.Start(ctx, "StatsRepository.GetTimeSeries")
+	defer span.End()
+
+	cond, args := filterConds(filter, "ev.at", "ev.author_id", []any{string(interval), byTeam})
+	rows, err := r.db.Query(ctx, `
+		SELECT date_trunc($1, ev.at AT TIME ZONE 'UTC') AS bucket,
+		       CASE WHEN $2 THEN a.team_name ELSE '' END AS team,
+		       COUNT(*) FILTER (WHERE ev.kind = 'created') AS created,
+		       COUNT(*) FILTER (WHERE ev.kind = 'merged') AS merged,
+		       COUNT(*) FILTER (WHERE ev.kind = 'assigned') AS assigned,
+		       percentile_cont(0.5) WITHIN GROUP (ORDER BY ev.merge_seconds)
+		           FILTER (WHERE ev.kind = 'merged') AS median_merge
+		FROM (
+			SELECT pr.author_id, 'created' AS kind, pr.created_at AS at, NULL::float8 AS merge_seconds
+			FROM pull_requests pr
+			UNION ALL
+			SELECT pr.author_id, 'merged', pr.merged_at, EXTRACT(EPOCH FROM (pr.merged_at - pr.created_at))::float8
+			FROM pull_requests pr
+			WHERE pr.merged_at IS NOT NULL
+			UNION ALL
+			SELECT pr.author_id, 'assigned', prr.assigned_at, NULL
+			FROM pr_reviewers prr
+			JOIN pull_requests pr ON pr.id = prr.pr_id
+		) AS ev
+		JOIN users a ON a.id = ev.author_id
+		WHERE ev.at IS NOT NULL`+cond+`
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []entity.TimeSeriesPoint
+	for rows.Next() {
+		var p entity.TimeSeriesPoint
+		var median sql.NullFloat64
+		if err := rows.Scan(&p.Bucket, &p.TeamName, &p.Created, &p.Merged, &p.Assignments, &median); err != nil {
+			return nil, err
+		}
+		if median.Valid {
+			d := secondsToDuration(median.Float64)
+			p.MedianMerge = &d
+		}
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if points == nil {
+		points = []entity.TimeSeriesPoint{}
+	}
+	return points, nil
+}
+
+func (r *statsRepo) GetUserStats(ctx context.Context, userID string, filter entity.StatsFilter) (*entity.UserReviewStats, error) {
+	ctx, span := tracing.Start(ctx, "StatsRepository.GetUserStats")
+	defer span.End()
+
+	stats := &entity.UserReviewStats{
+		UserID:          userID,
+		ReassignReasons: []entity.ReassignReasonStat{},
+	}
+
+	err := r.db.QueryRow(ctx, `SELECT team_name FROM users WHERE id = $1`, userID).Scan(&stats.Team.TeamName)
+	if err != nil {
+		if errors.Is(err, adapter.ErrNoRows) {
+			return nil, entity.ErrNotFound
+		}
+		return nil, err
+	}
+
+	window := entity.StatsFilter{From: filter.From, To: filter.To}
+
+	assignedCond, args := filterConds(window, "prr.assigned_at", "", []any{userID})
+	historyCond, args := filterConds(window, "rr.assigned_at", "", args)
+	err = r.db.QueryRow(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM pr_reviewers prr WHERE prr.user_id = $1`+assignedCond+`)
+			+ (SELECT COUNT(*) FROM reviewer_reassignments rr WHERE rr.old_user_id = $1`+historyCond+`)
+	`, args...).Scan(&stats.AssignmentsReceived)
+	if err != nil {
+		return nil, err
+	}
+
+	cond, args := filterConds(window, "rr.reassigned_at", "", []any{userID})
+	rows, err := r.db.Query(ctx, `
+		SELECT rr.reason, COUNT(*) AS cnt
+		FROM reviewer_reassignments rr
+		WHERE rr.old_user_id = $1`+cond+`
+		GROUP BY rr.reason
+		ORDER BY cnt DESC, rr.reason
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rs entity.ReassignReasonStat
+		if err := rows.Scan(&rs.Reason, &rs.Count); err != nil {
+			return nil, err
+		}
+		stats.ReassignedAway += rs.Count
+		stats.ReassignReasons = append(stats.ReassignReasons, rs)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var averageSeconds float64
+	cond, args = filterConds(window, "prr.assigned_at", "", []any{userID, entity.StatusMerged})
+	err = r.db.QueryRow(ctx, `
+		SELECT COUNT(*), COALESCE(AVG(EXTRACT(EPOCH FROM (pr.merged_at - prr.assigned_at))), 0)
+		FROM pr_reviewers prr
+		JOIN pull_requests pr ON pr.id = prr.pr_id
+		WHERE prr.user_id = $1 AND pr.status = $2 AND pr.merged_at IS NOT NULL`+cond,
+		args...).Scan(&stats.MergedReviews, &averageSeconds)
+	if err != nil {
+		return nil, err
+	}
+	stats.AverageAssignmentToMerge = secondsToDuration(averageSeconds)
+
+	err = r.db.QueryRow(ctx, `
+		SELECT COUNT(*)
+		FROM pr_reviewers prr
+		JOIN pull_requests pr ON pr.id = prr.pr_id
+		WHERE prr.user_id = $1 AND pr.status = $2
+	`, userID, entity.StatusOpen).Scan(&stats.OpenLoad)
+	if err != nil {
+		return nil, err
+	}
+
+	var medianAssignments, medianOpenLoad sql.NullFloat64
+	assignedCond, args = filterConds(window, "prr.assigned_at", "", []any{stats.Team.TeamName, entity.StatusOpen})
+	historyCond, args = filterConds(window, "rr.assigned_at", "", args)
+	err = r.db.QueryRow(ctx, `
+		SELECT COUNT(*),
+		       percentile_cont(0.5) WITHIN GROUP (ORDER BY m.received),
+		       percentile_cont(0.5) WITHIN GROUP (ORDER BY m.open_load)
+		FROM (
+			SELECT
+				(SELECT COUNT(*) FROM pr_reviewers prr WHERE prr.user_id = u.id`+assignedCond+`)
+				+ (SELECT COUNT(*) FROM reviewer_reassignments rr WHERE rr.old_user_id = u.id`+historyCond+`) AS received,
+				(SELECT COUNT(*)
+				 FROM pr_reviewers prr
+				 JOIN pull_requests pr ON pr.id = prr.pr_id
+				 WHERE prr.user_id = u.id AND pr.status = $2) AS open_load
+			FROM users u
+			WHERE u.team_name = $1 AND u.is_active
+		) AS m
+	`, args...).Scan(&stats.Team.ActiveMembers, &medianAssignments, &medianOpenLoad)
+	if err != nil {
+		return nil, err
+	}
+	if medianAssignments.Valid {
+		stats.Team.MedianAssignments = math.Round(medianAssignments.Float64*10) / 10
+	}
+	if medianOpenLoad.Valid {
+		stats.Team.MedianOpenLoad = math.Round(medianOpenLoad.Float64*10) / 10
+	}
+
+	return stats, nil
+}
+
+// prFilter renders the PR-scoped part of the filter as extra WHERE conditions
+// on the given pull_requests alias. Placeholders continue after the values
+// already present in args.
+func prFilter(filter entity.StatsFilter, alias string, args []any) (string, []any) {
+	return filterConds(filter, alias+".created_at", alias+".author_id", args)
+}
+
+func filterConds(filter entity.StatsFilter, timeCol, authorCol string, args []any) (string, []any) {
+	var cond strings.Builder
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		fmt.Fprintf(&cond, " AND %s >= $%d", timeCol, len(args))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		fmt.Fprintf(&cond, " AND %s < $%d", timeCol, len(args))
+	}
+	if filter.TeamName != "" {
+		args = append(args, filter.TeamName)
+		fmt.Fprintf(&cond, " AND %s IN (SELECT id FROM users WHERE team_name = $%d)", authorCol, len(args))
+	}
+	if filter.AuthorID != "" {
+		args = append(args, filter.AuthorID)
+		fmt.Fprintf(&cond, " AND %s = $%d", authorCol, len(args))
+	}
+	return cond.String(), args
+}
+
+func secondsToDuration(seconds float64) entity.DurationBreakdown {
+	return entity.NewDurationBreakdown(time.Duration(seconds * float64(time.Second)))
 }
@@ -3,68 +3,64 @@ package repository
 import (
 	"context"
 	"errors"
+	"sort"
 
-	"github.com/jackc/pgerrcode"
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+	adapter "github.com/xddprog/avito-test-task/pkg/db/adapter"
 )
 
 type TeamRepository interface {
 	Create(ctx context.Context, team *entity.Team) error
 	GetByName(ctx context.Context, name string) (*entity.Team, error)
-	DeactivateMembers(ctx context.Context, teamName string, userIDs []string) ([]string, error)
+	// LockByName locks the team row until the surrounding transaction ends
+	// and returns the team's version. Only meaningful inside a UnitOfWork.
+	LockByName(ctx context.Context, name string) (int, error)
+	// DeactivateMembers turns members off and bumps their and the team's
+	// versions. A non-zero version requires the team to still be at it.
+	DeactivateMembers(ctx context.Context, teamName string, userIDs []string, version int) ([]string, error)
 }
 
 type teamRepo struct {
-	db *pgxpool.Pool
+	db adapter.DB
 }
 
-func NewTeamRepository(db *pgxpool.Pool) TeamRepository {
+func NewTeamRepository(db adapter.DB) TeamRepository {
 	return &teamRepo{
 		db: db,
 	}
 }
 
 func (r *teamRepo) Create(ctx context.Context, team *entity.Team) error {
+	ctx, span := tracing.Start(ctx, "TeamRepository.Create")
+	defer span.End()
+
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = tx.Rollback(ctx) }()
 
-	_, err = tx.Exec(ctx, `INSERT INTO teams (name) VALUES ($1)`, team.Name)
+	err = tx.QueryRow(ctx, `INSERT INTO teams (name) VALUES ($1) RETURNING version`, team.Name).Scan(&team.Version)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		if r.db.Dialect().IsUniqueViolation(err) {
 			return entity.ErrTeamExists
 		}
 		return err
 	}
 
-	if len(team.Members) > 0 {
-		batch := &pgx.Batch{}
-		for _, member := range team.Members {
-			batch.Queue(`
-				INSERT INTO users (id, username, is_active, team_name)
-				VALUES ($1, $2, $3, $4)
-				ON CONFLICT (id) DO UPDATE
-				SET username = EXCLUDED.username,
-				    is_active = EXCLUDED.is_active,
-				    team_name = EXCLUDED.team_name
-			`, member.ID, member.Username, member.IsActive, team.Name)
-		}
-
-		br := tx.SendBatch(ctx, batch)
-		for i := 0; i < len(team.Members); i++ {
-			_, err := br.Exec()
-			if err != nil {
-				br.Close()
-				return err
-			}
-		}
-		if err := br.Close(); err != nil {
+	for i, member := range team.Members {
+		err := tx.QueryRow(ctx, `
+			INSERT INTO users (id, username, is_active, team_name)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (id) DO UPDATE
+			SET username = EXCLUDED.username,
+			    is_active = EXCLUDED.is_active,
+			    team_name = EXCLUDED.team_name,
+			    version = users.version + 1
+			RETURNING version
+		`, member.ID, member.Username, member.IsActive, team.Name).Scan(&team.Members[i].Version)
+		if err != nil {
 			return err
 		}
 	}
@@ -73,10 +69,14 @@ func (r *teamRepo) Create(ctx context.Context, team *entity.Team) error {
 }
 
 func (r *teamRepo) GetByName(ctx context.Context, name string) (*entity.Team, error) {
+	ctx, span := tracing.Start(ctx, "TeamRepository.GetByName")
+	defer span.End()
+
 	var teamName string
-	err := r.db.QueryRow(ctx, `SELECT name FROM teams WHERE name = $1`, name).Scan(&teamName)
+	var version int
+	err := r.db.QueryRow(ctx, `SELECT name, version FROM teams WHERE name = $1`, name).Scan(&teamName, &version)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
+		if errors.Is(err, adapter.ErrNoRows) {
 			return nil, entity.ErrNotFound
 		}
 		return nil, err
@@ -84,7 +84,7 @@ func (r *teamRepo) GetByName(ctx context.Context, name string) (*entity.Team, er
 
 	rows, err := r.db.Query(
 		ctx,
-		`SELECT id, username, is_active, team_name FROM users WHERE team_name = $1`,
+		`SELECT id, username, is_active, team_name, version FROM users WHERE team_name = $1 ORDER BY id`,
 		name,
 	)
 	if err != nil {
@@ -95,7 +95,7 @@ func (r *teamRepo) GetByName(ctx context.Context, name string) (*entity.Team, er
 	var members []entity.User
 	for rows.Next() {
 		var u entity.User
-		if err := rows.Scan(&u.ID, &u.Username, &u.IsActive, &u.TeamName); err != nil {
+		if err := rows.Scan(&u.ID, &u.Username, &u.IsActive, &u.TeamName, &u.Version); err != nil {
 			return nil, err
 		}
 		members = append(members, u)
@@ -108,28 +108,60 @@ func (r *teamRepo) GetByName(ctx context.Context, name string) (*entity.Team, er
 	return &entity.Team{
 		Name:    teamName,
 		Members: members,
+		Version: version,
 	}, nil
 }
 
-func (r *teamRepo) DeactivateMembers(ctx context.Context, teamName string, userIDs []string) ([]string, error) {
+func (r *teamRepo) LockByName(ctx context.Context, name string) (int, error) {
+	ctx, span := tracing.Start(ctx, "TeamRepository.LockByName")
+	defer span.End()
+
+	var version int
+	err := r.db.QueryRow(ctx, `SELECT version FROM teams WHERE name = $1 `+r.db.Dialect().ForUpdate(), name).Scan(&version)
+	if err != nil {
+		if errors.Is(err, adapter.ErrNoRows) {
+			return 0, entity.ErrNotFound
+		}
+		return 0, err
+	}
+	return version, nil
+}
+
+func (r *teamRepo) DeactivateMembers(ctx context.Context, teamName string, userIDs []string, version int) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "TeamRepository.DeactivateMembers")
+	defer span.End()
+
 	if teamName == "" || len(userIDs) == 0 {
 		return nil, entity.ErrBadRequest
 	}
 
-	var exists bool
-	if err := r.db.QueryRow(ctx, `SELECT true FROM teams WHERE name = $1`, teamName).Scan(&exists); err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var current int
+	if err := tx.QueryRow(ctx, `SELECT version FROM teams WHERE name = $1 `+r.db.Dialect().ForUpdate(), teamName).Scan(&current); err != nil {
+		if errors.Is(err, adapter.ErrNoRows) {
 			return nil, entity.ErrNotFound
 		}
 		return nil, err
 	}
+	if version != 0 && current != version {
+		return nil, entity.ErrConflictVersion
+	}
 
-	rows, err := r.db.Query(ctx, `
+	args := []any{teamName}
+	for _, id := range userIDs {
+		args = append(args, id)
+	}
+	rows, err := tx.Query(ctx, `
 		UPDATE users
-		SET is_active = false
-		WHERE team_name = $1 AND id = ANY($2) AND is_active = true
+		SET is_active = false, version = version + 1
+		WHERE team_name = $1 AND is_active = true AND id IN (`+placeholders(2, len(userIDs))+`)
 		RETURNING id
-	`, teamName, userIDs)
+	`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -146,8 +178,20 @@ func (r *teamRepo) DeactivateMembers(ctx context.Context, teamName string, userI
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
+	rows.Close()
+
+	if len(affected) > 0 {
+		if _, err := tx.Exec(ctx, `UPDATE teams SET version = version + 1 WHERE name = $1`, teamName); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
 	if affected == nil {
 		affected = []string{}
 	}
+	sort.Strings(affected)
 	return affected, nil
 }
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/tracing"
+	adapter "github.com/xddprog/avito-test-task/pkg/db/adapter"
+)
+
+// maxTxAttempts is high enough for every waiter on a contended row to get
+// through: under SERIALIZABLE each commit ahead of a transaction can fail it
+// once.
+const maxTxAttempts = 10
+
+// Repositories are bound to one transaction for the duration of a
+// UnitOfWork callback.
+type Repositories struct {
+	PullRequests PullRequestRepository
+	Users        UserRepository
+	Teams        TeamRepository
+	Backup       BackupRepository
+	Audit        AuditRepository
+}
+
+type UnitOfWork interface {
+	// Do runs fn in a single SERIALIZABLE transaction, committing if fn
+	// returns nil. Deadlocks and serialization failures are retried, so fn
+	// must not have side effects outside the repositories it is given.
+	Do(ctx context.Context, fn func(ctx context.Context, repos Repositories) error) error
+}
+
+type sqlUnitOfWork struct {
+	db adapter.DB
+}
+
+func NewUnitOfWork(db adapter.DB) UnitOfWork {
+	return &sqlUnitOfWork{db: db}
+}
+
+func (u *sqlUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context, repos Repositories) error) error {
+	ctx, span := tracing.Start(ctx, "UnitOfWork.Do")
+	defer span.End()
+
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = u.run(ctx, fn)
+		if !u.db.Dialect().IsRetryable(err) {
+			return err
+		}
+		slog.WarnContext(ctx, "retrying transaction", "attempt", attempt, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(rand.Int63n(int64(attempt) * int64(5*time.Millisecond)))):
+		}
+	}
+	return err
+}
+
+func (u *sqlUnitOfWork) run(ctx context.Context, fn func(ctx context.Context, repos Repositories) error) error {
+	tx, err := u.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if stmt := u.db.Dialect().Serializable(); stmt != "" {
+		if _, err := tx.Exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	if err := fn(ctx, Repositories{
+		PullRequests: NewPullRequestRepository(tx),
+		Users:        NewUserRepository(tx),
+		Teams:        NewTeamRepository(tx),
+		Backup:       NewBackupRepository(tx),
+		Audit:        NewAuditRepository(tx),
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// placeholders renders n numbered placeholders starting at $start, for use
+// in an IN (...) list.
+func placeholders(start, n int) string {
+	items := make([]string, n)
+	for i := range items {
+		items[i] = fmt.Sprintf("$%d", start+i)
+	}
+	return strings.Join(items, ", ")
+}
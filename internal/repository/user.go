@@ -4,35 +4,40 @@ import (
 	"context"
 	"errors"
 
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+	adapter "github.com/xddprog/avito-test-task/pkg/db/adapter"
 )
 
 type UserRepository interface {
 	GetByID(ctx context.Context, userID string) (*entity.User, error)
 	GetActiveByTeamID(ctx context.Context, teamName string) ([]entity.User, error)
-	UpdateActivity(ctx context.Context, userID string, isActive bool) (*entity.User, error)
+	// UpdateActivity sets is_active and bumps the user's and team's versions.
+	// A non-zero version makes the update conditional on the current version.
+	UpdateActivity(ctx context.Context, userID string, isActive bool, version int) (*entity.User, error)
 	GetAssignedPRs(ctx context.Context, userID string) ([]entity.BasePullRequest, error)
 }
 
 type userRepo struct {
-	db *pgxpool.Pool
+	db adapter.DB
 }
 
-func NewUserRepository(db *pgxpool.Pool) UserRepository {
+func NewUserRepository(db adapter.DB) UserRepository {
 	return &userRepo{db: db}
 }
 
 func (r *userRepo) GetByID(ctx context.Context, userID string) (*entity.User, error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.GetByID")
+	defer span.End()
+
 	var user entity.User
 	err := r.db.QueryRow(ctx, `
-		SELECT id, username, is_active, team_name
+		SELECT id, username, is_active, team_name, version
 		FROM users
 		WHERE id = $1
-	`, userID).Scan(&user.ID, &user.Username, &user.IsActive, &user.TeamName)
+	`, userID).Scan(&user.ID, &user.Username, &user.IsActive, &user.TeamName, &user.Version)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
+		if errors.Is(err, adapter.ErrNoRows) {
 			return nil, entity.ErrNotFound
 		}
 		return nil, err
@@ -41,10 +46,14 @@ func (r *userRepo) GetByID(ctx context.Context, userID string) (*entity.User, er
 }
 
 func (r *userRepo) GetActiveByTeamID(ctx context.Context, teamName string) ([]entity.User, error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.GetActiveByTeamID")
+	defer span.End()
+
 	rows, err := r.db.Query(ctx, `
-		SELECT id, username, is_active, team_name
+		SELECT id, username, is_active, team_name, version
 		FROM users
 		WHERE team_name = $1 AND is_active = true
+		ORDER BY id
 	`, teamName)
 	if err != nil {
 		return nil, err
@@ -54,7 +63,7 @@ func (r *userRepo) GetActiveByTeamID(ctx context.Context, teamName string) ([]en
 	var users []entity.User
 	for rows.Next() {
 		var user entity.User
-		if err := rows.Scan(&user.ID, &user.Username, &user.IsActive, &user.TeamName); err != nil {
+		if err := rows.Scan(&user.ID, &user.Username, &user.IsActive, &user.TeamName, &user.Version); err != nil {
 			return nil, err
 		}
 		users = append(users, user)
@@ -71,35 +80,57 @@ func (r *userRepo) GetActiveByTeamID(ctx context.Context, teamName string) ([]en
 	return users, nil
 }
 
-func (r *userRepo) UpdateActivity(ctx context.Context, userID string, isActive bool) (*entity.User, error) {
-	var user entity.User
+func (r *userRepo) UpdateActivity(ctx context.Context, userID string, isActive bool, version int) (*entity.User, error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.UpdateActivity")
+	defer span.End()
 
-	query := `
-		UPDATE users 
-		SET is_active = $1 
-		WHERE id = $2 
-		RETURNING id, username, is_active, team_name
-	`
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
 
-	err := r.db.QueryRow(ctx, query, isActive, userID).Scan(
-		&user.ID, &user.Username, &user.IsActive, &user.TeamName,
+	var user entity.User
+	err = tx.QueryRow(ctx, `
+		UPDATE users
+		SET is_active = $1, version = version + 1
+		WHERE id = $2 AND ($3 = 0 OR version = $3)
+		RETURNING id, username, is_active, team_name, version
+	`, isActive, userID, version).Scan(
+		&user.ID, &user.Username, &user.IsActive, &user.TeamName, &user.Version,
 	)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
+		if errors.Is(err, adapter.ErrNoRows) {
+			if version != 0 {
+				if _, getErr := NewUserRepository(tx).GetByID(ctx, userID); getErr == nil {
+					return nil, entity.ErrConflictVersion
+				}
+			}
 			return nil, entity.ErrNotFound
 		}
 		return nil, err
 	}
 
+	if _, err := tx.Exec(ctx, `UPDATE teams SET version = version + 1 WHERE name = $1`, user.TeamName); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
 
 func (r *userRepo) GetAssignedPRs(ctx context.Context, userID string) ([]entity.BasePullRequest, error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.GetAssignedPRs")
+	defer span.End()
+
 	query := `
 		SELECT pr.id, pr.name, pr.author_id, pr.status
 		FROM pull_requests pr
 		JOIN pr_reviewers r ON pr.id = r.pr_id
 		WHERE r.user_id = $1
+		ORDER BY pr.id
 	`
 
 	rows, err := r.db.Query(ctx, query, userID)
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/repository"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type AuditService interface {
+	// List returns matching entries newest first. A zero limit means the
+	// default page size; larger limits are capped.
+	List(ctx context.Context, filter entity.AuditFilter) ([]entity.AuditEntry, error)
+}
+
+type auditService struct {
+	repo repository.AuditRepository
+}
+
+func NewAuditService(repo repository.AuditRepository) AuditService {
+	return &auditService{repo: repo}
+}
+
+func (s *auditService) List(ctx context.Context, filter entity.AuditFilter) ([]entity.AuditEntry, error) {
+	ctx, span := tracing.Start(ctx, "AuditService.List")
+	defer span.End()
+
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, entity.ErrBadRequest
+	}
+	switch {
+	case filter.Limit < 0:
+		return nil, entity.ErrBadRequest
+	case filter.Limit == 0:
+		filter.Limit = defaultAuditLimit
+	case filter.Limit > maxAuditLimit:
+		filter.Limit = maxAuditLimit
+	}
+	return s.repo.List(ctx, filter)
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/repository"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+)
+
+type BackupService interface {
+	Export(ctx context.Context, opts entity.ExportOptions) (*entity.Backup, error)
+	// Import restores a backup in one transaction. The backup must be
+	// self-contained: every reference has to resolve within it.
+	Import(ctx context.Context, backup *entity.Backup, opts entity.ImportOptions) (*entity.ImportResult, error)
+}
+
+// errDryRun rolls back the import transaction after a successful dry run.
+var errDryRun = errors.New("dry run")
+
+type backupService struct {
+	uow  repository.UnitOfWork
+	repo repository.BackupRepository
+}
+
+func NewBackupService(uow repository.UnitOfWork, repo repository.BackupRepository) BackupService {
+	return &backupService{uow: uow, repo: repo}
+}
+
+func (s *backupService) Export(ctx context.Context, opts entity.ExportOptions) (*entity.Backup, error) {
+	ctx, span := tracing.Start(ctx, "BackupService.Export")
+	defer span.End()
+
+	backup, err := s.repo.Export(ctx)
+	if err != nil {
+		return nil, err
+	}
+	backup.FormatVersion = entity.BackupFormatVersion
+	backup.ExportedAt = time.Now().UTC()
+
+	if opts.Anonymize {
+		anonymize(backup)
+	}
+	return backup, nil
+}
+
+// anonymize replaces usernames and PR titles, and remaps user ids and team
+// names. Every occurrence of an id maps to the same alias, so references and
+// review statistics stay intact.
+func anonymize(backup *entity.Backup) {
+	teams := aliases{prefix: "team", names: make(map[string]string)}
+	users := aliases{prefix: "user", names: make(map[string]string)}
+
+	for i := range backup.Teams {
+		backup.Teams[i].Name = teams.of(backup.Teams[i].Name)
+	}
+	for i := range backup.Users {
+		u := &backup.Users[i]
+		u.ID = users.of(u.ID)
+		u.Username = u.ID
+		u.TeamName = teams.of(u.TeamName)
+	}
+	for i := range backup.PullRequests {
+		pr := &backup.PullRequests[i]
+		pr.Name = fmt.Sprintf("pull request %d", i+1)
+		pr.AuthorID = users.of(pr.AuthorID)
+	}
+	for i := range backup.Reviewers {
+		backup.Reviewers[i].UserID = users.of(backup.Reviewers[i].UserID)
+	}
+}
+
+// aliases hands out prefix-1, prefix-2, ... in order of first use.
+type aliases struct {
+	prefix string
+	names  map[string]string
+}
+
+func (a aliases) of(name string) string {
+	if alias, ok := a.names[name]; ok {
+		return alias
+	}
+	alias := fmt.Sprintf("%s-%d", a.prefix, len(a.names)+1)
+	a.names[name] = alias
+	return alias
+}
+
+func (s *backupService) Import(ctx context.Context, backup *entity.Backup, opts entity.ImportOptions) (*entity.ImportResult, error) {
+	ctx, span := tracing.Start(ctx, "BackupService.Import")
+	defer span.End()
+
+	if err := validateBackup(backup); err != nil {
+		return nil, err
+	}
+
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		if opts.Replace {
+			if err := repos.Backup.Clear(ctx); err != nil {
+				return err
+			}
+		}
+		if err := repos.Backup.Import(ctx, backup); err != nil {
+			return err
+		}
+		if opts.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+
+	return &entity.ImportResult{
+		DryRun:       opts.DryRun,
+		Replaced:     opts.Replace,
+		Teams:        len(backup.Teams),
+		Users:        len(backup.Users),
+		PullRequests: len(backup.PullRequests),
+		Reviewers:    len(backup.Reviewers),
+	}, nil
+}
+
+// validateBackup checks the format version, required fields, duplicates
+// and references, so a broken file is reported with the offending row
+// rather than as a constraint violation.
+func validateBackup(backup *entity.Backup) error {
+	if backup.FormatVersion != entity.BackupFormatVersion {
+		return invalidBackup("unsupported format_version %d, expected %d", backup.FormatVersion, entity.BackupFormatVersion)
+	}
+
+	teams := make(map[string]bool, len(backup.Teams))
+	for i, t := range backup.Teams {
+		if t.Name == "" {
+			return invalidBackup("teams[%d]: team_name is required", i)
+		}
+		if teams[t.Name] {
+			return invalidBackup("teams[%d]: duplicate team %q", i, t.Name)
+		}
+		teams[t.Name] = true
+	}
+
+	users := make(map[string]bool, len(backup.Users))
+	for i, u := range backup.Users {
+		if u.ID == "" || u.Username == "" {
+			return invalidBackup("users[%d]: user_id and username are required", i)
+		}
+		if users[u.ID] {
+			return invalidBackup("users[%d]: duplicate user %q", i, u.ID)
+		}
+		if !teams[u.TeamName] {
+			return invalidBackup("users[%d]: unknown team %q", i, u.TeamName)
+		}
+		users[u.ID] = true
+	}
+
+	prs := make(map[string]bool, len(backup.PullRequests))
+	for i, pr := range backup.PullRequests {
+		if pr.ID == "" || pr.Name == "" {
+			return invalidBackup("pull_requests[%d]: pull_request_id and pull_request_name are required", i)
+		}
+		if prs[pr.ID] {
+			return invalidBackup("pull_requests[%d]: duplicate pull request %q", i, pr.ID)
+		}
+		if !users[pr.AuthorID] {
+			return invalidBackup("pull_requests[%d]: unknown author %q", i, pr.AuthorID)
+		}
+		if pr.CreatedAt.IsZero() {
+			return invalidBackup("pull_requests[%d]: created_at is required", i)
+		}
+		if pr.SLADeadline != nil && pr.SLADeadline.Before(pr.CreatedAt) {
+			return invalidBackup("pull_requests[%d]: sla_deadline is before created_at", i)
+		}
+		switch pr.Status {
+		case entity.StatusOpen:
+			if pr.MergedAt != nil {
+				return invalidBackup("pull_requests[%d]: open pull request has merged_at", i)
+			}
+		case entity.StatusMerged:
+			if pr.MergedAt == nil {
+				return invalidBackup("pull_requests[%d]: merged pull request has no merged_at", i)
+			}
+		default:
+			return invalidBackup("pull_requests[%d]: unknown status %q", i, pr.Status)
+		}
+		prs[pr.ID] = true
+	}
+
+	reviewers := make(map[[2]string]bool, len(backup.Reviewers))
+	for i, rv := range backup.Reviewers {
+		if !prs[rv.PullRequestID] {
+			return invalidBackup("reviewers[%d]: unknown pull request %q", i, rv.PullRequestID)
+		}
+		if !users[rv.UserID] {
+			return invalidBackup("reviewers[%d]: unknown user %q", i, rv.UserID)
+		}
+		key := [2]string{rv.PullRequestID, rv.UserID}
+		if reviewers[key] {
+			return invalidBackup("reviewers[%d]: duplicate reviewer %q", i, rv.UserID)
+		}
+		if rv.AssignedAt.IsZero() {
+			return invalidBackup("reviewers[%d]: assigned_at is required", i)
+		}
+		reviewers[key] = true
+	}
+	return nil
+}
+
+func invalidBackup(format string, args ...any) error {
+	return entity.New(http.StatusBadRequest, entity.ErrBadRequest.SafeCode, "invalid backup: "+fmt.Sprintf(format, args...))
+}
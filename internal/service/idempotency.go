@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/repository"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+)
+
+const idempotencySweepInterval = 10 * time.Minute
+
+type IdempotencyService interface {
+	// Begin claims rec.Key for this request. It returns the stored record when
+	// an earlier request with the same key and payload already completed, and
+	// nil when the caller should process the request and then Complete it.
+	Begin(ctx context.Context, rec *entity.IdempotencyRecord) (*entity.IdempotencyRecord, error)
+	Complete(ctx context.Context, rec *entity.IdempotencyRecord) error
+	// Release frees a claimed key without storing a response, so the client
+	// can retry after a server-side failure.
+	Release(ctx context.Context, rec *entity.IdempotencyRecord) error
+	// Sweep deletes expired keys periodically until ctx is cancelled.
+	Sweep(ctx context.Context)
+}
+
+type idempotencyService struct {
+	repo  repository.IdempotencyRepository
+	ttl   time.Duration
+	lease time.Duration
+}
+
+// NewIdempotencyService keeps responses for ttl. A request holds its key for
+// at most lease; if it crashes without releasing the key, a retry can take
+// the key over once the lease has passed.
+func NewIdempotencyService(repo repository.IdempotencyRepository, ttl, lease time.Duration) IdempotencyService {
+	return &idempotencyService{repo: repo, ttl: ttl, lease: lease}
+}
+
+func (s *idempotencyService) Begin(ctx context.Context, rec *entity.IdempotencyRecord) (*entity.IdempotencyRecord, error) {
+	ctx, span := tracing.Start(ctx, "IdempotencyService.Begin")
+	defer span.End()
+
+	now := time.Now()
+	rec.LockedUntil = now.Add(s.lease)
+	rec.ExpiresAt = now.Add(s.ttl)
+	reserved, err := s.repo.Reserve(ctx, rec)
+	if err != nil {
+		return nil, err
+	}
+	if reserved {
+		return nil, nil
+	}
+
+	stored, err := s.repo.Get(ctx, rec.IdempotencyScope)
+	if err != nil {
+		if errors.Is(err, entity.ErrNotFound) {
+			// The holder released the key between Reserve and Get.
+			return nil, entity.ErrIdempotencyInProgress
+		}
+		return nil, err
+	}
+	if stored.RequestHash != rec.RequestHash {
+		return nil, entity.ErrIdempotencyKeyReused
+	}
+	if !stored.Completed() {
+		return nil, entity.ErrIdempotencyInProgress
+	}
+	return stored, nil
+}
+
+func (s *idempotencyService) Complete(ctx context.Context, rec *entity.IdempotencyRecord) error {
+	ctx, span := tracing.Start(ctx, "IdempotencyService.Complete")
+	defer span.End()
+
+	return s.repo.Complete(ctx, rec)
+}
+
+func (s *idempotencyService) Release(ctx context.Context, rec *entity.IdempotencyRecord) error {
+	ctx, span := tracing.Start(ctx, "IdempotencyService.Release")
+	defer span.End()
+
+	return s.repo.Release(ctx, rec.IdempotencyScope)
+}
+
+func (s *idempotencyService) Sweep(ctx context.Context) {
+	ticker := time.NewTicker(idempotencySweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := s.repo.DeleteExpired(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to delete expired idempotency keys", "error", err)
+				continue
+			}
+			if deleted > 0 {
+				slog.DebugContext(ctx, "deleted expired idempotency keys", "count", deleted)
+			}
+		}
+	}
+}
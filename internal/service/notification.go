@@ -0,0 +1,423 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"slices"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/event"
+	"github.com/xddprog/avito-test-task/internal/notify"
+	"github.com/xddprog/avito-test-task/internal/repository"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+)
+
+const (
+	defaultNotificationLimit = 100
+	maxNotificationLimit     = 1000
+	// notificationBatchSize is small so that a claimed batch is sent well
+	// within the lease even when every send runs into its timeout.
+	notificationBatchSize = 20
+)
+
+type NotificationOptions struct {
+	// Channels are the configured delivery channels; users can only pick
+	// from these.
+	Channels map[entity.NotificationChannel]notify.Channel
+	// DefaultChannels apply to users who saved no settings.
+	DefaultChannels []entity.NotificationChannel
+	Templates       *notify.Templates
+	Interval        time.Duration
+	// A notification is given up on after MaxAttempts failed sends. The
+	// n-th retry waits RetryBackoff doubled n-1 times.
+	MaxAttempts  int
+	RetryBackoff time.Duration
+	// Lease is how long a dispatcher holds the batch it claimed. It must
+	// cover sending the whole batch, or another replica sends it again.
+	Lease time.Duration
+}
+
+type NotificationService interface {
+	// GetSettings returns the user's settings, or the defaults when the user
+	// saved none.
+	GetSettings(ctx context.Context, userID string) (*entity.NotificationSettings, error)
+	SaveSettings(ctx context.Context, settings *entity.NotificationSettings) (*entity.NotificationSettings, error)
+	// List returns outbox notifications newest first, with the same limits
+	// as the audit log.
+	List(ctx context.Context, filter entity.NotificationFilter) ([]entity.Notification, error)
+	// Subscribe queues notifications for assignment, reassignment and
+	// reminder events published on bus.
+	Subscribe(bus event.Bus)
+	// Dispatch claims and sends every notification due at now once, so
+	// dispatchers on several replicas never send the same one twice while
+	// a claim holds.
+	Dispatch(ctx context.Context, now time.Time) (*entity.NotificationDispatch, error)
+	// Run dispatches every Interval, and right after something is queued,
+	// until ctx is cancelled.
+	Run(ctx context.Context)
+}
+
+type notificationService struct {
+	repo     repository.NotificationRepository
+	userRepo repository.UserRepository
+	prRepo   repository.PullRequestRepository
+	opts     NotificationOptions
+	wake     chan struct{}
+}
+
+func NewNotificationService(
+	repo repository.NotificationRepository,
+	userRepo repository.UserRepository,
+	prRepo repository.PullRequestRepository,
+	opts NotificationOptions,
+) NotificationService {
+	return &notificationService{
+		repo:     repo,
+		userRepo: userRepo,
+		prRepo:   prRepo,
+		opts:     opts,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+func (s *notificationService) GetSettings(ctx context.Context, userID string) (*entity.NotificationSettings, error) {
+	ctx, span := tracing.Start(ctx, "NotificationService.GetSettings")
+	defer span.End()
+
+	if userID == "" {
+		return nil, entity.ErrBadRequest
+	}
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.settings(ctx, userID)
+}
+
+func (s *notificationService) settings(ctx context.Context, userID string) (*entity.NotificationSettings, error) {
+	settings, err := s.repo.GetSettings(ctx, userID)
+	if errors.Is(err, entity.ErrNotFound) {
+		return &entity.NotificationSettings{UserID: userID, Channels: slices.Clone(s.opts.DefaultChannels)}, nil
+	}
+	return settings, err
+}
+
+func (s *notificationService) SaveSettings(ctx context.Context, settings *entity.NotificationSettings) (*entity.NotificationSettings, error) {
+	ctx, span := tracing.Start(ctx, "NotificationService.SaveSettings")
+	defer span.End()
+
+	if settings.UserID == "" {
+		return nil, entity.ErrBadRequest
+	}
+	if _, err := s.userRepo.GetByID(ctx, settings.UserID); err != nil {
+		return nil, err
+	}
+	if err := s.validateSettings(settings); err != nil {
+		return nil, err
+	}
+	if err := s.repo.SaveSettings(ctx, settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// validateSettings checks settings and normalizes them in place: channels
+// are deduplicated and quiet hours get an explicit time zone.
+func (s *notificationService) validateSettings(settings *entity.NotificationSettings) error {
+	channels := make([]entity.NotificationChannel, 0, len(settings.Channels))
+	for _, c := range settings.Channels {
+		if _, ok := s.opts.Channels[c]; !ok {
+			return invalidSettings("channel %q is not available", c)
+		}
+		if !slices.Contains(channels, c) {
+			channels = append(channels, c)
+		}
+	}
+	settings.Channels = channels
+
+	if settings.Email != "" {
+		if addr, err := mail.ParseAddress(settings.Email); err != nil || addr.Address != settings.Email {
+			return invalidSettings("email %q is not a valid address", settings.Email)
+		}
+	}
+	if slices.Contains(channels, entity.ChannelEmail) && settings.Email == "" {
+		return invalidSettings("the email channel requires an email")
+	}
+
+	if q := settings.QuietHours; q != nil {
+		if _, err := time.Parse("15:04", q.Start); err != nil {
+			return invalidSettings("quiet_hours.start %q is not a HH:MM time", q.Start)
+		}
+		if _, err := time.Parse("15:04", q.End); err != nil {
+			return invalidSettings("quiet_hours.end %q is not a HH:MM time", q.End)
+		}
+		if q.Start == q.End {
+			return invalidSettings("quiet_hours must not start and end at the same time")
+		}
+		if q.Timezone == "" {
+			q.Timezone = "UTC"
+		}
+		if _, err := time.LoadLocation(q.Timezone); err != nil {
+			return invalidSettings("unknown time zone %q", q.Timezone)
+		}
+	}
+	return nil
+}
+
+func invalidSettings(format string, args ...any) error {
+	return entity.New(http.StatusBadRequest, entity.ErrBadRequest.SafeCode, "invalid notification settings: "+fmt.Sprintf(format, args...))
+}
+
+func (s *notificationService) List(ctx context.Context, filter entity.NotificationFilter) ([]entity.Notification, error) {
+	ctx, span := tracing.Start(ctx, "NotificationService.List")
+	defer span.End()
+
+	switch filter.Status {
+	case "", entity.NotificationPending, entity.NotificationSending, entity.NotificationSent, entity.NotificationFailed:
+	default:
+		return nil, entity.ErrBadRequest
+	}
+	switch {
+	case filter.Limit < 0:
+		return nil, entity.ErrBadRequest
+	case filter.Limit == 0:
+		filter.Limit = defaultNotificationLimit
+	case filter.Limit > maxNotificationLimit:
+		filter.Limit = maxNotificationLimit
+	}
+	return s.repo.List(ctx, filter)
+}
+
+func (s *notificationService) Subscribe(bus event.Bus) {
+	bus.Subscribe(event.TypeReviewAssigned, s.onAssigned)
+	bus.Subscribe(event.TypeReviewReassigned, s.onAssigned)
+	bus.Subscribe(event.TypeReviewReminder, s.onReminder)
+}
+
+// onAssigned tells the new reviewer about the review and, on reassignment,
+// the previous one that it was taken off them.
+func (s *notificationService) onAssigned(ctx context.Context, e event.Event) {
+	a, ok := e.Payload.(entity.ReviewAssignment)
+	if !ok {
+		return
+	}
+	s.enqueue(ctx, e.OccurredAt, a.ReviewerID, a.PullRequestID, notify.Data{
+		Kind:               entity.NotifyReviewAssigned,
+		Reason:             a.Reason,
+		PreviousReviewerID: a.PreviousReviewerID,
+	})
+	if a.PreviousReviewerID != "" {
+		s.enqueue(ctx, e.OccurredAt, a.PreviousReviewerID, a.PullRequestID, notify.Data{
+			Kind:          entity.NotifyReviewUnassigned,
+			Reason:        a.Reason,
+			NewReviewerID: a.ReviewerID,
+		})
+	}
+}
+
+func (s *notificationService) onReminder(ctx context.Context, e event.Event) {
+	r, ok := e.Payload.(entity.StaleReview)
+	if !ok {
+		return
+	}
+	s.enqueue(ctx, e.OccurredAt, r.ReviewerID, r.PullRequestID, notify.Data{
+		Kind:    entity.NotifyReviewReminder,
+		Waiting: r.Waiting,
+	})
+}
+
+// enqueue renders data for the user and queues it on each of their
+// channels. It runs inside event handlers, so failures are logged rather
+// than returned to the publisher. The change behind the event is already
+// committed, so the notification is queued even if the request that made
+// it is cancelled meanwhile.
+func (s *notificationService) enqueue(ctx context.Context, now time.Time, userID, prID string, data notify.Data) {
+	ctx, span := tracing.Start(context.WithoutCancel(ctx), "NotificationService.Enqueue")
+	defer span.End()
+
+	logFailure := func(err error) {
+		slog.ErrorContext(ctx, "failed to queue notification",
+			"kind", data.Kind, "user_id", userID, "pull_request_id", prID, "error", err)
+	}
+
+	settings, err := s.settings(ctx, userID)
+	if err != nil {
+		logFailure(err)
+		return
+	}
+	if len(settings.Channels) == 0 {
+		return
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		logFailure(err)
+		return
+	}
+	pr, err := s.prRepo.GetByID(ctx, prID)
+	if err != nil {
+		logFailure(err)
+		return
+	}
+
+	data.Recipient, data.PullRequest = *user, *pr
+	subject, body, err := s.opts.Templates.Render(data)
+	if err != nil {
+		logFailure(err)
+		return
+	}
+
+	deliverAfter := quietHoursEnd(settings.QuietHours, now)
+	queued := false
+	for _, channel := range settings.Channels {
+		n := &entity.Notification{
+			UserID:        userID,
+			Channel:       channel,
+			Kind:          data.Kind,
+			Recipient:     recipient(channel, settings, user),
+			PullRequestID: prID,
+			Subject:       subject,
+			Body:          body,
+			CreatedAt:     now,
+			DeliverAfter:  deliverAfter,
+		}
+		if err := s.repo.Enqueue(ctx, n); err != nil {
+			logFailure(err)
+			continue
+		}
+		queued = true
+	}
+	if queued && !deliverAfter.After(now) {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func recipient(channel entity.NotificationChannel, settings *entity.NotificationSettings, user *entity.User) string {
+	switch channel {
+	case entity.ChannelEmail:
+		return settings.Email
+	case entity.ChannelChat:
+		if settings.ChatHandle != "" {
+			return settings.ChatHandle
+		}
+		return user.Username
+	default:
+		return user.ID
+	}
+}
+
+// quietHoursEnd returns when a notification created at now may be sent:
+// now itself, or the end of the quiet hours now falls into.
+func quietHoursEnd(q *entity.QuietHours, now time.Time) time.Time {
+	if q == nil {
+		return now
+	}
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		return now
+	}
+	start, errStart := time.Parse("15:04", q.Start)
+	end, errEnd := time.Parse("15:04", q.End)
+	if errStart != nil || errEnd != nil {
+		return now
+	}
+
+	local := now.In(loc)
+	y, m, d := local.Date()
+	startAt := time.Date(y, m, d, start.Hour(), start.Minute(), 0, 0, loc)
+	endAt := time.Date(y, m, d, end.Hour(), end.Minute(), 0, 0, loc)
+	switch {
+	case startAt.Before(endAt):
+		if !local.Before(startAt) && local.Before(endAt) {
+			return endAt
+		}
+	case local.Before(endAt):
+		// The window began yesterday evening.
+		return endAt
+	case !local.Before(startAt):
+		return time.Date(y, m, d+1, end.Hour(), end.Minute(), 0, 0, loc)
+	}
+	return now
+}
+
+func (s *notificationService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+		dispatch, err := s.Dispatch(ctx, time.Now())
+		if err != nil {
+			slog.ErrorContext(ctx, "notification dispatch failed", "error", err)
+			continue
+		}
+		if *dispatch != (entity.NotificationDispatch{}) {
+			slog.InfoContext(ctx, "notification dispatch finished",
+				"sent", dispatch.Sent,
+				"retried", dispatch.Retried,
+				"failed", dispatch.Failed,
+			)
+		}
+	}
+}
+
+func (s *notificationService) Dispatch(ctx context.Context, now time.Time) (*entity.NotificationDispatch, error) {
+	ctx, span := tracing.Start(ctx, "NotificationService.Dispatch")
+	defer span.End()
+
+	dispatch := &entity.NotificationDispatch{}
+	for {
+		due, err := s.repo.ClaimDue(ctx, now, now.Add(s.opts.Lease), notificationBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range due {
+			if err := s.deliver(ctx, now, n, dispatch); err != nil {
+				return nil, err
+			}
+		}
+		// Every notification in the batch was marked sent, rescheduled
+		// after now or failed, so the next claim takes new ones.
+		if len(due) < notificationBatchSize {
+			return dispatch, nil
+		}
+	}
+}
+
+func (s *notificationService) deliver(ctx context.Context, now time.Time, n entity.Notification, dispatch *entity.NotificationDispatch) error {
+	channel, ok := s.opts.Channels[n.Channel]
+	if !ok {
+		dispatch.Failed++
+		return s.repo.MarkFailed(ctx, n.ID, fmt.Sprintf("channel %q is not configured", n.Channel), nil)
+	}
+
+	err := channel.Send(ctx, notify.Message{Recipient: n.Recipient, Subject: n.Subject, Body: n.Body})
+	if err == nil {
+		dispatch.Sent++
+		return s.repo.MarkSent(ctx, n.ID, now)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	slog.WarnContext(ctx, "failed to send notification",
+		"notification_id", n.ID, "channel", n.Channel, "attempt", n.Attempts+1, "error", err)
+	if n.Attempts+1 >= s.opts.MaxAttempts {
+		dispatch.Failed++
+		return s.repo.MarkFailed(ctx, n.ID, err.Error(), nil)
+	}
+	retryAt := now.Add(s.opts.RetryBackoff << min(n.Attempts, 10))
+	dispatch.Retried++
+	return s.repo.MarkFailed(ctx, n.ID, err.Error(), &retryAt)
+}
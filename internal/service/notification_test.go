@@ -0,0 +1,285 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/event"
+	"github.com/xddprog/avito-test-task/internal/notify"
+	"github.com/xddprog/avito-test-task/internal/repository"
+	"github.com/xddprog/avito-test-task/internal/service"
+)
+
+type recordingChannel struct {
+	sent []notify.Message
+	err  error
+	// onSend, when set, runs before every send.
+	onSend func()
+}
+
+func (c *recordingChannel) Send(_ context.Context, msg notify.Message) error {
+	if c.onSend != nil {
+		c.onSend()
+	}
+	if c.err != nil {
+		return c.err
+	}
+	c.sent = append(c.sent, msg)
+	return nil
+}
+
+type notificationFixture struct {
+	store     *repository.MemoryStore
+	prService service.PullRequestService
+	notifier  service.NotificationService
+	log, chat *recordingChannel
+}
+
+// newNotificationFixture creates team backend with author u1, reviewers u2
+// and u3 and an inactive u4, with notifications on log by default.
+func newNotificationFixture(t *testing.T) *notificationFixture {
+	t.Helper()
+	ctx := context.Background()
+	f := &notificationFixture{store: repository.NewMemoryStore(), log: &recordingChannel{}, chat: &recordingChannel{}}
+
+	members := []entity.User{
+		{ID: "u1", Username: "Alice", IsActive: true},
+		{ID: "u2", Username: "Bob", IsActive: true},
+		{ID: "u3", Username: "Carol", IsActive: true},
+		{ID: "u4", Username: "Dave", IsActive: false},
+	}
+	if err := f.store.Teams().Create(ctx, &entity.Team{Name: "backend", Members: members}); err != nil {
+		t.Fatalf("create team: %v", err)
+	}
+
+	templates, err := notify.LoadTemplates("")
+	if err != nil {
+		t.Fatalf("load templates: %v", err)
+	}
+	bus := event.NewBus()
+	f.notifier = service.NewNotificationService(f.store.Notifications(), f.store.Users(), f.store.PullRequests(), service.NotificationOptions{
+		Channels: map[entity.NotificationChannel]notify.Channel{
+			entity.ChannelLog:  f.log,
+			entity.ChannelChat: f.chat,
+		},
+		DefaultChannels: []entity.NotificationChannel{entity.ChannelLog},
+		Templates:       templates,
+		Interval:        time.Minute,
+		MaxAttempts:     2,
+		RetryBackoff:    time.Minute,
+		Lease:           time.Minute,
+	})
+	f.notifier.Subscribe(bus)
+	f.prService = service.NewPullRequestService(f.store.UnitOfWork(), f.store.Users(), bus, service.SLAOptions{})
+	return f
+}
+
+func (f *notificationFixture) createPR(t *testing.T) {
+	t.Helper()
+	if _, err := f.prService.Create(context.Background(), &entity.CreatePRRequest{ID: "pr-1", Name: "feature", AuthorID: "u1"}); err != nil {
+		t.Fatalf("create pr: %v", err)
+	}
+}
+
+func (f *notificationFixture) dispatch(t *testing.T, after time.Duration, want entity.NotificationDispatch) {
+	t.Helper()
+	got, err := f.notifier.Dispatch(context.Background(), time.Now().Add(after))
+	if err != nil {
+		t.Fatalf("dispatch after %s: %v", after, err)
+	}
+	if *got != want {
+		t.Fatalf("dispatch after %s = %+v, want %+v", after, *got, want)
+	}
+}
+
+func recipients(msgs []notify.Message) []string {
+	var ids []string
+	for _, m := range msgs {
+		ids = append(ids, m.Recipient)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestNotificationPreferencesAndQuietHours(t *testing.T) {
+	f := newNotificationFixture(t)
+	ctx := context.Background()
+
+	// Bob's quiet hours are the two hours around now.
+	now := time.Now().UTC()
+	_, err := f.notifier.SaveSettings(ctx, &entity.NotificationSettings{
+		UserID:     "u2",
+		Channels:   []entity.NotificationChannel{entity.ChannelLog, entity.ChannelChat},
+		ChatHandle: "@bob",
+		QuietHours: &entity.QuietHours{Start: now.Add(-time.Hour).Format("15:04"), End: now.Add(time.Hour).Format("15:04")},
+	})
+	if err != nil {
+		t.Fatalf("save settings: %v", err)
+	}
+
+	f.createPR(t)
+	f.dispatch(t, time.Minute, entity.NotificationDispatch{Sent: 1})
+	if got := recipients(f.log.sent); !slices.Equal(got, []string{"u3"}) || len(f.chat.sent) != 0 {
+		t.Fatalf("sent before quiet hours ended: log %v, chat %v", got, f.chat.sent)
+	}
+	if msg := f.log.sent[0]; msg.Subject != "Review requested: feature" || !strings.Contains(msg.Body, "Hi Carol,") {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+
+	f.dispatch(t, 2*time.Hour, entity.NotificationDispatch{Sent: 2})
+	if got := recipients(f.log.sent); !slices.Equal(got, []string{"u2", "u3"}) {
+		t.Fatalf("log recipients = %v", got)
+	}
+	if got := recipients(f.chat.sent); !slices.Equal(got, []string{"@bob"}) {
+		t.Fatalf("chat recipients = %v", got)
+	}
+
+	// Carol opts out, Dave has no settings and gets the default channel.
+	if _, err := f.notifier.SaveSettings(ctx, &entity.NotificationSettings{UserID: "u3", Channels: []entity.NotificationChannel{}}); err != nil {
+		t.Fatalf("save settings: %v", err)
+	}
+	if _, err := f.store.Users().UpdateActivity(ctx, "u4", true, 0); err != nil {
+		t.Fatalf("activate u4: %v", err)
+	}
+	if _, _, err := f.prService.Reassign(ctx, "pr-1", "u3", 0); err != nil {
+		t.Fatalf("reassign: %v", err)
+	}
+	f.log.sent = nil
+	f.dispatch(t, 2*time.Hour, entity.NotificationDispatch{Sent: 1})
+	if msg := f.log.sent[0]; msg.Recipient != "u4" || !strings.Contains(msg.Body, "from u3: the review was reassigned") {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+}
+
+func TestNotificationReassignmentNotifiesBothReviewers(t *testing.T) {
+	f := newNotificationFixture(t)
+	ctx := context.Background()
+	f.createPR(t)
+	f.dispatch(t, 0, entity.NotificationDispatch{Sent: 2})
+
+	if _, err := f.store.Users().UpdateActivity(ctx, "u4", true, 0); err != nil {
+		t.Fatalf("activate u4: %v", err)
+	}
+	if _, _, err := f.prService.Reassign(ctx, "pr-1", "u2", 0); err != nil {
+		t.Fatalf("reassign: %v", err)
+	}
+	f.log.sent = nil
+	f.dispatch(t, 0, entity.NotificationDispatch{Sent: 2})
+
+	for _, msg := range f.log.sent {
+		switch msg.Recipient {
+		case "u2":
+			if msg.Subject != "Review reassigned: feature" || !strings.Contains(msg.Body, "u4") {
+				t.Errorf("unexpected unassignment: %+v", msg)
+			}
+		case "u4":
+			if msg.Subject != "Review requested: feature" {
+				t.Errorf("unexpected assignment: %+v", msg)
+			}
+		default:
+			t.Errorf("unexpected recipient: %+v", msg)
+		}
+	}
+}
+
+func TestNotificationRetriesThenFails(t *testing.T) {
+	f := newNotificationFixture(t)
+	f.log.err = errors.New("connection refused")
+	f.createPR(t)
+
+	f.dispatch(t, 0, entity.NotificationDispatch{Retried: 2})
+	f.dispatch(t, 30*time.Second, entity.NotificationDispatch{})
+	f.dispatch(t, 2*time.Minute, entity.NotificationDispatch{Failed: 2})
+	f.dispatch(t, time.Hour, entity.NotificationDispatch{})
+
+	failed, err := f.notifier.List(context.Background(), entity.NotificationFilter{Status: entity.NotificationFailed})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(failed) != 2 || failed[0].Attempts != 2 || failed[0].LastError != "connection refused" {
+		t.Fatalf("unexpected failed notifications: %+v", failed)
+	}
+}
+
+func TestNotificationDispatchClaimsBeforeSending(t *testing.T) {
+	f := newNotificationFixture(t)
+	ctx := context.Background()
+	f.createPR(t)
+
+	// A replica dispatching while this one sends finds nothing to claim.
+	templates, err := notify.LoadTemplates("")
+	if err != nil {
+		t.Fatalf("load templates: %v", err)
+	}
+	replicaLog := &recordingChannel{}
+	replica := service.NewNotificationService(f.store.Notifications(), f.store.Users(), f.store.PullRequests(), service.NotificationOptions{
+		Channels:        map[entity.NotificationChannel]notify.Channel{entity.ChannelLog: replicaLog},
+		DefaultChannels: []entity.NotificationChannel{entity.ChannelLog},
+		Templates:       templates,
+		Interval:        time.Minute,
+		MaxAttempts:     2,
+		RetryBackoff:    time.Minute,
+		Lease:           time.Minute,
+	})
+	var replicaDispatch *entity.NotificationDispatch
+	f.log.onSend = func() {
+		if replicaDispatch == nil {
+			if replicaDispatch, err = replica.Dispatch(ctx, time.Now()); err != nil {
+				t.Fatalf("replica dispatch: %v", err)
+			}
+		}
+	}
+	f.dispatch(t, 0, entity.NotificationDispatch{Sent: 2})
+	if replicaDispatch == nil || *replicaDispatch != (entity.NotificationDispatch{}) || len(replicaLog.sent) != 0 {
+		t.Fatalf("replica dispatched %+v and sent %v", replicaDispatch, replicaLog.sent)
+	}
+
+	// A claim whose dispatcher died is taken over once the lease lapses.
+	if _, err := f.store.Users().UpdateActivity(ctx, "u4", true, 0); err != nil {
+		t.Fatalf("activate u4: %v", err)
+	}
+	if _, _, err := f.prService.Reassign(ctx, "pr-1", "u2", 0); err != nil {
+		t.Fatalf("reassign: %v", err)
+	}
+	now := time.Now()
+	claimed, err := f.store.Notifications().ClaimDue(ctx, now, now.Add(time.Minute), 10)
+	if err != nil || len(claimed) != 2 {
+		t.Fatalf("claim: %v, %+v", err, claimed)
+	}
+	f.log.onSend = nil
+	f.dispatch(t, 0, entity.NotificationDispatch{})
+	f.dispatch(t, 2*time.Minute, entity.NotificationDispatch{Sent: 2})
+}
+
+func TestNotificationSettingsValidation(t *testing.T) {
+	f := newNotificationFixture(t)
+	ctx := context.Background()
+
+	settings, err := f.notifier.GetSettings(ctx, "u2")
+	if err != nil || !slices.Equal(settings.Channels, []entity.NotificationChannel{entity.ChannelLog}) {
+		t.Fatalf("default settings: %+v, %v", settings, err)
+	}
+	if _, err := f.notifier.GetSettings(ctx, "nobody"); !errors.Is(err, entity.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	invalid := []entity.NotificationSettings{
+		{UserID: "u2", Channels: []entity.NotificationChannel{entity.ChannelEmail}, Email: "bob@example.com"},
+		{UserID: "u2", Channels: []entity.NotificationChannel{"pager"}},
+		{UserID: "u2", Email: "not an address"},
+		{UserID: "u2", QuietHours: &entity.QuietHours{Start: "22:00", End: "22:00"}},
+		{UserID: "u2", QuietHours: &entity.QuietHours{Start: "22:00", End: "8am"}},
+		{UserID: "u2", QuietHours: &entity.QuietHours{Start: "22:00", End: "08:00", Timezone: "Mars/Olympus"}},
+	}
+	for _, s := range invalid {
+		var appErr *entity.AppError
+		if _, err := f.notifier.SaveSettings(ctx, &s); !errors.As(err, &appErr) || appErr.SafeCode != entity.ErrBadRequest.SafeCode {
+			t.Errorf("SaveSettings(%+v) = %v, want a bad request", s, err)
+		}
+	}
+}
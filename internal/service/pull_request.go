@@ -2,121 +2,516 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"maps"
 	"math/rand"
+	"net/http"
+	"slices"
 	"time"
 
 	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/event"
 	"github.com/xddprog/avito-test-task/internal/repository"
+	"github.com/xddprog/avito-test-task/internal/tracing"
 )
 
 type PullRequestService interface {
 	Create(ctx context.Context, req *entity.CreatePRRequest) (*entity.PullRequest, error)
-	Merge(ctx context.Context, prID string) (*entity.PullRequest, error)
-	Reassign(ctx context.Context, prID, oldUserID string) (*entity.PullRequest, string, error)
+	// BulkCreate imports open PRs, reporting the outcome of each item
+	// instead of failing the whole request on the first bad one.
+	BulkCreate(ctx context.Context, items []entity.BulkCreatePRItem) (*entity.BulkCreatePRResponse, error)
+	// Merge and Reassign fail with ErrConflictVersion if expectedVersion is
+	// non-zero and the PR is at a different version. Zero skips the check.
+	Merge(ctx context.Context, prID string, expectedVersion int) (*entity.PullRequest, error)
+	Reassign(ctx context.Context, prID, oldUserID string, expectedVersion int) (*entity.PullRequest, string, error)
+	// ReassignWithReason is Reassign recorded in the reassignment history
+	// under reason instead of MANUAL. A non-nil audit entry is recorded in
+	// the same transaction, with the new reviewer added to its details as
+	// new_user_id; if an entry with its key already exists the reassignment
+	// fails with ErrConflictVersion.
+	ReassignWithReason(
+		ctx context.Context,
+		prID, oldUserID string,
+		expectedVersion int,
+		reason entity.ReassignReason,
+		audit *entity.AuditEntry,
+	) (*entity.PullRequest, string, error)
 }
 
 type prService struct {
-	prRepo   repository.PullRequestRepository
+	uow      repository.UnitOfWork
 	userRepo repository.UserRepository
+	events   event.Bus
+	sla      SLAOptions
 }
 
-func NewPullRequestService(prRepo repository.PullRequestRepository, userRepo repository.UserRepository) PullRequestService {
+func NewPullRequestService(
+	uow repository.UnitOfWork,
+	userRepo repository.UserRepository,
+	events event.Bus,
+	sla SLAOptions,
+) PullRequestService {
 	return &prService{
-		prRepo:   prRepo,
+		uow:      uow,
 		userRepo: userRepo,
+		events:   events,
+		sla:      sla,
 	}
 }
 
+// Create picks reviewers and inserts the PR while holding the author's team
+// lock, so it never assigns a member that a concurrent DeactivateMembers is
+// turning off.
 func (s *prService) Create(ctx context.Context, req *entity.CreatePRRequest) (*entity.PullRequest, error) {
-	author, err := s.userRepo.GetByID(ctx, req.AuthorID)
-	if err != nil {
-		return nil, entity.ErrNotFound
-	}
+	ctx, span := tracing.Start(ctx, "PullRequestService.Create")
+	defer span.End()
 
-	candidates, err := s.userRepo.GetActiveByTeamID(ctx, author.TeamName)
-	if err != nil {
-		return nil, err
-	}
+	var pr *entity.PullRequest
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		author, err := repos.Users.GetByID(ctx, req.AuthorID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := repos.Teams.LockByName(ctx, author.TeamName); err != nil {
+			return err
+		}
 
-	reviewers := selectRandomReviewers(candidates, author.ID, nil, 2)
+		candidates, err := repos.Users.GetActiveByTeamID(ctx, author.TeamName)
+		if err != nil {
+			return err
+		}
 
-	pr := &entity.PullRequest{
-		BasePullRequest: entity.BasePullRequest{
-			ID:       req.ID,
-			Name:     req.Name,
-			AuthorID: req.AuthorID,
-			Status:   entity.StatusOpen,
-		},
-		Reviewers: make([]string, 0, len(reviewers)),
-	}
+		reviewers := selectRandomReviewers(candidates, author.ID, nil, 2)
 
-	for _, r := range reviewers {
-		pr.Reviewers = append(pr.Reviewers, r.ID)
-	}
+		now := time.Now()
+		pr = &entity.PullRequest{
+			BasePullRequest: entity.BasePullRequest{
+				ID:       req.ID,
+				Name:     req.Name,
+				AuthorID: req.AuthorID,
+				Status:   entity.StatusOpen,
+			},
+			CreatedAt:   &now,
+			SLADeadline: s.sla.deadline(author.TeamName, now),
+			Reviewers:   make([]string, 0, len(reviewers)),
+		}
 
-	if err := s.prRepo.Create(ctx, pr); err != nil {
+		for _, r := range reviewers {
+			pr.Reviewers = append(pr.Reviewers, r.ID)
+		}
+
+		return repos.PullRequests.Create(ctx, pr)
+	})
+	if err != nil {
 		return nil, err
 	}
+	s.publishAssigned(ctx, pr.ID, pr.Reviewers)
 
 	return pr, nil
 }
 
-func (s *prService) Merge(ctx context.Context, prID string) (*entity.PullRequest, error) {
-	return s.prRepo.Merge(ctx, prID)
+// bulkCreateBatchSize is how many PRs BulkCreate inserts per transaction.
+const bulkCreateBatchSize = 100
+
+// bulkPR is a validated BulkCreate item waiting to be inserted.
+type bulkPR struct {
+	pr       *entity.PullRequest
+	teamName string
+	// pick is set when the item names no reviewers, so they are chosen from
+	// the author's team when the PR is inserted.
+	pick bool
 }
 
-func (s *prService) Reassign(ctx context.Context, prID, oldUserID string) (*entity.PullRequest, string, error) {
-	pr, err := s.prRepo.GetByID(ctx, prID)
-	if err != nil {
-		return nil, "", err
+// BulkCreate validates every item, resolves reviewers and inserts the valid
+// PRs in batches. A batch that hits a constraint, e.g. a PR created
+// concurrently, is retried one PR at a time so only the offending items fail.
+func (s *prService) BulkCreate(ctx context.Context, items []entity.BulkCreatePRItem) (*entity.BulkCreatePRResponse, error) {
+	ctx, span := tracing.Start(ctx, "PullRequestService.BulkCreate")
+	defer span.End()
+
+	if len(items) == 0 {
+		return nil, entity.ErrBadRequest
 	}
 
-	if pr.Status == entity.StatusMerged {
-		return nil, "", entity.ErrPRMerged
+	result := &entity.BulkCreatePRResponse{
+		Created: []entity.BulkCreatePRResult{},
+		Failed:  []entity.BulkCreatePRResult{},
+	}
+	fail := func(prID string, err error) {
+		appErr := entity.ErrInternal
+		errors.As(err, &appErr)
+		result.Failed = append(result.Failed, entity.BulkCreatePRResult{
+			PullRequestID: prID,
+			ErrorCode:     appErr.SafeCode,
+			Error:         appErr.Message,
+		})
 	}
 
-	found := false
-	for _, reviewerID := range pr.Reviewers {
-		if reviewerID == oldUserID {
-			found = true
-			break
+	users := make(map[string]*entity.User)
+	getUser := func(id string) (*entity.User, error) {
+		if u, ok := users[id]; ok {
+			return u, nil
+		}
+		u, err := s.userRepo.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
 		}
+		users[id] = u
+		return u, nil
 	}
-	if !found {
-		return nil, "", entity.ErrNotAssigned
+
+	pending := make([]bulkPR, 0, len(items))
+	seen := make(map[string]bool, len(items))
+	now := time.Now()
+	for _, item := range items {
+		if err := validateBulkItem(item, now); err != nil {
+			fail(item.ID, err)
+			continue
+		}
+		if seen[item.ID] {
+			fail(item.ID, entity.ErrPRExists)
+			continue
+		}
+		seen[item.ID] = true
+
+		author, err := getUser(item.AuthorID)
+		if errors.Is(err, entity.ErrNotFound) {
+			fail(item.ID, err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		createdAt := now
+		if item.CreatedAt != nil {
+			createdAt = *item.CreatedAt
+		}
+		pr := &entity.PullRequest{
+			BasePullRequest: entity.BasePullRequest{
+				ID:       item.ID,
+				Name:     item.Name,
+				AuthorID: item.AuthorID,
+				Status:   entity.StatusOpen,
+			},
+			CreatedAt:   &createdAt,
+			SLADeadline: s.sla.deadline(author.TeamName, createdAt),
+			Reviewers:   []string{},
+		}
+
+		if item.Reviewers != nil {
+			err = nil
+			for _, reviewerID := range item.Reviewers {
+				var reviewer *entity.User
+				if reviewer, err = getUser(reviewerID); err != nil {
+					break
+				}
+				if !reviewer.IsActive || reviewer.TeamName != author.TeamName {
+					err = unavailableReviewer(reviewerID)
+					break
+				}
+			}
+			var appErr *entity.AppError
+			if errors.As(err, &appErr) {
+				fail(item.ID, err)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			pr.Reviewers = append(pr.Reviewers, item.Reviewers...)
+		}
+		pending = append(pending, bulkPR{pr: pr, teamName: author.TeamName, pick: item.Reviewers == nil})
 	}
 
-	oldReviewer, err := s.userRepo.GetByID(ctx, oldUserID)
-	if err != nil {
-		return nil, "", entity.ErrNotFound
+	// Reviewers given in an item were assigned in the source system before
+	// the import, so only the ones picked here are announced.
+	created := func(item bulkPR) {
+		result.Created = append(result.Created, entity.BulkCreatePRResult{PullRequestID: item.pr.ID, Reviewers: item.pr.Reviewers})
+		if item.pick {
+			s.publishAssigned(ctx, item.pr.ID, item.pr.Reviewers)
+		}
 	}
 
-	candidates, err := s.userRepo.GetActiveByTeamID(ctx, oldReviewer.TeamName)
-	if err != nil {
-		return nil, "", err
+	for start := 0; start < len(pending); start += bulkCreateBatchSize {
+		batch := pending[start:min(start+bulkCreateBatchSize, len(pending))]
+
+		err := s.createBulkBatch(ctx, batch)
+		var appErr *entity.AppError
+		if err != nil && !errors.As(err, &appErr) {
+			return nil, err
+		}
+		if err == nil {
+			for _, item := range batch {
+				created(item)
+			}
+			continue
+		}
+
+		for _, item := range batch {
+			err := s.createBulkBatch(ctx, []bulkPR{item})
+			if errors.As(err, &appErr) {
+				fail(item.pr.ID, err)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			created(item)
+		}
 	}
 
-	excludeIDs := make([]string, 0, len(pr.Reviewers)+1)
-	excludeIDs = append(excludeIDs, pr.AuthorID)
-	excludeIDs = append(excludeIDs, pr.Reviewers...)
+	return result, nil
+}
 
-	newReviewers := selectRandomReviewers(candidates, pr.AuthorID, excludeIDs, 1)
-	if len(newReviewers) == 0 {
-		return nil, "", entity.ErrNoCandidate
+// createBulkBatch locks the authors' teams, in name order so concurrent
+// batches cannot deadlock, picks the missing reviewers, rechecks the kept
+// ones against the locked teams and inserts the batch in one transaction.
+func (s *prService) createBulkBatch(ctx context.Context, batch []bulkPR) error {
+	teamNames := make([]string, 0, len(batch))
+	for _, item := range batch {
+		if !slices.Contains(teamNames, item.teamName) {
+			teamNames = append(teamNames, item.teamName)
+		}
 	}
+	slices.Sort(teamNames)
 
-	newUserID := newReviewers[0].ID
+	return s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		candidates := make(map[string][]entity.User, len(teamNames))
+		for _, name := range teamNames {
+			if _, err := repos.Teams.LockByName(ctx, name); err != nil {
+				return err
+			}
+			team, err := repos.Users.GetActiveByTeamID(ctx, name)
+			if err != nil {
+				return err
+			}
+			candidates[name] = team
+		}
 
-	if err := s.prRepo.Reassign(ctx, prID, oldUserID, newUserID); err != nil {
-		return nil, "", err
+		prs := make([]*entity.PullRequest, 0, len(batch))
+		for _, item := range batch {
+			if item.pick {
+				item.pr.Reviewers = []string{}
+				for _, r := range selectRandomReviewers(candidates[item.teamName], item.pr.AuthorID, nil, 2) {
+					item.pr.Reviewers = append(item.pr.Reviewers, r.ID)
+				}
+			}
+			for _, reviewerID := range item.pr.Reviewers {
+				if !slices.ContainsFunc(candidates[item.teamName], func(u entity.User) bool { return u.ID == reviewerID }) {
+					return unavailableReviewer(reviewerID)
+				}
+			}
+			prs = append(prs, item.pr)
+		}
+		return repos.PullRequests.CreateBatch(ctx, prs)
+	})
+}
+
+// validateBulkItem checks an item on its own: required fields, a creation
+// time that is not in the future and at most two distinct reviewers, none of
+// them the author.
+func validateBulkItem(item entity.BulkCreatePRItem, now time.Time) error {
+	if item.ID == "" || item.Name == "" || item.AuthorID == "" {
+		return entity.ErrBadRequest
+	}
+	if item.CreatedAt != nil && item.CreatedAt.After(now) {
+		return entity.New(http.StatusBadRequest, entity.ErrBadRequest.SafeCode, "createdAt is in the future")
+	}
+	if len(item.Reviewers) > 2 {
+		return entity.ErrBadRequest
 	}
+	for i, reviewerID := range item.Reviewers {
+		if reviewerID == "" || reviewerID == item.AuthorID {
+			return entity.ErrBadRequest
+		}
+		if i > 0 && item.Reviewers[0] == reviewerID {
+			return entity.ErrBadRequest
+		}
+	}
+	return nil
+}
+
+// unavailableReviewer rejects a kept reviewer who is inactive or not in the
+// author's team.
+func unavailableReviewer(id string) error {
+	return entity.New(http.StatusBadRequest, entity.ErrBadRequest.SafeCode, fmt.Sprintf("reviewer %s is not an active member of the author's team", id))
+}
+
+func (s *prService) Merge(ctx context.Context, prID string, expectedVersion int) (*entity.PullRequest, error) {
+	ctx, span := tracing.Start(ctx, "PullRequestService.Merge")
+	defer span.End()
+
+	var merged *entity.PullRequest
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		pr, err := repos.PullRequests.GetByIDForUpdate(ctx, prID)
+		if err != nil {
+			return err
+		}
+		if expectedVersion != 0 && pr.Version != expectedVersion {
+			return entity.ErrConflictVersion
+		}
+		if pr.Status == entity.StatusMerged {
+			merged = pr
+			return nil
+		}
+
+		merged, err = repos.PullRequests.Merge(ctx, prID, pr.Version)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// Reassign reads the PR, picks a candidate and swaps the reviewer while
+// holding the reviewer's team lock and the PR row lock, so concurrent
+// reassignments of the same PR run one after another, each sees the
+// reviewers the previous one left, and no candidate is being deactivated.
+func (s *prService) Reassign(ctx context.Context, prID, oldUserID string, expectedVersion int) (*entity.PullRequest, string, error) {
+	ctx, span := tracing.Start(ctx, "PullRequestService.Reassign")
+	defer span.End()
+
+	return s.reassign(ctx, prID, oldUserID, expectedVersion, entity.ReasonManual, nil)
+}
+
+func (s *prService) ReassignWithReason(
+	ctx context.Context,
+	prID, oldUserID string,
+	expectedVersion int,
+	reason entity.ReassignReason,
+	audit *entity.AuditEntry,
+) (*entity.PullRequest, string, error) {
+	ctx, span := tracing.Start(ctx, "PullRequestService.ReassignWithReason")
+	defer span.End()
+
+	return s.reassign(ctx, prID, oldUserID, expectedVersion, reason, audit)
+}
+
+func (s *prService) reassign(
+	ctx context.Context,
+	prID, oldUserID string,
+	expectedVersion int,
+	reason entity.ReassignReason,
+	audit *entity.AuditEntry,
+) (*entity.PullRequest, string, error) {
+	var (
+		updated   *entity.PullRequest
+		newUserID string
+	)
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		// The team is locked before the PR, in the same order as
+		// DeactivateMembers, so the candidates read below stay active until
+		// the reassignment commits.
+		oldReviewer, userErr := repos.Users.GetByID(ctx, oldUserID)
+		if userErr != nil && !errors.Is(userErr, entity.ErrNotFound) {
+			return userErr
+		}
+		if userErr == nil {
+			if _, err := repos.Teams.LockByName(ctx, oldReviewer.TeamName); err != nil {
+				return err
+			}
+		}
 
-	pr, err = s.prRepo.GetByID(ctx, prID)
+		pr, err := repos.PullRequests.GetByIDForUpdate(ctx, prID)
+		if err != nil {
+			return err
+		}
+
+		if expectedVersion != 0 && pr.Version != expectedVersion {
+			return entity.ErrConflictVersion
+		}
+
+		if pr.Status == entity.StatusMerged {
+			return entity.ErrPRMerged
+		}
+
+		found := false
+		for _, reviewerID := range pr.Reviewers {
+			if reviewerID == oldUserID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return entity.ErrNotAssigned
+		}
+		if userErr != nil {
+			return entity.ErrNotFound
+		}
+
+		candidates, err := repos.Users.GetActiveByTeamID(ctx, oldReviewer.TeamName)
+		if err != nil {
+			return err
+		}
+
+		excludeIDs := make([]string, 0, len(pr.Reviewers)+1)
+		excludeIDs = append(excludeIDs, pr.AuthorID)
+		excludeIDs = append(excludeIDs, pr.Reviewers...)
+
+		newReviewers := selectRandomReviewers(candidates, pr.AuthorID, excludeIDs, 1)
+		if len(newReviewers) == 0 {
+			return entity.ErrNoCandidate
+		}
+		newUserID = newReviewers[0].ID
+
+		if err := repos.PullRequests.Reassign(ctx, prID, oldUserID, newUserID, reason, pr.Version); err != nil {
+			return err
+		}
+
+		if audit != nil {
+			entry := *audit
+			entry.Details = map[string]string{}
+			maps.Copy(entry.Details, audit.Details)
+			entry.Details["new_user_id"] = newUserID
+			recorded, err := repos.Audit.RecordOnce(ctx, &entry)
+			if err != nil {
+				return err
+			}
+			if !recorded {
+				return entity.ErrConflictVersion
+			}
+		}
+
+		updated, err = repos.PullRequests.GetByID(ctx, prID)
+		return err
+	})
 	if err != nil {
+		if errors.Is(err, entity.ErrNoCandidate) {
+			s.events.Publish(ctx, event.Event{
+				Type: event.TypeNoCandidate,
+				Payload: entity.NoCandidateFailure{
+					PullRequestID: prID,
+					ReviewerID:    oldUserID,
+					Reason:        reason,
+				},
+			})
+		}
 		return nil, "", err
 	}
 
-	return pr, newUserID, nil
+	s.events.Publish(ctx, event.Event{
+		Type: event.TypeReviewReassigned,
+		Payload: entity.ReviewAssignment{
+			PullRequestID:      prID,
+			ReviewerID:         newUserID,
+			PreviousReviewerID: oldUserID,
+			Reason:             reason,
+		},
+	})
+	return updated, newUserID, nil
+}
+
+func (s *prService) publishAssigned(ctx context.Context, prID string, reviewers []string) {
+	for _, reviewerID := range reviewers {
+		s.events.Publish(ctx, event.Event{
+			Type:    event.TypeReviewAssigned,
+			Payload: entity.ReviewAssignment{PullRequestID: prID, ReviewerID: reviewerID},
+		})
+	}
 }
 
 func selectRandomReviewers(candidates []entity.User, authorID string, excludeIDs []string, limit int) []entity.User {
@@ -0,0 +1,80 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/event"
+	"github.com/xddprog/avito-test-task/internal/repository"
+	"github.com/xddprog/avito-test-task/internal/service"
+)
+
+func TestBulkCreateKeepsCreatedAtAndChecksReviewers(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	teams := map[string][]entity.User{
+		"backend": {
+			{ID: "u1", Username: "u1", IsActive: true},
+			{ID: "u2", Username: "u2", IsActive: true},
+			{ID: "u3", Username: "u3", IsActive: false},
+		},
+		"frontend": {{ID: "f1", Username: "f1", IsActive: true}},
+	}
+	for name, members := range teams {
+		if err := store.Teams().Create(ctx, &entity.Team{Name: name, Members: members}); err != nil {
+			t.Fatalf("create team: %v", err)
+		}
+	}
+	bus := event.NewBus()
+	var assigned []entity.ReviewAssignment
+	bus.Subscribe(event.TypeReviewAssigned, func(_ context.Context, e event.Event) {
+		assigned = append(assigned, e.Payload.(entity.ReviewAssignment))
+	})
+	prs := service.NewPullRequestService(store.UnitOfWork(), store.Users(), bus,
+		service.SLAOptions{Default: service.SLAPolicy{Target: 24 * time.Hour}})
+
+	createdAt := time.Date(2025, 10, 1, 9, 30, 0, 0, time.UTC)
+	future := time.Now().Add(time.Hour)
+	result, err := prs.BulkCreate(ctx, []entity.BulkCreatePRItem{
+		{ID: "pr-1", Name: "legacy", AuthorID: "u1", Reviewers: []string{"u2"}, CreatedAt: &createdAt},
+		{ID: "pr-2", Name: "inactive reviewer", AuthorID: "u1", Reviewers: []string{"u3"}},
+		{ID: "pr-3", Name: "other team", AuthorID: "u1", Reviewers: []string{"f1"}},
+		{ID: "pr-4", Name: "from the future", AuthorID: "u1", CreatedAt: &future},
+		{ID: "pr-5", Name: "picked", AuthorID: "u1"},
+	})
+	if err != nil {
+		t.Fatalf("bulk create: %v", err)
+	}
+	if len(result.Created) != 2 || result.Created[0].PullRequestID != "pr-1" || result.Created[1].PullRequestID != "pr-5" {
+		t.Fatalf("unexpected created: %+v", result.Created)
+	}
+	// Only the reviewer picked during the import is announced.
+	if len(assigned) != 1 || assigned[0] != (entity.ReviewAssignment{PullRequestID: "pr-5", ReviewerID: "u2"}) {
+		t.Fatalf("unexpected review.assigned events: %+v", assigned)
+	}
+	if len(result.Failed) != 3 {
+		t.Fatalf("unexpected failed: %+v", result.Failed)
+	}
+	for _, f := range result.Failed {
+		if f.ErrorCode != entity.ErrBadRequest.SafeCode {
+			t.Errorf("%s failed with %s, want %s", f.PullRequestID, f.ErrorCode, entity.ErrBadRequest.SafeCode)
+		}
+	}
+
+	pr, err := store.PullRequests().GetByID(ctx, "pr-1")
+	if err != nil {
+		t.Fatalf("get pr: %v", err)
+	}
+	if !pr.CreatedAt.Equal(createdAt) || !pr.SLADeadline.Equal(createdAt.Add(24*time.Hour)) {
+		t.Fatalf("created at %s with deadline %s, want %s", pr.CreatedAt, pr.SLADeadline, createdAt)
+	}
+	assignments, err := store.PullRequests().GetOpenReviewAssignments(ctx, createdAt)
+	if err != nil {
+		t.Fatalf("open assignments: %v", err)
+	}
+	if len(assignments) != 1 || assignments[0].ReviewerID != "u2" || !assignments[0].AssignedAt.Equal(createdAt) {
+		t.Fatalf("unexpected assignments: %+v", assignments)
+	}
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"time"
+
+	"github.com/xddprog/avito-test-task/pkg/workcal"
+)
+
+// SLAPolicy is how soon a PR should be merged after it is created. Without
+// a Calendar the Target is wall-clock time, otherwise it is counted in the
+// calendar's working hours. A zero Target means no SLA.
+type SLAPolicy struct {
+	Target   time.Duration
+	Calendar *workcal.Calendar
+}
+
+type SLAOptions struct {
+	Default SLAPolicy
+	// Teams overrides Default for the team of the PR's author.
+	Teams map[string]SLAPolicy
+}
+
+// deadline returns the SLA deadline of a PR by an author of team created at
+// created, nil when no SLA applies.
+func (o SLAOptions) deadline(team string, created time.Time) *time.Time {
+	policy, ok := o.Teams[team]
+	if !ok {
+		policy = o.Default
+	}
+	if policy.Target <= 0 {
+		return nil
+	}
+	deadline := created.Add(policy.Target)
+	if policy.Calendar != nil {
+		deadline = policy.Calendar.Add(created, policy.Target)
+	}
+	return &deadline
+}
@@ -0,0 +1,325 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/event"
+	"github.com/xddprog/avito-test-task/internal/repository"
+	"github.com/xddprog/avito-test-task/internal/tracing"
+)
+
+// staleReviewActor is the audit actor of everything the scheduler does.
+const staleReviewActor = "stale-review-scheduler"
+
+// StaleReviewPolicy is how long a reviewer may sit on an open PR. Waiting
+// is counted from the moment the reviewer was assigned.
+type StaleReviewPolicy struct {
+	RemindAfter   time.Duration
+	EscalateAfter time.Duration
+	Action        entity.StaleAction
+	// LeadID receives escalations, and reassignments that found no
+	// replacement.
+	LeadID string
+}
+
+type StaleReviewOptions struct {
+	Interval time.Duration
+	Default  StaleReviewPolicy
+	// Teams overrides Default for the team of the PR's author.
+	Teams map[string]StaleReviewPolicy
+}
+
+type StaleReviewService interface {
+	// Scan checks every open review once, as of now. A reviewer past
+	// RemindAfter is reminded once per assignment. Once reminded and past
+	// EscalateAfter, the longest-waiting reviewer of the PR is reassigned
+	// or escalated to the team lead, one reviewer per PR per scan.
+	Scan(ctx context.Context, now time.Time) (*entity.StaleReviewScan, error)
+	// Run scans every Interval until ctx is cancelled.
+	Run(ctx context.Context)
+}
+
+type staleReviewService struct {
+	prRepo    repository.PullRequestRepository
+	audit     repository.AuditRepository
+	prService PullRequestService
+	events    event.Bus
+	opts      StaleReviewOptions
+}
+
+func NewStaleReviewService(
+	prRepo repository.PullRequestRepository,
+	audit repository.AuditRepository,
+	prService PullRequestService,
+	events event.Bus,
+	opts StaleReviewOptions,
+) StaleReviewService {
+	return &staleReviewService{
+		prRepo:    prRepo,
+		audit:     audit,
+		prService: prService,
+		events:    events,
+		opts:      opts,
+	}
+}
+
+func (s *staleReviewService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			scan, err := s.Scan(ctx, time.Now())
+			if err != nil {
+				slog.ErrorContext(ctx, "stale review scan failed", "error", err)
+				continue
+			}
+			if *scan != (entity.StaleReviewScan{}) {
+				slog.InfoContext(ctx, "stale review scan finished",
+					"reminded", scan.Reminded,
+					"reassigned", scan.Reassigned,
+					"escalated", scan.Escalated,
+					"failed", scan.Failed,
+				)
+			}
+		}
+	}
+}
+
+func (s *staleReviewService) Scan(ctx context.Context, now time.Time) (*entity.StaleReviewScan, error) {
+	ctx, span := tracing.Start(ctx, "StaleReviewService.Scan")
+	defer span.End()
+
+	earliest := s.opts.Default.RemindAfter
+	for _, p := range s.opts.Teams {
+		earliest = min(earliest, p.RemindAfter)
+	}
+	assignments, err := s.prRepo.GetOpenReviewAssignments(ctx, now.Add(-earliest))
+	if err != nil {
+		return nil, err
+	}
+
+	scan := &entity.StaleReviewScan{}
+	for start := 0; start < len(assignments); {
+		end := start + 1
+		for end < len(assignments) && assignments[end].PullRequestID == assignments[start].PullRequestID {
+			end++
+		}
+		if err := s.scanPullRequest(ctx, now, assignments[start:end], scan); err != nil {
+			return nil, err
+		}
+		start = end
+	}
+	return scan, nil
+}
+
+// scanPullRequest handles the reviewers of one PR, longest-waiting first.
+func (s *staleReviewService) scanPullRequest(
+	ctx context.Context,
+	now time.Time,
+	reviews []entity.OpenReviewAssignment,
+	scan *entity.StaleReviewScan,
+) error {
+	policy := s.policy(reviews[0].TeamName)
+	acted := false
+	for _, review := range reviews {
+		waiting := now.Sub(review.AssignedAt)
+		if waiting < policy.RemindAfter {
+			continue
+		}
+
+		reminded, err := s.recorded(ctx, entity.AuditReviewReminder, review)
+		if err != nil {
+			return err
+		}
+		if !reminded {
+			if reminded, err = s.remind(ctx, now, review); err != nil {
+				return err
+			}
+			if reminded {
+				scan.Reminded++
+			}
+			continue
+		}
+
+		if acted || policy.Action == entity.StaleActionRemind || waiting < policy.EscalateAfter {
+			continue
+		}
+		if acted, err = s.act(ctx, now, policy, review, scan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *staleReviewService) policy(team string) StaleReviewPolicy {
+	if p, ok := s.opts.Teams[team]; ok {
+		return p
+	}
+	return s.opts.Default
+}
+
+// remind reports whether this scan sent the reminder, false when another
+// replica got there first.
+func (s *staleReviewService) remind(ctx context.Context, now time.Time, review entity.OpenReviewAssignment) (bool, error) {
+	recorded, err := s.record(ctx, now, entity.AuditReviewReminder, review, nil)
+	if err != nil || !recorded {
+		return false, err
+	}
+	s.publish(ctx, event.TypeReviewReminder, now, review, func(*entity.StaleReview) {})
+	return true, nil
+}
+
+// act reassigns or escalates an overdue review and reports whether it did
+// anything. A reassignment without a replacement falls back to the team
+// lead, or is audited as failed without one. Either fallback happens once
+// per assignment and ends the attempts: later scans neither retry the
+// reassignment nor raise another no-candidate alert.
+func (s *staleReviewService) act(
+	ctx context.Context,
+	now time.Time,
+	policy StaleReviewPolicy,
+	review entity.OpenReviewAssignment,
+	scan *entity.StaleReviewScan,
+) (bool, error) {
+	fallback := entity.AuditReviewActionFailed
+	if policy.LeadID != "" {
+		fallback = entity.AuditReviewEscalated
+	}
+	done, err := s.recorded(ctx, fallback, review)
+	if err != nil || done {
+		return false, err
+	}
+
+	reason := ""
+	if policy.Action == entity.StaleActionReassign {
+		// The audit entry is written with the reassignment, so of several
+		// replicas only one reassigns and none is left without a record.
+		entry := staleAuditEntry(now, entity.AuditReviewReassigned, review, nil)
+		_, newUserID, err := s.prService.ReassignWithReason(ctx, review.PullRequestID, review.ReviewerID, 0, entity.ReasonStale, entry)
+		switch {
+		case err == nil:
+			s.publish(ctx, event.TypeReviewEscalated, now, review, func(p *entity.StaleReview) {
+				p.Action = entity.StaleActionReassign
+				p.NewReviewerID = newUserID
+			})
+			scan.Reassigned++
+			return true, nil
+		case errors.Is(err, entity.ErrNotAssigned), errors.Is(err, entity.ErrPRMerged),
+			errors.Is(err, entity.ErrNotFound), errors.Is(err, entity.ErrConflictVersion):
+			// The PR changed since it was listed.
+			return false, nil
+		case !errors.Is(err, entity.ErrNoCandidate):
+			return false, err
+		}
+		reason = "no active replacement in the team"
+	}
+
+	if policy.LeadID != "" {
+		details := map[string]string{"lead_id": policy.LeadID}
+		if reason != "" {
+			details["reason"] = reason
+		}
+		recorded, err := s.record(ctx, now, entity.AuditReviewEscalated, review, details)
+		if err != nil || !recorded {
+			return false, err
+		}
+		s.publish(ctx, event.TypeReviewEscalated, now, review, func(p *entity.StaleReview) {
+			p.Action = entity.StaleActionEscalate
+			p.LeadID = policy.LeadID
+		})
+		scan.Escalated++
+		return true, nil
+	}
+
+	recorded, err := s.record(ctx, now, entity.AuditReviewActionFailed, review, map[string]string{"reason": reason})
+	if err != nil || !recorded {
+		return false, err
+	}
+	scan.Failed++
+	return true, nil
+}
+
+// recorded reports whether action was audited for this reviewer since the
+// current assignment began.
+func (s *staleReviewService) recorded(ctx context.Context, action entity.AuditAction, review entity.OpenReviewAssignment) (bool, error) {
+	entries, err := s.audit.List(ctx, entity.AuditFilter{
+		Action:        action,
+		PullRequestID: review.PullRequestID,
+		UserID:        review.ReviewerID,
+		From:          &review.AssignedAt,
+		Limit:         1,
+	})
+	if err != nil {
+		return false, err
+	}
+	return len(entries) > 0, nil
+}
+
+// record audits action for the current assignment and reports whether it
+// was recorded by this call.
+func (s *staleReviewService) record(
+	ctx context.Context,
+	now time.Time,
+	action entity.AuditAction,
+	review entity.OpenReviewAssignment,
+	details map[string]string,
+) (bool, error) {
+	return s.audit.RecordOnce(ctx, staleAuditEntry(now, action, review, details))
+}
+
+// staleAuditEntry is the audit entry of action on the current assignment.
+// The key makes it unique per assignment, so of several replicas scanning at
+// once only one records, and acts on, it.
+func staleAuditEntry(
+	now time.Time,
+	action entity.AuditAction,
+	review entity.OpenReviewAssignment,
+	details map[string]string,
+) *entity.AuditEntry {
+	if details == nil {
+		details = map[string]string{}
+	}
+	details["assigned_at"] = review.AssignedAt.UTC().Format(time.RFC3339)
+	return &entity.AuditEntry{
+		OccurredAt:    now,
+		Actor:         staleReviewActor,
+		Action:        action,
+		PullRequestID: review.PullRequestID,
+		UserID:        review.ReviewerID,
+		TeamName:      review.TeamName,
+		Details:       details,
+		Key: strings.Join([]string{
+			string(action), review.PullRequestID, review.ReviewerID,
+			review.AssignedAt.UTC().Format(time.RFC3339Nano),
+		}, ":"),
+	}
+}
+
+func (s *staleReviewService) publish(
+	ctx context.Context,
+	t event.Type,
+	now time.Time,
+	review entity.OpenReviewAssignment,
+	fill func(*entity.StaleReview),
+) {
+	payload := entity.StaleReview{
+		PullRequestID:   review.PullRequestID,
+		PullRequestName: review.PullRequestName,
+		AuthorID:        review.AuthorID,
+		TeamName:        review.TeamName,
+		ReviewerID:      review.ReviewerID,
+		AssignedAt:      review.AssignedAt,
+		Waiting:         entity.NewDurationBreakdown(now.Sub(review.AssignedAt)),
+	}
+	fill(&payload)
+	s.events.Publish(ctx, event.Event{Type: t, OccurredAt: now, Payload: payload})
+}
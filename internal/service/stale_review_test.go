@@ -0,0 +1,247 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/event"
+	"github.com/xddprog/avito-test-task/internal/repository"
+	"github.com/xddprog/avito-test-task/internal/service"
+)
+
+type staleReviewFixture struct {
+	store     *repository.MemoryStore
+	prService service.PullRequestService
+	scheduler service.StaleReviewService
+	events    []entity.StaleReview
+	// noCandidate counts reassignments that found no replacement.
+	noCandidate int
+}
+
+// newStaleReviewFixture creates team backend with author u1 and the given
+// reviewers, and pr-1 which gets two of them. Reviews are stale after an
+// hour and overdue after three.
+func newStaleReviewFixture(t *testing.T, reviewers []string, policy service.StaleReviewPolicy, teams map[string]service.StaleReviewPolicy) *staleReviewFixture {
+	t.Helper()
+	ctx := context.Background()
+	f := &staleReviewFixture{store: repository.NewMemoryStore()}
+	bus := event.NewBus()
+	collect := func(_ context.Context, e event.Event) {
+		f.events = append(f.events, e.Payload.(entity.StaleReview))
+	}
+	bus.Subscribe(event.TypeReviewReminder, collect)
+	bus.Subscribe(event.TypeReviewEscalated, collect)
+	bus.Subscribe(event.TypeNoCandidate, func(context.Context, event.Event) { f.noCandidate++ })
+
+	members := []entity.User{{ID: "u1", Username: "u1", IsActive: true}}
+	for _, id := range reviewers {
+		members = append(members, entity.User{ID: id, Username: id, IsActive: true})
+	}
+	if err := f.store.Teams().Create(ctx, &entity.Team{Name: "backend", Members: members}); err != nil {
+		t.Fatalf("create team: %v", err)
+	}
+
+	f.prService = service.NewPullRequestService(f.store.UnitOfWork(), f.store.Users(), bus, service.SLAOptions{})
+	if _, err := f.prService.Create(ctx, &entity.CreatePRRequest{ID: "pr-1", Name: "feature", AuthorID: "u1"}); err != nil {
+		t.Fatalf("create pr: %v", err)
+	}
+
+	policy.RemindAfter, policy.EscalateAfter = time.Hour, 3*time.Hour
+	f.scheduler = service.NewStaleReviewService(f.store.PullRequests(), f.store.Audit(), f.prService, bus, service.StaleReviewOptions{
+		Interval: time.Minute,
+		Default:  policy,
+		Teams:    teams,
+	})
+	return f
+}
+
+func (f *staleReviewFixture) scan(t *testing.T, after time.Duration, want entity.StaleReviewScan) {
+	t.Helper()
+	got, err := f.scheduler.Scan(context.Background(), time.Now().Add(after))
+	if err != nil {
+		t.Fatalf("scan after %s: %v", after, err)
+	}
+	if *got != want {
+		t.Fatalf("scan after %s = %+v, want %+v", after, *got, want)
+	}
+}
+
+func (f *staleReviewFixture) reviewers(t *testing.T) []string {
+	t.Helper()
+	pr, err := f.store.PullRequests().GetByID(context.Background(), "pr-1")
+	if err != nil {
+		t.Fatalf("get pr: %v", err)
+	}
+	return pr.Reviewers
+}
+
+func (f *staleReviewFixture) audit(t *testing.T, action entity.AuditAction) []entity.AuditEntry {
+	t.Helper()
+	entries, err := f.store.Audit().List(context.Background(), entity.AuditFilter{Action: action})
+	if err != nil {
+		t.Fatalf("list audit: %v", err)
+	}
+	return entries
+}
+
+func TestStaleReviewReassigns(t *testing.T) {
+	f := newStaleReviewFixture(t, []string{"u2", "u3", "u4"}, service.StaleReviewPolicy{Action: entity.StaleActionReassign}, nil)
+	original := f.reviewers(t)
+
+	f.scan(t, 30*time.Minute, entity.StaleReviewScan{})
+	f.scan(t, 2*time.Hour, entity.StaleReviewScan{Reminded: 2})
+	f.scan(t, 2*time.Hour, entity.StaleReviewScan{})
+	if len(f.events) != 2 || f.events[0].Action != "" || f.events[0].Waiting.Hours != 2 {
+		t.Fatalf("unexpected reminders: %+v", f.events)
+	}
+
+	// Only the longest-waiting reviewer is replaced in one scan.
+	f.scan(t, 4*time.Hour, entity.StaleReviewScan{Reassigned: 1})
+	got := f.reviewers(t)
+	if slices.Contains(got, original[0]) || !slices.Contains(got, original[1]) {
+		t.Fatalf("reviewers after reassignment = %v, original %v", got, original)
+	}
+
+	entries := f.audit(t, entity.AuditReviewReassigned)
+	if len(entries) != 1 || entries[0].UserID != original[0] || !slices.Contains(got, entries[0].Details["new_user_id"]) {
+		t.Fatalf("unexpected audit entries: %+v", entries)
+	}
+	last := f.events[len(f.events)-1]
+	if last.Action != entity.StaleActionReassign || last.ReviewerID != original[0] || last.NewReviewerID != entries[0].Details["new_user_id"] {
+		t.Fatalf("unexpected escalation event: %+v", last)
+	}
+
+	stats, err := f.store.Stats().GetUserStats(context.Background(), original[0], entity.StatsFilter{})
+	if err != nil {
+		t.Fatalf("user stats: %v", err)
+	}
+	if !slices.Equal(stats.ReassignReasons, []entity.ReassignReasonStat{{Reason: entity.ReasonStale, Count: 1}}) {
+		t.Fatalf("reassign reasons = %+v", stats.ReassignReasons)
+	}
+}
+
+func TestStaleReviewEscalatesWithoutCandidate(t *testing.T) {
+	f := newStaleReviewFixture(t, []string{"u2", "u3"}, service.StaleReviewPolicy{Action: entity.StaleActionReassign, LeadID: "lead"}, nil)
+
+	f.scan(t, 2*time.Hour, entity.StaleReviewScan{Reminded: 2})
+	f.scan(t, 4*time.Hour, entity.StaleReviewScan{Escalated: 1})
+	f.scan(t, 4*time.Hour, entity.StaleReviewScan{Escalated: 1})
+	f.scan(t, 5*time.Hour, entity.StaleReviewScan{})
+
+	if got := f.reviewers(t); !slices.Equal(got, []string{"u2", "u3"}) {
+		t.Fatalf("reviewers changed: %v", got)
+	}
+	entries := f.audit(t, entity.AuditReviewEscalated)
+	if len(entries) != 2 {
+		t.Fatalf("expected one escalation per reviewer, got %+v", entries)
+	}
+	// An escalated review is not reassigned again.
+	if f.noCandidate != 2 {
+		t.Fatalf("got %d no-candidate failures, want one per reviewer", f.noCandidate)
+	}
+	for _, e := range entries {
+		if e.Details["lead_id"] != "lead" || e.Details["reason"] == "" {
+			t.Fatalf("unexpected escalation details: %+v", e)
+		}
+	}
+}
+
+func TestStaleReviewTeamPolicy(t *testing.T) {
+	f := newStaleReviewFixture(t, []string{"u2", "u3"}, service.StaleReviewPolicy{Action: entity.StaleActionReassign},
+		map[string]service.StaleReviewPolicy{"backend": {RemindAfter: 2 * time.Hour, Action: entity.StaleActionRemind}})
+
+	f.scan(t, 90*time.Minute, entity.StaleReviewScan{})
+	f.scan(t, 3*time.Hour, entity.StaleReviewScan{Reminded: 2})
+	f.scan(t, 48*time.Hour, entity.StaleReviewScan{})
+}
+
+func TestStaleReviewRecordsFailure(t *testing.T) {
+	f := newStaleReviewFixture(t, []string{"u2", "u3"}, service.StaleReviewPolicy{Action: entity.StaleActionReassign}, nil)
+
+	f.scan(t, 2*time.Hour, entity.StaleReviewScan{Reminded: 2})
+	f.scan(t, 4*time.Hour, entity.StaleReviewScan{Failed: 1})
+	f.scan(t, 4*time.Hour, entity.StaleReviewScan{Failed: 1})
+	f.scan(t, 4*time.Hour, entity.StaleReviewScan{})
+
+	if entries := f.audit(t, entity.AuditReviewActionFailed); len(entries) != 2 {
+		t.Fatalf("expected one failure per reviewer, got %+v", entries)
+	}
+	if f.noCandidate != 2 {
+		t.Fatalf("got %d no-candidate failures, want one per reviewer", f.noCandidate)
+	}
+}
+
+func TestStaleReviewReassignmentCommitsWithItsAuditEntry(t *testing.T) {
+	f := newStaleReviewFixture(t, []string{"u2", "u3", "u4"}, service.StaleReviewPolicy{Action: entity.StaleActionReassign}, nil)
+	ctx := context.Background()
+	original := f.reviewers(t)
+
+	entry := &entity.AuditEntry{Actor: "test", Action: entity.AuditReviewReassigned, PullRequestID: "pr-1", Key: "taken"}
+	if _, err := f.store.Audit().RecordOnce(ctx, entry); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	_, _, err := f.prService.ReassignWithReason(ctx, "pr-1", original[0], 0, entity.ReasonStale,
+		&entity.AuditEntry{Actor: "test", Action: entity.AuditReviewReassigned, PullRequestID: "pr-1", Key: "taken"})
+	if !errors.Is(err, entity.ErrConflictVersion) {
+		t.Fatalf("reassign with a taken audit key: %v", err)
+	}
+	if got := f.reviewers(t); !slices.Equal(got, original) {
+		t.Fatalf("reviewers changed to %v without an audit entry", got)
+	}
+
+	_, newUserID, err := f.prService.ReassignWithReason(ctx, "pr-1", original[0], 0, entity.ReasonStale,
+		&entity.AuditEntry{Actor: "test", Action: entity.AuditReviewReassigned, PullRequestID: "pr-1", Key: "free"})
+	if err != nil {
+		t.Fatalf("reassign: %v", err)
+	}
+	entries := f.audit(t, entity.AuditReviewReassigned)
+	if len(entries) != 2 || entries[0].Details["new_user_id"] != newUserID {
+		t.Fatalf("unexpected audit entries: %+v", entries)
+	}
+}
+
+// blindAudit hides recorded entries, as when another replica records them
+// between this replica's check and its write.
+type blindAudit struct {
+	repository.AuditRepository
+}
+
+func (blindAudit) List(context.Context, entity.AuditFilter) ([]entity.AuditEntry, error) {
+	return []entity.AuditEntry{}, nil
+}
+
+func TestStaleReviewActsOnceAcrossReplicas(t *testing.T) {
+	f := newStaleReviewFixture(t, []string{"u2", "u3"}, service.StaleReviewPolicy{Action: entity.StaleActionEscalate, LeadID: "lead"}, nil)
+	replica := service.NewStaleReviewService(f.store.PullRequests(), blindAudit{f.store.Audit()}, f.prService, event.NewBus(),
+		service.StaleReviewOptions{
+			Interval: time.Minute,
+			Default:  service.StaleReviewPolicy{RemindAfter: time.Hour, EscalateAfter: 3 * time.Hour, Action: entity.StaleActionEscalate, LeadID: "lead"},
+		})
+
+	f.scan(t, 2*time.Hour, entity.StaleReviewScan{Reminded: 2})
+	scan, err := replica.Scan(context.Background(), time.Now().Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("replica scan: %v", err)
+	}
+	if *scan != (entity.StaleReviewScan{}) {
+		t.Fatalf("replica scan = %+v, want nothing", *scan)
+	}
+
+	f.scan(t, 4*time.Hour, entity.StaleReviewScan{Escalated: 1})
+	if scan, err = replica.Scan(context.Background(), time.Now().Add(4*time.Hour)); err != nil {
+		t.Fatalf("replica scan: %v", err)
+	}
+	if *scan != (entity.StaleReviewScan{}) {
+		t.Fatalf("replica scan = %+v, want nothing", *scan)
+	}
+	if entries := f.audit(t, entity.AuditReviewReminder); len(entries) != 2 {
+		t.Fatalf("expected one reminder per reviewer, got %+v", entries)
+	}
+	if entries := f.audit(t, entity.AuditReviewEscalated); len(entries) != 1 {
+		t.Fatalf("expected one escalation, got %+v", entries)
+	}
+}
@@ -2,40 +2,103 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/event"
 	"github.com/xddprog/avito-test-task/internal/repository"
+	"github.com/xddprog/avito-test-task/internal/tracing"
 )
 
+const (
+	defaultStaleAfter    = entity.WeekStaleAfter
+	defaultSeriesBuckets = 30
+	maxTimeSeriesBuckets = 366
+)
+
+// fairnessActor is the audit actor of the scheduled fairness check.
+const fairnessActor = "fairness-checker"
+
 type StatsService interface {
-	GetSummary(ctx context.Context) (*entity.StatsSummary, error)
+	GetSummary(ctx context.Context, filter entity.StatsFilter) (*entity.StatsSummary, error)
+	GetTimeSeries(ctx context.Context, filter entity.StatsFilter, interval entity.StatsInterval, byTeam bool) (*entity.TimeSeries, error)
+	GetUserStats(ctx context.Context, userID string, filter entity.StatsFilter) (*entity.UserReviewStats, error)
+	GetFairness(ctx context.Context, filter entity.StatsFilter) (*entity.FairnessReport, error)
+	// RunFairnessChecks calls CheckFairness every CheckInterval until ctx is
+	// done.
+	RunFairnessChecks(ctx context.Context)
+	// CheckFairness computes fairness of every team over the Window ending at
+	// now and publishes an imbalance event for each team that crossed the
+	// threshold since the previous check.
+	CheckFairness(ctx context.Context, now time.Time) error
+	// GetSLA reports review SLA outcomes of PRs created in the window, as of
+	// now.
+	GetSLA(ctx context.Context, filter entity.StatsFilter) (*entity.SLAReport, error)
+}
+
+type FairnessOptions struct {
+	GiniThreshold float64
+	Window        time.Duration
+	CheckInterval time.Duration
 }
 
 type statsService struct {
-	repo repository.StatsRepository
+	repo     repository.StatsRepository
+	audit    repository.AuditRepository
+	events   event.Bus
+	fairness FairnessOptions
 }
 
-func NewStatsService(repo repository.StatsRepository) StatsService {
-	return &statsService{repo: repo}
+func NewStatsService(
+	repo repository.StatsRepository,
+	audit repository.AuditRepository,
+	events event.Bus,
+	fairness FairnessOptions,
+) StatsService {
+	return &statsService{
+		repo:     repo,
+		audit:    audit,
+		events:   events,
+		fairness: fairness,
+	}
 }
 
-func (s *statsService) GetSummary(ctx context.Context) (*entity.StatsSummary, error) {
-	reviewerStats, err := s.repo.GetReviewerAssignments(ctx)
+func (s *statsService) GetSummary(ctx context.Context, filter entity.StatsFilter) (*entity.StatsSummary, error) {
+	ctx, span := tracing.Start(ctx, "StatsService.GetSummary")
+	defer span.End()
+
+	filter, err := normalizeStatsFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, release, err := s.repo.Snapshot(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer release()
 
-	prStatus, err := s.repo.GetPRStatus(ctx)
+	reviewerStats, err := repo.GetReviewerAssignments(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	teamMembers, err := s.repo.GetTeamMembers(ctx)
+	prStatus, err := repo.GetPRStatus(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	prLifetime, err := s.repo.GetPRLifetime(ctx)
+	teamMembers, err := repo.GetTeamMembers(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	prLifetime, err := repo.GetPRLifetime(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -47,3 +110,386 @@ func (s *statsService) GetSummary(ctx context.Context) (*entity.StatsSummary, er
 		PRLifetime:          prLifetime,
 	}, nil
 }
+
+func (s *statsService) GetTimeSeries(
+	ctx context.Context,
+	filter entity.StatsFilter,
+	interval entity.StatsInterval,
+	byTeam bool,
+) (*entity.TimeSeries, error) {
+	ctx, span := tracing.Start(ctx, "StatsService.GetTimeSeries")
+	defer span.End()
+
+	if interval == "" {
+		interval = entity.IntervalDay
+	}
+	if interval != entity.IntervalDay && interval != entity.IntervalWeek && interval != entity.IntervalMonth {
+		return nil, entity.ErrBadRequest
+	}
+
+	to := time.Now().UTC()
+	if filter.To != nil {
+		to = filter.To.UTC()
+	}
+	from := truncateToInterval(to, interval)
+	for i := 1; i < defaultSeriesBuckets; i++ {
+		from = nextBucket(from, interval, -1)
+	}
+	if filter.From != nil {
+		from = filter.From.UTC()
+	}
+	filter.From, filter.To = &from, &to
+
+	filter, err := normalizeStatsFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := bucketStarts(from, to, interval)
+	if len(buckets) > maxTimeSeriesBuckets {
+		return nil, entity.ErrBadRequest
+	}
+
+	points, err := s.repo.GetTimeSeries(ctx, filter, interval, byTeam)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.TimeSeries{
+		Interval: interval,
+		From:     from,
+		To:       to,
+		ByTeam:   byTeam,
+		Points:   fillSeriesGaps(points, buckets, byTeam),
+	}, nil
+}
+
+func (s *statsService) GetUserStats(ctx context.Context, userID string, filter entity.StatsFilter) (*entity.UserReviewStats, error) {
+	ctx, span := tracing.Start(ctx, "StatsService.GetUserStats")
+	defer span.End()
+
+	if userID == "" {
+		return nil, entity.ErrBadRequest
+	}
+
+	filter, err := normalizeStatsFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := s.repo.GetUserStats(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if stats.Team.MedianOpenLoad > 0 {
+		stats.Team.OpenLoadVsMedian = math.Round(float64(stats.OpenLoad)/stats.Team.MedianOpenLoad*100) / 100
+	}
+
+	return stats, nil
+}
+
+func (s *statsService) GetFairness(ctx context.Context, filter entity.StatsFilter) (*entity.FairnessReport, error) {
+	ctx, span := tracing.Start(ctx, "StatsService.GetFairness")
+	defer span.End()
+
+	to := time.Now().UTC()
+	if filter.To != nil {
+		to = filter.To.UTC()
+	}
+	from := to.Add(-s.fairness.Window)
+	if filter.From != nil {
+		from = filter.From.UTC()
+	}
+	filter.From, filter.To = &from, &to
+
+	return s.fairnessReport(ctx, filter)
+}
+
+func (s *statsService) RunFairnessChecks(ctx context.Context) {
+	ticker := time.NewTicker(s.fairness.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.CheckFairness(ctx, time.Now()); err != nil {
+				slog.ErrorContext(ctx, "fairness check failed", "error", err)
+			}
+		}
+	}
+}
+
+func (s *statsService) CheckFairness(ctx context.Context, now time.Time) error {
+	ctx, span := tracing.Start(ctx, "StatsService.CheckFairness")
+	defer span.End()
+
+	to := now.UTC()
+	from := to.Add(-s.fairness.Window)
+	report, err := s.fairnessReport(ctx, entity.StatsFilter{From: &from, To: &to})
+	if err != nil {
+		return err
+	}
+
+	return s.publishImbalances(ctx, now, report.Teams)
+}
+
+// fairnessReport computes the report for a filter whose From and To are set.
+func (s *statsService) fairnessReport(ctx context.Context, filter entity.StatsFilter) (*entity.FairnessReport, error) {
+	filter, err := normalizeStatsFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := s.repo.GetMemberAssignmentCounts(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &entity.FairnessReport{
+		From:          *filter.From,
+		To:            *filter.To,
+		GiniThreshold: s.fairness.GiniThreshold,
+		Teams:         []entity.TeamFairness{},
+	}
+
+	byTeam := make(map[string][]int)
+	var teams []string
+	for _, c := range counts {
+		if _, ok := byTeam[c.TeamName]; !ok {
+			teams = append(teams, c.TeamName)
+		}
+		byTeam[c.TeamName] = append(byTeam[c.TeamName], c.Assignments)
+	}
+
+	for _, team := range teams {
+		fairness := teamFairness(team, byTeam[team])
+		fairness.Imbalanced = s.fairness.GiniThreshold > 0 && fairness.Gini >= s.fairness.GiniThreshold
+		report.Teams = append(report.Teams, fairness)
+	}
+
+	return report, nil
+}
+
+func (s *statsService) GetSLA(ctx context.Context, filter entity.StatsFilter) (*entity.SLAReport, error) {
+	ctx, span := tracing.Start(ctx, "StatsService.GetSLA")
+	defer span.End()
+
+	filter, err := normalizeStatsFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := s.repo.GetSLA(ctx, filter, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range report.ByTeam {
+		team := &report.ByTeam[i].SLAOutcome
+		report.Overall.Total += team.Total
+		report.Overall.Met += team.Met
+		report.Overall.Breached += team.Breached
+		report.Overall.Pending += team.Pending
+		team.BreachRate = breachRate(*team)
+	}
+	for i := range report.ByReviewer {
+		report.ByReviewer[i].BreachRate = breachRate(report.ByReviewer[i].SLAOutcome)
+	}
+	report.Overall.BreachRate = breachRate(report.Overall)
+
+	return report, nil
+}
+
+// breachRate is the share of decided PRs that breached the SLA. Pending PRs
+// are left out until they are merged or run out of time.
+func breachRate(o entity.SLAOutcome) float64 {
+	decided := o.Met + o.Breached
+	if decided == 0 {
+		return 0
+	}
+	return math.Round(float64(o.Breached)/float64(decided)*1000) / 1000
+}
+
+// publishImbalances emits an event only when a team crosses the threshold, so
+// a team that stays skewed does not flood subscribers on every check.
+//
+// A team's state is its last transition in the audit log, and each
+// transition is keyed by the one before it. Replicas checking at once and a
+// restarted process thus agree on the state, and only the one that records
+// a transition publishes it.
+func (s *statsService) publishImbalances(ctx context.Context, now time.Time, teams []entity.TeamFairness) error {
+	for _, team := range teams {
+		last, err := s.lastFairnessTransition(ctx, team.TeamName)
+		if err != nil {
+			return err
+		}
+		if team.Imbalanced == (last != nil && last.Action == entity.AuditFairnessImbalanced) {
+			continue
+		}
+
+		action := entity.AuditFairnessBalanced
+		if team.Imbalanced {
+			action = entity.AuditFairnessImbalanced
+		}
+		var previous int64
+		if last != nil {
+			previous = last.ID
+		}
+		recorded, err := s.audit.RecordOnce(ctx, &entity.AuditEntry{
+			OccurredAt: now,
+			Actor:      fairnessActor,
+			Action:     action,
+			TeamName:   team.TeamName,
+			Details:    map[string]string{"gini": strconv.FormatFloat(team.Gini, 'f', -1, 64)},
+			Key:        fmt.Sprintf("%s:%s:%d", action, team.TeamName, previous),
+		})
+		if err != nil {
+			return err
+		}
+		if recorded && team.Imbalanced {
+			s.events.Publish(ctx, event.Event{
+				Type:    event.TypeFairnessImbalance,
+				Payload: team,
+			})
+		}
+	}
+	return nil
+}
+
+// lastFairnessTransition returns the team's latest fairness audit entry, or
+// nil when it never crossed the threshold.
+func (s *statsService) lastFairnessTransition(ctx context.Context, team string) (*entity.AuditEntry, error) {
+	var last *entity.AuditEntry
+	for _, action := range []entity.AuditAction{entity.AuditFairnessImbalanced, entity.AuditFairnessBalanced} {
+		entries, err := s.audit.List(ctx, entity.AuditFilter{Action: action, TeamName: team, Limit: 1})
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > 0 && (last == nil || entries[0].ID > last.ID) {
+			last = &entries[0]
+		}
+	}
+	return last, nil
+}
+
+func teamFairness(team string, assignments []int) entity.TeamFairness {
+	sorted := append([]int(nil), assignments...)
+	sort.Ints(sorted)
+
+	fairness := entity.TeamFairness{
+		TeamName:      team,
+		ActiveMembers: len(sorted),
+	}
+	if len(sorted) == 0 {
+		return fairness
+	}
+
+	fairness.MinAssignments = sorted[0]
+	fairness.MaxAssignments = sorted[len(sorted)-1]
+	if fairness.MinAssignments > 0 {
+		ratio := math.Round(float64(fairness.MaxAssignments)/float64(fairness.MinAssignments)*100) / 100
+		fairness.MaxMinRatio = &ratio
+	}
+
+	var weighted int
+	for i, x := range sorted {
+		fairness.TotalAssignments += x
+		weighted += (i + 1) * x
+	}
+	if fairness.TotalAssignments > 0 {
+		n := float64(len(sorted))
+		gini := 2*float64(weighted)/(n*float64(fairness.TotalAssignments)) - (n+1)/n
+		fairness.Gini = math.Round(gini*1000) / 1000
+	}
+
+	return fairness
+}
+
+func normalizeStatsFilter(filter entity.StatsFilter) (entity.StatsFilter, error) {
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return filter, entity.ErrBadRequest
+	}
+	if filter.StaleAfter < 0 {
+		return filter, entity.ErrBadRequest
+	}
+	if filter.StaleAfter == 0 {
+		filter.StaleAfter = defaultStaleAfter
+	}
+	return filter, nil
+}
+
+// fillSeriesGaps adds zero-valued points for buckets without any activity so
+// that every series has exactly one point per bucket.
+func fillSeriesGaps(points []entity.TimeSeriesPoint, buckets []time.Time, byTeam bool) []entity.TimeSeriesPoint {
+	type key struct {
+		bucket time.Time
+		team   string
+	}
+
+	existing := make(map[key]entity.TimeSeriesPoint, len(points))
+	teams := []string{}
+	seenTeams := make(map[string]bool)
+	for _, p := range points {
+		existing[key{bucket: p.Bucket.UTC(), team: p.TeamName}] = p
+		if !seenTeams[p.TeamName] {
+			seenTeams[p.TeamName] = true
+			teams = append(teams, p.TeamName)
+		}
+	}
+	if !byTeam && len(teams) == 0 {
+		teams = append(teams, "")
+	}
+	sort.Strings(teams)
+
+	filled := make([]entity.TimeSeriesPoint, 0, len(buckets)*len(teams))
+	for _, bucket := range buckets {
+		for _, team := range teams {
+			if p, ok := existing[key{bucket: bucket, team: team}]; ok {
+				filled = append(filled, p)
+				continue
+			}
+			filled = append(filled, entity.TimeSeriesPoint{Bucket: bucket, TeamName: team})
+		}
+	}
+	return filled
+}
+
+func bucketStarts(from, to time.Time, interval entity.StatsInterval) []time.Time {
+	var buckets []time.Time
+	for b := truncateToInterval(from, interval); b.Before(to); b = nextBucket(b, interval, 1) {
+		buckets = append(buckets, b)
+		if len(buckets) > maxTimeSeriesBuckets {
+			break
+		}
+	}
+	return buckets
+}
+
+// truncateToInterval mirrors Postgres date_trunc in UTC: weeks start on Monday.
+func truncateToInterval(t time.Time, interval entity.StatsInterval) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch interval {
+	case entity.IntervalWeek:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case entity.IntervalMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+func nextBucket(t time.Time, interval entity.StatsInterval, step int) time.Time {
+	switch interval {
+	case entity.IntervalWeek:
+		return t.AddDate(0, 0, 7*step)
+	case entity.IntervalMonth:
+		return t.AddDate(0, step, 0)
+	default:
+		return t.AddDate(0, 0, step)
+	}
+}
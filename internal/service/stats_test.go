@@ -0,0 +1,95 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/event"
+	"github.com/xddprog/avito-test-task/internal/repository"
+	"github.com/xddprog/avito-test-task/internal/service"
+)
+
+func TestFairnessImbalanceEventsComeFromScheduledCheck(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	members := []entity.User{
+		{ID: "u1", Username: "u1", IsActive: true},
+		{ID: "u2", Username: "u2", IsActive: true},
+		{ID: "u3", Username: "u3", IsActive: true},
+		{ID: "u4", Username: "u4", IsActive: true},
+	}
+	if err := store.Teams().Create(ctx, &entity.Team{Name: "backend", Members: members}); err != nil {
+		t.Fatalf("create team: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		pr := &entity.PullRequest{
+			BasePullRequest: entity.BasePullRequest{ID: fmt.Sprintf("pr-%d", i), Name: "feature", AuthorID: "u1"},
+			Reviewers:       []string{"u2"},
+		}
+		if err := store.PullRequests().Create(ctx, pr); err != nil {
+			t.Fatalf("create pr: %v", err)
+		}
+	}
+
+	bus := event.NewBus()
+	var events []entity.TeamFairness
+	bus.Subscribe(event.TypeFairnessImbalance, func(_ context.Context, e event.Event) {
+		events = append(events, e.Payload.(entity.TeamFairness))
+	})
+	newStats := func() service.StatsService {
+		return service.NewStatsService(store.Stats(), store.Audit(), bus, service.FairnessOptions{GiniThreshold: 0.4, Window: 24 * time.Hour})
+	}
+	stats := newStats()
+
+	now := time.Now()
+	hourAgo := now.Add(-time.Hour)
+	for _, filter := range []entity.StatsFilter{{}, {TeamName: "backend"}, {From: &hourAgo}} {
+		report, err := stats.GetFairness(ctx, filter)
+		if err != nil {
+			t.Fatalf("get fairness: %v", err)
+		}
+		if len(report.Teams) != 1 || !report.Teams[0].Imbalanced {
+			t.Fatalf("unexpected report for %+v: %+v", filter, report.Teams)
+		}
+	}
+	if len(events) != 0 {
+		t.Fatalf("GetFairness published events: %+v", events)
+	}
+
+	check := func(stats service.StatsService, at time.Time, want int) {
+		t.Helper()
+		if err := stats.CheckFairness(ctx, at); err != nil {
+			t.Fatalf("check fairness: %v", err)
+		}
+		if len(events) != want {
+			t.Fatalf("after check at %s got %d events, want %d", at, len(events), want)
+		}
+	}
+	check(stats, now, 1)
+	check(stats, now, 1)
+	// Another replica, or this one after a restart, knows the team is
+	// already imbalanced.
+	check(newStats(), now, 1)
+	// Two windows later the PRs have left the window and the team is even.
+	check(stats, now.Add(48*time.Hour), 1)
+	check(stats, now, 2)
+	if events[1].TeamName != "backend" || events[1].MaxAssignments != 4 {
+		t.Fatalf("unexpected event: %+v", events[1])
+	}
+
+	entries, err := store.Audit().List(ctx, entity.AuditFilter{TeamName: "backend"})
+	if err != nil {
+		t.Fatalf("list audit: %v", err)
+	}
+	var actions []entity.AuditAction
+	for _, e := range entries {
+		actions = append(actions, e.Action)
+	}
+	if !slices.Equal(actions, []entity.AuditAction{entity.AuditFairnessBalanced, entity.AuditFairnessImbalanced, entity.AuditFairnessImbalanced}) {
+		t.Fatalf("unexpected audit actions: %v", actions)
+	}
+}
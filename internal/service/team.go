@@ -4,38 +4,45 @@ import (
 	"context"
 
 	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/event"
 	"github.com/xddprog/avito-test-task/internal/repository"
+	"github.com/xddprog/avito-test-task/internal/tracing"
 	"github.com/xddprog/avito-test-task/internal/utils"
 )
 
 type TeamService interface {
 	Create(ctx context.Context, team *entity.Team) error
 	GetByName(ctx context.Context, name string) (*entity.Team, error)
-	DeactivateMembers(ctx context.Context, req *entity.DeactivateTeamMembersRequest) (*entity.DeactivateTeamMembersResponse, error)
+	// DeactivateMembers fails with ErrConflictVersion if expectedVersion is
+	// non-zero and the team is at a different version.
+	DeactivateMembers(ctx context.Context, req *entity.DeactivateTeamMembersRequest, expectedVersion int) (*entity.DeactivateTeamMembersResponse, error)
 }
 
 type teamService struct {
+	uow            repository.UnitOfWork
 	teamRepository repository.TeamRepository
-	prRepository   repository.PullRequestRepository
 	prService      PullRequestService
-	userRepository repository.UserRepository
+	events         event.Bus
 }
 
 func NewTeamService(
+	uow repository.UnitOfWork,
 	teamRepository repository.TeamRepository,
-	prRepository repository.PullRequestRepository,
 	prService PullRequestService,
-	userRepository repository.UserRepository,
+	events event.Bus,
 ) TeamService {
 	return &teamService{
+		uow:            uow,
 		teamRepository: teamRepository,
-		prRepository:   prRepository,
 		prService:      prService,
-		userRepository: userRepository,
+		events:         events,
 	}
 }
 
 func (s *teamService) Create(ctx context.Context, team *entity.Team) error {
+	ctx, span := tracing.Start(ctx, "TeamService.Create")
+	defer span.End()
+
 	if err := utils.ValidateForm(team); err != nil {
 		return err
 	}
@@ -44,13 +51,23 @@ func (s *teamService) Create(ctx context.Context, team *entity.Team) error {
 }
 
 func (s *teamService) GetByName(ctx context.Context, name string) (*entity.Team, error) {
+	ctx, span := tracing.Start(ctx, "TeamService.GetByName")
+	defer span.End()
+
 	if name == "" {
 		return nil, entity.ErrBadRequest
 	}
 	return s.teamRepository.GetByName(ctx, name)
 }
 
-func (s *teamService) DeactivateMembers(ctx context.Context, req *entity.DeactivateTeamMembersRequest) (*entity.DeactivateTeamMembersResponse, error) {
+// DeactivateMembers locks the team and the affected PRs, moves reviews off
+// the users and deactivates them in one transaction. PR creation and
+// reassignment take the same team lock before reading candidates, so no PR
+// can pick up a user who is being deactivated or lose a reviewer twice.
+func (s *teamService) DeactivateMembers(ctx context.Context, req *entity.DeactivateTeamMembersRequest, expectedVersion int) (*entity.DeactivateTeamMembersResponse, error) {
+	ctx, span := tracing.Start(ctx, "TeamService.DeactivateMembers")
+	defer span.End()
+
 	if err := utils.ValidateForm(req); err != nil {
 		return nil, err
 	}
@@ -58,74 +75,113 @@ func (s *teamService) DeactivateMembers(ctx context.Context, req *entity.Deactiv
 		return nil, entity.ErrBadRequest
 	}
 
-	result := &entity.DeactivateTeamMembersResponse{
-		DeactivatedUsers:    []string{},
-		SuccessfulReassigns: []entity.ReassignmentResult{},
-		FailedReassigns:     []entity.ReassignmentResult{},
-	}
-
-	assignments, err := s.prRepository.GetOpenAssignmentsForUsers(ctx, req.UserIDs)
-	if err != nil {
-		return nil, err
-	}
+	var result *entity.DeactivateTeamMembersResponse
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		result = &entity.DeactivateTeamMembersResponse{
+			DeactivatedUsers:    []string{},
+			SuccessfulReassigns: []entity.ReassignmentResult{},
+			FailedReassigns:     []entity.ReassignmentResult{},
+		}
 
-	activeCandidates, err := s.userRepository.GetActiveByTeamID(ctx, req.TeamName)
-	if err != nil {
-		return nil, err
-	}
-	candidatePool := filterCandidates(activeCandidates, req.UserIDs)
+		version, err := repos.Teams.LockByName(ctx, req.TeamName)
+		if err != nil {
+			return err
+		}
+		if expectedVersion != 0 && version != expectedVersion {
+			return entity.ErrConflictVersion
+		}
 
-	replacements := make([]entity.ReassignmentResult, 0, len(assignments))
-	userFailed := make(map[string]bool, len(req.UserIDs))
+		assignments, err := repos.PullRequests.GetOpenAssignmentsForUsers(ctx, req.UserIDs)
+		if err != nil {
+			return err
+		}
 
-	for _, assignment := range assignments {
-		exclude := make(map[string]struct{}, len(assignment.Reviewers)+2)
-		exclude[assignment.AuthorID] = struct{}{}
-		for _, reviewer := range assignment.Reviewers {
-			exclude[reviewer] = struct{}{}
+		activeCandidates, err := repos.Users.GetActiveByTeamID(ctx, req.TeamName)
+		if err != nil {
+			return err
 		}
+		candidatePool := filterCandidates(activeCandidates, req.UserIDs)
+
+		replacements := make([]entity.ReassignmentResult, 0, len(assignments))
+		userFailed := make(map[string]bool, len(req.UserIDs))
+
+		for _, assignment := range assignments {
+			exclude := make(map[string]struct{}, len(assignment.Reviewers)+2)
+			exclude[assignment.AuthorID] = struct{}{}
+			for _, reviewer := range assignment.Reviewers {
+				exclude[reviewer] = struct{}{}
+			}
+
+			newReviewer := pickReplacement(candidatePool, exclude)
+			if newReviewer == "" {
+				result.FailedReassigns = append(result.FailedReassigns, entity.ReassignmentResult{
+					PullRequestID: assignment.PullRequestID,
+					OldReviewerID: assignment.OldReviewerID,
+					Error:         entity.ErrNoCandidate.Error(),
+				})
+				userFailed[assignment.OldReviewerID] = true
+				continue
+			}
+
+			replacements = append(replacements, entity.ReassignmentResult{
+				PullRequestID: assignment.PullRequestID,
+				OldReviewerID: assignment.OldReviewerID,
+				NewReviewerID: newReviewer,
+			})
 
-		newReviewer := pickReplacement(candidatePool, exclude)
-		if newReviewer == "" {
-			result.FailedReassigns = append(result.FailedReassigns, entity.ReassignmentResult{
+			result.SuccessfulReassigns = append(result.SuccessfulReassigns, entity.ReassignmentResult{
 				PullRequestID: assignment.PullRequestID,
 				OldReviewerID: assignment.OldReviewerID,
-				Error:         entity.ErrNoCandidate.Error(),
+				NewReviewerID: newReviewer,
 			})
-			userFailed[assignment.OldReviewerID] = true
-			continue
 		}
 
-		replacements = append(replacements, entity.ReassignmentResult{
-			PullRequestID: assignment.PullRequestID,
-			OldReviewerID: assignment.OldReviewerID,
-			NewReviewerID: newReviewer,
-		})
+		if err := repos.PullRequests.ApplyReviewerReplacements(ctx, replacements, entity.ReasonDeactivation); err != nil {
+			return err
+		}
 
-		result.SuccessfulReassigns = append(result.SuccessfulReassigns, entity.ReassignmentResult{
-			PullRequestID: assignment.PullRequestID,
-			OldReviewerID: assignment.OldReviewerID,
-			NewReviewerID: newReviewer,
-		})
-	}
+		usersToDeactivate := make([]string, 0, len(req.UserIDs))
+		for _, userID := range req.UserIDs {
+			if !userFailed[userID] {
+				usersToDeactivate = append(usersToDeactivate, userID)
+			}
+		}
+
+		if len(usersToDeactivate) > 0 {
+			actualDeactivated, err := repos.Teams.DeactivateMembers(ctx, req.TeamName, usersToDeactivate, expectedVersion)
+			if err != nil {
+				return err
+			}
+			result.DeactivatedUsers = actualDeactivated
+		}
 
-	if err := s.prRepository.ApplyReviewerReplacements(ctx, replacements); err != nil {
+		result.TeamVersion, err = repos.Teams.LockByName(ctx, req.TeamName)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	usersToDeactivate := make([]string, 0, len(req.UserIDs))
-	for _, userID := range req.UserIDs {
-		if !userFailed[userID] {
-			usersToDeactivate = append(usersToDeactivate, userID)
-		}
+	for _, r := range result.SuccessfulReassigns {
+		s.events.Publish(ctx, event.Event{
+			Type: event.TypeReviewReassigned,
+			Payload: entity.ReviewAssignment{
+				PullRequestID:      r.PullRequestID,
+				ReviewerID:         r.NewReviewerID,
+				PreviousReviewerID: r.OldReviewerID,
+				Reason:             entity.ReasonDeactivation,
+			},
+		})
 	}
-
-	if len(usersToDeactivate) > 0 {
-		actualDeactivated, err := s.teamRepository.DeactivateMembers(ctx, req.TeamName, usersToDeactivate)
-		if err != nil {
-			return nil, err
-		}
-		result.DeactivatedUsers = actualDeactivated
+	for _, failed := range result.FailedReassigns {
+		s.events.Publish(ctx, event.Event{
+			Type: event.TypeNoCandidate,
+			Payload: entity.NoCandidateFailure{
+				PullRequestID: failed.PullRequestID,
+				ReviewerID:    failed.OldReviewerID,
+				Reason:        entity.ReasonDeactivation,
+			},
+		})
 	}
 
 	return result, nil
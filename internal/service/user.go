@@ -5,29 +5,58 @@ import (
 
 	"github.com/xddprog/avito-test-task/internal/entity"
 	"github.com/xddprog/avito-test-task/internal/repository"
+	"github.com/xddprog/avito-test-task/internal/tracing"
 )
 
 type UserService interface {
-	SetIsActive(ctx context.Context, userID string, isActive bool) (*entity.User, error)
+	// SetIsActive fails with ErrConflictVersion if expectedVersion is non-zero
+	// and the user is at a different version.
+	SetIsActive(ctx context.Context, userID string, isActive bool, expectedVersion int) (*entity.User, error)
 	GetReviews(ctx context.Context, userID string) ([]entity.BasePullRequest, error)
 }
 
 type userService struct {
+	uow            repository.UnitOfWork
 	userRepository repository.UserRepository
 }
 
-func NewUserService(userRepository repository.UserRepository) UserService {
-	return &userService{userRepository: userRepository}
+func NewUserService(uow repository.UnitOfWork, userRepository repository.UserRepository) UserService {
+	return &userService{uow: uow, userRepository: userRepository}
 }
 
-func (s *userService) SetIsActive(ctx context.Context, userID string, isActive bool) (*entity.User, error) {
+func (s *userService) SetIsActive(ctx context.Context, userID string, isActive bool, expectedVersion int) (*entity.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.SetIsActive")
+	defer span.End()
+
 	if userID == "" {
 		return nil, entity.ErrBadRequest
 	}
-	return s.userRepository.UpdateActivity(ctx, userID, isActive)
+
+	// Lock the team before the user, the same order DeactivateMembers uses,
+	// since both bump the team's version.
+	var updated *entity.User
+	err := s.uow.Do(ctx, func(ctx context.Context, repos repository.Repositories) error {
+		user, err := repos.Users.GetByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if _, err := repos.Teams.LockByName(ctx, user.TeamName); err != nil {
+			return err
+		}
+
+		updated, err = repos.Users.UpdateActivity(ctx, userID, isActive, expectedVersion)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
 }
 
 func (s *userService) GetReviews(ctx context.Context, userID string) ([]entity.BasePullRequest, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetReviews")
+	defer span.End()
+
 	if userID == "" {
 		return nil, entity.ErrBadRequest
 	}
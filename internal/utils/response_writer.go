@@ -1,41 +1,49 @@
 package utils
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"log/slog"
 	"net/http"
 
 	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/logger"
 )
 
 type response struct {
 	Error errorDetail `json:"error"`
 }
 type errorDetail struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
 }
 
-func WriteError(w http.ResponseWriter, err error) {
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
 	var appErr *entity.AppError
+	ctx := r.Context()
 
 	if errors.As(err, &appErr) {
-		writeJSON(w, appErr.Code, appErr.SafeCode, appErr.Message)
+		writeJSON(ctx, w, appErr.Code, appErr.SafeCode, appErr.Message)
 		return
 	}
 
-	slog.Error("unknown error occurred", "error", err)
-	writeJSON(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+	slog.ErrorContext(ctx, "unknown error occurred", "error", err)
+	writeJSON(ctx, w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
 }
 
-func writeJSON(w http.ResponseWriter, status int, safeCode string, msg string) {
+func writeJSON(ctx context.Context, w http.ResponseWriter, status int, safeCode string, msg string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(response{
-		Error: errorDetail{Code: safeCode, Message: msg},
+		Error: errorDetail{
+			Code:      safeCode,
+			Message:   msg,
+			RequestID: logger.RequestIDFromContext(ctx),
+		},
 	}); err != nil {
-		slog.Error("failed to encode error response", "error", err)
+		slog.ErrorContext(ctx, "failed to encode error response", "error", err)
 	}
 }
 
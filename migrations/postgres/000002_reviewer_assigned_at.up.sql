@@ -0,0 +1,10 @@
+ALTER TABLE pr_reviewers
+    ADD COLUMN IF NOT EXISTS assigned_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+
+UPDATE pr_reviewers prr
+SET assigned_at = pr.created_at
+FROM pull_requests pr
+WHERE pr.id = prr.pr_id AND pr.created_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_pr_reviewers_assigned_at ON pr_reviewers(assigned_at);
+CREATE INDEX IF NOT EXISTS idx_pr_created_at ON pull_requests(created_at);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS reviewer_reassignments (
+    id BIGSERIAL PRIMARY KEY,
+    pr_id VARCHAR(255) NOT NULL,
+    old_user_id VARCHAR(255) NOT NULL,
+    new_user_id VARCHAR(255) NOT NULL,
+    reason VARCHAR(50) NOT NULL,
+    assigned_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    reassigned_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT fk_reassign_pr FOREIGN KEY (pr_id)
+        REFERENCES pull_requests(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_reassignments_old_user ON reviewer_reassignments(old_user_id);
+CREATE INDEX IF NOT EXISTS idx_reassignments_new_user ON reviewer_reassignments(new_user_id);
@@ -0,0 +1,2 @@
+ALTER TABLE pull_requests DROP COLUMN IF EXISTS sla_met;
+ALTER TABLE pull_requests DROP COLUMN IF EXISTS sla_deadline;
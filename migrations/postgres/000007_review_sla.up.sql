@@ -0,0 +1,2 @@
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS sla_deadline TIMESTAMP WITH TIME ZONE;
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS sla_met BOOLEAN;
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS notification_settings (
+    user_id VARCHAR(255) PRIMARY KEY,
+    channels TEXT NOT NULL,
+    email VARCHAR(255),
+    chat_handle VARCHAR(255),
+    quiet_start VARCHAR(5),
+    quiet_end VARCHAR(5),
+    quiet_timezone VARCHAR(64)
+);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id BIGSERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    channel VARCHAR(20) NOT NULL,
+    kind VARCHAR(50) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    pr_id VARCHAR(255),
+    subject TEXT NOT NULL,
+    body TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    deliver_after TIMESTAMP WITH TIME ZONE NOT NULL,
+    sent_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_due ON notifications(status, deliver_after);
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id);
@@ -0,0 +1,7 @@
+-- Different clients may hold the same key, which the old primary key forbids.
+DELETE FROM idempotency_keys;
+
+ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS client;
+ALTER TABLE idempotency_keys ADD PRIMARY KEY (key, method, path);
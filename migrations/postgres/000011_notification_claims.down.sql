@@ -0,0 +1,3 @@
+UPDATE notifications SET status = 'PENDING' WHERE status = 'SENDING';
+
+ALTER TABLE notifications DROP COLUMN IF EXISTS locked_until;
@@ -0,0 +1,3 @@
+-- A dispatcher claims notifications as SENDING until locked_until, so
+-- replicas do not send the same one twice.
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE;
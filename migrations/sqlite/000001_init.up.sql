@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS teams (
+    name TEXT PRIMARY KEY
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id TEXT PRIMARY KEY,
+    username TEXT NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    team_name TEXT NOT NULL,
+
+    CONSTRAINT fk_team FOREIGN KEY (team_name)
+        REFERENCES teams(name) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS pull_requests (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    author_id TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'OPEN',
+    created_at TIMESTAMP,
+    merged_at TIMESTAMP,
+
+    CONSTRAINT fk_author FOREIGN KEY (author_id)
+        REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS pr_reviewers (
+    pr_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+
+    PRIMARY KEY (pr_id, user_id),
+
+    CONSTRAINT fk_pr FOREIGN KEY (pr_id)
+        REFERENCES pull_requests(id) ON DELETE CASCADE,
+    CONSTRAINT fk_reviewer FOREIGN KEY (user_id)
+        REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_users_team ON users(team_name);
+CREATE INDEX IF NOT EXISTS idx_users_active ON users(is_active);
+CREATE INDEX IF NOT EXISTS idx_pr_author ON pull_requests(author_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_pr_created_at;
+DROP INDEX IF EXISTS idx_pr_reviewers_assigned_at;
+
+ALTER TABLE pr_reviewers DROP COLUMN assigned_at;
@@ -0,0 +1,12 @@
+-- Timestamps are stored as fixed-width UTC text so they compare correctly.
+ALTER TABLE pr_reviewers
+    ADD COLUMN assigned_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00.000000000+00:00';
+
+UPDATE pr_reviewers
+SET assigned_at = (SELECT pr.created_at FROM pull_requests pr WHERE pr.id = pr_reviewers.pr_id)
+WHERE EXISTS (
+    SELECT 1 FROM pull_requests pr WHERE pr.id = pr_reviewers.pr_id AND pr.created_at IS NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_pr_reviewers_assigned_at ON pr_reviewers(assigned_at);
+CREATE INDEX IF NOT EXISTS idx_pr_created_at ON pull_requests(created_at);
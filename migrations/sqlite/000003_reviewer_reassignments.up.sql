@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS reviewer_reassignments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    pr_id TEXT NOT NULL,
+    old_user_id TEXT NOT NULL,
+    new_user_id TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    assigned_at TIMESTAMP NOT NULL,
+    reassigned_at TIMESTAMP NOT NULL,
+
+    CONSTRAINT fk_reassign_pr FOREIGN KEY (pr_id)
+        REFERENCES pull_requests(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_reassignments_old_user ON reviewer_reassignments(old_user_id);
+CREATE INDEX IF NOT EXISTS idx_reassignments_new_user ON reviewer_reassignments(new_user_id);
@@ -0,0 +1,2 @@
+ALTER TABLE pull_requests DROP COLUMN sla_met;
+ALTER TABLE pull_requests DROP COLUMN sla_deadline;
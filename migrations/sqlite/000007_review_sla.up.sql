@@ -0,0 +1,2 @@
+ALTER TABLE pull_requests ADD COLUMN sla_deadline TIMESTAMP;
+ALTER TABLE pull_requests ADD COLUMN sla_met BOOLEAN;
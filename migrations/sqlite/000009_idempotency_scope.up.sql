@@ -0,0 +1,25 @@
+-- SQLite cannot change a primary key in place, so the table is rebuilt.
+CREATE TABLE idempotency_keys_new (
+    client TEXT NOT NULL,
+    key TEXT NOT NULL,
+    method TEXT NOT NULL,
+    path TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    status_code INTEGER,
+    content_type TEXT,
+    response_body BLOB,
+    created_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (client, key, method, path)
+);
+
+INSERT INTO idempotency_keys_new (client, key, method, path, request_hash, status_code, content_type, response_body, created_at, expires_at)
+SELECT '', key, method, path, request_hash, status_code, content_type, response_body, created_at, expires_at
+FROM idempotency_keys;
+
+DROP TABLE idempotency_keys;
+ALTER TABLE idempotency_keys_new RENAME TO idempotency_keys;
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
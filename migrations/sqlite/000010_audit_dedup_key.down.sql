@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_audit_log_dedup_key;
+
+ALTER TABLE audit_log DROP COLUMN dedup_key;
@@ -0,0 +1,5 @@
+-- Scheduler actions carry a key, so replicas racing on the same review
+-- record it once. NULL keys never conflict.
+ALTER TABLE audit_log ADD COLUMN dedup_key TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_log_dedup_key ON audit_log(dedup_key);
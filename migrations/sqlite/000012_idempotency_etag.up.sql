@@ -0,0 +1,2 @@
+-- Replays carry the ETag of the first response.
+ALTER TABLE idempotency_keys ADD COLUMN etag TEXT;
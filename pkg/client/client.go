@@ -0,0 +1,252 @@
+// Package client is a typed Go client for the reviewer service HTTP API
+// described in api/openapi.yml.
+package client
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultTimeout = 30 * time.Second
+
+// Client calls a running reviewer service. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	timeout    time.Duration
+	retry      RetryPolicy
+}
+
+// RetryPolicy retries requests that failed in transit or were answered with
+// 429, 502, 503, 504 or IDEMPOTENCY_IN_PROGRESS. GET requests are always
+// safe to repeat; a POST is retried under an Idempotency-Key, which the
+// client generates when the caller did not set one.
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt; 0 and 1 disable retries.
+	MaxAttempts int
+	// MinBackoff doubles after every attempt up to MaxBackoff. A longer
+	// Retry-After from the server takes precedence.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+type Option func(*Client)
+
+// WithHTTPClient replaces http.DefaultClient, e.g. to set a transport.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTimeout bounds every attempt, DefaultTimeout unless set. Zero leaves
+// requests bounded only by the caller's context.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// New returns a client for the service at baseURL, e.g. "http://localhost:8080".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		timeout:    DefaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// CallOption sets per-request headers.
+type CallOption func(http.Header)
+
+// IfMatch makes the request fail with ErrPreconditionFailed unless the
+// entity is still at version.
+func IfMatch(version int) CallOption {
+	return func(h http.Header) {
+		h.Set("If-Match", strconv.Quote(strconv.Itoa(version)))
+	}
+}
+
+// IdempotencyKey lets the server replay the stored response when a POST is
+// retried with the same key.
+func IdempotencyKey(key string) CallOption {
+	return func(h http.Header) {
+		h.Set("Idempotency-Key", key)
+	}
+}
+
+// do sends the request and decodes a 2xx body into out, if out is not nil.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any, opts []CallOption) error {
+	resp, err := c.send(ctx, method, path, query, body, opts)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return decodeError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s %s response: %w", method, path, err)
+	}
+	return nil
+}
+
+// send performs the request with retries. The caller closes the body of the
+// returned response, which may carry any status.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body any, opts []CallOption) (*http.Response, error) {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("encode request: %w", err)
+		}
+	}
+
+	header := make(http.Header)
+	header.Set("Accept", "application/json")
+	if body != nil {
+		header.Set("Content-Type", "application/json")
+	}
+	for _, opt := range opts {
+		opt(header)
+	}
+
+	attempts := max(c.retry.MaxAttempts, 1)
+	if method != http.MethodGet && attempts > 1 && header.Get("Idempotency-Key") == "" {
+		header.Set("Idempotency-Key", newIdempotencyKey())
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, cancel, err := c.attempt(ctx, method, u, header, payload)
+		if attempt == attempts || !retryable(resp, err) || ctx.Err() != nil {
+			if err != nil {
+				return nil, err
+			}
+			resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		}
+
+		wait := c.backoff(attempt, resp)
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, method, u string, header http.Header, payload []byte) (*http.Response, context.CancelFunc, error) {
+	cancel := context.CancelFunc(func() {})
+	if c.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+	}
+
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		cancel()
+		return nil, func() {}, err
+	}
+	req.Header = header.Clone()
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, func() {}, err
+	}
+	return resp, cancel, nil
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case http.StatusConflict:
+		// Peek at the code without consuming the body for the caller.
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+		var envelope errorEnvelope
+		return json.Unmarshal(data, &envelope) == nil && envelope.Error.Code == ErrIdempotencyInProgress.Code
+	default:
+		return false
+	}
+}
+
+func (c *Client) backoff(attempt int, resp *http.Response) time.Duration {
+	wait := c.retry.MinBackoff << (attempt - 1)
+	if c.retry.MaxBackoff > 0 && (wait > c.retry.MaxBackoff || wait <= 0) {
+		wait = c.retry.MaxBackoff
+	}
+	if wait > 0 {
+		// Up to 20% jitter so clients that failed together spread out.
+		wait += rand.N(wait/5 + 1)
+	}
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			wait = max(wait, time.Duration(seconds)*time.Second)
+		}
+	}
+	return wait
+}
+
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	_, _ = crand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// cancelOnClose releases the attempt's timeout once the body is read.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
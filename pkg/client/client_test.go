@@ -0,0 +1,302 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/config"
+	"github.com/xddprog/avito-test-task/internal/entity"
+	"github.com/xddprog/avito-test-task/internal/event"
+	"github.com/xddprog/avito-test-task/internal/handler"
+	"github.com/xddprog/avito-test-task/internal/middleware"
+	"github.com/xddprog/avito-test-task/internal/notify"
+	"github.com/xddprog/avito-test-task/internal/repository"
+	"github.com/xddprog/avito-test-task/internal/service"
+	"github.com/xddprog/avito-test-task/pkg/client"
+)
+
+// newServer runs the real router and idempotency middleware over in-memory
+// storage. wrap, if set, sits in front of them.
+func newServer(t *testing.T, wrap func(http.Handler) http.Handler) *httptest.Server {
+	t.Helper()
+	store := repository.NewMemoryStore()
+	events := event.NewBus()
+	uow := store.UnitOfWork()
+
+	prService := service.NewPullRequestService(uow, store.Users(), events, service.SLAOptions{})
+	notifications := service.NewNotificationService(store.Notifications(), store.Users(), store.PullRequests(), service.NotificationOptions{
+		Channels: map[entity.NotificationChannel]notify.Channel{entity.ChannelLog: notify.NewLogChannel(slog.New(slog.DiscardHandler))},
+	})
+	router := handler.NewRouter(
+		handler.NewUserHandler(service.NewUserService(uow, store.Users())),
+		handler.NewNotificationHandler(notifications),
+		handler.NewTeamHandler(service.NewTeamService(uow, store.Teams(), prService, events)),
+		handler.NewPullRequestHandler(prService),
+		handler.NewStatsHandler(service.NewStatsService(store.Stats(), store.Audit(), events, service.FairnessOptions{GiniThreshold: 0.4, Window: 24 * time.Hour})),
+		handler.NewHealthHandler(map[string]handler.ReadinessCheck{}),
+		nil,
+		http.NotFoundHandler(),
+		"../../api/openapi.yml",
+	)
+	idempotency := service.NewIdempotencyService(store.Idempotency(), time.Hour, time.Minute)
+
+	var h http.Handler = middleware.IdempotencyMiddleware(idempotency, middleware.ClientIdentity(config.RateLimitConfig{APIKeyHeader: "X-API-Key", APIKeys: "other"}), router)
+	if wrap != nil {
+		h = wrap(h)
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func seedTeam(t *testing.T, c *client.Client) {
+	t.Helper()
+	_, err := c.AddTeam(context.Background(), client.CreateTeamRequest{
+		TeamName: "backend",
+		Members: []client.TeamMember{
+			{UserID: "u1", Username: "Alice", IsActive: true},
+			{UserID: "u2", Username: "Bob", IsActive: true},
+			{UserID: "u3", Username: "Carol", IsActive: true},
+			{UserID: "u4", Username: "Dave", IsActive: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("add team: %v", err)
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	ctx := context.Background()
+	c := client.New(newServer(t, nil).URL)
+	seedTeam(t, c)
+
+	team, err := c.GetTeam(ctx, "backend")
+	if err != nil || len(team.Members) != 4 {
+		t.Fatalf("get team: %+v, %v", team, err)
+	}
+
+	pr, err := c.CreatePullRequest(ctx, client.CreatePRRequest{ID: "pr-1", Name: "feature", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("create pr: %v", err)
+	}
+	if pr.Status != client.StatusOpen || len(pr.Reviewers) != 2 {
+		t.Fatalf("unexpected pr: %+v", pr)
+	}
+
+	reviews, err := c.GetUserReviews(ctx, pr.Reviewers[0])
+	if err != nil || len(reviews) != 1 || reviews[0].ID != "pr-1" {
+		t.Fatalf("get reviews: %+v, %v", reviews, err)
+	}
+
+	reassigned, err := c.ReassignPullRequest(ctx, "pr-1", pr.Reviewers[0], client.IfMatch(pr.Version))
+	if err != nil {
+		t.Fatalf("reassign: %v", err)
+	}
+	if reassigned.ReplacedBy == "" || reassigned.PR.Version != pr.Version+1 {
+		t.Fatalf("unexpected reassign result: %+v", reassigned)
+	}
+
+	deactivated, err := c.DeactivateTeamMembers(ctx, client.DeactivateTeamMembersRequest{TeamName: "backend", UserIDs: []string{"u3"}})
+	if err != nil || len(deactivated.DeactivatedUsers) != 1 {
+		t.Fatalf("deactivate: %+v, %v", deactivated, err)
+	}
+
+	settings, err := c.GetNotificationSettings(ctx, "u2")
+	if err != nil || len(settings.Channels) != 0 {
+		t.Fatalf("get notification settings: %+v, %v", settings, err)
+	}
+	settings, err = c.SetNotificationSettings(ctx, client.NotificationSettings{
+		UserID:     "u2",
+		Channels:   []client.NotificationChannel{client.ChannelLog},
+		QuietHours: &client.QuietHours{Start: "22:00", End: "08:00"},
+	})
+	if err != nil || settings.QuietHours.Timezone != "UTC" {
+		t.Fatalf("set notification settings: %+v, %v", settings, err)
+	}
+	_, err = c.SetNotificationSettings(ctx, client.NotificationSettings{UserID: "u2", Channels: []client.NotificationChannel{client.ChannelEmail}})
+	expectErr(t, err, client.ErrBadRequest, entity.ErrBadRequest)
+
+	user, err := c.SetUserActive(ctx, "u4", false)
+	if err != nil || user.IsActive {
+		t.Fatalf("set active: %+v, %v", user, err)
+	}
+
+	merged, err := c.MergePullRequest(ctx, "pr-1")
+	if err != nil || merged.Status != client.StatusMerged || merged.MergedAt == nil {
+		t.Fatalf("merge: %+v, %v", merged, err)
+	}
+
+	imported, err := c.BulkCreatePullRequests(ctx, []client.BulkCreatePRItem{
+		{ID: "pr-2", Name: "kept", AuthorID: "u1", Reviewers: []string{"u2"}},
+		{ID: "pr-1", Name: "duplicate", AuthorID: "u1"},
+		{ID: "pr-3", Name: "orphan", AuthorID: "nobody"},
+	})
+	if err != nil || len(imported.Created) != 1 || len(imported.Failed) != 2 {
+		t.Fatalf("bulk create: %+v, %v", imported, err)
+	}
+	if got := imported.Created[0]; got.PullRequestID != "pr-2" || len(got.Reviewers) != 1 || got.Reviewers[0] != "u2" {
+		t.Fatalf("unexpected created item: %+v", got)
+	}
+	failed := make(map[string]string)
+	for _, item := range imported.Failed {
+		failed[item.PullRequestID] = item.ErrorCode
+	}
+	if failed["pr-1"] != "PR_EXISTS" || failed["pr-3"] != "NOT_FOUND" {
+		t.Fatalf("unexpected failed items: %+v", imported.Failed)
+	}
+
+	summary, err := c.StatsSummary(ctx, client.StatsFilter{TeamName: "backend"})
+	if err != nil || summary.PRStatus.Merged != 1 {
+		t.Fatalf("summary: %+v, %v", summary, err)
+	}
+	series, err := c.StatsTimeSeries(ctx, client.StatsFilter{}, client.IntervalDay, true)
+	if err != nil || series.Interval != client.IntervalDay || !series.ByTeam {
+		t.Fatalf("timeseries: %+v, %v", series, err)
+	}
+	userStats, err := c.StatsUser(ctx, "u2", client.StatsFilter{})
+	if err != nil || userStats.UserID != "u2" {
+		t.Fatalf("user stats: %+v, %v", userStats, err)
+	}
+	if _, err := c.StatsFairness(ctx, client.StatsFilter{}); err != nil {
+		t.Fatalf("fairness: %v", err)
+	}
+	if sla, err := c.StatsSLA(ctx, client.StatsFilter{}); err != nil || sla.ByTeam == nil {
+		t.Fatalf("sla: %+v, %v", sla, err)
+	}
+
+	if err := c.Live(ctx); err != nil {
+		t.Fatalf("live: %v", err)
+	}
+	ready, err := c.Ready(ctx)
+	if err != nil || ready.Status != "OK" {
+		t.Fatalf("ready: %+v, %v", ready, err)
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	ctx := context.Background()
+	c := client.New(newServer(t, nil).URL)
+	seedTeam(t, c)
+
+	pr, err := c.CreatePullRequest(ctx, client.CreatePRRequest{ID: "pr-1", Name: "feature", AuthorID: "u1"})
+	if err != nil {
+		t.Fatalf("create pr: %v", err)
+	}
+
+	_, err = c.GetTeam(ctx, "missing")
+	expectErr(t, err, client.ErrNotFound, entity.ErrNotFound)
+
+	_, err = c.CreatePullRequest(ctx, client.CreatePRRequest{ID: "pr-1", Name: "again", AuthorID: "u1"})
+	expectErr(t, err, client.ErrPRExists, entity.ErrPRExists)
+
+	_, err = c.AddTeam(ctx, client.CreateTeamRequest{TeamName: "backend"})
+	expectErr(t, err, client.ErrTeamExists, entity.ErrTeamExists)
+
+	_, err = c.ReassignPullRequest(ctx, "pr-1", "u1")
+	expectErr(t, err, client.ErrNotAssigned, entity.ErrNotAssigned)
+
+	_, err = c.MergePullRequest(ctx, "pr-1", client.IfMatch(pr.Version+1))
+	expectErr(t, err, client.ErrPreconditionFailed, entity.ErrPreconditionFailed)
+
+	if _, err := c.MergePullRequest(ctx, "pr-1"); err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	_, err = c.ReassignPullRequest(ctx, "pr-1", pr.Reviewers[0])
+	expectErr(t, err, client.ErrPRMerged, entity.ErrPRMerged)
+
+	_, err = c.SetUserActive(ctx, "", true)
+	expectErr(t, err, client.ErrBadRequest, entity.ErrBadRequest)
+
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected *client.Error with status 400, got %#v", err)
+	}
+	if errors.Is(err, client.ErrNotFound) {
+		t.Fatal("BAD_REQUEST must not match ErrNotFound")
+	}
+}
+
+func expectErr(t *testing.T, err error, want *client.Error, wantEntity *entity.AppError) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("expected %s, got %v", want.Code, err)
+	}
+	if !errors.Is(err, wantEntity) {
+		t.Fatalf("expected %v to match entity error %s", err, wantEntity.SafeCode)
+	}
+}
+
+// failFirst answers 503 to the first n requests.
+func failFirst(n int32, seen *[]string, mu *sync.Mutex) func(http.Handler) http.Handler {
+	var calls atomic.Int32
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			*seen = append(*seen, r.Header.Get("Idempotency-Key"))
+			mu.Unlock()
+			if calls.Add(1) <= n {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+	srv := newServer(t, failFirst(2, &keys, &mu))
+	policy := client.RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+	c := client.New(srv.URL, client.WithRetry(policy))
+	seedTeam(t, c)
+
+	if len(keys) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(keys))
+	}
+	if keys[0] == "" || keys[0] != keys[1] || keys[1] != keys[2] {
+		t.Fatalf("retries must reuse one generated Idempotency-Key, got %q", keys)
+	}
+
+	keys = nil
+	srv = newServer(t, failFirst(1, &keys, &mu))
+	_, err := client.New(srv.URL).GetTeam(context.Background(), "backend")
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || apiErr.Code != client.ErrUnexpectedStatus {
+		t.Fatalf("without a retry policy the 503 must be returned, got %v", err)
+	}
+	if keys[0] != "" {
+		t.Fatalf("GET must not carry an Idempotency-Key, got %q", keys[0])
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	srv := newServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		})
+	})
+
+	c := client.New(srv.URL, client.WithTimeout(20*time.Millisecond))
+	start := time.Now()
+	_, err := c.GetTeam(context.Background(), "backend")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("timeout took %v", elapsed)
+	}
+}
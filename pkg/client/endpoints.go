@@ -0,0 +1,247 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+)
+
+// AddTeam creates a team and creates or updates its members.
+func (c *Client) AddTeam(ctx context.Context, req CreateTeamRequest, opts ...CallOption) (*Team, error) {
+	var resp struct {
+		Team *Team `json:"team"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/team/add", nil, req, &resp, opts); err != nil {
+		return nil, err
+	}
+	return resp.Team, nil
+}
+
+func (c *Client) GetTeam(ctx context.Context, name string, opts ...CallOption) (*Team, error) {
+	var team Team
+	query := url.Values{"team_name": {name}}
+	if err := c.do(ctx, http.MethodGet, "/team/get", query, nil, &team, opts); err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// DeactivateTeamMembers deactivates users of a team and moves their open
+// reviews to other members. An empty UserIDs deactivates the whole team.
+func (c *Client) DeactivateTeamMembers(ctx context.Context, req DeactivateTeamMembersRequest, opts ...CallOption) (*DeactivateTeamMembersResponse, error) {
+	var resp struct {
+		Result *DeactivateTeamMembersResponse `json:"result"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/team/deactivate", nil, req, &resp, opts); err != nil {
+		return nil, err
+	}
+	return resp.Result, nil
+}
+
+func (c *Client) SetUserActive(ctx context.Context, userID string, active bool, opts ...CallOption) (*User, error) {
+	var resp struct {
+		User *User `json:"user"`
+	}
+	req := entity.SetUserIsActiveRequest{UserID: userID, IsActive: active}
+	if err := c.do(ctx, http.MethodPost, "/users/setIsActive", nil, req, &resp, opts); err != nil {
+		return nil, err
+	}
+	return resp.User, nil
+}
+
+// GetUserReviews lists the pull requests userID is assigned to review.
+func (c *Client) GetUserReviews(ctx context.Context, userID string, opts ...CallOption) ([]PullRequestShort, error) {
+	var resp struct {
+		PullRequests []PullRequestShort `json:"pull_requests"`
+	}
+	query := url.Values{"user_id": {userID}}
+	if err := c.do(ctx, http.MethodGet, "/users/getReview", query, nil, &resp, opts); err != nil {
+		return nil, err
+	}
+	return resp.PullRequests, nil
+}
+
+// GetNotificationSettings returns the user's notification settings, or the
+// server defaults if the user saved none.
+func (c *Client) GetNotificationSettings(ctx context.Context, userID string, opts ...CallOption) (*NotificationSettings, error) {
+	var resp struct {
+		Settings *NotificationSettings `json:"settings"`
+	}
+	query := url.Values{"user_id": {userID}}
+	if err := c.do(ctx, http.MethodGet, "/users/getNotificationSettings", query, nil, &resp, opts); err != nil {
+		return nil, err
+	}
+	return resp.Settings, nil
+}
+
+// SetNotificationSettings replaces the user's notification settings.
+func (c *Client) SetNotificationSettings(ctx context.Context, settings NotificationSettings, opts ...CallOption) (*NotificationSettings, error) {
+	var resp struct {
+		Settings *NotificationSettings `json:"settings"`
+	}
+	if settings.Channels == nil {
+		settings.Channels = []NotificationChannel{}
+	}
+	if err := c.do(ctx, http.MethodPost, "/users/setNotificationSettings", nil, settings, &resp, opts); err != nil {
+		return nil, err
+	}
+	return resp.Settings, nil
+}
+
+// CreatePullRequest creates a pull request; the server picks the reviewers.
+func (c *Client) CreatePullRequest(ctx context.Context, req CreatePRRequest, opts ...CallOption) (*PullRequest, error) {
+	var resp struct {
+		PR *PullRequest `json:"pr"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/pullRequest/create", nil, req, &resp, opts); err != nil {
+		return nil, err
+	}
+	return resp.PR, nil
+}
+
+// BulkCreatePullRequests imports items in one request. Items that could not
+// be created are listed in Failed rather than returned as an error.
+func (c *Client) BulkCreatePullRequests(ctx context.Context, items []BulkCreatePRItem, opts ...CallOption) (*BulkCreatePRResponse, error) {
+	var resp struct {
+		Result *BulkCreatePRResponse `json:"result"`
+	}
+	req := entity.BulkCreatePRRequest{PullRequests: items}
+	if err := c.do(ctx, http.MethodPost, "/pullRequest/bulkCreate", nil, req, &resp, opts); err != nil {
+		return nil, err
+	}
+	return resp.Result, nil
+}
+
+func (c *Client) MergePullRequest(ctx context.Context, prID string, opts ...CallOption) (*PullRequest, error) {
+	var resp struct {
+		PR *PullRequest `json:"pr"`
+	}
+	req := entity.MergePRRequest{ID: prID}
+	if err := c.do(ctx, http.MethodPost, "/pullRequest/merge", nil, req, &resp, opts); err != nil {
+		return nil, err
+	}
+	return resp.PR, nil
+}
+
+// ReassignPullRequest replaces oldUserID among the reviewers of prID.
+func (c *Client) ReassignPullRequest(ctx context.Context, prID, oldUserID string, opts ...CallOption) (*ReassignResult, error) {
+	var resp ReassignResult
+	req := entity.ReassignPRRequest{PRID: prID, OldUserID: oldUserID}
+	if err := c.do(ctx, http.MethodPost, "/pullRequest/reassign", nil, req, &resp, opts); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) StatsSummary(ctx context.Context, filter StatsFilter, opts ...CallOption) (*StatsSummary, error) {
+	var resp struct {
+		Stats *StatsSummary `json:"stats"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/stats/summary", statsQuery(filter), nil, &resp, opts); err != nil {
+		return nil, err
+	}
+	return resp.Stats, nil
+}
+
+func (c *Client) StatsTimeSeries(ctx context.Context, filter StatsFilter, interval StatsInterval, byTeam bool, opts ...CallOption) (*TimeSeries, error) {
+	var resp struct {
+		TimeSeries *TimeSeries `json:"timeseries"`
+	}
+	q := statsQuery(filter)
+	if interval != "" {
+		q.Set("interval", string(interval))
+	}
+	if byTeam {
+		q.Set("by_team", "true")
+	}
+	if err := c.do(ctx, http.MethodGet, "/stats/timeseries", q, nil, &resp, opts); err != nil {
+		return nil, err
+	}
+	return resp.TimeSeries, nil
+}
+
+func (c *Client) StatsUser(ctx context.Context, userID string, filter StatsFilter, opts ...CallOption) (*UserReviewStats, error) {
+	var resp struct {
+		Stats *UserReviewStats `json:"stats"`
+	}
+	q := statsQuery(filter)
+	q.Set("user_id", userID)
+	if err := c.do(ctx, http.MethodGet, "/stats/user", q, nil, &resp, opts); err != nil {
+		return nil, err
+	}
+	return resp.Stats, nil
+}
+
+func (c *Client) StatsFairness(ctx context.Context, filter StatsFilter, opts ...CallOption) (*FairnessReport, error) {
+	var resp struct {
+		Fairness *FairnessReport `json:"fairness"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/stats/fairness", statsQuery(filter), nil, &resp, opts); err != nil {
+		return nil, err
+	}
+	return resp.Fairness, nil
+}
+
+func (c *Client) StatsSLA(ctx context.Context, filter StatsFilter, opts ...CallOption) (*SLAReport, error) {
+	var resp struct {
+		SLA *SLAReport `json:"sla"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/stats/sla", statsQuery(filter), nil, &resp, opts); err != nil {
+		return nil, err
+	}
+	return resp.SLA, nil
+}
+
+// Live returns nil while the service process answers.
+func (c *Client) Live(ctx context.Context, opts ...CallOption) error {
+	return c.do(ctx, http.MethodGet, "/health/live", nil, nil, nil, opts)
+}
+
+// Ready returns the readiness report. A service that is not ready answers
+// 503 with the same report, which is returned without an error and is not
+// retried.
+func (c *Client) Ready(ctx context.Context, opts ...CallOption) (*Readiness, error) {
+	once := *c
+	once.retry = RetryPolicy{}
+	resp, err := once.send(ctx, http.MethodGet, "/health/ready", nil, nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil, decodeError(resp)
+	}
+	var readiness Readiness
+	if err := json.NewDecoder(resp.Body).Decode(&readiness); err != nil {
+		return nil, fmt.Errorf("decode readiness response: %w", err)
+	}
+	return &readiness, nil
+}
+
+// statsQuery encodes the filter the way the stats handlers parse it.
+func statsQuery(filter StatsFilter) url.Values {
+	q := url.Values{}
+	if filter.TeamName != "" {
+		q.Set("team_name", filter.TeamName)
+	}
+	if filter.AuthorID != "" {
+		q.Set("author_id", filter.AuthorID)
+	}
+	if filter.From != nil {
+		q.Set("from", filter.From.Format(time.RFC3339))
+	}
+	if filter.To != nil {
+		q.Set("to", filter.To.Format(time.RFC3339))
+	}
+	if days := int(filter.StaleAfter / (24 * time.Hour)); days > 0 {
+		q.Set("stale_after_days", strconv.Itoa(days))
+	}
+	return q
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/xddprog/avito-test-task/internal/entity"
+)
+
+// Error is a non-2xx response decoded from the {"error": {...}} envelope.
+// Compare it with the Err* values below using errors.Is.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	RequestID  string
+}
+
+func (e *Error) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("%s: %s (status %d, request %s)", e.Code, e.Message, e.StatusCode, e.RequestID)
+	}
+	return fmt.Sprintf("%s: %s (status %d)", e.Code, e.Message, e.StatusCode)
+}
+
+// Is matches errors by code, so a response with a custom message, such as a
+// validation failure, still matches ErrBadRequest. Inside this module the
+// server's entity.Err* values match as well.
+func (e *Error) Is(target error) bool {
+	switch t := target.(type) {
+	case *Error:
+		return t.Code == e.Code
+	case *entity.AppError:
+		return t.SafeCode == e.Code
+	default:
+		return false
+	}
+}
+
+// Errors the API can return, one per entity.Err* code.
+var (
+	ErrNotFound              = fromEntity(entity.ErrNotFound)
+	ErrInternal              = fromEntity(entity.ErrInternal)
+	ErrBadRequest            = fromEntity(entity.ErrBadRequest)
+	ErrTeamExists            = fromEntity(entity.ErrTeamExists)
+	ErrPRExists              = fromEntity(entity.ErrPRExists)
+	ErrPRMerged              = fromEntity(entity.ErrPRMerged)
+	ErrNotAssigned           = fromEntity(entity.ErrNotAssigned)
+	ErrNoCandidate           = fromEntity(entity.ErrNoCandidate)
+	ErrConflictVersion       = fromEntity(entity.ErrConflictVersion)
+	ErrPreconditionFailed    = fromEntity(entity.ErrPreconditionFailed)
+	ErrRateLimited           = fromEntity(entity.ErrRateLimited)
+	ErrPayloadTooLarge       = fromEntity(entity.ErrPayloadTooLarge)
+	ErrIdempotencyKeyReused  = fromEntity(entity.ErrIdempotencyKeyReused)
+	ErrIdempotencyInProgress = fromEntity(entity.ErrIdempotencyInProgress)
+	ErrUnauthorized          = fromEntity(entity.ErrUnauthorized)
+	ErrImportConflict        = fromEntity(entity.ErrImportConflict)
+)
+
+// ErrUnexpectedStatus is the code of error responses that carry no envelope,
+// e.g. a 502 from a proxy in front of the service.
+const ErrUnexpectedStatus = "UNEXPECTED_STATUS"
+
+func fromEntity(e *entity.AppError) *Error {
+	return &Error{StatusCode: e.Code, Code: e.SafeCode, Message: e.Message}
+}
+
+type errorEnvelope struct {
+	Error struct {
+		Code      string `json:"code"`
+		Message   string `json:"message"`
+		RequestID string `json:"request_id"`
+	} `json:"error"`
+}
+
+func decodeError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	var envelope errorEnvelope
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err == nil && envelope.Error.Code != "" {
+		apiErr.Code = envelope.Error.Code
+		apiErr.Message = envelope.Error.Message
+		apiErr.RequestID = envelope.Error.RequestID
+		return apiErr
+	}
+	apiErr.Code = ErrUnexpectedStatus
+	apiErr.Message = http.StatusText(resp.StatusCode)
+	return apiErr
+}
@@ -0,0 +1,59 @@
+package client
+
+import "github.com/xddprog/avito-test-task/internal/entity"
+
+// The API types are the server's own entity types, so requests and
+// responses cannot drift from what the handlers encode.
+type (
+	Team                          = entity.Team
+	TeamMember                    = entity.TeamMemberDTO
+	CreateTeamRequest             = entity.CreateTeamRequest
+	DeactivateTeamMembersRequest  = entity.DeactivateTeamMembersRequest
+	DeactivateTeamMembersResponse = entity.DeactivateTeamMembersResponse
+	ReassignmentResult            = entity.ReassignmentResult
+	User                          = entity.User
+	PullRequest                   = entity.PullRequest
+	PullRequestShort              = entity.BasePullRequest
+	CreatePRRequest               = entity.CreatePRRequest
+	BulkCreatePRItem              = entity.BulkCreatePRItem
+	BulkCreatePRResult            = entity.BulkCreatePRResult
+	BulkCreatePRResponse          = entity.BulkCreatePRResponse
+	PRStatus                      = entity.PRStatus
+	StatsFilter                   = entity.StatsFilter
+	StatsInterval                 = entity.StatsInterval
+	StatsSummary                  = entity.StatsSummary
+	TimeSeries                    = entity.TimeSeries
+	UserReviewStats               = entity.UserReviewStats
+	FairnessReport                = entity.FairnessReport
+	SLAReport                     = entity.SLAReport
+	NotificationSettings          = entity.NotificationSettings
+	NotificationChannel           = entity.NotificationChannel
+	QuietHours                    = entity.QuietHours
+)
+
+const (
+	StatusOpen   = entity.StatusOpen
+	StatusMerged = entity.StatusMerged
+
+	IntervalDay   = entity.IntervalDay
+	IntervalWeek  = entity.IntervalWeek
+	IntervalMonth = entity.IntervalMonth
+
+	ChannelEmail = entity.ChannelEmail
+	ChannelChat  = entity.ChannelChat
+	ChannelLog   = entity.ChannelLog
+)
+
+// ReassignResult is the reassigned pull request and the reviewer that took
+// over from the old one.
+type ReassignResult struct {
+	PR         *PullRequest `json:"pr"`
+	ReplacedBy string       `json:"replaced_by"`
+}
+
+// Readiness is the /health/ready report. Status is "OK", "UNAVAILABLE" or
+// "DRAINING"; Checks maps each dependency to "OK" or its error.
+type Readiness struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
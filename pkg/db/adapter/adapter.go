@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// ErrNoRows is returned by Row.Scan when the query matched nothing. Both
+// drivers report it through database/sql's sentinel, so errors.Is works
+// regardless of the backend.
+var ErrNoRows = sql.ErrNoRows
+
+// Querier runs statements. Queries use $1, $2, ... placeholders on every
+// dialect.
+type Querier interface {
+	Exec(ctx context.Context, query string, args ...any) (Result, error)
+	Query(ctx context.Context, query string, args ...any) (Rows, error)
+	QueryRow(ctx context.Context, query string, args ...any) Row
+	SendBatch(ctx context.Context, batch *Batch) BatchResults
+}
+
+// Batch is a list of statements sent together. Postgres runs them in one
+// round trip; SQLite, which has no round trips to save, runs them one by one
+// as their results are read. Only a batch sent on a Tx is atomic on every
+// dialect.
+type Batch struct {
+	queries []queuedQuery
+}
+
+type queuedQuery struct {
+	sql  string
+	args []any
+}
+
+func (b *Batch) Queue(query string, args ...any) {
+	b.queries = append(b.queries, queuedQuery{sql: query, args: args})
+}
+
+func (b *Batch) Len() int {
+	return len(b.queries)
+}
+
+// BatchResults returns the results of a Batch in the order its statements
+// were queued. Close must always be called; it runs or drains the statements
+// whose results were not read and returns their first error.
+type BatchResults interface {
+	Exec() (Result, error)
+	QueryRow() Row
+	Close() error
+}
+
+// DB is the storage surface repositories are written against. Begin on a Tx
+// opens a savepoint, so code that needs its own transaction nests inside a
+// caller's one.
+type DB interface {
+	Querier
+	Begin(ctx context.Context) (Tx, error)
+	// BeginReadOnly opens a transaction that only reads and sees the same
+	// snapshot of the data throughout. On SQLite it does not take the write
+	// lock. Inside a Tx it opens a savepoint like Begin.
+	BeginReadOnly(ctx context.Context) (Tx, error)
+	Dialect() Dialect
+}
+
+type Tx interface {
+	DB
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
+}
+
+type Rows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Err() error
+	Close()
+}
+
+type Row interface {
+	Scan(dest ...any) error
+}
+
+type Result interface {
+	RowsAffected() int64
+}
+
+// Dialect describes what differs between the supported databases.
+type Dialect interface {
+	Name() string
+	// ForUpdate returns the row-locking clause for a SELECT, limited to the
+	// given table aliases if any. Databases that lock the whole file on
+	// write return an empty string.
+	ForUpdate(tables ...string) string
+	// ForUpdateSkipLocked is ForUpdate that passes over rows locked by
+	// other transactions instead of waiting for them.
+	ForUpdateSkipLocked() string
+	// Serializable returns the statement that raises the current
+	// transaction to SERIALIZABLE, or an empty string if every transaction
+	// already is.
+	Serializable() string
+	IsUniqueViolation(err error) bool
+	IsForeignKeyViolation(err error) bool
+	// IsRetryable reports whether a transaction failed only because of
+	// contention and can be run again.
+	IsRetryable(err error) bool
+}
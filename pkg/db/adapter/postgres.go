@@ -1 +1,154 @@
-package db
\ No newline at end of file
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const DialectPostgres = "postgres"
+
+// pgQuerier is the part of the API shared by *pgxpool.Pool and pgx.Tx.
+type pgQuerier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+type postgresDB struct {
+	q pgQuerier
+}
+
+// postgresPool is the top-level DB. Transactions begun on it are
+// postgresTx values, whose Begin opens a savepoint.
+type postgresPool struct {
+	postgresDB
+	pool *pgxpool.Pool
+}
+
+// NewPostgres adapts a pgx pool. The pool stays owned by the caller.
+func NewPostgres(pool *pgxpool.Pool) DB {
+	return &postgresPool{postgresDB: postgresDB{q: pool}, pool: pool}
+}
+
+func (p *postgresPool) BeginReadOnly(ctx context.Context) (Tx, error) {
+	tx, err := p.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	return &postgresTx{postgresDB: postgresDB{q: tx}, tx: tx}, nil
+}
+
+func (d *postgresDB) Exec(ctx context.Context, query string, args ...any) (Result, error) {
+	return d.q.Exec(ctx, query, args...)
+}
+
+func (d *postgresDB) Query(ctx context.Context, query string, args ...any) (Rows, error) {
+	return d.q.Query(ctx, query, args...)
+}
+
+func (d *postgresDB) QueryRow(ctx context.Context, query string, args ...any) Row {
+	return d.q.QueryRow(ctx, query, args...)
+}
+
+func (d *postgresDB) SendBatch(ctx context.Context, batch *Batch) BatchResults {
+	b := &pgx.Batch{}
+	for _, q := range batch.queries {
+		b.Queue(q.sql, q.args...)
+	}
+	return postgresBatchResults{br: d.q.SendBatch(ctx, b)}
+}
+
+func (d *postgresDB) Begin(ctx context.Context) (Tx, error) {
+	tx, err := d.q.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &postgresTx{postgresDB: postgresDB{q: tx}, tx: tx}, nil
+}
+
+func (d *postgresDB) BeginReadOnly(ctx context.Context) (Tx, error) {
+	return d.Begin(ctx)
+}
+
+func (d *postgresDB) Dialect() Dialect {
+	return postgresDialect{}
+}
+
+type postgresTx struct {
+	postgresDB
+	tx pgx.Tx
+}
+
+func (t *postgresTx) Commit(ctx context.Context) error {
+	return t.tx.Commit(ctx)
+}
+
+func (t *postgresTx) Rollback(ctx context.Context) error {
+	return t.tx.Rollback(ctx)
+}
+
+type postgresBatchResults struct {
+	br pgx.BatchResults
+}
+
+func (r postgresBatchResults) Exec() (Result, error) {
+	return r.br.Exec()
+}
+
+func (r postgresBatchResults) QueryRow() Row {
+	return r.br.QueryRow()
+}
+
+func (r postgresBatchResults) Close() error {
+	return r.br.Close()
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return DialectPostgres
+}
+
+func (postgresDialect) ForUpdate(tables ...string) string {
+	if len(tables) == 0 {
+		return "FOR UPDATE"
+	}
+	return "FOR UPDATE OF " + strings.Join(tables, ", ")
+}
+
+func (postgresDialect) ForUpdateSkipLocked() string {
+	return "FOR UPDATE SKIP LOCKED"
+}
+
+func (postgresDialect) Serializable() string {
+	return "SET TRANSACTION ISOLATION LEVEL SERIALIZABLE"
+}
+
+func (postgresDialect) IsUniqueViolation(err error) bool {
+	return pgErrorCode(err) == pgerrcode.UniqueViolation
+}
+
+func (postgresDialect) IsForeignKeyViolation(err error) bool {
+	return pgErrorCode(err) == pgerrcode.ForeignKeyViolation
+}
+
+func (postgresDialect) IsRetryable(err error) bool {
+	code := pgErrorCode(err)
+	return code == pgerrcode.SerializationFailure || code == pgerrcode.DeadlockDetected
+}
+
+func pgErrorCode(err error) string {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return ""
+	}
+	return pgErr.Code
+}
@@ -0,0 +1,334 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const DialectSQLite = "sqlite"
+
+// sqliteTimeLayout is fixed-width and always UTC, so timestamps stored as
+// text compare correctly with < and >.
+const sqliteTimeLayout = "2006-01-02 15:04:05.000000000-07:00"
+
+// SQLite is a single-file database. Writers are serialized by SQLite itself:
+// every transaction starts IMMEDIATE and waits on busy_timeout for the lock,
+// which stands in for the row locks taken on Postgres.
+type SQLite struct {
+	sqliteDB
+	db *sql.DB
+}
+
+// OpenSQLite opens (creating if needed) the database file at path.
+func OpenSQLite(path string) (*SQLite, error) {
+	dsn := fmt.Sprintf(
+		"file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate",
+		path,
+	)
+	conn, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLite{sqliteDB: sqliteDB{q: conn}, db: conn}, nil
+}
+
+// SQLiteURL is the golang-migrate database URL for the file at path.
+func SQLiteURL(path string) string {
+	return "sqlite://" + path
+}
+
+func (s *SQLite) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLite) Begin(ctx context.Context) (Tx, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteTx{sqliteDB: sqliteDB{q: tx}, tx: tx}, nil
+}
+
+// BeginReadOnly starts a DEFERRED transaction: it takes a read snapshot on
+// its first query and never waits for, or blocks, writers.
+func (s *SQLite) BeginReadOnly(ctx context.Context) (Tx, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteTx{sqliteDB: sqliteDB{q: tx}, tx: tx}, nil
+}
+
+// sqlQuerier is the part of the API shared by *sql.DB and *sql.Tx.
+type sqlQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type sqliteDB struct {
+	q sqlQuerier
+}
+
+func (d *sqliteDB) Exec(ctx context.Context, query string, args ...any) (Result, error) {
+	res, err := d.q.ExecContext(ctx, query, sqliteArgs(args)...)
+	if err != nil {
+		return nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	return sqliteResult(n), nil
+}
+
+func (d *sqliteDB) Query(ctx context.Context, query string, args ...any) (Rows, error) {
+	rows, err := d.q.QueryContext(ctx, query, sqliteArgs(args)...)
+	if err != nil {
+		return nil, err
+	}
+	return sqliteRows{rows}, nil
+}
+
+func (d *sqliteDB) QueryRow(ctx context.Context, query string, args ...any) Row {
+	return d.q.QueryRowContext(ctx, query, sqliteArgs(args)...)
+}
+
+func (d *sqliteDB) SendBatch(ctx context.Context, batch *Batch) BatchResults {
+	return &sqliteBatchResults{ctx: ctx, db: d, queries: batch.queries}
+}
+
+func (d *sqliteDB) Dialect() Dialect {
+	return sqliteDialect{}
+}
+
+var savepointSeq atomic.Uint64
+
+type sqliteTx struct {
+	sqliteDB
+	tx *sql.Tx
+}
+
+// Begin opens a savepoint inside the transaction.
+func (t *sqliteTx) Begin(ctx context.Context) (Tx, error) {
+	return beginSavepoint(ctx, t.sqliteDB)
+}
+
+func (t *sqliteTx) BeginReadOnly(ctx context.Context) (Tx, error) {
+	return beginSavepoint(ctx, t.sqliteDB)
+}
+
+func (t *sqliteTx) Commit(context.Context) error {
+	return t.tx.Commit()
+}
+
+func (t *sqliteTx) Rollback(context.Context) error {
+	return t.tx.Rollback()
+}
+
+type sqliteSavepoint struct {
+	sqliteDB
+	name string
+	done bool
+}
+
+func beginSavepoint(ctx context.Context, db sqliteDB) (Tx, error) {
+	sp := &sqliteSavepoint{sqliteDB: db, name: fmt.Sprintf("sp_%d", savepointSeq.Add(1))}
+	if _, err := db.q.ExecContext(ctx, "SAVEPOINT "+sp.name); err != nil {
+		return nil, err
+	}
+	return sp, nil
+}
+
+func (s *sqliteSavepoint) Begin(ctx context.Context) (Tx, error) {
+	return beginSavepoint(ctx, s.sqliteDB)
+}
+
+func (s *sqliteSavepoint) BeginReadOnly(ctx context.Context) (Tx, error) {
+	return beginSavepoint(ctx, s.sqliteDB)
+}
+
+func (s *sqliteSavepoint) Commit(ctx context.Context) error {
+	if s.done {
+		return sql.ErrTxDone
+	}
+	s.done = true
+	_, err := s.q.ExecContext(ctx, "RELEASE SAVEPOINT "+s.name)
+	return err
+}
+
+// Rollback undoes the savepoint's changes. ROLLBACK TO keeps the savepoint
+// open, so it is released afterwards.
+func (s *sqliteSavepoint) Rollback(ctx context.Context) error {
+	if s.done {
+		return sql.ErrTxDone
+	}
+	s.done = true
+	if _, err := s.q.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+s.name); err != nil {
+		return err
+	}
+	_, err := s.q.ExecContext(ctx, "RELEASE SAVEPOINT "+s.name)
+	return err
+}
+
+// errBatchDone is returned when more results are read than statements were
+// queued.
+var errBatchDone = errors.New("db: no more statements in the batch")
+
+// sqliteBatchResults runs each statement when its result is read. After a
+// statement fails the rest are skipped, as Postgres does inside a
+// transaction.
+type sqliteBatchResults struct {
+	ctx     context.Context
+	db      *sqliteDB
+	queries []queuedQuery
+	failed  bool
+}
+
+func (r *sqliteBatchResults) next() (queuedQuery, error) {
+	if r.failed || len(r.queries) == 0 {
+		return queuedQuery{}, errBatchDone
+	}
+	q := r.queries[0]
+	r.queries = r.queries[1:]
+	return q, nil
+}
+
+func (r *sqliteBatchResults) Exec() (Result, error) {
+	q, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	res, err := r.db.Exec(r.ctx, q.sql, q.args...)
+	if err != nil {
+		r.failed = true
+	}
+	return res, err
+}
+
+func (r *sqliteBatchResults) QueryRow() Row {
+	q, err := r.next()
+	if err != nil {
+		return errRow{err: err}
+	}
+	return &sqliteBatchRow{results: r, row: r.db.QueryRow(r.ctx, q.sql, q.args...)}
+}
+
+func (r *sqliteBatchResults) Close() error {
+	for !r.failed && len(r.queries) > 0 {
+		if _, err := r.Exec(); err != nil {
+			return err
+		}
+	}
+	r.queries = nil
+	return nil
+}
+
+// sqliteBatchRow marks the batch failed when its statement does.
+type sqliteBatchRow struct {
+	results *sqliteBatchResults
+	row     Row
+}
+
+func (r *sqliteBatchRow) Scan(dest ...any) error {
+	err := r.row.Scan(dest...)
+	if err != nil && !errors.Is(err, ErrNoRows) {
+		r.results.failed = true
+	}
+	return err
+}
+
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(...any) error {
+	return r.err
+}
+
+type sqliteResult int64
+
+func (r sqliteResult) RowsAffected() int64 {
+	return int64(r)
+}
+
+type sqliteRows struct {
+	*sql.Rows
+}
+
+func (r sqliteRows) Close() {
+	_ = r.Rows.Close()
+}
+
+// sqliteArgs stores timestamps in sqliteTimeLayout.
+func sqliteArgs(args []any) []any {
+	converted := make([]any, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case time.Time:
+			converted[i] = v.UTC().Format(sqliteTimeLayout)
+		case *time.Time:
+			if v == nil {
+				converted[i] = nil
+			} else {
+				converted[i] = v.UTC().Format(sqliteTimeLayout)
+			}
+		default:
+			converted[i] = arg
+		}
+	}
+	return converted
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return DialectSQLite
+}
+
+func (sqliteDialect) ForUpdate(...string) string {
+	return ""
+}
+
+func (sqliteDialect) ForUpdateSkipLocked() string {
+	return ""
+}
+
+// Serializable is empty: SQLite runs one writer at a time, which is already
+// serializable.
+func (sqliteDialect) Serializable() string {
+	return ""
+}
+
+func (sqliteDialect) IsUniqueViolation(err error) bool {
+	code := sqliteErrorCode(err)
+	return code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+}
+
+func (sqliteDialect) IsForeignKeyViolation(err error) bool {
+	return sqliteErrorCode(err) == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+}
+
+func (sqliteDialect) IsRetryable(err error) bool {
+	code := sqliteErrorCode(err) & 0xff
+	return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+}
+
+func sqliteErrorCode(err error) int {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return 0
+	}
+	return sqliteErr.Code()
+}
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var migrationNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// Create adds an empty up/down pair named name to every directory in dirs.
+// All dialects share one version sequence, so the new version is one past
+// the highest version found in any of them.
+func Create(dirs []string, name string) ([]string, error) {
+	if !migrationNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid migration name %q: use lowercase letters, digits and underscores", name)
+	}
+
+	var latest uint
+	for _, dir := range dirs {
+		v, err := LatestVersion(dir)
+		if err != nil {
+			return nil, err
+		}
+		latest = max(latest, v)
+	}
+
+	var created []string
+	for _, dir := range dirs {
+		for _, direction := range []string{"up", "down"} {
+			path := filepath.Join(dir, fmt.Sprintf("%06d_%s.%s.sql", latest+1, name, direction))
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+			if err != nil {
+				return created, fmt.Errorf("failed to create migration: %w", err)
+			}
+			if err := f.Close(); err != nil {
+				return created, fmt.Errorf("failed to create migration: %w", err)
+			}
+			created = append(created, path)
+		}
+	}
+	return created, nil
+}
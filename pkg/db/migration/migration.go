@@ -7,23 +7,91 @@ import (
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 )
 
 func RunMigrations(migrationsPath string, databaseURL string) error {
+	m, err := NewMigrator(migrationsPath, databaseURL)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	applied, err := m.Up()
+	if err != nil {
+		return err
+	}
+	if !applied {
+		slog.Info("migrations: no changes")
+		return nil
+	}
+
+	slog.Info("migrations: successfully applied")
+	return nil
+}
+
+// Migrator runs golang-migrate commands against one database. It backs both
+// the startup migration and the "migrate" subcommand.
+type Migrator struct {
+	m *migrate.Migrate
+}
+
+func NewMigrator(migrationsPath string, databaseURL string) (*Migrator, error) {
 	m, err := migrate.New(migrationsPath, databaseURL)
 	if err != nil {
-		return fmt.Errorf("failed to create migrate instance: %w", err)
+		return nil, fmt.Errorf("failed to create migrate instance: %w", err)
 	}
+	return &Migrator{m: m}, nil
+}
 
-	if err := m.Up(); err != nil {
+// Up applies all pending migrations and reports whether anything changed.
+func (m *Migrator) Up() (bool, error) {
+	if err := m.m.Up(); err != nil {
 		if errors.Is(err, migrate.ErrNoChange) {
-			slog.Info("migrations: no changes")
-			return nil
+			return false, nil
 		}
-		return fmt.Errorf("failed to run migrate up: %w", err)
+		return false, fmt.Errorf("failed to run migrate up: %w", err)
+	}
+	return true, nil
+}
+
+// Down rolls back the last n applied migrations.
+func (m *Migrator) Down(n int) error {
+	if n < 1 {
+		return fmt.Errorf("number of migrations to roll back must be positive, got %d", n)
 	}
+	if err := m.m.Steps(-n); err != nil {
+		return fmt.Errorf("failed to run migrate down: %w", err)
+	}
+	return nil
+}
 
-	slog.Info("migrations: successfully applied")
+// Force records version v as applied and clears the dirty flag without
+// running any migration. It is the way out after a failed migration was
+// fixed by hand; -1 means no version.
+func (m *Migrator) Force(v int) error {
+	if err := m.m.Force(v); err != nil {
+		return fmt.Errorf("failed to force version %d: %w", v, err)
+	}
 	return nil
 }
+
+// Version returns the applied version; applied is false for an empty schema.
+func (m *Migrator) Version() (version uint, dirty bool, applied bool, err error) {
+	version, dirty, err = m.m.Version()
+	if err != nil {
+		if errors.Is(err, migrate.ErrNilVersion) {
+			return 0, false, false, nil
+		}
+		return 0, false, false, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, dirty, true, nil
+}
+
+func (m *Migrator) Close() {
+	srcErr, dbErr := m.m.Close()
+	if err := errors.Join(srcErr, dbErr); err != nil {
+		slog.Error("failed to close migrate instance", "error", err)
+	}
+}
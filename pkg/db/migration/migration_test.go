@@ -0,0 +1,91 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	adapter "github.com/xddprog/avito-test-task/pkg/db/adapter"
+)
+
+func TestMigratorSQLite(t *testing.T) {
+	m, err := NewMigrator("file://../../../migrations/sqlite", adapter.SQLiteURL(filepath.Join(t.TempDir(), "test.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.Close)
+
+	expectVersion := func(want uint, wantApplied bool) {
+		t.Helper()
+		version, dirty, applied, err := m.Version()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != want || applied != wantApplied || dirty {
+			t.Fatalf("version = %d (applied=%t, dirty=%t), want %d (applied=%t)", version, applied, dirty, want, wantApplied)
+		}
+	}
+
+	expectVersion(0, false)
+
+	latest, err := LatestVersion("../../../migrations/sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := m.Up(); err != nil || !changed {
+		t.Fatalf("up: changed=%t err=%v", changed, err)
+	}
+	expectVersion(latest, true)
+	if changed, err := m.Up(); err != nil || changed {
+		t.Fatalf("second up: changed=%t err=%v", changed, err)
+	}
+
+	if err := m.Down(2); err != nil {
+		t.Fatal(err)
+	}
+	expectVersion(latest-2, true)
+	if err := m.Down(0); err == nil {
+		t.Fatal("down 0 must fail")
+	}
+
+	if err := m.Force(int(latest)); err != nil {
+		t.Fatal(err)
+	}
+	expectVersion(latest, true)
+}
+
+func TestCreateUsesSharedVersion(t *testing.T) {
+	postgres, sqlite := t.TempDir(), t.TempDir()
+	for _, f := range []string{
+		filepath.Join(postgres, "000001_init.up.sql"),
+		filepath.Join(postgres, "000002_more.up.sql"),
+		filepath.Join(sqlite, "000001_init.up.sql"),
+	} {
+		if err := os.WriteFile(f, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := Create([]string{postgres, sqlite}, "add_index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(postgres, "000003_add_index.up.sql"),
+		filepath.Join(postgres, "000003_add_index.down.sql"),
+		filepath.Join(sqlite, "000003_add_index.up.sql"),
+		filepath.Join(sqlite, "000003_add_index.down.sql"),
+	}
+	if len(files) != len(want) {
+		t.Fatalf("created %v, want %v", files, want)
+	}
+	for i := range want {
+		if files[i] != want[i] {
+			t.Fatalf("created %v, want %v", files, want)
+		}
+	}
+
+	if _, err := Create([]string{postgres}, "Bad-Name"); err == nil {
+		t.Fatal("invalid name must fail")
+	}
+}
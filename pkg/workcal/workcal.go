@@ -0,0 +1,137 @@
+// Package workcal adds durations in working time: chosen weekdays with one
+// working interval each, minus holidays, in a given time zone.
+package workcal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+type Calendar struct {
+	loc  *time.Location
+	days [7]bool
+	// open and close are minutes since midnight, close may be 24:00.
+	open, close int
+	holidays    map[string]bool
+}
+
+// New builds a calendar from its text form: days as "mon-fri" or
+// "mon,wed,fri", hours as "10:00-19:00", an IANA time zone name and holidays
+// as YYYY-MM-DD dates in that zone.
+func New(days, hours, timezone string, holidays []string) (*Calendar, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("workcal: unknown time zone %q", timezone)
+	}
+	c := &Calendar{loc: loc, holidays: make(map[string]bool, len(holidays))}
+
+	if c.days, err = parseDays(days); err != nil {
+		return nil, err
+	}
+	if c.open, c.close, err = parseHours(hours); err != nil {
+		return nil, err
+	}
+	for _, h := range holidays {
+		h = strings.TrimSpace(h)
+		if _, err := time.Parse(dateLayout, h); err != nil {
+			return nil, fmt.Errorf("workcal: holiday %q is not a YYYY-MM-DD date", h)
+		}
+		c.holidays[h] = true
+	}
+	return c, nil
+}
+
+// Add returns the moment d of working time after t, in t's location. Time
+// outside working hours does not count, so for a positive d the result is
+// always inside working hours.
+func (c *Calendar) Add(t time.Time, d time.Duration) time.Time {
+	if d <= 0 {
+		return t
+	}
+	cur := t.In(c.loc)
+	for {
+		y, m, day := cur.Date()
+		if c.days[cur.Weekday()] && !c.holidays[cur.Format(dateLayout)] {
+			open := time.Date(y, m, day, c.open/60, c.open%60, 0, 0, c.loc)
+			closing := time.Date(y, m, day, c.close/60, c.close%60, 0, 0, c.loc)
+			if cur.Before(open) {
+				cur = open
+			}
+			if left := closing.Sub(cur); left > 0 {
+				if d <= left {
+					return cur.Add(d).In(t.Location())
+				}
+				d -= left
+			}
+		}
+		cur = time.Date(y, m, day+1, 0, 0, 0, 0, c.loc)
+	}
+}
+
+func parseDays(s string) ([7]bool, error) {
+	var days [7]bool
+	for _, item := range strings.Split(s, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		from, to, isRange := strings.Cut(item, "-")
+		first, ok := weekdays[from]
+		if !ok {
+			return days, fmt.Errorf("workcal: unknown weekday %q", from)
+		}
+		last := first
+		if isRange {
+			if last, ok = weekdays[to]; !ok {
+				return days, fmt.Errorf("workcal: unknown weekday %q", to)
+			}
+		}
+		// Ranges may wrap around the week, as in "sun-thu" or "fri-mon".
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseHours(s string) (open, closing int, err error) {
+	from, to, ok := strings.Cut(strings.TrimSpace(s), "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("workcal: hours %q must look like 10:00-19:00", s)
+	}
+	if open, err = parseClock(from); err != nil {
+		return 0, 0, err
+	}
+	if closing, err = parseClock(to); err != nil {
+		return 0, 0, err
+	}
+	if open >= closing {
+		return 0, 0, fmt.Errorf("workcal: hours %q end before they start", s)
+	}
+	return open, closing, nil
+}
+
+func parseClock(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("workcal: %q is not a HH:MM time", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
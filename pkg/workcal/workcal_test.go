@@ -0,0 +1,91 @@
+package workcal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdd(t *testing.T) {
+	cal, err := New("mon-fri", "10:00-19:00", "Europe/Moscow", []string{"2025-01-08"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msk := time.FixedZone("MSK", 3*60*60)
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2025, time.January, day, hour, minute, 0, 0, msk)
+	}
+
+	// 2025-01-06 is a Monday, 2025-01-08 a holiday and 2025-01-11 a Saturday.
+	cases := []struct {
+		name  string
+		start time.Time
+		d     time.Duration
+		want  time.Time
+	}{
+		{"within the day", at(6, 11, 0), 2 * time.Hour, at(6, 13, 0)},
+		{"before opening", at(6, 7, 30), time.Hour, at(6, 11, 0)},
+		{"ends at closing", at(6, 18, 0), time.Hour, at(6, 19, 0)},
+		{"overnight", at(6, 18, 0), 2 * time.Hour, at(7, 11, 0)},
+		{"after closing", at(6, 20, 0), 30 * time.Minute, at(7, 10, 30)},
+		{"skips holiday", at(7, 18, 0), 3 * time.Hour, at(9, 12, 0)},
+		{"skips weekend", at(10, 17, 0), 4 * time.Hour, at(13, 12, 0)},
+		{"starts on weekend", at(11, 12, 0), time.Hour, at(13, 11, 0)},
+		{"week with a holiday", at(6, 10, 0), 5 * 9 * time.Hour, at(13, 19, 0)},
+		{"zero", at(11, 12, 0), 0, at(11, 12, 0)},
+	}
+	for _, tc := range cases {
+		if got := cal.Add(tc.start, tc.d); !got.Equal(tc.want) {
+			t.Errorf("%s: Add(%s, %s) = %s, want %s", tc.name, tc.start, tc.d, got, tc.want)
+		}
+	}
+
+	utc := cal.Add(at(6, 11, 0).UTC(), time.Hour)
+	if utc.Location() != time.UTC || !utc.Equal(at(6, 12, 0)) {
+		t.Errorf("Add in UTC = %s", utc)
+	}
+}
+
+func TestAddAcrossDST(t *testing.T) {
+	cal, err := New("sun-sat", "09:00-17:00", "Europe/Berlin", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	// Clocks go forward on 2025-03-30; the working day still opens at 09:00.
+	got := cal.Add(time.Date(2025, time.March, 29, 16, 0, 0, 0, berlin), 2*time.Hour)
+	if want := time.Date(2025, time.March, 30, 10, 0, 0, 0, berlin); !got.Equal(want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestNewRejects(t *testing.T) {
+	cases := map[string][4]string{
+		"unknown day":      {"mon-fry", "10:00-19:00", "UTC", ""},
+		"empty days":       {"", "10:00-19:00", "UTC", ""},
+		"no range":         {"mon-fri", "10:00", "UTC", ""},
+		"bad clock":        {"mon-fri", "10:00-25:00", "UTC", ""},
+		"inverted hours":   {"mon-fri", "19:00-10:00", "UTC", ""},
+		"unknown timezone": {"mon-fri", "10:00-19:00", "Mars/Olympus", ""},
+		"bad holiday":      {"mon-fri", "10:00-19:00", "UTC", "01.01.2025"},
+	}
+	for name, args := range cases {
+		var holidays []string
+		if args[3] != "" {
+			holidays = []string{args[3]}
+		}
+		if _, err := New(args[0], args[1], args[2], holidays); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	cal, err := New("fri-mon", "00:00-24:00", "UTC", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for d, want := range [7]bool{true, true, false, false, false, true, true} {
+		if cal.days[d] != want {
+			t.Errorf("fri-mon: %s = %t", time.Weekday(d), cal.days[d])
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// Package xlsx writes simple Office Open XML workbooks: one table per sheet,
+// a bold frozen header row, strings stored inline and no formulas.
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+const maxSheetName = 31
+
+// Sheet is one worksheet. Cells may be strings, integers, floats, bools,
+// time.Time (written as RFC 3339 text) or nil for an empty cell.
+type Sheet struct {
+	Name   string
+	Header []string
+	Rows   [][]any
+}
+
+// Write writes a workbook with the given sheets, in order.
+func Write(w io.Writer, sheets []Sheet) error {
+	if len(sheets) == 0 {
+		return errors.New("xlsx: workbook needs at least one sheet")
+	}
+	seen := make(map[string]bool, len(sheets))
+	for _, s := range sheets {
+		if err := checkSheetName(s.Name); err != nil {
+			return err
+		}
+		key := strings.ToLower(s.Name)
+		if seen[key] {
+			return fmt.Errorf("xlsx: duplicate sheet name %q", s.Name)
+		}
+		seen[key] = true
+	}
+
+	zw := zip.NewWriter(w)
+	parts := []struct {
+		name string
+		data []byte
+	}{
+		{"[Content_Types].xml", contentTypes(len(sheets))},
+		{"_rels/.rels", []byte(xml.Header + rootRels)},
+		{"xl/workbook.xml", workbook(sheets)},
+		{"xl/_rels/workbook.xml.rels", workbookRels(len(sheets))},
+		{"xl/styles.xml", []byte(xml.Header + styles)},
+	}
+	for i, s := range sheets {
+		data, err := worksheet(s)
+		if err != nil {
+			return err
+		}
+		parts = append(parts, struct {
+			name string
+			data []byte
+		}{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), data})
+	}
+
+	for _, p := range parts {
+		fw, err := zw.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(p.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func checkSheetName(name string) error {
+	if name == "" || len([]rune(name)) > maxSheetName {
+		return fmt.Errorf("xlsx: sheet name %q must be 1 to %d characters", name, maxSheetName)
+	}
+	if strings.ContainsAny(name, `[]:*?/\`) || strings.HasPrefix(name, "'") || strings.HasSuffix(name, "'") {
+		return fmt.Errorf("xlsx: sheet name %q contains a forbidden character", name)
+	}
+	return nil
+}
+
+const (
+	mainNS = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+	relNS  = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+
+	rootRels = `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="` + relNS + `/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+
+	// Style 1 is the bold header.
+	styles = `<styleSheet xmlns="` + mainNS + `">` +
+		`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+		`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+		`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+		`</styleSheet>`
+)
+
+func contentTypes(sheets int) []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	b.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	b.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+	}
+	b.WriteString(`</Types>`)
+	return b.Bytes()
+}
+
+func workbook(sheets []Sheet) []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<workbook xmlns="` + mainNS + `" xmlns:r="` + relNS + `"><sheets>`)
+	for i, s := range sheets {
+		fmt.Fprintf(&b, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(s.Name), i+1, i+1)
+	}
+	b.WriteString(`</sheets></workbook>`)
+	return b.Bytes()
+}
+
+func workbookRels(sheets int) []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="%s/worksheet" Target="worksheets/sheet%d.xml"/>`, i, relNS, i)
+	}
+	fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="%s/styles" Target="styles.xml"/>`, sheets+1, relNS)
+	b.WriteString(`</Relationships>`)
+	return b.Bytes()
+}
+
+func worksheet(s Sheet) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="` + mainNS + `">`)
+	if len(s.Header) > 0 {
+		b.WriteString(`<sheetViews><sheetView workbookViewId="0">` +
+			`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>` +
+			`</sheetView></sheetViews>`)
+	}
+	b.WriteString(`<sheetData>`)
+
+	row := 0
+	if len(s.Header) > 0 {
+		row++
+		fmt.Fprintf(&b, `<row r="%d">`, row)
+		for col, name := range s.Header {
+			writeString(&b, cellRef(col, row), name, ` s="1"`)
+		}
+		b.WriteString(`</row>`)
+	}
+	for _, cells := range s.Rows {
+		row++
+		fmt.Fprintf(&b, `<row r="%d">`, row)
+		for col, v := range cells {
+			if err := writeCell(&b, cellRef(col, row), v); err != nil {
+				return nil, fmt.Errorf("xlsx: sheet %q, cell %s: %w", s.Name, cellRef(col, row), err)
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.Bytes(), nil
+}
+
+func writeCell(b *bytes.Buffer, ref string, v any) error {
+	switch v := v.(type) {
+	case nil:
+	case string:
+		writeString(b, ref, v, "")
+	case bool:
+		n := 0
+		if v {
+			n = 1
+		}
+		fmt.Fprintf(b, `<c r="%s" t="b"><v>%d</v></c>`, ref, n)
+	case int:
+		writeNumber(b, ref, strconv.Itoa(v))
+	case int64:
+		writeNumber(b, ref, strconv.FormatInt(v, 10))
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("%v is not a finite number", v)
+		}
+		writeNumber(b, ref, strconv.FormatFloat(v, 'f', -1, 64))
+	case time.Time:
+		writeString(b, ref, v.UTC().Format(time.RFC3339), "")
+	default:
+		return fmt.Errorf("unsupported value of type %T", v)
+	}
+	return nil
+}
+
+func writeString(b *bytes.Buffer, ref, v, style string) {
+	fmt.Fprintf(b, `<c r="%s" t="inlineStr"%s><is><t xml:space="preserve">%s</t></is></c>`, ref, style, escape(v))
+}
+
+func writeNumber(b *bytes.Buffer, ref, v string) {
+	fmt.Fprintf(b, `<c r="%s"><v>%s</v></c>`, ref, v)
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// cellRef converts a zero-based column and one-based row to "A1" notation.
+func cellRef(col, row int) string {
+	name := ""
+	for col >= 0 {
+		name = string(rune('A'+col%26)) + name
+		col = col/26 - 1
+	}
+	return name + strconv.Itoa(row)
+}
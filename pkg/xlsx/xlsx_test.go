@@ -0,0 +1,110 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, []Sheet{
+		{Name: "People", Header: []string{"name", "age", "active", "since"}, Rows: [][]any{
+			{"Alice <&>", 30, true, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)},
+			{"  Bob", 1.5, false, nil},
+		}},
+		{Name: "Empty"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("not a zip: %v", err)
+	}
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(data)
+
+		if err := checkWellFormed(data); err != nil {
+			t.Errorf("%s is not well-formed XML: %v", f.Name, err)
+		}
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml", "xl/worksheets/sheet2.xml"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("%s is missing", name)
+		}
+	}
+	if !strings.Contains(files["xl/workbook.xml"], `<sheet name="People" sheetId="1" r:id="rId1"/>`) {
+		t.Errorf("workbook does not list the first sheet: %s", files["xl/workbook.xml"])
+	}
+
+	sheet := files["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="A1" t="inlineStr" s="1"><is><t xml:space="preserve">name</t></is></c>`,
+		`<c r="A2" t="inlineStr"><is><t xml:space="preserve">Alice &lt;&amp;&gt;</t></is></c>`,
+		`<c r="B2"><v>30</v></c>`,
+		`<c r="C2" t="b"><v>1</v></c>`,
+		`<t xml:space="preserve">2025-01-02T03:04:05Z</t>`,
+		`<t xml:space="preserve">  Bob</t>`,
+		`<c r="B3"><v>1.5</v></c>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet1 has no %s", want)
+		}
+	}
+	if strings.Contains(sheet, `r="D3"`) {
+		t.Error("nil cell was written")
+	}
+}
+
+func TestWriteRejects(t *testing.T) {
+	cases := map[string][]Sheet{
+		"no sheets":      nil,
+		"empty name":     {{Name: ""}},
+		"long name":      {{Name: strings.Repeat("x", 32)}},
+		"forbidden char": {{Name: "a/b"}},
+		"duplicate name": {{Name: "Data"}, {Name: "data"}},
+		"unsupported":    {{Name: "Data", Rows: [][]any{{struct{}{}}}}},
+		"non-finite":     {{Name: "Data", Rows: [][]any{{math.NaN()}}}},
+	}
+	for name, sheets := range cases {
+		if err := Write(io.Discard, sheets); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestCellRef(t *testing.T) {
+	for col, want := range map[int]string{0: "A1", 25: "Z1", 26: "AA1", 51: "AZ1", 52: "BA1", 701: "ZZ1", 702: "AAA1"} {
+		if got := cellRef(col, 1); got != want {
+			t.Errorf("cellRef(%d) = %s, want %s", col, got, want)
+		}
+	}
+}
+
+func checkWellFormed(data []byte) error {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
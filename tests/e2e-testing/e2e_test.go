@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -44,8 +45,9 @@ type userResponse struct {
 
 type errorResponse struct {
 	Error struct {
-		Code    string `json:"code"`
-		Message string `json:"message"`
+		Code      string `json:"code"`
+		Message   string `json:"message"`
+		RequestID string `json:"request_id"`
 	} `json:"error"`
 }
 
@@ -55,6 +57,7 @@ type createPRResponse struct {
 		AuthorID  string   `json:"author_id"`
 		Status    string   `json:"status"`
 		Reviewers []string `json:"assigned_reviewers"`
+		Version   int      `json:"version"`
 	} `json:"pr"`
 }
 
@@ -96,7 +99,16 @@ type statsResponse struct {
 				Hours   int `json:"hours"`
 				Minutes int `json:"minutes"`
 			} `json:"average_merge"`
-			OpenOlderThan7Days int `json:"open_older_than_7_days"`
+			OpenAgeHistogram []struct {
+				Label string `json:"label"`
+				Count int    `json:"count"`
+			} `json:"open_age_histogram"`
+			ByTeam []struct {
+				TeamName string `json:"team_name"`
+				Open     int    `json:"open"`
+			} `json:"by_team"`
+			OpenOlderThan7Days     int `json:"open_older_than_7_days"`
+			OpenOlderThanThreshold int `json:"open_older_than_threshold"`
 		} `json:"pr_lifetime"`
 	} `json:"stats"`
 }
@@ -133,6 +145,94 @@ func TestHealthEndpoint(t *testing.T) {
 	}
 }
 
+func TestHealthProbes(t *testing.T) {
+	baseURL := requireBaseURL(t)
+	body := doRequest(t, http.MethodGet, baseURL+"/health/live", nil, http.StatusOK)
+	var live map[string]string
+	decodeJSON(t, body, &live)
+	if live["status"] != "OK" {
+		t.Fatalf("unexpected liveness status: %v", live)
+	}
+
+	body = doRequest(t, http.MethodGet, baseURL+"/health/ready", nil, http.StatusOK)
+	var ready struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+	decodeJSON(t, body, &ready)
+	if ready.Status != "OK" {
+		t.Fatalf("unexpected readiness status: %+v", ready)
+	}
+	for _, check := range []string{"postgres", "migrations"} {
+		if ready.Checks[check] != "OK" {
+			t.Fatalf("readiness check %s not OK: %+v", check, ready.Checks)
+		}
+	}
+}
+
+func TestRequestBodyTooLarge(t *testing.T) {
+	baseURL := requireBaseURL(t)
+	payload := map[string]any{
+		"team_name": strings.Repeat("x", 2<<20),
+		"members":   []teamMember{},
+	}
+	body := doRequest(t, http.MethodPost, baseURL+"/team/add", payload, http.StatusRequestEntityTooLarge)
+	var errResp errorResponse
+	decodeJSON(t, body, &errResp)
+	if errResp.Error.Code != "PAYLOAD_TOO_LARGE" {
+		t.Fatalf("expected PAYLOAD_TOO_LARGE, got %s", errResp.Error.Code)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	baseURL := requireBaseURL(t)
+	doRequest(t, http.MethodGet, baseURL+"/stats/summary", nil, http.StatusOK)
+	body := string(doRequest(t, http.MethodGet, baseURL+"/metrics", nil, http.StatusOK))
+	for _, metric := range []string{
+		`reviewer_service_http_requests_total{method="GET",route="/stats/summary",status="200"}`,
+		"reviewer_service_http_request_duration_seconds_bucket",
+		"reviewer_service_db_pool_total_connections",
+		"reviewer_service_open_pull_requests",
+	} {
+		if !strings.Contains(body, metric) {
+			t.Fatalf("metrics output missing %s", metric)
+		}
+	}
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	baseURL := requireBaseURL(t)
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/team/get?team_name="+randomID("missing"), nil)
+	if err != nil {
+		t.Fatalf("build request: %v", err)
+	}
+	req.Header.Set("X-Request-ID", "e2e-request-42")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("X-Request-ID"); got != "e2e-request-42" {
+		t.Fatalf("expected echoed request id, got %q", got)
+	}
+	var errResp errorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
+		t.Fatalf("decode json: %v", err)
+	}
+	if errResp.Error.RequestID != "e2e-request-42" {
+		t.Fatalf("expected request id in error body, got %q", errResp.Error.RequestID)
+	}
+
+	resp, err = httpClient.Get(baseURL + "/health")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("X-Request-ID") == "" {
+		t.Fatalf("expected generated request id")
+	}
+}
+
 func TestTeamCreateAndGet(t *testing.T) {
 	baseURL := requireBaseURL(t)
 	members := []teamMember{
@@ -328,6 +428,372 @@ func TestPullRequestReassignSuccess(t *testing.T) {
 	}
 }
 
+func TestPullRequestReassignIdempotent(t *testing.T) {
+	baseURL := requireBaseURL(t)
+	teamName := fmt.Sprintf("reassign-idem-%s", randomID("team"))
+	members := []teamMember{
+		{UserID: randomID("user"), Username: "author", IsActive: true},
+		{UserID: randomID("user"), Username: "r1", IsActive: true},
+		{UserID: randomID("user"), Username: "r2", IsActive: true},
+		{UserID: randomID("user"), Username: "r3", IsActive: true},
+		{UserID: randomID("user"), Username: "r4", IsActive: true},
+	}
+	createTeam(t, baseURL, teamName, members)
+	body := doRequest(t, http.MethodPost, baseURL+"/pullRequest/create", map[string]string{
+		"pull_request_id":   randomID("pr"),
+		"pull_request_name": "feature/reassign-idem",
+		"author_id":         members[0].UserID,
+	}, http.StatusCreated)
+	var pr createPRResponse
+	decodeJSON(t, body, &pr)
+	if len(pr.PR.Reviewers) == 0 {
+		t.Fatalf("expected reviewers to be assigned")
+	}
+
+	key := randomID("idem")
+	payload := map[string]string{
+		"pull_request_id": pr.PR.ID,
+		"old_user_id":     pr.PR.Reviewers[0],
+	}
+	first, replayed := doIdempotentRequest(t, baseURL+"/pullRequest/reassign", key, payload, http.StatusOK)
+	if replayed {
+		t.Fatalf("first request must not be a replay")
+	}
+	second, replayed := doIdempotentRequest(t, baseURL+"/pullRequest/reassign", key, payload, http.StatusOK)
+	if !replayed {
+		t.Fatalf("expected retry to be replayed")
+	}
+	if !bytes.Equal(first, second) {
+		t.Fatalf("replayed response differs:\n%s\n%s", first, second)
+	}
+
+	payload["old_user_id"] = pr.PR.Reviewers[len(pr.PR.Reviewers)-1] + "-other"
+	conflict, _ := doIdempotentRequest(t, baseURL+"/pullRequest/reassign", key, payload, http.StatusUnprocessableEntity)
+	var errResp errorResponse
+	decodeJSON(t, conflict, &errResp)
+	if errResp.Error.Code != "IDEMPOTENCY_KEY_REUSED" {
+		t.Fatalf("expected IDEMPOTENCY_KEY_REUSED, got %s", errResp.Error.Code)
+	}
+}
+
+func TestPullRequestMergeIfMatch(t *testing.T) {
+	baseURL := requireBaseURL(t)
+	teamName := fmt.Sprintf("if-match-%s", randomID("team"))
+	members := []teamMember{
+		{UserID: randomID("user"), Username: "author", IsActive: true},
+		{UserID: randomID("user"), Username: "r1", IsActive: true},
+	}
+	createTeam(t, baseURL, teamName, members)
+	prID := randomID("pr")
+	_, header := doRequestWithHeaders(t, http.MethodPost, baseURL+"/pullRequest/create", map[string]string{
+		"pull_request_id":   prID,
+		"pull_request_name": "feature/if-match",
+		"author_id":         members[0].UserID,
+	}, nil, http.StatusCreated)
+	etag := header.Get("ETag")
+	if etag == "" {
+		t.Fatalf("expected ETag on created PR")
+	}
+
+	payload := map[string]string{"pull_request_id": prID}
+	body, _ := doRequestWithHeaders(t, http.MethodPost, baseURL+"/pullRequest/merge", payload,
+		map[string]string{"If-Match": `"999"`}, http.StatusPreconditionFailed)
+	var errResp errorResponse
+	decodeJSON(t, body, &errResp)
+	if errResp.Error.Code != "PRECONDITION_FAILED" {
+		t.Fatalf("expected PRECONDITION_FAILED, got %s", errResp.Error.Code)
+	}
+
+	mergeHeaders := map[string]string{"If-Match": etag, "Idempotency-Key": randomID("merge")}
+	_, header = doRequestWithHeaders(t, http.MethodPost, baseURL+"/pullRequest/merge", payload, mergeHeaders, http.StatusOK)
+	merged := header.Get("ETag")
+	if merged == "" || merged == etag {
+		t.Fatalf("expected a new ETag after merge, got %q", merged)
+	}
+
+	// A retry of the merge gets the stored response with the same ETag.
+	_, header = doRequestWithHeaders(t, http.MethodPost, baseURL+"/pullRequest/merge", payload, mergeHeaders, http.StatusOK)
+	if header.Get("Idempotent-Replayed") != "true" || header.Get("ETag") != merged {
+		t.Fatalf("expected a replay with ETag %q, got %q", merged, header.Get("ETag"))
+	}
+
+	doRequestWithHeaders(t, http.MethodPost, baseURL+"/pullRequest/merge", payload,
+		map[string]string{"If-Match": etag}, http.StatusPreconditionFailed)
+}
+
+func TestTeamGetETag(t *testing.T) {
+	baseURL := requireBaseURL(t)
+	teamName := fmt.Sprintf("etag-%s", randomID("team"))
+	members := []teamMember{
+		{UserID: randomID("user"), Username: "a", IsActive: true},
+		{UserID: randomID("user"), Username: "b", IsActive: true},
+	}
+	createTeam(t, baseURL, teamName, members)
+
+	url := baseURL + "/team/get?team_name=" + teamName
+	_, header := doRequestWithHeaders(t, http.MethodGet, url, nil, nil, http.StatusOK)
+	etag := header.Get("ETag")
+	doRequestWithHeaders(t, http.MethodGet, url, nil, map[string]string{"If-None-Match": etag}, http.StatusNotModified)
+
+	doRequest(t, http.MethodPost, baseURL+"/users/setIsActive", map[string]any{
+		"user_id":   members[1].UserID,
+		"is_active": false,
+	}, http.StatusOK)
+	_, header = doRequestWithHeaders(t, http.MethodGet, url, nil, map[string]string{"If-None-Match": etag}, http.StatusOK)
+	if header.Get("ETag") == etag {
+		t.Fatalf("expected team ETag to change after member update")
+	}
+
+	deactivate := map[string]any{
+		"team_name": teamName,
+		"user_ids":  []string{members[0].UserID},
+	}
+	doRequestWithHeaders(t, http.MethodPost, baseURL+"/team/deactivate", deactivate,
+		map[string]string{"If-Match": etag}, http.StatusPreconditionFailed)
+
+	etag = header.Get("ETag")
+	_, header = doRequestWithHeaders(t, http.MethodPost, baseURL+"/team/deactivate", deactivate,
+		map[string]string{"If-Match": etag}, http.StatusOK)
+	next := header.Get("ETag")
+	if next == "" || next == etag {
+		t.Fatalf("expected a new ETag after deactivation, got %q", next)
+	}
+	doRequestWithHeaders(t, http.MethodGet, url, nil, map[string]string{"If-None-Match": next}, http.StatusNotModified)
+}
+
+func TestConcurrentReassignKeepsReviewersConsistent(t *testing.T) {
+	baseURL := requireBaseURL(t)
+	teamName := fmt.Sprintf("concurrent-%s", randomID("team"))
+	members := []teamMember{{UserID: randomID("user"), Username: "author", IsActive: true}}
+	for i := 0; i < 7; i++ {
+		members = append(members, teamMember{UserID: randomID("user"), Username: fmt.Sprintf("r%d", i), IsActive: true})
+	}
+	createTeam(t, baseURL, teamName, members)
+	author := members[0].UserID
+
+	body := doRequest(t, http.MethodPost, baseURL+"/pullRequest/create", map[string]string{
+		"pull_request_id":   randomID("pr"),
+		"pull_request_name": "feature/concurrent",
+		"author_id":         author,
+	}, http.StatusCreated)
+	var latest createPRResponse
+	decodeJSON(t, body, &latest)
+	if len(latest.PR.Reviewers) != 2 {
+		t.Fatalf("expected 2 reviewers, got %v", latest.PR.Reviewers)
+	}
+
+	const duplicates = 3
+	for round := 0; round < 4; round++ {
+		type outcome struct {
+			status int
+			body   []byte
+		}
+		var wg sync.WaitGroup
+		outcomes := make(chan outcome, len(latest.PR.Reviewers)*duplicates)
+		for _, reviewer := range latest.PR.Reviewers {
+			for i := 0; i < duplicates; i++ {
+				wg.Add(1)
+				go func(oldUserID string) {
+					defer wg.Done()
+					status, respBody := postJSON(baseURL+"/pullRequest/reassign", map[string]string{
+						"pull_request_id": latest.PR.ID,
+						"old_user_id":     oldUserID,
+					})
+					outcomes <- outcome{status: status, body: respBody}
+				}(reviewer)
+			}
+		}
+		wg.Wait()
+		close(outcomes)
+
+		for o := range outcomes {
+			switch o.status {
+			case http.StatusOK:
+				var resp createPRResponse
+				decodeJSON(t, o.body, &resp)
+				assertReviewersValid(t, resp.PR.Reviewers, author)
+				if resp.PR.Version > latest.PR.Version {
+					latest = resp
+				}
+			case http.StatusConflict:
+				var errResp errorResponse
+				decodeJSON(t, o.body, &errResp)
+				if errResp.Error.Code != "NOT_ASSIGNED" && errResp.Error.Code != "NO_CANDIDATE" {
+					t.Fatalf("unexpected conflict: %s", o.body)
+				}
+			default:
+				t.Fatalf("unexpected status %d: %s", o.status, o.body)
+			}
+		}
+	}
+
+	body = doRequest(t, http.MethodPost, baseURL+"/pullRequest/merge", map[string]string{
+		"pull_request_id": latest.PR.ID,
+	}, http.StatusOK)
+	var merged createPRResponse
+	decodeJSON(t, body, &merged)
+	assertReviewersValid(t, merged.PR.Reviewers, author)
+	if len(merged.PR.Reviewers) != 2 {
+		t.Fatalf("expected 2 reviewers after concurrent reassigns, got %v", merged.PR.Reviewers)
+	}
+}
+
+func TestConcurrentMergeIsIdempotent(t *testing.T) {
+	baseURL := requireBaseURL(t)
+	teamName := fmt.Sprintf("concurrent-merge-%s", randomID("team"))
+	members := []teamMember{
+		{UserID: randomID("user"), Username: "author", IsActive: true},
+		{UserID: randomID("user"), Username: "r1", IsActive: true},
+	}
+	createTeam(t, baseURL, teamName, members)
+	prID := randomID("pr")
+	doRequest(t, http.MethodPost, baseURL+"/pullRequest/create", map[string]string{
+		"pull_request_id":   prID,
+		"pull_request_name": "feature/concurrent-merge",
+		"author_id":         members[0].UserID,
+	}, http.StatusCreated)
+
+	var wg sync.WaitGroup
+	results := make(chan mergeResponse, 8)
+	errs := make(chan string, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, body := postJSON(baseURL+"/pullRequest/merge", map[string]string{"pull_request_id": prID})
+			if status != http.StatusOK {
+				errs <- fmt.Sprintf("status %d: %s", status, body)
+				return
+			}
+			var resp mergeResponse
+			if err := json.Unmarshal(body, &resp); err != nil {
+				errs <- err.Error()
+				return
+			}
+			results <- resp
+		}()
+	}
+	wg.Wait()
+	close(results)
+	close(errs)
+
+	for e := range errs {
+		t.Fatalf("concurrent merge failed: %s", e)
+	}
+	var mergedAt *time.Time
+	for resp := range results {
+		if resp.PR.Status != "MERGED" || resp.PR.MergedAt == nil {
+			t.Fatalf("unexpected merge response: %+v", resp)
+		}
+		if mergedAt != nil && !mergedAt.Equal(*resp.PR.MergedAt) {
+			t.Fatalf("mergedAt changed between concurrent merges: %v vs %v", *mergedAt, *resp.PR.MergedAt)
+		}
+		mergedAt = resp.PR.MergedAt
+	}
+}
+
+func TestConcurrentReassignAndDeactivate(t *testing.T) {
+	baseURL := requireBaseURL(t)
+	teamName := fmt.Sprintf("concurrent-deact-%s", randomID("team"))
+	members := []teamMember{{UserID: randomID("user"), Username: "author", IsActive: true}}
+	for i := 0; i < 8; i++ {
+		members = append(members, teamMember{UserID: randomID("user"), Username: fmt.Sprintf("r%d", i), IsActive: true})
+	}
+	createTeam(t, baseURL, teamName, members)
+	author := members[0].UserID
+
+	var prs []createPRResponse
+	for i := 0; i < 3; i++ {
+		body := doRequest(t, http.MethodPost, baseURL+"/pullRequest/create", map[string]string{
+			"pull_request_id":   randomID("pr"),
+			"pull_request_name": "feature/concurrent-deact",
+			"author_id":         author,
+		}, http.StatusCreated)
+		var pr createPRResponse
+		decodeJSON(t, body, &pr)
+		prs = append(prs, pr)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan string, 16)
+	created := make(chan string, 3)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		disable := []string{members[1].UserID, members[2].UserID, members[3].UserID}
+		status, body := postJSON(baseURL+"/team/deactivate", map[string]any{"team_name": teamName, "user_ids": disable})
+		if status != http.StatusOK {
+			errs <- fmt.Sprintf("deactivate: status %d: %s", status, body)
+		}
+	}()
+	for _, pr := range prs {
+		for _, reviewer := range pr.PR.Reviewers {
+			wg.Add(1)
+			go func(prID, oldUserID string) {
+				defer wg.Done()
+				status, body := postJSON(baseURL+"/pullRequest/reassign", map[string]string{
+					"pull_request_id": prID,
+					"old_user_id":     oldUserID,
+				})
+				if status != http.StatusOK && status != http.StatusConflict {
+					errs <- fmt.Sprintf("reassign: status %d: %s", status, body)
+				}
+			}(pr.PR.ID, reviewer)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			prID := randomID("pr")
+			status, body := postJSON(baseURL+"/pullRequest/create", map[string]string{
+				"pull_request_id":   prID,
+				"pull_request_name": "feature/concurrent-deact-new",
+				"author_id":         author,
+			})
+			if status != http.StatusCreated {
+				errs <- fmt.Sprintf("create: status %d: %s", status, body)
+				return
+			}
+			created <- prID
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	close(created)
+	for e := range errs {
+		t.Fatal(e)
+	}
+
+	inactive := make(map[string]bool)
+	for _, m := range getTeam(t, baseURL, teamName).Members {
+		if !m.IsActive {
+			inactive[m.UserID] = true
+		}
+	}
+
+	prIDs := make([]string, 0, len(prs)+3)
+	for _, pr := range prs {
+		prIDs = append(prIDs, pr.PR.ID)
+	}
+	for id := range created {
+		prIDs = append(prIDs, id)
+	}
+	for _, prID := range prIDs {
+		body := doRequest(t, http.MethodPost, baseURL+"/pullRequest/merge", map[string]string{
+			"pull_request_id": prID,
+		}, http.StatusOK)
+		var merged createPRResponse
+		decodeJSON(t, body, &merged)
+		assertReviewersValid(t, merged.PR.Reviewers, author)
+		for _, reviewer := range merged.PR.Reviewers {
+			if inactive[reviewer] {
+				t.Fatalf("pr %s kept deactivated reviewer %s: %v", prID, reviewer, merged.PR.Reviewers)
+			}
+		}
+	}
+}
+
 func TestPullRequestReassignMerged(t *testing.T) {
 	baseURL := requireBaseURL(t)
 	teamName := fmt.Sprintf("reassign-merged-%s", randomID("team"))
@@ -491,6 +957,206 @@ func TestStatsSummary(t *testing.T) {
 	}
 }
 
+func TestStatsSummaryFiltered(t *testing.T) {
+	baseURL := requireBaseURL(t)
+	teamName := fmt.Sprintf("stats-%s", randomID("team"))
+	members := []teamMember{
+		{UserID: randomID("user"), Username: "author", IsActive: true},
+		{UserID: randomID("user"), Username: "r1", IsActive: true},
+		{UserID: randomID("user"), Username: "r2", IsActive: false},
+	}
+	createTeam(t, baseURL, teamName, members)
+	payload := map[string]string{
+		"pull_request_id":   randomID("pr"),
+		"pull_request_name": "feature/stats",
+		"author_id":         members[0].UserID,
+	}
+	doRequest(t, http.MethodPost, baseURL+"/pullRequest/create", payload, http.StatusCreated)
+
+	body := doRequest(t, http.MethodGet, fmt.Sprintf("%s/stats/summary?team_name=%s&stale_after_days=1", baseURL, teamName), nil, http.StatusOK)
+	var stats statsResponse
+	decodeJSON(t, body, &stats)
+	if stats.Stats.PRStatus.Total != 1 || stats.Stats.PRStatus.Open != 1 {
+		t.Fatalf("unexpected filtered pr status: %+v", stats.Stats.PRStatus)
+	}
+	if len(stats.Stats.TeamMembers) != 1 || stats.Stats.TeamMembers[0].Active != 2 || stats.Stats.TeamMembers[0].Inactive != 1 {
+		t.Fatalf("unexpected filtered team members: %+v", stats.Stats.TeamMembers)
+	}
+	if stats.Stats.PRLifetime.OpenOlderThanThreshold != 0 || stats.Stats.PRLifetime.OpenOlderThan7Days != 0 {
+		t.Fatalf("fresh PR counted as stale: %+v", stats.Stats.PRLifetime)
+	}
+	histogram := stats.Stats.PRLifetime.OpenAgeHistogram
+	if len(histogram) == 0 || histogram[0].Count != 1 {
+		t.Fatalf("expected fresh PR in first age bucket: %+v", histogram)
+	}
+	if len(stats.Stats.PRLifetime.ByTeam) != 1 || stats.Stats.PRLifetime.ByTeam[0].TeamName != teamName {
+		t.Fatalf("unexpected lifetime team breakdown: %+v", stats.Stats.PRLifetime.ByTeam)
+	}
+
+	future := time.Now().Add(24 * time.Hour).UTC().Format("2006-01-02")
+	body = doRequest(t, http.MethodGet, fmt.Sprintf("%s/stats/summary?team_name=%s&from=%s", baseURL, teamName, future), nil, http.StatusOK)
+	decodeJSON(t, body, &stats)
+	if stats.Stats.PRStatus.Total != 0 {
+		t.Fatalf("expected no PRs in future window, got %d", stats.Stats.PRStatus.Total)
+	}
+
+	doRequest(t, http.MethodGet, baseURL+"/stats/summary?from=2025-02-01&to=2025-01-01", nil, http.StatusBadRequest)
+}
+
+type timeSeriesResponse struct {
+	TimeSeries struct {
+		Interval string `json:"interval"`
+		ByTeam   bool   `json:"by_team"`
+		Points   []struct {
+			Bucket      time.Time `json:"bucket"`
+			TeamName    string    `json:"team_name"`
+			Created     int       `json:"prs_created"`
+			Merged      int       `json:"prs_merged"`
+			Assignments int       `json:"assignments"`
+		} `json:"points"`
+	} `json:"timeseries"`
+}
+
+func TestStatsTimeSeries(t *testing.T) {
+	baseURL := requireBaseURL(t)
+	teamName := fmt.Sprintf("series-%s", randomID("team"))
+	members := []teamMember{
+		{UserID: randomID("user"), Username: "author", IsActive: true},
+		{UserID: randomID("user"), Username: "r1", IsActive: true},
+	}
+	createTeam(t, baseURL, teamName, members)
+	prID := randomID("pr")
+	doRequest(t, http.MethodPost, baseURL+"/pullRequest/create", map[string]string{
+		"pull_request_id":   prID,
+		"pull_request_name": "feature/series",
+		"author_id":         members[0].UserID,
+	}, http.StatusCreated)
+	doRequest(t, http.MethodPost, baseURL+"/pullRequest/merge", map[string]string{"pull_request_id": prID}, http.StatusOK)
+
+	body := doRequest(t, http.MethodGet, fmt.Sprintf("%s/stats/timeseries?interval=day&by_team=true&team_name=%s", baseURL, teamName), nil, http.StatusOK)
+	var series timeSeriesResponse
+	decodeJSON(t, body, &series)
+	if series.TimeSeries.Interval != "day" || len(series.TimeSeries.Points) == 0 {
+		t.Fatalf("unexpected series: %+v", series.TimeSeries)
+	}
+	var created, merged, assigned int
+	for _, p := range series.TimeSeries.Points {
+		if p.TeamName != teamName {
+			t.Fatalf("unexpected team in series: %s", p.TeamName)
+		}
+		created += p.Created
+		merged += p.Merged
+		assigned += p.Assignments
+	}
+	if created != 1 || merged != 1 || assigned != 1 {
+		t.Fatalf("unexpected totals: created=%d merged=%d assigned=%d", created, merged, assigned)
+	}
+
+	doRequest(t, http.MethodGet, baseURL+"/stats/timeseries?interval=hour", nil, http.StatusBadRequest)
+}
+
+type userStatsResponse struct {
+	Stats struct {
+		UserID              string `json:"user_id"`
+		AssignmentsReceived int    `json:"assignments_received"`
+		ReassignedAway      int    `json:"reassigned_away"`
+		ReassignReasons     []struct {
+			Reason string `json:"reason"`
+			Count  int    `json:"count"`
+		} `json:"reassign_reasons"`
+		OpenLoad int `json:"open_load"`
+		Team     struct {
+			TeamName      string `json:"team_name"`
+			ActiveMembers int    `json:"active_members"`
+		} `json:"team_comparison"`
+	} `json:"stats"`
+}
+
+func TestStatsUser(t *testing.T) {
+	baseURL := requireBaseURL(t)
+	teamName := fmt.Sprintf("user-stats-%s", randomID("team"))
+	members := []teamMember{
+		{UserID: randomID("user"), Username: "author", IsActive: true},
+		{UserID: randomID("user"), Username: "r1", IsActive: true},
+		{UserID: randomID("user"), Username: "r2", IsActive: true},
+		{UserID: randomID("user"), Username: "r3", IsActive: true},
+	}
+	createTeam(t, baseURL, teamName, members)
+	body := doRequest(t, http.MethodPost, baseURL+"/pullRequest/create", map[string]string{
+		"pull_request_id":   randomID("pr"),
+		"pull_request_name": "feature/user-stats",
+		"author_id":         members[0].UserID,
+	}, http.StatusCreated)
+	var pr createPRResponse
+	decodeJSON(t, body, &pr)
+	if len(pr.PR.Reviewers) < 2 {
+		t.Skip("not enough reviewers assigned")
+	}
+	old := pr.PR.Reviewers[0]
+	doRequest(t, http.MethodPost, baseURL+"/pullRequest/reassign", map[string]string{
+		"pull_request_id": pr.PR.ID,
+		"old_user_id":     old,
+	}, http.StatusOK)
+
+	body = doRequest(t, http.MethodGet, fmt.Sprintf("%s/stats/user?user_id=%s", baseURL, old), nil, http.StatusOK)
+	var stats userStatsResponse
+	decodeJSON(t, body, &stats)
+	if stats.Stats.AssignmentsReceived != 1 || stats.Stats.ReassignedAway != 1 || stats.Stats.OpenLoad != 0 {
+		t.Fatalf("unexpected user stats: %+v", stats.Stats)
+	}
+	if len(stats.Stats.ReassignReasons) != 1 || stats.Stats.ReassignReasons[0].Reason != "MANUAL" {
+		t.Fatalf("unexpected reassign reasons: %+v", stats.Stats.ReassignReasons)
+	}
+	if stats.Stats.Team.TeamName != teamName || stats.Stats.Team.ActiveMembers != len(members) {
+		t.Fatalf("unexpected team comparison: %+v", stats.Stats.Team)
+	}
+
+	doRequest(t, http.MethodGet, baseURL+"/stats/user?user_id=unknown-user", nil, http.StatusNotFound)
+	doRequest(t, http.MethodGet, baseURL+"/stats/user", nil, http.StatusBadRequest)
+}
+
+type fairnessResponse struct {
+	Fairness struct {
+		Teams []struct {
+			TeamName         string   `json:"team_name"`
+			ActiveMembers    int      `json:"active_members"`
+			TotalAssignments int      `json:"total_assignments"`
+			MaxMinRatio      *float64 `json:"max_min_ratio"`
+			Gini             float64  `json:"gini"`
+		} `json:"teams"`
+	} `json:"fairness"`
+}
+
+func TestStatsFairness(t *testing.T) {
+	baseURL := requireBaseURL(t)
+	teamName := fmt.Sprintf("fair-%s", randomID("team"))
+	members := []teamMember{
+		{UserID: randomID("user"), Username: "author", IsActive: true},
+		{UserID: randomID("user"), Username: "r1", IsActive: true},
+		{UserID: randomID("user"), Username: "r2", IsActive: true},
+	}
+	createTeam(t, baseURL, teamName, members)
+	doRequest(t, http.MethodPost, baseURL+"/pullRequest/create", map[string]string{
+		"pull_request_id":   randomID("pr"),
+		"pull_request_name": "feature/fairness",
+		"author_id":         members[0].UserID,
+	}, http.StatusCreated)
+
+	body := doRequest(t, http.MethodGet, fmt.Sprintf("%s/stats/fairness?team_name=%s", baseURL, teamName), nil, http.StatusOK)
+	var report fairnessResponse
+	decodeJSON(t, body, &report)
+	if len(report.Fairness.Teams) != 1 {
+		t.Fatalf("expected one team, got %+v", report.Fairness.Teams)
+	}
+	team := report.Fairness.Teams[0]
+	if team.ActiveMembers != len(members) || team.TotalAssignments != 2 {
+		t.Fatalf("unexpected fairness totals: %+v", team)
+	}
+	if team.MaxMinRatio != nil || team.Gini <= 0 {
+		t.Fatalf("author without assignments should make load uneven: %+v", team)
+	}
+}
+
 func TestUserGetReviewScenarios(t *testing.T) {
 	baseURL := requireBaseURL(t)
 	teamName := fmt.Sprintf("reviews-%s", randomID("team"))
@@ -556,6 +1222,12 @@ func randomID(prefix string) string {
 }
 
 func doRequest(t *testing.T, method, url string, payload any, expected int) []byte {
+	t.Helper()
+	body, _ := doRequestWithHeaders(t, method, url, payload, nil, expected)
+	return body
+}
+
+func doRequestWithHeaders(t *testing.T, method, url string, payload any, headers map[string]string, expected int) ([]byte, http.Header) {
 	t.Helper()
 	var body io.Reader
 	if payload != nil {
@@ -573,6 +1245,9 @@ func doRequest(t *testing.T, method, url string, payload any, expected int) []by
 	if payload != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
@@ -587,7 +1262,49 @@ func doRequest(t *testing.T, method, url string, payload any, expected int) []by
 	if resp.StatusCode != expected {
 		t.Fatalf("unexpected status %d (want %d): %s", resp.StatusCode, expected, string(respBody))
 	}
-	return respBody
+	return respBody, resp.Header
+}
+
+func doIdempotentRequest(t *testing.T, url, key string, payload any, expected int) ([]byte, bool) {
+	t.Helper()
+	body, header := doRequestWithHeaders(t, http.MethodPost, url, payload, map[string]string{"Idempotency-Key": key}, expected)
+	return body, header.Get("Idempotent-Replayed") == "true"
+}
+
+// postJSON is safe to call from goroutines: it reports failures through the
+// returned status instead of t.Fatal.
+func postJSON(url string, payload any) (int, []byte) {
+	buf, err := json.Marshal(payload)
+	if err != nil {
+		return 0, []byte(err.Error())
+	}
+	resp, err := httpClient.Post(url, "application/json", bytes.NewReader(buf))
+	if err != nil {
+		return 0, []byte(err.Error())
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, []byte(err.Error())
+	}
+	return resp.StatusCode, body
+}
+
+func assertReviewersValid(t *testing.T, reviewers []string, authorID string) {
+	t.Helper()
+	if len(reviewers) > 2 {
+		t.Fatalf("too many reviewers: %v", reviewers)
+	}
+	seen := make(map[string]bool, len(reviewers))
+	for _, r := range reviewers {
+		if r == authorID {
+			t.Fatalf("author assigned as reviewer: %v", reviewers)
+		}
+		if seen[r] {
+			t.Fatalf("duplicate reviewer %s: %v", r, reviewers)
+		}
+		seen[r] = true
+	}
 }
 
 func decodeJSON[T any](t *testing.T, data []byte, out *T) {